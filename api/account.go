@@ -0,0 +1,84 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AccountDeletionMode tells how data of a deleted account is erased.
+type AccountDeletionMode string
+
+const (
+	// All rows of the account are deleted.
+	DeleteAccountDeletionMode AccountDeletionMode = "delete"
+	// Entries and their changes are kept for aggregate statistics but
+	// pseudonymized: the account UUID is replaced by a random one stored
+	// nowhere, entry UUIDs are regenerated and comments and source devices
+	// cleared. Timings, sleep quality and stages of the entries are kept and
+	// remain linkable to each other through the new UUID. No key is destroyed,
+	// so this is not crypto-shredding. Other rows are deleted.
+	PseudonymizeAccountDeletionMode AccountDeletionMode = "pseudonymize"
+)
+
+var AccountDeletionModes = []AccountDeletionMode{DeleteAccountDeletionMode, PseudonymizeAccountDeletionMode}
+
+// AccountDeletionReceiptDto proves that data of an account was erased. Rows
+// holds the number of rows deleted or pseudonymized in each table. Signature is
+// "sha256=" followed by hex HMAC-SHA256 of SignedContent keyed by the
+// deletion receipt secret of the server; it is empty when none is set.
+type AccountDeletionReceiptDto struct {
+	Id          string              `json:"id"`
+	AccountUuid string              `json:"account_uuid"`
+	TenantId    string              `json:"tenant_id"`
+	Mode        AccountDeletionMode `json:"mode"`
+	RequestedBy string              `json:"requested_by"`
+	DeletedAt   time.Time           `json:"deleted_at"`
+	Rows        map[string]int64    `json:"rows"`
+	Signature   string              `json:"signature,omitempty"`
+}
+
+// SignedContent returns the content the signature of the receipt is computed
+// over: a "name: value" line for every field but the signature, with rows
+// sorted by table.
+func (dto *AccountDeletionReceiptDto) SignedContent() []byte {
+	var content strings.Builder
+	fmt.Fprintf(&content, "id: %s\n", dto.Id)
+	fmt.Fprintf(&content, "account_uuid: %s\n", dto.AccountUuid)
+	fmt.Fprintf(&content, "tenant_id: %s\n", dto.TenantId)
+	fmt.Fprintf(&content, "mode: %s\n", dto.Mode)
+	fmt.Fprintf(&content, "requested_by: %s\n", dto.RequestedBy)
+	fmt.Fprintf(&content, "deleted_at: %s\n", dto.DeletedAt.UTC().Format(time.RFC3339Nano))
+	tables := make([]string, 0, len(dto.Rows))
+	for table := range dto.Rows {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		fmt.Fprintf(&content, "rows.%s: %d\n", table, dto.Rows[table])
+	}
+	return []byte(content.String())
+}
+
+// AccountExportDto holds everything stored about an account within a tenant.
+// Changes are given without the entry; grants include those the account
+// gave and those given to it.
+type AccountExportDto struct {
+	AccountUuid    string               `json:"account_uuid"`
+	TenantId       string               `json:"tenant_id"`
+	ExportedAt     time.Time            `json:"exported_at"`
+	Entries        []SleepDiaryEntryDto `json:"entries"`
+	SleepStages    []EntrySleepStageDto `json:"sleep_stages"`
+	Drafts         []SleepDiaryDraftDto `json:"drafts"`
+	Changes        []EntryChangeDto     `json:"changes"`
+	Grants         []GrantDto           `json:"grants"`
+	ReceivedGrants []GrantDto           `json:"received_grants"`
+	ApiKeys        []ApiKeyDto          `json:"api_keys"`
+}
+
+// EntrySleepStageDto is a sleep stage segment of the entry with EntryId.
+type EntrySleepStageDto struct {
+	EntryId int64 `json:"entry_id"`
+	SleepStageSegmentDto
+}
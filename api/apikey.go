@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+const MAX_API_KEY_NAME_LENGTH = 100
+
+// ApiKeyScopes are the scopes an API key may be given.
+var ApiKeyScopes = []string{SCOPE_READ_ENTRIES, SCOPE_WRITE_ENTRIES, SCOPE_EXPORT, SCOPE_ADMIN}
+
+// CreateApiKeyDto creates an API key for a server-to-server integration. A key
+// with AccountUuid only accesses entries of that account; one without it
+// accesses entries of all accounts within its scopes.
+type CreateApiKeyDto struct {
+	Name        string     `json:"name"`
+	Scopes      []string   `json:"scopes"`
+	AccountUuid string     `json:"account_uuid,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+func (dto *CreateApiKeyDto) Validate() []error {
+	errors := []error{}
+	if dto.Name == "" {
+		errors = append(errors, NewFieldError("/name", RULE_REQUIRED, nil, "name is required"))
+	}
+	if len(dto.Name) > MAX_API_KEY_NAME_LENGTH {
+		errors = append(errors, NewFieldError("/name", RULE_MAX_LENGTH, map[string]any{"maxLength": MAX_API_KEY_NAME_LENGTH}, "name should not exceed %d characters", MAX_API_KEY_NAME_LENGTH))
+	}
+	if len(dto.Scopes) == 0 {
+		errors = append(errors, NewFieldError("/scopes", RULE_REQUIRED, nil, "scopes is required"))
+	}
+	for i, scope := range dto.Scopes {
+		if !slices.Contains(ApiKeyScopes, scope) {
+			errors = append(errors, NewFieldError(fmt.Sprintf("/scopes/%d", i), RULE_ENUM, map[string]any{"enum": ApiKeyScopes}, "unknown scope '%s'", scope))
+		}
+	}
+	if dto.AccountUuid != "" {
+		if err := ValidateAccountUuid(dto.AccountUuid); err != nil {
+			errors = append(errors, err)
+		}
+		// Admins access all accounts, which a restricted key must not.
+		if i := slices.Index(dto.Scopes, SCOPE_ADMIN); i >= 0 {
+			scopes := []string{SCOPE_READ_ENTRIES, SCOPE_WRITE_ENTRIES, SCOPE_EXPORT}
+			errors = append(errors, NewFieldError(fmt.Sprintf("/scopes/%d", i), RULE_ENUM, map[string]any{"enum": scopes}, "scope '%s' is not allowed for keys restricted to an account", SCOPE_ADMIN))
+		}
+	}
+	return errors
+}
+
+// ApiKeyDto describes an API key. Only a hash of the key is stored, so the key
+// itself is returned just once, when it is created or rotated.
+type ApiKeyDto struct {
+	Id          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Scopes      []string   `json:"scopes"`
+	AccountUuid string     `json:"account_uuid,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RotatedAt   *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	Key         string     `json:"key,omitempty"`
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditAction is the operation an audit event records.
+type AuditAction string
+
+const (
+	ReadEntryAuditAction          AuditAction = "entry.read"
+	ListEntriesAuditAction        AuditAction = "entry.list"
+	CreateEntryAuditAction        AuditAction = "entry.create"
+	UpdateEntryAuditAction        AuditAction = "entry.update"
+	DeleteEntryAuditAction        AuditAction = "entry.delete"
+	ReadHypnogramAuditAction      AuditAction = "hypnogram.read"
+	ReplaceHypnogramAuditAction   AuditAction = "hypnogram.replace"
+	ImportDraftsAuditAction       AuditAction = "draft.import"
+	ListDraftsAuditAction         AuditAction = "draft.list"
+	ConfirmDraftAuditAction       AuditAction = "draft.confirm"
+	DeleteDraftAuditAction        AuditAction = "draft.delete"
+	ListChangesAuditAction        AuditAction = "change.list"
+	PullSyncAuditAction           AuditAction = "sync.pull"
+	CreateGrantAuditAction        AuditAction = "grant.create"
+	ListGrantsAuditAction         AuditAction = "grant.list"
+	ListReceivedGrantsAuditAction AuditAction = "grant.list_received"
+	RevokeGrantAuditAction        AuditAction = "grant.revoke"
+	ExportAccountAuditAction      AuditAction = "account.export"
+	DeleteAccountAuditAction      AuditAction = "account.delete"
+	ReadAuditLogAuditAction       AuditAction = "audit_log.read"
+	ExportResearchAuditAction     AuditAction = "research.export"
+)
+
+var AuditActions = []AuditAction{
+	ReadEntryAuditAction, ListEntriesAuditAction, CreateEntryAuditAction, UpdateEntryAuditAction, DeleteEntryAuditAction,
+	ReadHypnogramAuditAction, ReplaceHypnogramAuditAction,
+	ImportDraftsAuditAction, ListDraftsAuditAction, ConfirmDraftAuditAction, DeleteDraftAuditAction,
+	ListChangesAuditAction, PullSyncAuditAction,
+	CreateGrantAuditAction, ListGrantsAuditAction, ListReceivedGrantsAuditAction, RevokeGrantAuditAction,
+	ExportAccountAuditAction, DeleteAccountAuditAction,
+	ReadAuditLogAuditAction, ExportResearchAuditAction,
+}
+
+// AuditEventDto records an operation on data of accounts: who performed it,
+// on which accounts and entries, and in which request. Hash is hex SHA-256
+// of the event chained to the previous event of the tenant, whose hash is
+// PrevHash; it is empty for the first event.
+type AuditEventDto struct {
+	Id           int64       `json:"id"`
+	Action       AuditAction `json:"action"`
+	Subject      string      `json:"subject"`
+	AccountUuids []string    `json:"account_uuids"`
+	EntryIds     []int64     `json:"entry_ids"`
+	TraceId      string      `json:"trace_id"`
+	CreatedAt    time.Time   `json:"created_at"`
+	PrevHash     string      `json:"prev_hash,omitempty"`
+	Hash         string      `json:"hash"`
+}
+
+// AuditLogFilterDto selects audit events; all conditions given must hold.
+type AuditLogFilterDto struct {
+	Subject     string       `json:"subject,omitempty"`
+	AccountUuid string       `json:"account_uuid,omitempty"`
+	EntryId     *int64       `json:"entry_id,omitempty"`
+	Action      *AuditAction `json:"action,omitempty"`
+	TraceId     string       `json:"trace_id,omitempty"`
+	FromDate    *time.Time   `json:"from_date,omitempty"`
+	ToDate      *time.Time   `json:"to_date,omitempty"`
+	PageSize    int64        `json:"page_size"`
+	PageNumber  int64        `json:"page_number"`
+}
+
+func (dto *AuditLogFilterDto) Validate() []error {
+	errors := validateTimeOrder(
+		labeledTime{dto.FromDate, "from_date"},
+		labeledTime{dto.ToDate, "to_date"},
+	)
+	if dto.AccountUuid != "" {
+		if _, err := uuid.Parse(dto.AccountUuid); err != nil {
+			errors = append(errors, NewFieldError("/account_uuid", RULE_FORMAT, map[string]any{"format": "uuid"}, "invalid UUID '%s'", dto.AccountUuid))
+		}
+	}
+	if dto.Action != nil && !slices.Contains(AuditActions, *dto.Action) {
+		errors = append(errors, NewFieldError("/action", RULE_ENUM, map[string]any{"enum": AuditActions}, "unknown action '%s'", *dto.Action))
+	}
+	if dto.PageSize < 1 {
+		errors = append(errors, NewFieldError("/page_size", RULE_MINIMUM, map[string]any{"minimum": 1}, "page_size should be greater than 0"))
+	}
+	if dto.PageSize > MAX_PAGE_SIZE {
+		errors = append(errors, NewFieldError("/page_size", RULE_MAXIMUM, map[string]any{"maximum": MAX_PAGE_SIZE}, "page_size should not exceed %d", MAX_PAGE_SIZE))
+	}
+	if dto.PageNumber < 1 {
+		errors = append(errors, NewFieldError("/page_number", RULE_MINIMUM, map[string]any{"minimum": 1}, "page_number should be greater than 0"))
+	}
+	return errors
+}
+
+// AuditLogVerificationDto is the outcome of checking the hash chain of the
+// audit log of a tenant. When the chain is broken, BrokenAtId is the ID of
+// the first event whose hash or link to the previous event does not match.
+// PendingCount is the number of events recorded but not chained yet, which
+// are not verified.
+type AuditLogVerificationDto struct {
+	Valid        bool   `json:"valid"`
+	EventsCount  int64  `json:"events_count"`
+	PendingCount int64  `json:"pending_count"`
+	BrokenAtId   *int64 `json:"broken_at_id,omitempty"`
+}
@@ -0,0 +1,25 @@
+package api
+
+import "time"
+
+type ChangeType string
+
+const (
+	CHANGE_CREATED ChangeType = "created"
+	CHANGE_UPDATED ChangeType = "updated"
+	CHANGE_DELETED ChangeType = "deleted"
+)
+
+// EntryChangeDto is an event of the change feed. Id grows with every change
+// and is used to resume the feed. Entry holds the current state of the entry
+// and is omitted once the entry is deleted.
+type EntryChangeDto struct {
+	Id          int64               `json:"id"`
+	Type        ChangeType          `json:"type"`
+	EntryId     int64               `json:"entry_id"`
+	EntryUuid   string              `json:"entry_uuid,omitempty"`
+	AccountUuid string              `json:"account_uuid"`
+	Version     int64               `json:"version"`
+	ChangedAt   time.Time           `json:"changed_at"`
+	Entry       *SleepDiaryEntryDto `json:"entry,omitempty"`
+}
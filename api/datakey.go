@@ -0,0 +1,11 @@
+package api
+
+// DataKeyRotationDto is the outcome of rotating the data key of a tenant:
+// ID of the new key and numbers of comments re-encrypted with it and of
+// retired keys deleted.
+type DataKeyRotationDto struct {
+	KeyId              int64 `json:"key_id"`
+	ReencryptedEntries int64 `json:"reencrypted_entries"`
+	ReencryptedDrafts  int64 `json:"reencrypted_drafts"`
+	DeletedKeys        int64 `json:"deleted_keys"`
+}
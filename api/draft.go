@@ -0,0 +1,84 @@
+package api
+
+import (
+	"fmt"
+)
+
+type ImportSource string
+
+const (
+	AppleHealthImportSource ImportSource = "apple_health"
+	FitbitImportSource      ImportSource = "fitbit"
+)
+
+// SleepDiaryDraftDto is an entry proposed by an import that still has to be
+// confirmed by the user. Sleep quality is zero when it is unknown.
+type SleepDiaryDraftDto struct {
+	Id          int64        `json:"id"`
+	AccountUuid string       `json:"account_uuid"`
+	Source      ImportSource `json:"source"`
+	SleepDiaryEntryDataDto
+}
+
+type ConfirmSleepDiaryDraftDto struct {
+	SleepQuality SleepQuality `json:"sleep_quality"`
+	Comments     *string      `json:"comments,omitempty"`
+}
+
+func (dto *ConfirmSleepDiaryDraftDto) Validate() []error {
+	errors := validateSleepQuality(dto.SleepQuality)
+	errors = append(errors, validateComments(dto.Comments)...)
+	return errors
+}
+
+type ImportDto struct {
+	AccountUuid string                     `json:"account_uuid"`
+	Source      ImportSource               `json:"source"`
+	Entries     []CreateSleepDiaryEntryDto `json:"entries"`
+}
+
+func (dto *ImportDto) Validate() []error {
+	errors := []error{}
+	if err := ValidateAccountUuid(dto.AccountUuid); err != nil {
+		errors = append(errors, err)
+	}
+	if dto.Source == "" {
+		errors = append(errors, NewFieldError("/source", RULE_REQUIRED, nil, "source is required"))
+	}
+	for i, entry := range dto.Entries {
+		entryErrors := []error{}
+		if entry.AccountUuid != dto.AccountUuid {
+			entryErrors = append(entryErrors, NewFieldError("/account_uuid", RULE_MATCH, map[string]any{"other": "/account_uuid"}, "account_uuid does not match import account"))
+		}
+		entryErrors = append(entryErrors, entry.ValidateDraft()...)
+		errors = append(errors, nestErrors(fmt.Sprintf("/entries/%d", i), fmt.Sprintf("entries[%d]", i), entryErrors)...)
+	}
+	return errors
+}
+
+type ImportRecordStatus string
+
+const (
+	// Record was stored as a new draft.
+	CreatedImportRecordStatus ImportRecordStatus = "created"
+	// Record overlapped an existing draft and was merged into it.
+	MergedImportRecordStatus ImportRecordStatus = "merged"
+	// Record overlapped an existing entry and was not imported.
+	SkippedImportRecordStatus ImportRecordStatus = "skipped"
+)
+
+type ImportRecordDto struct {
+	Index   int                `json:"index"`
+	Status  ImportRecordStatus `json:"status"`
+	DraftId *int64             `json:"draft_id,omitempty"`
+	EntryId *int64             `json:"entry_id,omitempty"`
+}
+
+type ImportResultDto struct {
+	Source       ImportSource         `json:"source"`
+	CreatedCount int                  `json:"created_count"`
+	MergedCount  int                  `json:"merged_count"`
+	SkippedCount int                  `json:"skipped_count"`
+	Drafts       []SleepDiaryDraftDto `json:"drafts"`
+	Records      []ImportRecordDto    `json:"records"`
+}
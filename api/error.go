@@ -1,5 +1,7 @@
 package api
 
+import "errors"
+
 type ErrorCode string
 
 const (
@@ -7,12 +9,22 @@ const (
 	ERR_INVALID   ErrorCode = "ERR_INVALID"
 	ERR_NOT_FOUND ErrorCode = "ERR_NOT_FOUND"
 	ERR_CONFLICT  ErrorCode = "ERR_CONFLICT"
+	// The request carries no valid credentials.
+	ERR_UNAUTHORIZED ErrorCode = "ERR_UNAUTHORIZED"
+	// The caller is not allowed to perform the request.
+	ERR_FORBIDDEN ErrorCode = "ERR_FORBIDDEN"
+	// The caller sent too many requests; it may retry later.
+	ERR_RATE_LIMITED ErrorCode = "ERR_RATE_LIMITED"
 )
 
+// ErrorDto is the body of every error response. Validation errors are listed
+// both in Errors and, as plain messages, in Details; Details is kept for
+// clients that predate Errors.
 type ErrorDto struct {
-	Message string    `json:"message"`
-	Code    ErrorCode `json:"code"`
-	Details []string  `json:"details,omitempty"`
+	Message string          `json:"message"`
+	Code    ErrorCode       `json:"code"`
+	Details []string        `json:"details,omitempty"`
+	Errors  []FieldErrorDto `json:"errors,omitempty"`
 }
 
 type Error interface {
@@ -37,12 +49,17 @@ func NewError(message string, code ErrorCode) ErrorDto {
 
 func NewValidationError(message string, details []error) ErrorDto {
 	stringDetails := make([]string, len(details))
+	fieldErrors := make([]FieldErrorDto, len(details))
 	for i, err := range details {
 		stringDetails[i] = err.Error()
+		if !errors.As(err, &fieldErrors[i]) {
+			fieldErrors[i] = FieldErrorDto{Message: err.Error()}
+		}
 	}
 	return ErrorDto{
 		Message: message,
 		Code:    ERR_INVALID,
 		Details: stringDetails,
+		Errors:  fieldErrors,
 	}
 }
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"slices"
+	"time"
+)
+
+const MAX_GRANTEE_LENGTH = 255
+
+type GrantAccess string
+
+const (
+	ReadGrantAccess      GrantAccess = "read"
+	ReadWriteGrantAccess GrantAccess = "read_write"
+)
+
+// CreateGrantDto gives another principal (a clinician, coach, researcher
+// etc.) access to the diary of an account from ValidFrom (now by default)
+// until ValidTo. Grantee is the subject of the principal.
+type CreateGrantDto struct {
+	AccountUuid string      `json:"account_uuid"`
+	Grantee     string      `json:"grantee"`
+	Access      GrantAccess `json:"access"`
+	ValidFrom   *time.Time  `json:"valid_from,omitempty"`
+	ValidTo     time.Time   `json:"valid_to"`
+}
+
+func (dto *CreateGrantDto) Validate() []error {
+	errors := []error{}
+	if err := ValidateAccountUuid(dto.AccountUuid); err != nil {
+		errors = append(errors, err)
+	}
+	if dto.Grantee == "" {
+		errors = append(errors, NewFieldError("/grantee", RULE_REQUIRED, nil, "grantee is required"))
+	}
+	if len(dto.Grantee) > MAX_GRANTEE_LENGTH {
+		errors = append(errors, NewFieldError("/grantee", RULE_MAX_LENGTH, map[string]any{"maxLength": MAX_GRANTEE_LENGTH}, "grantee should not exceed %d characters", MAX_GRANTEE_LENGTH))
+	}
+	accesses := []GrantAccess{ReadGrantAccess, ReadWriteGrantAccess}
+	if !slices.Contains(accesses, dto.Access) {
+		errors = append(errors, NewFieldError("/access", RULE_ENUM, map[string]any{"enum": accesses}, "unknown access '%s'", dto.Access))
+	}
+	if dto.ValidTo.IsZero() {
+		errors = append(errors, NewFieldError("/valid_to", RULE_REQUIRED, nil, "valid_to is required"))
+	} else {
+		errors = append(errors, validateTimeOrder(
+			labeledTime{dto.ValidFrom, "valid_from"},
+			labeledTime{&dto.ValidTo, "valid_to"},
+		)...)
+	}
+	return errors
+}
+
+// GrantDto describes a grant; revoked grants are kept with RevokedAt set.
+type GrantDto struct {
+	Id          int64       `json:"id"`
+	AccountUuid string      `json:"account_uuid"`
+	Grantee     string      `json:"grantee"`
+	Access      GrantAccess `json:"access"`
+	ValidFrom   time.Time   `json:"valid_from"`
+	ValidTo     time.Time   `json:"valid_to"`
+	CreatedAt   time.Time   `json:"created_at"`
+	RevokedAt   *time.Time  `json:"revoked_at,omitempty"`
+}
@@ -0,0 +1,22 @@
+package api
+
+const MAX_GRAPHQL_QUERY_LENGTH = 16384
+
+// GraphQLRequestDto is the body of a GraphQL request; field names follow the
+// GraphQL over HTTP convention rather than the snake case of other DTOs.
+type GraphQLRequestDto struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+func (dto *GraphQLRequestDto) Validate() []error {
+	errors := []error{}
+	if dto.Query == "" {
+		errors = append(errors, NewFieldError("/query", RULE_REQUIRED, nil, "query is required"))
+	}
+	if len(dto.Query) > MAX_GRAPHQL_QUERY_LENGTH {
+		errors = append(errors, NewFieldError("/query", RULE_MAX_LENGTH, map[string]any{"maxLength": MAX_GRAPHQL_QUERY_LENGTH}, "query should not exceed %d characters", MAX_GRAPHQL_QUERY_LENGTH))
+	}
+	return errors
+}
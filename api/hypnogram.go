@@ -0,0 +1,92 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const MAX_HYPNOGRAM_SEGMENTS = 5000
+const MAX_SOURCE_DEVICE_LENGTH = 256
+
+type SleepStage string
+
+const (
+	WakeSleepStage  SleepStage = "wake"
+	LightSleepStage SleepStage = "light"
+	DeepSleepStage  SleepStage = "deep"
+	RemSleepStage   SleepStage = "rem"
+)
+
+func (s SleepStage) IsValid() bool {
+	switch s {
+	case WakeSleepStage, LightSleepStage, DeepSleepStage, RemSleepStage:
+		return true
+	default:
+		return false
+	}
+}
+
+type SleepStageSegmentDto struct {
+	Stage        SleepStage `json:"stage"`
+	StartAt      time.Time  `json:"start_at"`
+	EndAt        time.Time  `json:"end_at"`
+	SourceDevice *string    `json:"source_device,omitempty"`
+}
+
+type UploadHypnogramDto struct {
+	Segments []SleepStageSegmentDto `json:"segments"`
+}
+
+func (dto *UploadHypnogramDto) Validate() []error {
+	errors := []error{}
+	if len(dto.Segments) > MAX_HYPNOGRAM_SEGMENTS {
+		errors = append(errors, NewFieldError("/segments", RULE_MAX_ITEMS, map[string]any{"maxItems": MAX_HYPNOGRAM_SEGMENTS}, "segments should not exceed %d items", MAX_HYPNOGRAM_SEGMENTS))
+	}
+	for i, segment := range dto.Segments {
+		segmentErrors := []error{}
+		if !segment.Stage.IsValid() {
+			segmentErrors = append(segmentErrors, NewFieldError("/stage", RULE_ENUM, map[string]any{"enum": []SleepStage{WakeSleepStage, LightSleepStage, DeepSleepStage, RemSleepStage}}, "stage '%s' is not recognized", segment.Stage))
+		}
+		if !segment.EndAt.After(segment.StartAt) {
+			segmentErrors = append(segmentErrors, NewFieldError("/start_at", RULE_ORDER, map[string]any{"before": "/end_at"}, "start_at should be before end_at"))
+		}
+		if segment.SourceDevice != nil && len(*segment.SourceDevice) > MAX_SOURCE_DEVICE_LENGTH {
+			segmentErrors = append(segmentErrors, NewFieldError("/source_device", RULE_MAX_LENGTH, map[string]any{"maxLength": MAX_SOURCE_DEVICE_LENGTH}, "source_device should not exceed %d characters", MAX_SOURCE_DEVICE_LENGTH))
+		}
+		errors = append(errors, nestErrors(fmt.Sprintf("/segments/%d", i), fmt.Sprintf("segments[%d]", i), segmentErrors)...)
+	}
+
+	sorted := make([]SleepStageSegmentDto, len(dto.Segments))
+	copy(sorted, dto.Segments)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].StartAt.Before(sorted[j].StartAt)
+	})
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].StartAt.Before(sorted[i-1].EndAt) {
+			at := sorted[i].StartAt.Format(time.RFC3339)
+			errors = append(errors, NewFieldError("/segments", RULE_OVERLAP, map[string]any{"at": at}, "segments should not overlap (at %s)", at))
+			break
+		}
+	}
+	return errors
+}
+
+// SleepMetricsDto holds the standard sleep measures of a single night.
+type SleepMetricsDto struct {
+	SleepOnsetLatencyInMin   int `json:"sleep_onset_latency_in_min"`
+	WakeAfterSleepOnsetInMin int `json:"wake_after_sleep_onset_in_min"`
+	TotalSleepTimeInMin      int `json:"total_sleep_time_in_min"`
+}
+
+// HypnogramDto holds the sleep stages recorded by a device for an entry along
+// with the measures derived from the diary (subjective) and from the stages
+// (objective). Discrepancy is subjective minus objective; it is omitted when
+// no stages were uploaded.
+type HypnogramDto struct {
+	EntryId     int64                  `json:"entry_id"`
+	Segments    []SleepStageSegmentDto `json:"segments"`
+	Subjective  SleepMetricsDto        `json:"subjective"`
+	Objective   *SleepMetricsDto       `json:"objective,omitempty"`
+	Discrepancy *SleepMetricsDto       `json:"discrepancy,omitempty"`
+}
@@ -52,6 +52,16 @@ type SleepDiaryEntryDataDto struct {
 }
 
 func (dto *SleepDiaryEntryDataDto) Validate() []error {
+	return dto.validate(true)
+}
+
+// ValidateDraft validates entry data the same way as Validate, except that
+// the sleep quality may be left unknown (zero).
+func (dto *SleepDiaryEntryDataDto) ValidateDraft() []error {
+	return dto.validate(dto.SleepQuality != 0)
+}
+
+func (dto *SleepDiaryEntryDataDto) validate(requireSleepQuality bool) []error {
 	errors := validateTimeOrder(
 		labeledTime{dto.InBedAt, "in_bed_at"},
 		labeledTime{&dto.TriedToSleepAt, "tried_to_sleep_at"},
@@ -64,33 +74,50 @@ func (dto *SleepDiaryEntryDataDto) Validate() []error {
 	}
 	_, err := time.LoadLocation(timezone)
 	if err != nil {
-		errors = append(errors, fmt.Errorf("timezone is not recognized"))
+		errors = append(errors, NewFieldError("/timezone", RULE_FORMAT, map[string]any{"format": "timezone"}, "timezone is not recognized"))
 	}
 	if dto.TriedToSleepAt.IsZero() {
-		errors = append(errors, fmt.Errorf("tried_to_sleep_at is required"))
+		errors = append(errors, NewFieldError("/tried_to_sleep_at", RULE_REQUIRED, nil, "tried_to_sleep_at is required"))
 	}
 	if dto.FinalWakeUpAt.IsZero() {
-		errors = append(errors, fmt.Errorf("final_wake_up_at is required"))
+		errors = append(errors, NewFieldError("/final_wake_up_at", RULE_REQUIRED, nil, "final_wake_up_at is required"))
 	}
-	if dto.SleepQuality < VeryPoorSleepQuality || dto.SleepQuality > ExcellentSleepQuality {
-		errors = append(errors, fmt.Errorf("sleep_quality should be between %d and %d", VeryPoorSleepQuality, ExcellentSleepQuality))
+	if requireSleepQuality {
+		errors = append(errors, validateSleepQuality(dto.SleepQuality)...)
 	}
 	if dto.SleepDelayInMin != nil && *dto.SleepDelayInMin < 0 {
-		errors = append(errors, fmt.Errorf("sleep_delay_in_min should be non-negative"))
+		errors = append(errors, NewFieldError("/sleep_delay_in_min", RULE_MINIMUM, map[string]any{"minimum": 0}, "sleep_delay_in_min should be non-negative"))
 	}
 	if dto.AwakeningsCount != nil && *dto.AwakeningsCount < 0 {
-		errors = append(errors, fmt.Errorf("awakenings_count should be non-negative"))
+		errors = append(errors, NewFieldError("/awakenings_count", RULE_MINIMUM, map[string]any{"minimum": 0}, "awakenings_count should be non-negative"))
 	}
 	if dto.AwakeningsTotalDurationInMin != nil && *dto.AwakeningsTotalDurationInMin < 0 {
-		errors = append(errors, fmt.Errorf("awakenings_total_duration_in_min should be non-negative"))
-	}
-	if dto.Comments != nil && len(*dto.Comments) > MAX_COMMENT_LENGTH {
-		errors = append(errors, fmt.Errorf("comments should not exceed %d characters", MAX_COMMENT_LENGTH))
+		errors = append(errors, NewFieldError("/awakenings_total_duration_in_min", RULE_MINIMUM, map[string]any{"minimum": 0}, "awakenings_total_duration_in_min should be non-negative"))
 	}
+	errors = append(errors, validateComments(dto.Comments)...)
 
 	return errors
 }
 
+func validateSleepQuality(sleepQuality SleepQuality) []error {
+	params := map[string]any{"minimum": VeryPoorSleepQuality, "maximum": ExcellentSleepQuality}
+	message := fmt.Sprintf("sleep_quality should be between %d and %d", VeryPoorSleepQuality, ExcellentSleepQuality)
+	if sleepQuality < VeryPoorSleepQuality {
+		return []error{NewFieldError("/sleep_quality", RULE_MINIMUM, params, "%s", message)}
+	}
+	if sleepQuality > ExcellentSleepQuality {
+		return []error{NewFieldError("/sleep_quality", RULE_MAXIMUM, params, "%s", message)}
+	}
+	return nil
+}
+
+func validateComments(comments *string) []error {
+	if comments != nil && len(*comments) > MAX_COMMENT_LENGTH {
+		return []error{NewFieldError("/comments", RULE_MAX_LENGTH, map[string]any{"maxLength": MAX_COMMENT_LENGTH}, "comments should not exceed %d characters", MAX_COMMENT_LENGTH)}
+	}
+	return nil
+}
+
 type SleepDiaryFilterDto struct {
 	AccountUuid []string   `json:"account_uuid"`
 	FromDate    *time.Time `json:"from_date,omitempty"`
@@ -105,21 +132,21 @@ func (dto *SleepDiaryFilterDto) Validate() []error {
 		labeledTime{dto.ToDate, "to_date"},
 	)
 	if len(dto.AccountUuid) == 0 {
-		errors = append(errors, fmt.Errorf("account_uuid is required"))
+		errors = append(errors, NewFieldError("/account_uuid", RULE_REQUIRED, nil, "account_uuid is required"))
 	}
-	for _, id := range dto.AccountUuid {
+	for i, id := range dto.AccountUuid {
 		if _, err := uuid.Parse(id); err != nil {
-			errors = append(errors, fmt.Errorf("invalid UUID '%s'", id))
+			errors = append(errors, NewFieldError(fmt.Sprintf("/account_uuid/%d", i), RULE_FORMAT, map[string]any{"format": "uuid"}, "invalid UUID '%s'", id))
 		}
 	}
 	if dto.PageSize < 1 {
-		errors = append(errors, fmt.Errorf("page_size should be greater than 0"))
+		errors = append(errors, NewFieldError("/page_size", RULE_MINIMUM, map[string]any{"minimum": 1}, "page_size should be greater than 0"))
 	}
 	if dto.PageSize > MAX_PAGE_SIZE {
-		errors = append(errors, fmt.Errorf("page_size should not exceed %d", MAX_PAGE_SIZE))
+		errors = append(errors, NewFieldError("/page_size", RULE_MAXIMUM, map[string]any{"maximum": MAX_PAGE_SIZE}, "page_size should not exceed %d", MAX_PAGE_SIZE))
 	}
 	if dto.PageNumber < 1 {
-		errors = append(errors, fmt.Errorf("page_number should be greater than 0"))
+		errors = append(errors, NewFieldError("/page_number", RULE_MINIMUM, map[string]any{"minimum": 1}, "page_number should be greater than 0"))
 	}
 	return errors
 }
@@ -134,24 +161,32 @@ func (dto *UpdateSleepDiaryEntryDto) Validate() []error {
 	return errors
 }
 
+// CreateSleepDiaryEntryDto creates an entry. Uuid may be generated by the
+// client, e.g. to create entries offline; otherwise it is generated by the
+// server.
 type CreateSleepDiaryEntryDto struct {
 	AccountUuid string `json:"account_uuid"`
+	Uuid        string `json:"uuid,omitempty"`
 	SleepDiaryEntryDataDto
 }
 
 func (dto *CreateSleepDiaryEntryDto) Validate() []error {
 	errors := dto.SleepDiaryEntryDataDto.Validate()
 	if dto.AccountUuid == "" {
-		errors = append(errors, fmt.Errorf("account_uuid is required"))
+		errors = append(errors, NewFieldError("/account_uuid", RULE_REQUIRED, nil, "account_uuid is required"))
 	}
 	if _, err := uuid.Parse(dto.AccountUuid); err != nil {
-		errors = append(errors, fmt.Errorf("invalid UUID '%s'", dto.AccountUuid))
+		errors = append(errors, NewFieldError("/account_uuid", RULE_FORMAT, map[string]any{"format": "uuid"}, "invalid UUID '%s'", dto.AccountUuid))
+	}
+	if _, err := uuid.Parse(dto.Uuid); dto.Uuid != "" && err != nil {
+		errors = append(errors, NewFieldError("/uuid", RULE_FORMAT, map[string]any{"format": "uuid"}, "invalid UUID '%s'", dto.Uuid))
 	}
 	return errors
 }
 
 type SleepDiaryEntryDto struct {
 	Id          int64  `json:"id"`
+	Uuid        string `json:"uuid"`
 	AccountUuid string `json:"account_uuid"`
 	Version     int64  `json:"version"`
 	SleepDiaryEntryDataDto
@@ -164,6 +199,13 @@ type PageDto[T any] struct {
 	Items      []T   `json:"items"`
 }
 
+func ValidateAccountUuid(accountUuid string) error {
+	if _, err := uuid.Parse(accountUuid); err != nil {
+		return NewFieldError("/account_uuid", RULE_FORMAT, map[string]any{"format": "uuid"}, "invalid UUID '%s'", accountUuid)
+	}
+	return nil
+}
+
 type labeledTime struct {
 	time  *time.Time
 	label string
@@ -178,7 +220,7 @@ func validateTimeOrder(items ...labeledTime) []error {
 			continue
 		}
 		if prev != nil && i.time.Before(*prev.time) {
-			errors = append(errors, fmt.Errorf("%s should be before %s", prev.label, i.label))
+			errors = append(errors, NewFieldError("/"+prev.label, RULE_ORDER, map[string]any{"before": "/" + i.label}, "%s should be before %s", prev.label, i.label))
 		}
 		prev = &i
 	}
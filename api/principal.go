@@ -0,0 +1,53 @@
+package api
+
+import "slices"
+
+// SCOPE_ADMIN grants access to entries of all accounts of the tenant.
+const SCOPE_ADMIN = "admin"
+
+// Scopes of API keys; callers authenticated otherwise are not limited by them.
+const (
+	SCOPE_READ_ENTRIES  = "entries:read"
+	SCOPE_WRITE_ENTRIES = "entries:write"
+	SCOPE_EXPORT        = "export"
+)
+
+// DEFAULT_TENANT is the tenant of callers not assigned to one, including all
+// callers of servers running without authentication.
+const DEFAULT_TENANT = "default"
+
+// Principal is the caller of the API: the subject of its credentials, the
+// tenant and account it acts as and the scopes granted to it.
+type Principal struct {
+	Subject     string
+	TenantId    string
+	AccountUuid string
+	Scopes      []string
+	// AllAccounts is set for callers acting on behalf of every account, such
+	// as API keys not restricted to an account.
+	AllAccounts bool
+	// TraceId identifies the request the caller made, for the audit log.
+	TraceId string
+}
+
+// SystemPrincipal is the caller of servers running without authentication,
+// trusted with access to all accounts of the default tenant.
+var SystemPrincipal = Principal{Subject: "system", Scopes: []string{SCOPE_ADMIN}}
+
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// AccessesAllAccounts tells whether the caller may access entries of every
+// account without being granted access.
+func (p Principal) AccessesAllAccounts() bool {
+	return p.AllAccounts || p.HasScope(SCOPE_ADMIN)
+}
+
+// Tenant returns the tenant whose data the caller accesses.
+func (p Principal) Tenant() string {
+	if p.TenantId == "" {
+		return DEFAULT_TENANT
+	}
+	return p.TenantId
+}
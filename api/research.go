@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const MAX_STUDY_ID_LENGTH = 100
+
+// REDACTED_COMMENTS replaces comments of entries in research datasets exported
+// with RedactResearchCommentsMode.
+const REDACTED_COMMENTS = "[redacted]"
+
+// ResearchCommentsMode tells how comments are left out of research datasets.
+type ResearchCommentsMode string
+
+const (
+	// Comments are omitted.
+	StripResearchCommentsMode ResearchCommentsMode = "strip"
+	// Comments are replaced by REDACTED_COMMENTS, telling which entries had
+	// any.
+	RedactResearchCommentsMode ResearchCommentsMode = "redact"
+)
+
+var ResearchCommentsModes = []ResearchCommentsMode{StripResearchCommentsMode, RedactResearchCommentsMode}
+
+// ResearchExportFilterDto selects entries of a research dataset: entries of
+// given accounts (of all accounts of the tenant when none are given) with
+// tried_to_sleep_at in the date range, before dates are shifted.
+type ResearchExportFilterDto struct {
+	StudyId     string               `json:"study_id"`
+	AccountUuid []string             `json:"account_uuid,omitempty"`
+	FromDate    *time.Time           `json:"from_date,omitempty"`
+	ToDate      *time.Time           `json:"to_date,omitempty"`
+	Comments    ResearchCommentsMode `json:"comments_mode"`
+}
+
+func (dto *ResearchExportFilterDto) Validate() []error {
+	errors := validateTimeOrder(
+		labeledTime{dto.FromDate, "from_date"},
+		labeledTime{dto.ToDate, "to_date"},
+	)
+	if dto.StudyId == "" {
+		errors = append(errors, NewFieldError("/study_id", RULE_REQUIRED, nil, "study_id is required"))
+	}
+	if len(dto.StudyId) > MAX_STUDY_ID_LENGTH {
+		errors = append(errors, NewFieldError("/study_id", RULE_MAX_LENGTH, map[string]any{"maxLength": MAX_STUDY_ID_LENGTH}, "study_id should not exceed %d characters", MAX_STUDY_ID_LENGTH))
+	}
+	for i, id := range dto.AccountUuid {
+		if _, err := uuid.Parse(id); err != nil {
+			errors = append(errors, NewFieldError(fmt.Sprintf("/account_uuid/%d", i), RULE_FORMAT, map[string]any{"format": "uuid"}, "invalid UUID '%s'", id))
+		}
+	}
+	if !slices.Contains(ResearchCommentsModes, dto.Comments) {
+		errors = append(errors, NewFieldError("/comments_mode", RULE_ENUM, map[string]any{"enum": ResearchCommentsModes}, "unknown comments mode '%s'", dto.Comments))
+	}
+	return errors
+}
+
+// ResearchDatasetDto is a de-identified dataset of diary entries for a study.
+// Entries carry no identifiers of accounts or entries: each account is
+// replaced by a participant ID stable within the study, and dates of its
+// entries are shifted by whole weeks, keeping weekdays and local times.
+type ResearchDatasetDto struct {
+	StudyId           string               `json:"study_id"`
+	ExportedAt        time.Time            `json:"exported_at"`
+	ParticipantsCount int                  `json:"participants_count"`
+	Comments          ResearchCommentsMode `json:"comments_mode"`
+	Entries           []ResearchEntryDto   `json:"entries"`
+}
+
+type ResearchEntryDto struct {
+	ParticipantId string `json:"participant_id"`
+	SleepDiaryEntryDataDto
+}
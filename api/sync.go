@@ -0,0 +1,99 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Maximum number of changes pushed in a single request.
+const MAX_SYNC_PUSH_CHANGES = 100
+
+// SyncDto holds the entries of an account changed since a sync token: the
+// current state of created or updated entries and tombstones of deleted
+// ones. Clients store NextToken and pass it to the next sync; HasMore means
+// that more changes are available with NextToken right away.
+type SyncDto struct {
+	Entries   []SleepDiaryEntryDto `json:"entries"`
+	Deleted   []TombstoneDto       `json:"deleted"`
+	NextToken string               `json:"next_token"`
+	HasMore   bool                 `json:"has_more"`
+}
+
+// TombstoneDto marks a deleted entry. Uuid is missing only for entries
+// deleted before entries had UUIDs.
+type TombstoneDto struct {
+	Id        int64     `json:"id"`
+	Uuid      string    `json:"uuid,omitempty"`
+	Version   int64     `json:"version"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+type SyncPushDto struct {
+	AccountUuid string          `json:"account_uuid"`
+	Changes     []SyncChangeDto `json:"changes"`
+}
+
+// SyncChangeDto is a change made by a client, possibly offline. A change
+// without Version creates an entry with the client-generated Uuid. A change
+// with Version updates the entry (or deletes it when Deleted is set), as long
+// as Version is still the current version of the entry.
+type SyncChangeDto struct {
+	Uuid    string                  `json:"uuid"`
+	Version *int64                  `json:"version,omitempty"`
+	Deleted bool                    `json:"deleted,omitempty"`
+	Entry   *SleepDiaryEntryDataDto `json:"entry,omitempty"`
+}
+
+func (dto *SyncPushDto) Validate() []error {
+	errors := []error{}
+	if err := ValidateAccountUuid(dto.AccountUuid); err != nil {
+		errors = append(errors, err)
+	}
+	if len(dto.Changes) > MAX_SYNC_PUSH_CHANGES {
+		errors = append(errors, NewFieldError("/changes", RULE_MAX_ITEMS, map[string]any{"maxItems": MAX_SYNC_PUSH_CHANGES}, "changes should not exceed %d items", MAX_SYNC_PUSH_CHANGES))
+	}
+	for i, change := range dto.Changes {
+		errors = append(errors, nestErrors(fmt.Sprintf("/changes/%d", i), fmt.Sprintf("changes[%d]", i), change.validate())...)
+	}
+	return errors
+}
+
+func (dto *SyncChangeDto) validate() []error {
+	errors := []error{}
+	if _, err := uuid.Parse(dto.Uuid); err != nil {
+		errors = append(errors, NewFieldError("/uuid", RULE_FORMAT, map[string]any{"format": "uuid"}, "invalid UUID '%s'", dto.Uuid))
+	}
+	if dto.Deleted {
+		if dto.Version == nil {
+			errors = append(errors, NewFieldError("/version", RULE_REQUIRED, nil, "version is required to delete an entry"))
+		}
+		return errors
+	}
+	if dto.Entry == nil {
+		errors = append(errors, NewFieldError("/entry", RULE_REQUIRED, nil, "entry is required"))
+		return errors
+	}
+	return append(errors, nestErrors("/entry", "entry", dto.Entry.Validate())...)
+}
+
+type SyncStatus string
+
+const (
+	// Change was applied; Entry is the entry after the change.
+	AppliedSyncStatus SyncStatus = "applied"
+	// Change was made to an outdated version of the entry, or its UUID is
+	// already taken; Entry is the current entry (omitted once deleted).
+	ConflictSyncStatus SyncStatus = "conflict"
+)
+
+type SyncResultDto struct {
+	Uuid   string              `json:"uuid"`
+	Status SyncStatus          `json:"status"`
+	Entry  *SleepDiaryEntryDto `json:"entry,omitempty"`
+}
+
+type SyncPushResultDto struct {
+	Results []SyncResultDto `json:"results"`
+}
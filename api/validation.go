@@ -0,0 +1,72 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ValidationRule is a machine-readable code of a failed validation rule.
+// Where applicable it is the JSON Schema keyword of the OpenAPI document that
+// describes the same constraint.
+type ValidationRule string
+
+const (
+	RULE_REQUIRED      ValidationRule = "required"
+	RULE_TYPE          ValidationRule = "type"
+	RULE_FORMAT        ValidationRule = "format"
+	RULE_ENUM          ValidationRule = "enum"
+	RULE_MINIMUM       ValidationRule = "minimum"
+	RULE_MAXIMUM       ValidationRule = "maximum"
+	RULE_MIN_LENGTH    ValidationRule = "minLength"
+	RULE_MAX_LENGTH    ValidationRule = "maxLength"
+	RULE_MAX_ITEMS     ValidationRule = "maxItems"
+	RULE_UNKNOWN_FIELD ValidationRule = "additionalProperties"
+	// Request body is not well-formed JSON.
+	RULE_SYNTAX ValidationRule = "syntax"
+	// Field should be before the field in "before" param.
+	RULE_ORDER ValidationRule = "order"
+	// Item overlaps another item of the same list.
+	RULE_OVERLAP ValidationRule = "overlap"
+	// Field should be equal to the field in "other" param.
+	RULE_MATCH ValidationRule = "match"
+)
+
+// FieldErrorDto describes a single validation failure. Field is a JSON
+// pointer (RFC 6901) to the offending value; it is empty when the failure
+// concerns the whole request.
+type FieldErrorDto struct {
+	Field   string         `json:"field"`
+	Rule    ValidationRule `json:"rule"`
+	Params  map[string]any `json:"params,omitempty"`
+	Message string         `json:"message"`
+}
+
+func (e FieldErrorDto) Error() string {
+	return e.Message
+}
+
+func NewFieldError(field string, rule ValidationRule, params map[string]any, format string, args ...any) FieldErrorDto {
+	return FieldErrorDto{
+		Field:   field,
+		Rule:    rule,
+		Params:  params,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// nestErrors moves errors of a nested object under pointer, prefixing their
+// messages with label.
+func nestErrors(pointer string, label string, errs []error) []error {
+	nested := make([]error, len(errs))
+	for i, err := range errs {
+		var fieldErr FieldErrorDto
+		if errors.As(err, &fieldErr) {
+			fieldErr.Field = pointer + fieldErr.Field
+			fieldErr.Message = label + ": " + fieldErr.Message
+			nested[i] = fieldErr
+		} else {
+			nested[i] = fmt.Errorf("%s: %w", label, err)
+		}
+	}
+	return nested
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+const MIN_WEBHOOK_SECRET_LENGTH = 16
+const MAX_WEBHOOK_SECRET_LENGTH = 256
+const MAX_WEBHOOK_URL_LENGTH = 2048
+
+type WebhookDeliveryStatus string
+
+const (
+	// Delivery waits for its first or next attempt.
+	PendingWebhookDeliveryStatus WebhookDeliveryStatus = "pending"
+	// Receiver responded with a 2xx status.
+	DeliveredWebhookDeliveryStatus WebhookDeliveryStatus = "delivered"
+	// All attempts failed; the delivery is kept until redelivered.
+	DeadWebhookDeliveryStatus WebhookDeliveryStatus = "dead"
+)
+
+// CreateWebhookDto registers a webhook. Payloads are signed with the secret;
+// an empty account list subscribes to changes of all accounts.
+type CreateWebhookDto struct {
+	Url          string       `json:"url"`
+	Secret       string       `json:"secret"`
+	EventTypes   []ChangeType `json:"event_types"`
+	AccountUuids []string     `json:"account_uuids,omitempty"`
+}
+
+func (dto *CreateWebhookDto) Validate() []error {
+	errors := []error{}
+	if dto.Url == "" {
+		errors = append(errors, NewFieldError("/url", RULE_REQUIRED, nil, "url is required"))
+	} else if u, err := url.Parse(dto.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errors = append(errors, NewFieldError("/url", RULE_FORMAT, map[string]any{"format": "uri"}, "url should be an absolute http(s) URL"))
+	}
+	if len(dto.Url) > MAX_WEBHOOK_URL_LENGTH {
+		errors = append(errors, NewFieldError("/url", RULE_MAX_LENGTH, map[string]any{"maxLength": MAX_WEBHOOK_URL_LENGTH}, "url should not exceed %d characters", MAX_WEBHOOK_URL_LENGTH))
+	}
+	if len(dto.Secret) < MIN_WEBHOOK_SECRET_LENGTH {
+		errors = append(errors, NewFieldError("/secret", RULE_MIN_LENGTH, map[string]any{"minLength": MIN_WEBHOOK_SECRET_LENGTH}, "secret should have at least %d characters", MIN_WEBHOOK_SECRET_LENGTH))
+	}
+	if len(dto.Secret) > MAX_WEBHOOK_SECRET_LENGTH {
+		errors = append(errors, NewFieldError("/secret", RULE_MAX_LENGTH, map[string]any{"maxLength": MAX_WEBHOOK_SECRET_LENGTH}, "secret should not exceed %d characters", MAX_WEBHOOK_SECRET_LENGTH))
+	}
+	if len(dto.EventTypes) == 0 {
+		errors = append(errors, NewFieldError("/event_types", RULE_REQUIRED, nil, "event_types is required"))
+	}
+	changeTypes := []ChangeType{CHANGE_CREATED, CHANGE_UPDATED, CHANGE_DELETED}
+	for i, eventType := range dto.EventTypes {
+		if !slices.Contains(changeTypes, eventType) {
+			errors = append(errors, NewFieldError(fmt.Sprintf("/event_types/%d", i), RULE_ENUM, map[string]any{"enum": changeTypes}, "unknown event type '%s'", eventType))
+		}
+	}
+	for i, accountUuid := range dto.AccountUuids {
+		if err := ValidateAccountUuid(accountUuid); err != nil {
+			errors = append(errors, NewFieldError(fmt.Sprintf("/account_uuids/%d", i), RULE_FORMAT, map[string]any{"format": "uuid"}, "invalid UUID '%s'", accountUuid))
+		}
+	}
+	return errors
+}
+
+// WebhookDto describes a registered webhook; the secret is never returned.
+type WebhookDto struct {
+	Id           int64        `json:"id"`
+	Url          string       `json:"url"`
+	EventTypes   []ChangeType `json:"event_types"`
+	AccountUuids []string     `json:"account_uuids"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+// WebhookDeliveryDto is an entry of the delivery log of a webhook. The
+// payload delivered is the EntryChangeDto of change ChangeId.
+type WebhookDeliveryDto struct {
+	Id             int64                 `json:"id"`
+	WebhookId      int64                 `json:"webhook_id"`
+	ChangeId       int64                 `json:"change_id"`
+	EventType      ChangeType            `json:"event_type"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	LastStatusCode *int                  `json:"last_status_code,omitempty"`
+	LastError      *string               `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+}
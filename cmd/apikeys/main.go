@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/internal/service"
+)
+
+const usage = `Usage:
+  apikeys create -name <name> -scopes <scope,...> [-account <account_uuid>] [-expires <duration>] [-tenant <tenant>]
+  apikeys list [-tenant <tenant>]
+  apikeys rotate [-tenant <tenant>] <id>
+  apikeys revoke [-tenant <tenant>] <id>
+
+Scopes: entries:read, entries:write, export, admin
+`
+
+// Snorlax API key management tool (apikeys). Keys are created and rotated
+// with the admin rights of the system, so that the first admin key can be
+// created before any exists. Keys belong to the tenant given with -tenant.
+func main() {
+	log.SetPrefix("[apikeys] ")
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "create":
+		create(os.Args[2:])
+	case "list":
+		list(os.Args[2:])
+	case "rotate":
+		rotate(os.Args[2:])
+	case "revoke":
+		revoke(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func create(args []string) {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	name := flags.String("name", "", "name of the integration using the key")
+	scopes := flags.String("scopes", "", "comma-separated scopes of the key")
+	accountUuid := flags.String("account", "", "account UUID the key is restricted to")
+	expires := flags.Duration("expires", 0, "time after which the key expires, e.g. 720h")
+	tenant := tenantFlag(flags)
+	flags.Parse(args)
+
+	dto := api.CreateApiKeyDto{
+		Name:        *name,
+		Scopes:      strings.Split(*scopes, ","),
+		AccountUuid: *accountUuid,
+	}
+	if *scopes == "" {
+		dto.Scopes = nil
+	}
+	if *expires > 0 {
+		expiresAt := time.Now().Add(*expires).UTC()
+		dto.ExpiresAt = &expiresAt
+	}
+
+	key, serviceErr := newService().CreateApiKey(systemPrincipal(*tenant), dto)
+	if serviceErr != nil {
+		log.Fatalf("Failed to create API key: %v %v", serviceErr, serviceErr.ToErrorDto().Details)
+	}
+	printJson(key)
+}
+
+func list(args []string) {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	tenant := tenantFlag(flags)
+	flags.Parse(args)
+
+	keys, serviceErr := newService().GetApiKeys(systemPrincipal(*tenant))
+	if serviceErr != nil {
+		log.Fatalf("Failed to list API keys: %v", serviceErr)
+	}
+	printJson(keys)
+}
+
+func rotate(args []string) {
+	flags := flag.NewFlagSet("rotate", flag.ExitOnError)
+	tenant := tenantFlag(flags)
+	flags.Parse(args)
+
+	id := parseId(flags.Args())
+	key, serviceErr := newService().RotateApiKey(systemPrincipal(*tenant), id)
+	if serviceErr != nil {
+		log.Fatalf("Failed to rotate API key %d: %v", id, serviceErr)
+	}
+	printJson(key)
+}
+
+func revoke(args []string) {
+	flags := flag.NewFlagSet("revoke", flag.ExitOnError)
+	tenant := tenantFlag(flags)
+	flags.Parse(args)
+
+	id := parseId(flags.Args())
+	if serviceErr := newService().RevokeApiKey(systemPrincipal(*tenant), id); serviceErr != nil {
+		log.Fatalf("Failed to revoke API key %d: %v", id, serviceErr)
+	}
+	log.Printf("Revoked API key %d\n", id)
+}
+
+func parseId(args []string) int64 {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		log.Fatalf("Invalid ID '%s'", args[0])
+	}
+	return id
+}
+
+func tenantFlag(flags *flag.FlagSet) *string {
+	return flags.String("tenant", api.DEFAULT_TENANT, "tenant the key belongs to")
+}
+
+// systemPrincipal returns the system principal acting within a tenant.
+func systemPrincipal(tenant string) api.Principal {
+	caller := api.SystemPrincipal
+	caller.TenantId = tenant
+	return caller
+}
+
+func newService() *service.SleepDiaryService {
+	return service.NewSleepDiaryService(config.LoadConfig())
+}
+
+func printJson(v any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Fatalf("Failed to print result: %v", err)
+	}
+}
@@ -1,16 +1,59 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
+	"os"
 
+	"github.com/mabzd/snorlax/api"
 	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/internal/service"
 	"github.com/mabzd/snorlax/pkg/dbm"
 )
 
-// Snorlax database migration tool (dbm).
+const usage = `Usage:
+  dbm
+  dbm rotate-keys [-tenant <tenant>]
+`
+
+// Snorlax database migration tool (dbm). Without a command it upgrades the
+// database; rotate-keys replaces the data key encrypting comments of the
+// tenant given with -tenant and re-encrypts them with the new key.
 func main() {
 	log.SetPrefix("[dbm] ")
-	log.Println("Running snorlax database migration")
-	cfg := config.LoadConfig()
-	dbm.UpgradeDatabaseIfNeeded(cfg)
+	if len(os.Args) < 2 {
+		log.Println("Running snorlax database migration")
+		dbm.UpgradeDatabaseIfNeeded(config.LoadConfig())
+		return
+	}
+
+	switch os.Args[1] {
+	case "rotate-keys":
+		rotateKeys(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func rotateKeys(args []string) {
+	flags := flag.NewFlagSet("rotate-keys", flag.ExitOnError)
+	tenant := flags.String("tenant", api.DEFAULT_TENANT, "tenant whose data key is rotated")
+	flags.Parse(args)
+
+	log.Printf("Rotating data key of tenant %s\n", *tenant)
+	caller := api.SystemPrincipal
+	caller.TenantId = *tenant
+	result, serviceErr := service.NewSleepDiaryService(config.LoadConfig()).RotateDataKeys(caller)
+	if serviceErr != nil {
+		log.Fatalf("Failed to rotate data key: %v", serviceErr)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		log.Fatalf("Failed to print result: %v", err)
+	}
 }
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"log"
+
+	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/pkg/rpc"
+)
+
+// Snorlax gRPC service (grpc).
+func main() {
+	log.SetPrefix("[grpc] ")
+	log.Println("Running snorlax grpc")
+	cfg := config.LoadConfig()
+	server := rpc.NewServer(cfg)
+	log.Fatal(rpc.ListenAndServe(cfg, server))
+}
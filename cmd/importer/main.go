@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/importer"
+)
+
+// Snorlax sleep data import tool (importer).
+//
+// Usage: importer -source apple_health|fitbit -account <account_uuid> [-timezone <tz>] [-tenant <tenant>] <file>
+func main() {
+	log.SetPrefix("[importer] ")
+	source := flag.String("source", string(api.AppleHealthImportSource), "import source")
+	accountUuid := flag.String("account", "", "account UUID the entries belong to")
+	timezone := flag.String("timezone", "UTC", "timezone the person slept in")
+	tenant := flag.String("tenant", api.DEFAULT_TENANT, "tenant the account belongs to")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	tz, err := time.LoadLocation(*timezone)
+	if err != nil {
+		log.Fatalf("Invalid timezone '%s': %v", *timezone, err)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", flag.Arg(0), err)
+	}
+	defer file.Close()
+
+	cfg := config.LoadConfig()
+	svc := service.NewSleepDiaryService(cfg)
+	_, maxExtractedSize := svc.ImportMaxSizes()
+	log.Printf("Importing %s from %s\n", *source, flag.Arg(0))
+	entries, err := importer.Parse(api.ImportSource(*source), file, *accountUuid, tz, maxExtractedSize)
+	if err != nil {
+		log.Fatalf("Failed to parse %s: %v", flag.Arg(0), err)
+	}
+
+	caller := api.SystemPrincipal
+	caller.TenantId = *tenant
+	result, serviceErr := svc.ImportDrafts(caller, api.ImportDto{
+		AccountUuid: *accountUuid,
+		Source:      api.ImportSource(*source),
+		Entries:     entries,
+	})
+	if serviceErr != nil {
+		log.Fatalf("Failed to import: %v %v", serviceErr, serviceErr.ToErrorDto().Details)
+	}
+
+	log.Printf(
+		"Imported %d records: %d new drafts, %d merged into existing drafts, %d skipped as already in diary\n",
+		len(result.Records),
+		result.CreatedCount,
+		result.MergedCount,
+		result.SkippedCount)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auditlog"
+	"github.com/mabzd/snorlax/pkg/outbox"
+	"github.com/mabzd/snorlax/pkg/webhook"
+)
+
+// Snorlax background worker (worker) delivering webhooks, publishing outbox
+// events and chaining audit log events.
+func main() {
+	log.SetPrefix("[worker] ")
+	log.Println("Running snorlax worker")
+	cfg := config.LoadConfig()
+	svc := service.NewSleepDiaryService(cfg)
+	publisher, err := outbox.NewPublisher(cfg)
+	if err != nil {
+		log.Fatalf("Creating outbox publisher failed: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		webhook.NewWorker(svc).Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		outbox.NewRelay(svc, publisher).Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		auditlog.NewChainer(svc).Run(ctx)
+	}()
+	wg.Wait()
+	log.Println("Worker stopped")
+}
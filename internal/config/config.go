@@ -2,27 +2,108 @@ package config
 
 import (
 	"os"
+	"strconv"
 )
 
 type Config struct {
 	ApiPort            string
+	GrpcPort           string
 	DbHost             string
 	DbPort             string
 	DbUser             string
 	DbPass             string
 	DbName             string
 	ServerTimeoutInSec int
+	// Reject request bodies with unknown JSON fields unless the request asks
+	// for lenient handling.
+	StrictJson bool
+	// Sink of outbox events: "log", "http" or "file".
+	OutboxSink     string
+	OutboxHttpUrl  string
+	OutboxFilePath string
+	// Bearer tokens are required when a JWKS URL or file is set.
+	JwksUrl     string
+	JwksFile    string
+	JwtIssuer   string
+	JwtAudience string
+	// Claim holding the account UUID of the caller.
+	JwtAccountClaim string
+	// Claim holding the tenant of the caller.
+	JwtTenantClaim string
+	// Headers set by a trusted gateway with the account UUID, the
+	// (space-separated) scopes and the tenant of the caller; used when no
+	// JWKS is set.
+	AccountHeader string
+	ScopesHeader  string
+	TenantHeader  string
+	// Accept API keys in the X-Api-Key header.
+	ApiKeys bool
+	// Requests per minute allowed to each caller for reading, writing and
+	// exporting entries; zero disables the limit.
+	RateLimitReads   int
+	RateLimitWrites  int
+	RateLimitExports int
+	// Failed authentications per minute allowed to each IP address; zero
+	// disables the limit.
+	RateLimitAuthFailures int
+	// Store of rate limit buckets: "memory" (per replica) or "postgres"
+	// (shared by all replicas).
+	RateLimitStore string
+	// Maximum size of an uploaded import file and of a file extracted from
+	// an uploaded archive, in megabytes.
+	ImportMaxSizeInMb          int
+	ImportMaxExtractedSizeInMb int
+	// Secret signing receipts of account deletions.
+	DeletionReceiptSecret string
+	// Base64 of the 256-bit master key wrapping data keys of tenants, which
+	// encrypt comments; comments are stored in plain text when it is empty.
+	EncryptionKey string
+	// Comma-separated base64 of former master keys, unwrapping data keys
+	// until they are rotated.
+	PreviousEncryptionKeys string
+	// Secret deriving pseudonyms and date shifts of research datasets;
+	// research exports are disabled when it is empty.
+	ResearchExportSecret string
+	// Minimum number of participants of a research dataset.
+	ResearchMinCohortSize int
 }
 
 func LoadConfig() Config {
 	return Config{
-		ApiPort:            getenv("API_PORT", "8080"),
-		DbHost:             getenv("DB_HOST", "localhost"),
-		DbPort:             getenv("DB_PORT", "5432"),
-		DbUser:             getenv("DB_USER", "postgres"),
-		DbPass:             getenv("DB_PASS", "postgres"),
-		DbName:             getenv("DB_NAME", "snorlax_db"),
-		ServerTimeoutInSec: 30,
+		ApiPort:                    getenv("API_PORT", "8080"),
+		GrpcPort:                   getenv("GRPC_PORT", "9090"),
+		DbHost:                     getenv("DB_HOST", "localhost"),
+		DbPort:                     getenv("DB_PORT", "5432"),
+		DbUser:                     getenv("DB_USER", "postgres"),
+		DbPass:                     getenv("DB_PASS", "postgres"),
+		DbName:                     getenv("DB_NAME", "snorlax_db"),
+		ServerTimeoutInSec:         30,
+		StrictJson:                 getenv("STRICT_JSON", "false") == "true",
+		OutboxSink:                 getenv("OUTBOX_SINK", "log"),
+		OutboxHttpUrl:              os.Getenv("OUTBOX_HTTP_URL"),
+		OutboxFilePath:             os.Getenv("OUTBOX_FILE_PATH"),
+		JwksUrl:                    os.Getenv("JWKS_URL"),
+		JwksFile:                   os.Getenv("JWKS_FILE"),
+		JwtIssuer:                  os.Getenv("JWT_ISSUER"),
+		JwtAudience:                os.Getenv("JWT_AUDIENCE"),
+		JwtAccountClaim:            getenv("JWT_ACCOUNT_CLAIM", "sub"),
+		JwtTenantClaim:             getenv("JWT_TENANT_CLAIM", "tenant_id"),
+		AccountHeader:              os.Getenv("ACCOUNT_HEADER"),
+		ScopesHeader:               os.Getenv("SCOPES_HEADER"),
+		TenantHeader:               os.Getenv("TENANT_HEADER"),
+		ApiKeys:                    getenv("API_KEYS", "false") == "true",
+		RateLimitReads:             getenvInt("RATE_LIMIT_READS", 0),
+		RateLimitWrites:            getenvInt("RATE_LIMIT_WRITES", 0),
+		RateLimitExports:           getenvInt("RATE_LIMIT_EXPORTS", 0),
+		RateLimitAuthFailures:      getenvInt("RATE_LIMIT_AUTH_FAILURES", 0),
+		RateLimitStore:             getenv("RATE_LIMIT_STORE", "memory"),
+		ImportMaxSizeInMb:          getenvInt("IMPORT_MAX_SIZE_MB", 256),
+		ImportMaxExtractedSizeInMb: getenvInt("IMPORT_MAX_EXTRACTED_SIZE_MB", 2048),
+		DeletionReceiptSecret:      os.Getenv("DELETION_RECEIPT_SECRET"),
+		EncryptionKey:              os.Getenv("ENCRYPTION_KEY"),
+		PreviousEncryptionKeys:     os.Getenv("PREVIOUS_ENCRYPTION_KEYS"),
+		ResearchExportSecret:       os.Getenv("RESEARCH_EXPORT_SECRET"),
+		ResearchMinCohortSize:      getenvInt("RESEARCH_MIN_COHORT_SIZE", 10),
 	}
 }
 
@@ -33,3 +114,11 @@ func getenv(key string, fallback string) string {
 	}
 	return env
 }
+
+func getenvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
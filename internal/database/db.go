@@ -10,10 +10,14 @@ import (
 	"github.com/mabzd/snorlax/internal/config"
 )
 
-func InitDB(cfg config.Config) (*sql.DB, error) {
-	connStr := fmt.Sprintf(
+func ConnString(cfg config.Config) string {
+	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		cfg.DbHost, cfg.DbPort, cfg.DbUser, cfg.DbPass, cfg.DbName)
+}
+
+func InitDB(cfg config.Config) (*sql.DB, error) {
+	connStr := ConnString(cfg)
 
 	var db *sql.DB
 	var err error
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+type accessMode int
+
+const (
+	readAccess accessMode = iota
+	writeAccess
+)
+
+// isOwner tells whether the caller owns an account or, as an admin, acts on
+// behalf of its owner.
+func (s *SleepDiaryService) isOwner(caller api.Principal, accountUuid string) bool {
+	return caller.AccessesAllAccounts() ||
+		(caller.AccountUuid != "" && strings.EqualFold(caller.AccountUuid, accountUuid))
+}
+
+// canAccess tells whether the caller may access entries of an account, either
+// as its owner or with a grant in force. Grants are read with tx, a tenant
+// transaction of the caller.
+func (s *SleepDiaryService) canAccess(tx queryer, caller api.Principal, accountUuid string, mode accessMode) (bool, api.Error) {
+	accessible, serviceErr := s.accessibleAccounts(tx, caller, []string{accountUuid}, mode)
+	return len(accessible) > 0, serviceErr
+}
+
+// accessibleAccounts returns those of given accounts the caller may access.
+func (s *SleepDiaryService) accessibleAccounts(tx queryer, caller api.Principal, accountUuids []string, mode accessMode) ([]string, api.Error) {
+	accessible := []string{}
+	others := []string{}
+	for _, accountUuid := range accountUuids {
+		if s.isOwner(caller, accountUuid) {
+			accessible = append(accessible, accountUuid)
+		} else {
+			others = append(others, accountUuid)
+		}
+	}
+	if len(others) == 0 || caller.Subject == "" {
+		return accessible, nil
+	}
+
+	granted, err := getGrantedAccounts(tx, caller.Subject, others, mode == writeAccess, time.Now().UTC())
+	if err != nil {
+		log.Printf("Reading grants of %s failed: %v\n", caller.Subject, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	return append(accessible, granted...), nil
+}
+
+// checkEntryAccess fails with ERR_NOT_FOUND unless the caller may access the
+// entry, so that entries of other accounts cannot be told from missing ones.
+// Entries of other tenants are not visible in tx at all.
+// Writing entries the caller may only read fails with ERR_FORBIDDEN.
+func (s *SleepDiaryService) checkEntryAccess(tx queryer, caller api.Principal, id int64, mode accessMode) api.Error {
+	if caller.AccessesAllAccounts() {
+		return nil
+	}
+	accountUuid, err := getSleepDiaryEntryAccountUuid(tx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return api.NewError("entry not found", api.ERR_NOT_FOUND)
+		}
+		log.Printf("Reading account of entry %d failed: %v\n", id, err)
+		return api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	accessible, serviceErr := s.canAccess(tx, caller, accountUuid, mode)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	if accessible {
+		return nil
+	}
+	if mode == writeAccess {
+		readable, serviceErr := s.canAccess(tx, caller, accountUuid, readAccess)
+		if serviceErr != nil {
+			return serviceErr
+		}
+		if readable {
+			return api.NewError("entry not writable", api.ERR_FORBIDDEN)
+		}
+	}
+	return api.NewError("entry not found", api.ERR_NOT_FOUND)
+}
+
+// checkAccountAccess fails with ERR_NOT_FOUND unless the caller may access
+// entries of the account, so that other accounts cannot be told from missing
+// ones. Writing entries the caller may only read fails with ERR_FORBIDDEN.
+func (s *SleepDiaryService) checkAccountAccess(tx queryer, caller api.Principal, accountUuid string, mode accessMode) api.Error {
+	accessible, serviceErr := s.canAccess(tx, caller, accountUuid, mode)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	if accessible {
+		return nil
+	}
+	if mode == writeAccess {
+		readable, serviceErr := s.canAccess(tx, caller, accountUuid, readAccess)
+		if serviceErr != nil {
+			return serviceErr
+		}
+		if readable {
+			return api.NewError("account not writable", api.ERR_FORBIDDEN)
+		}
+	}
+	return api.NewError("account not found", api.ERR_NOT_FOUND)
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+)
+
+// DeleteAccount erases all data of an account within the tenant of the caller
+// in a single transaction and returns a signed receipt of it. Only the owner
+// of the account or an admin may delete it.
+func (s *SleepDiaryService) DeleteAccount(caller api.Principal, accountUuid string, mode api.AccountDeletionMode) (api.AccountDeletionReceiptDto, api.Error) {
+	errs := []error{}
+	if err := api.ValidateAccountUuid(accountUuid); err != nil {
+		errs = append(errs, err)
+	}
+	if !slices.Contains(api.AccountDeletionModes, mode) {
+		errs = append(errs, api.NewFieldError("/mode", api.RULE_ENUM, map[string]any{"enum": api.AccountDeletionModes}, "unknown mode '%s'", mode))
+	}
+	if len(errs) > 0 {
+		return api.AccountDeletionReceiptDto{}, api.NewValidationError("invalid account deletion", errs)
+	}
+	if !s.isOwner(caller, accountUuid) {
+		return api.AccountDeletionReceiptDto{}, api.NewError("account not accessible", api.ERR_FORBIDDEN)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting delete transaction failed: %v\n", err)
+		return api.AccountDeletionReceiptDto{}, api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	rows, err := eraseAccount(tx, caller.Tenant(), accountUuid, mode)
+	if err != nil {
+		log.Printf("Erasing account %s failed: %v\n", accountUuid, err)
+		return api.AccountDeletionReceiptDto{}, api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+	// Events of the account are kept in the audit log, as it is append-only.
+	if err := recordAudit(tx, caller, api.DeleteAccountAuditAction, []string{accountUuid}, []int64{}); err != nil {
+		log.Printf("Recording erasure of account %s failed: %v\n", accountUuid, err)
+		return api.AccountDeletionReceiptDto{}, api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing erasure of account %s failed: %v\n", accountUuid, err)
+		return api.AccountDeletionReceiptDto{}, api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+
+	receipt := api.AccountDeletionReceiptDto{
+		Id:          uuid.NewString(),
+		AccountUuid: accountUuid,
+		TenantId:    caller.Tenant(),
+		Mode:        mode,
+		RequestedBy: caller.Subject,
+		DeletedAt:   time.Now().UTC(),
+		Rows:        rows,
+	}
+	receipt.Signature = s.signDeletionReceipt(receipt)
+	return receipt, nil
+}
+
+// VerifyDeletionReceipt fails with ERR_INVALID unless the receipt was signed
+// by this server and not altered since.
+func (s *SleepDiaryService) VerifyDeletionReceipt(receipt api.AccountDeletionReceiptDto) api.Error {
+	expected := s.signDeletionReceipt(receipt)
+	if expected == "" || !hmac.Equal([]byte(receipt.Signature), []byte(expected)) {
+		return api.NewError("invalid receipt signature", api.ERR_INVALID)
+	}
+	return nil
+}
+
+func (s *SleepDiaryService) signDeletionReceipt(receipt api.AccountDeletionReceiptDto) string {
+	if s.deletionReceiptSecret == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(s.deletionReceiptSecret))
+	mac.Write(receipt.SignedContent())
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ExportAccount returns everything stored about an account within the tenant
+// of the caller, read in a single transaction. Only the owner of the account
+// or an admin may export it.
+func (s *SleepDiaryService) ExportAccount(caller api.Principal, accountUuid string) (api.AccountExportDto, api.Error) {
+	if err := api.ValidateAccountUuid(accountUuid); err != nil {
+		return api.AccountExportDto{}, api.NewValidationError("invalid account", []error{err})
+	}
+	if !s.isOwner(caller, accountUuid) {
+		return api.AccountExportDto{}, api.NewError("account not accessible", api.ERR_FORBIDDEN)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return api.AccountExportDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	export, err := readAccountExport(tx, s.commentKeys(tx), caller.Tenant(), accountUuid)
+	if err != nil {
+		log.Printf("Reading export of account %s failed: %v\n", accountUuid, err)
+		return api.AccountExportDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	entryIds := make([]int64, len(export.Entries))
+	for i, entry := range export.Entries {
+		entryIds[i] = entry.Id
+	}
+	if err := recordAudit(tx, caller, api.ExportAccountAuditAction, []string{accountUuid}, entryIds); err != nil {
+		log.Printf("Recording export of account %s failed: %v\n", accountUuid, err)
+		return api.AccountExportDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing export of account %s failed: %v\n", accountUuid, err)
+		return api.AccountExportDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	return export, nil
+}
+
+func readAccountExport(tx queryer, keys *commentKeys, tenantId string, accountUuid string) (api.AccountExportDto, error) {
+	export := api.AccountExportDto{
+		AccountUuid: accountUuid,
+		TenantId:    tenantId,
+		ExportedAt:  time.Now().UTC(),
+	}
+
+	entries, err := getAllSleepDiaryEntriesByAccount(tx, keys, accountUuid)
+	if err != nil {
+		return export, err
+	}
+	export.Entries = make([]api.SleepDiaryEntryDto, len(entries))
+	locations := map[int64]*time.Location{}
+	for i, entry := range entries {
+		if export.Entries[i], err = toSleepDiaryEntryDto(entry); err != nil {
+			return export, err
+		}
+		if locations[entry.Id], err = time.LoadLocation(entry.Timezone); err != nil {
+			return export, err
+		}
+	}
+
+	segments, err := getSleepStageSegmentsByAccount(tx, accountUuid)
+	if err != nil {
+		return export, err
+	}
+	export.SleepStages = make([]api.EntrySleepStageDto, len(segments))
+	for i, segment := range segments {
+		export.SleepStages[i] = api.EntrySleepStageDto{
+			EntryId:              segment.EntryId,
+			SleepStageSegmentDto: toSleepStageSegmentDto(segment, locations[segment.EntryId]),
+		}
+	}
+
+	drafts, err := getSleepDiaryDraftsByAccount(tx, keys, accountUuid)
+	if err != nil {
+		return export, err
+	}
+	export.Drafts = make([]api.SleepDiaryDraftDto, len(drafts))
+	for i, draft := range drafts {
+		if export.Drafts[i], err = toSleepDiaryDraftDto(draft); err != nil {
+			return export, err
+		}
+	}
+
+	changes, err := getAllSleepDiaryEntryChangesByAccount(tx, accountUuid)
+	if err != nil {
+		return export, err
+	}
+	export.Changes = make([]api.EntryChangeDto, len(changes))
+	for i, change := range changes {
+		if export.Changes[i], err = toEntryChangeDto(change, nil); err != nil {
+			return export, err
+		}
+	}
+
+	grants, err := getGrantsByAccount(tx, accountUuid)
+	if err != nil {
+		return export, err
+	}
+	export.Grants = toGrantDtos(grants)
+	received, err := getGrantsByGrantee(tx, accountUuid)
+	if err != nil {
+		return export, err
+	}
+	export.ReceivedGrants = toGrantDtos(received)
+
+	apiKeys, err := getApiKeysByAccount(tx, tenantId, accountUuid)
+	if err != nil {
+		return export, err
+	}
+	export.ApiKeys = make([]api.ApiKeyDto, len(apiKeys))
+	for i, key := range apiKeys {
+		export.ApiKeys[i] = toApiKeyDto(key)
+	}
+	return export, nil
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+)
+
+// erasureStep erases rows of an account from a table.
+type erasureStep struct {
+	table string
+	query string
+	args  []any
+}
+
+// eraseAccount deletes or pseudonymizes all rows of an account visible in tx, a
+// tenant transaction, and API keys of the account in given tenant. It returns
+// the number of rows erased in each table.
+func eraseAccount(tx queryer, tenantId string, accountUuid string, mode api.AccountDeletionMode) (map[string]int64, error) {
+	steps := []erasureStep{
+		// Queued events carry entries of the account in their payloads; they
+		// are found through the changes of the account, which are in the
+		// tenant unlike events.
+		{
+			"webhook_deliveries",
+			"DELETE FROM webhook_deliveries WHERE change_id IN (SELECT id FROM sleep_diary_entry_changes WHERE account_uuid = $1)",
+			[]any{accountUuid},
+		},
+		{
+			"outbox",
+			"DELETE FROM outbox WHERE aggregate_id IN (SELECT entry_id FROM sleep_diary_entry_changes WHERE account_uuid = $1)",
+			[]any{accountUuid},
+		},
+	}
+
+	if mode == api.PseudonymizeAccountDeletionMode {
+		pseudonym := uuid.NewString()
+		steps = append(steps,
+			erasureStep{
+				"sleep_stage_segments",
+				`UPDATE sleep_stage_segments SET source_device = NULL
+				WHERE source_device IS NOT NULL AND entry_id IN (SELECT id FROM sleep_diary_entries WHERE account_uuid = $1)`,
+				[]any{accountUuid},
+			},
+			erasureStep{
+				"sleep_diary_entries",
+				"UPDATE sleep_diary_entries SET account_uuid = $2, uuid = gen_random_uuid(), comments = NULL, comments_key_id = NULL WHERE account_uuid = $1",
+				[]any{accountUuid, pseudonym},
+			},
+			erasureStep{
+				"sleep_diary_entry_changes",
+				`UPDATE sleep_diary_entry_changes c
+				SET account_uuid = $2, entry_uuid = (SELECT e.uuid FROM sleep_diary_entries e WHERE e.id = c.entry_id)
+				WHERE c.account_uuid = $1`,
+				[]any{accountUuid, pseudonym},
+			},
+		)
+	} else {
+		steps = append(steps,
+			erasureStep{
+				"sleep_stage_segments",
+				"DELETE FROM sleep_stage_segments WHERE entry_id IN (SELECT id FROM sleep_diary_entries WHERE account_uuid = $1)",
+				[]any{accountUuid},
+			},
+			erasureStep{
+				"sleep_diary_entries",
+				"DELETE FROM sleep_diary_entries WHERE account_uuid = $1",
+				[]any{accountUuid},
+			},
+			erasureStep{
+				"sleep_diary_entry_changes",
+				"DELETE FROM sleep_diary_entry_changes WHERE account_uuid = $1",
+				[]any{accountUuid},
+			},
+		)
+	}
+
+	steps = append(steps,
+		erasureStep{
+			"sleep_diary_drafts",
+			"DELETE FROM sleep_diary_drafts WHERE account_uuid = $1",
+			[]any{accountUuid},
+		},
+		erasureStep{
+			"account_grants",
+			"DELETE FROM account_grants WHERE account_uuid = $1 OR lower(grantee) = lower($2)",
+			[]any{accountUuid, accountUuid},
+		},
+		erasureStep{
+			"api_keys",
+			"DELETE FROM api_keys WHERE tenant_id = $1 AND account_uuid = $2",
+			[]any{tenantId, accountUuid},
+		},
+		// Webhooks of the account alone are deleted rather than left without
+		// accounts, which would subscribe them to all accounts.
+		erasureStep{
+			"webhooks",
+			"DELETE FROM webhooks WHERE account_uuids = ARRAY[$1::uuid]",
+			[]any{accountUuid},
+		},
+		erasureStep{
+			"webhooks",
+			"UPDATE webhooks SET account_uuids = array_remove(account_uuids, $1::uuid) WHERE $1::uuid = ANY(account_uuids)",
+			[]any{accountUuid},
+		},
+	)
+
+	rows := map[string]int64{}
+	for _, step := range steps {
+		result, err := tx.Exec(step.query, step.args...)
+		if err != nil {
+			return nil, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		rows[step.table] += affected
+	}
+	return rows, nil
+}
+
+func getAllSleepDiaryEntriesByAccount(db queryer, keys *commentKeys, accountUuid string) ([]SleepDiaryEntry, error) {
+	query := "SELECT " + entryColumns + " FROM sleep_diary_entries WHERE account_uuid = $1 ORDER BY tried_to_sleep_at"
+	rows, err := db.Query(query, accountUuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []SleepDiaryEntry{}
+	for rows.Next() {
+		var entry SleepDiaryEntry
+		err := rows.Scan(
+			&entry.Id,
+			&entry.AccountUuid,
+			&entry.Timezone,
+			&entry.InBedAt,
+			&entry.TriedToSleepAt,
+			&entry.SleepDelayInMin,
+			&entry.AwakeningsCount,
+			&entry.AwakeningsTotalDurationInMin,
+			&entry.FinalWakeUpAt,
+			&entry.OutOfBedAt,
+			&entry.SleepQuality,
+			&entry.Comments,
+			&entry.CreatedAt,
+			&entry.UpdatedAt,
+			&entry.Version,
+			&entry.Uuid,
+			&entry.CommentsKeyId,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if err := keys.decryptEntry(&entries[i]); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func getSleepStageSegmentsByAccount(db queryer, accountUuid string) ([]SleepStageSegment, error) {
+	query := `
+		SELECT s.id, s.entry_id, s.stage, s.start_at, s.end_at, s.source_device
+		FROM sleep_stage_segments s
+		JOIN sleep_diary_entries e ON e.id = s.entry_id
+		WHERE e.account_uuid = $1
+		ORDER BY e.tried_to_sleep_at, s.entry_id, s.start_at
+	`
+	rows, err := db.Query(query, accountUuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	segments := []SleepStageSegment{}
+	for rows.Next() {
+		var segment SleepStageSegment
+		err := rows.Scan(
+			&segment.Id,
+			&segment.EntryId,
+			&segment.Stage,
+			&segment.StartAt,
+			&segment.EndAt,
+			&segment.SourceDevice,
+		)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+	}
+	return segments, rows.Err()
+}
+
+func getAllSleepDiaryEntryChangesByAccount(db queryer, accountUuid string) ([]SleepDiaryEntryChange, error) {
+	query := `
+		SELECT id, entry_id, entry_uuid, account_uuid, change_type, version, changed_at
+		FROM sleep_diary_entry_changes
+		WHERE account_uuid = $1
+		ORDER BY id
+	`
+	rows, err := db.Query(query, accountUuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []SleepDiaryEntryChange{}
+	for rows.Next() {
+		var change SleepDiaryEntryChange
+		err := rows.Scan(
+			&change.Id,
+			&change.EntryId,
+			&change.EntryUuid,
+			&change.AccountUuid,
+			&change.ChangeType,
+			&change.Version,
+			&change.ChangedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
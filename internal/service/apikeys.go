@@ -0,0 +1,213 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// API_KEY_PREFIX starts every API key, so that leaked keys are easy to spot.
+const API_KEY_PREFIX = "snx_"
+
+// API_KEY_SUBJECT_PREFIX starts subjects of principals of API keys, followed
+// by the key ID.
+const API_KEY_SUBJECT_PREFIX = "api_key:"
+
+// Number of random bytes of a key.
+const apiKeySize = 32
+
+// Number of characters of a key kept in the clear to tell keys apart.
+const apiKeyVisibleLength = len(API_KEY_PREFIX) + 8
+
+// CreateApiKey creates an API key of the tenant of the caller; only admins
+// may create keys.
+func (s *SleepDiaryService) CreateApiKey(caller api.Principal, dto api.CreateApiKeyDto) (api.ApiKeyDto, api.Error) {
+	if !caller.HasScope(api.SCOPE_ADMIN) {
+		return api.ApiKeyDto{}, api.NewError("admin scope required", api.ERR_FORBIDDEN)
+	}
+	errs := dto.Validate()
+	if len(errs) > 0 {
+		return api.ApiKeyDto{}, api.NewValidationError("invalid API key data", errs)
+	}
+
+	secret, keyHash, err := newApiKeySecret()
+	if err != nil {
+		log.Printf("Generating API key failed: %v\n", err)
+		return api.ApiKeyDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting insert transaction failed: %v\n", err)
+		return api.ApiKeyDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	key, err := insertApiKey(tx, ApiKey{
+		TenantId:    caller.Tenant(),
+		Name:        dto.Name,
+		Prefix:      secret[:apiKeyVisibleLength],
+		KeyHash:     keyHash,
+		Scopes:      dto.Scopes,
+		AccountUuid: sql.NullString{String: dto.AccountUuid, Valid: dto.AccountUuid != ""},
+		ExpiresAt:   toNullTime(dto.ExpiresAt),
+		CreatedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("Inserting API key %s failed: %v\n", dto.Name, err)
+		return api.ApiKeyDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing API key %s failed: %v\n", dto.Name, err)
+		return api.ApiKeyDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+
+	keyDto := toApiKeyDto(key)
+	keyDto.Key = secret
+	return keyDto, nil
+}
+
+func (s *SleepDiaryService) GetApiKeys(caller api.Principal) ([]api.ApiKeyDto, api.Error) {
+	if !caller.HasScope(api.SCOPE_ADMIN) {
+		return nil, api.NewError("admin scope required", api.ERR_FORBIDDEN)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	keys, err := getApiKeys(tx, caller.Tenant())
+	if err != nil {
+		log.Printf("Reading API keys failed: %v\n", err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	dtos := make([]api.ApiKeyDto, len(keys))
+	for i, key := range keys {
+		dtos[i] = toApiKeyDto(key)
+	}
+	return dtos, nil
+}
+
+// RotateApiKey replaces the key of an API key, keeping its name, scopes and
+// account. The previous key stops working immediately.
+func (s *SleepDiaryService) RotateApiKey(caller api.Principal, id int64) (api.ApiKeyDto, api.Error) {
+	if !caller.HasScope(api.SCOPE_ADMIN) {
+		return api.ApiKeyDto{}, api.NewError("admin scope required", api.ERR_FORBIDDEN)
+	}
+
+	secret, keyHash, err := newApiKeySecret()
+	if err != nil {
+		log.Printf("Generating API key failed: %v\n", err)
+		return api.ApiKeyDto{}, api.NewError("rotate failed", api.ERR_UNKNOWN)
+	}
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting rotate transaction failed: %v\n", err)
+		return api.ApiKeyDto{}, api.NewError("rotate failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	key, err := updateApiKeyHash(tx, caller.Tenant(), id, secret[:apiKeyVisibleLength], keyHash, time.Now().UTC())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return api.ApiKeyDto{}, api.NewError("API key not found", api.ERR_NOT_FOUND)
+		}
+		if err == ErrConflict {
+			return api.ApiKeyDto{}, api.NewError("revoked API keys cannot be rotated", api.ERR_CONFLICT)
+		}
+		log.Printf("Rotating API key %d failed: %v\n", id, err)
+		return api.ApiKeyDto{}, api.NewError("rotate failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing rotation of API key %d failed: %v\n", id, err)
+		return api.ApiKeyDto{}, api.NewError("rotate failed", api.ERR_UNKNOWN)
+	}
+
+	keyDto := toApiKeyDto(key)
+	keyDto.Key = secret
+	return keyDto, nil
+}
+
+// RevokeApiKey disables an API key for good; it is kept with RevokedAt set.
+func (s *SleepDiaryService) RevokeApiKey(caller api.Principal, id int64) api.Error {
+	if !caller.HasScope(api.SCOPE_ADMIN) {
+		return api.NewError("admin scope required", api.ERR_FORBIDDEN)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting revoke transaction failed: %v\n", err)
+		return api.NewError("revoke failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if err := revokeApiKey(tx, caller.Tenant(), id, time.Now().UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			return api.NewError("API key not found", api.ERR_NOT_FOUND)
+		}
+		log.Printf("Revoking API key %d failed: %v\n", id, err)
+		return api.NewError("revoke failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing revocation of API key %d failed: %v\n", id, err)
+		return api.NewError("revoke failed", api.ERR_UNKNOWN)
+	}
+	return nil
+}
+
+// AuthenticateApiKey returns the principal of an API key, failing with
+// ERR_UNAUTHORIZED for unknown, revoked and expired keys. Keys not restricted
+// to an account act on behalf of all accounts of their tenant within their
+// scopes; keys restricted to an account never act as admins.
+func (s *SleepDiaryService) AuthenticateApiKey(secret string) (api.Principal, api.Error) {
+	key, err := getActiveApiKeyByHash(s.db, hashApiKey(secret), time.Now().UTC())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return api.Principal{}, api.NewError("invalid API key", api.ERR_UNAUTHORIZED)
+		}
+		log.Printf("Reading API key failed: %v\n", err)
+		return api.Principal{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	scopes := key.Scopes
+	if key.AccountUuid.Valid {
+		// The admin scope would access all accounts, so it is dropped from
+		// keys restricted to an account, should one have been stored with it.
+		scopes = slices.DeleteFunc(slices.Clone(scopes), func(scope string) bool {
+			return scope == api.SCOPE_ADMIN
+		})
+	}
+	return api.Principal{
+		Subject:     fmt.Sprintf("%s%d", API_KEY_SUBJECT_PREFIX, key.Id),
+		TenantId:    key.TenantId,
+		AccountUuid: key.AccountUuid.String,
+		Scopes:      scopes,
+		AllAccounts: !key.AccountUuid.Valid,
+	}, nil
+}
+
+// newApiKeySecret returns a new random key along with its hash. Keys carry
+// enough entropy for a plain SHA-256 to protect them; slow password hashes
+// would only slow down every request.
+func newApiKeySecret() (string, []byte, error) {
+	random := make([]byte, apiKeySize)
+	if _, err := rand.Read(random); err != nil {
+		return "", nil, err
+	}
+	secret := API_KEY_PREFIX + base64.RawURLEncoding.EncodeToString(random)
+	return secret, hashApiKey(secret), nil
+}
+
+func hashApiKey(secret string) []byte {
+	hash := sha256.Sum256([]byte(secret))
+	return hash[:]
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ApiKey struct {
+	Id          int64
+	TenantId    string
+	Name        string
+	Prefix      string
+	KeyHash     []byte
+	Scopes      []string
+	AccountUuid sql.NullString
+	ExpiresAt   sql.NullTime
+	CreatedAt   time.Time
+	RotatedAt   sql.NullTime
+	RevokedAt   sql.NullTime
+}
+
+const apiKeyColumns = "id, tenant_id, name, prefix, key_hash, scopes, account_uuid, expires_at, created_at, rotated_at, revoked_at"
+
+func insertApiKey(db queryer, key ApiKey) (ApiKey, error) {
+	query := `
+		INSERT INTO api_keys (tenant_id, name, prefix, key_hash, scopes, account_uuid, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+	err := db.QueryRow(
+		query,
+		key.TenantId,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		pq.Array(key.Scopes),
+		key.AccountUuid,
+		key.ExpiresAt,
+		key.CreatedAt,
+	).Scan(&key.Id)
+	return key, err
+}
+
+// API keys are managed in tenant transactions, where row-level security
+// shows keys of the tenant only; functions filter by tenant as well.
+// getActiveApiKeyByHash alone runs outside a tenant transaction, since the
+// tenant is known only from the key it finds. It runs as the table owner,
+// which the policy of api_keys, enabled but not forced, does not apply to.
+
+func getApiKeys(db queryer, tenantId string) ([]ApiKey, error) {
+	return queryApiKeys(db, "SELECT "+apiKeyColumns+" FROM api_keys WHERE tenant_id = $1 ORDER BY id", tenantId)
+}
+
+func getApiKeysByAccount(db queryer, tenantId string, accountUuid string) ([]ApiKey, error) {
+	return queryApiKeys(db, "SELECT "+apiKeyColumns+" FROM api_keys WHERE tenant_id = $1 AND account_uuid = $2 ORDER BY id", tenantId, accountUuid)
+}
+
+func queryApiKeys(db queryer, query string, args ...any) ([]ApiKey, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []ApiKey{}
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func getApiKeyById(db queryer, tenantId string, id int64) (ApiKey, error) {
+	return scanApiKey(db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE tenant_id = $1 AND id = $2", tenantId, id))
+}
+
+// getActiveApiKeyByHash returns the key with given hash unless it is revoked
+// or expired at given time.
+func getActiveApiKeyByHash(db queryer, keyHash []byte, at time.Time) (ApiKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > $2)
+	`
+	return scanApiKey(db.QueryRow(query, keyHash, at))
+}
+
+// updateApiKeyHash replaces the key of an API key that is not revoked,
+// failing with ErrConflict for revoked ones.
+func updateApiKeyHash(db queryer, tenantId string, id int64, prefix string, keyHash []byte, rotatedAt time.Time) (ApiKey, error) {
+	query := `
+		UPDATE api_keys SET prefix = $3, key_hash = $4, rotated_at = $5
+		WHERE tenant_id = $1 AND id = $2 AND revoked_at IS NULL
+		RETURNING ` + apiKeyColumns
+	key, err := scanApiKey(db.QueryRow(query, tenantId, id, prefix, keyHash, rotatedAt))
+	if err == sql.ErrNoRows {
+		if _, err := getApiKeyById(db, tenantId, id); err != nil {
+			return ApiKey{}, err
+		}
+		return ApiKey{}, ErrConflict
+	}
+	return key, err
+}
+
+// revokeApiKey marks a key revoked; revoking it again keeps the time of the
+// first revocation.
+func revokeApiKey(db queryer, tenantId string, id int64, revokedAt time.Time) error {
+	query := "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $3) WHERE tenant_id = $1 AND id = $2"
+	result, err := db.Exec(query, tenantId, id, revokedAt)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanApiKey(row scanner) (ApiKey, error) {
+	var key ApiKey
+	err := row.Scan(
+		&key.Id,
+		&key.TenantId,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		pq.Array(&key.Scopes),
+		&key.AccountUuid,
+		&key.ExpiresAt,
+		&key.CreatedAt,
+		&key.RotatedAt,
+		&key.RevokedAt,
+	)
+	return key, err
+}
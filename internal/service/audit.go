@@ -0,0 +1,252 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+)
+
+// recordAudit appends an event of an operation of the caller on accounts and
+// entries to the audit log, in tx, the tenant transaction of the operation,
+// so that the event is recorded if and only if the operation is committed.
+// The event is added to the hash chain later by ChainAuditEvents, so that
+// operations of a tenant do not wait for one another to extend the chain.
+func recordAudit(tx queryer, caller api.Principal, action api.AuditAction, accountUuids []string, entryIds []int64) error {
+	event := AuditEvent{
+		Action:       action,
+		Subject:      caller.Subject,
+		AccountUuids: make([]string, len(accountUuids)),
+		EntryIds:     append([]int64{}, entryIds...),
+		TraceId:      caller.TraceId,
+		// Truncated to the precision of the database, so that the hash of
+		// the stored event is the same.
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	// Likewise, UUIDs are hashed in the form the database returns them in.
+	for i, accountUuid := range accountUuids {
+		event.AccountUuids[i] = strings.ToLower(accountUuid)
+		if parsed, err := uuid.Parse(accountUuid); err == nil {
+			event.AccountUuids[i] = parsed.String()
+		}
+	}
+
+	_, err := insertAuditEvent(tx, event)
+	return err
+}
+
+// ChainAuditEvents adds up to limit events recorded but not chained yet to
+// the hash chains of their tenants, in the order they were recorded, and
+// returns how many it chained. It runs as the owner of the database across
+// tenants; chainers running at once take turns.
+func (s *SleepDiaryService) ChainAuditEvents(limit int) (int, api.Error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Starting chain transaction failed: %v\n", err)
+		return 0, api.NewError("chain failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if err := lockAuditChain(tx); err != nil {
+		log.Printf("Locking audit chain failed: %v\n", err)
+		return 0, api.NewError("chain failed", api.ERR_UNKNOWN)
+	}
+	events, err := getUnchainedAuditEvents(tx, limit)
+	if err != nil {
+		log.Printf("Reading unchained audit events failed: %v\n", err)
+		return 0, api.NewError("chain failed", api.ERR_UNKNOWN)
+	}
+
+	heads := map[string]AuditEvent{}
+	for _, event := range events {
+		head, ok := heads[event.TenantId]
+		if !ok {
+			head, err = getLastChainedAuditEvent(tx, event.TenantId)
+			if err != nil {
+				log.Printf("Reading last chained audit event of tenant %s failed: %v\n", event.TenantId, err)
+				return 0, api.NewError("chain failed", api.ERR_UNKNOWN)
+			}
+		}
+		event.ChainSeq = sql.NullInt64{Int64: head.ChainSeq.Int64 + 1, Valid: true}
+		event.PrevHash = head.Hash
+		event.Hash = hashAuditEvent(event.TenantId, event)
+		if err := chainAuditEvent(tx, event); err != nil {
+			log.Printf("Chaining audit event %d failed: %v\n", event.Id, err)
+			return 0, api.NewError("chain failed", api.ERR_UNKNOWN)
+		}
+		heads[event.TenantId] = event
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing chain of audit events failed: %v\n", err)
+		return 0, api.NewError("chain failed", api.ERR_UNKNOWN)
+	}
+	return len(events), nil
+}
+
+// audit records an event in a transaction of its own, for operations
+// spanning several transactions.
+func (s *SleepDiaryService) audit(caller api.Principal, action api.AuditAction, accountUuids []string, entryIds []int64) error {
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := recordAudit(tx, caller, action, accountUuids, entryIds); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// hashAuditEvent returns SHA-256 of a "name: value" line for every field of
+// the event but its ID, along with the tenant and the hash of the previous
+// event.
+func hashAuditEvent(tenantId string, event AuditEvent) []byte {
+	entryIds := make([]string, len(event.EntryIds))
+	for i, id := range event.EntryIds {
+		entryIds[i] = fmt.Sprint(id)
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "tenant_id: %s\n", tenantId)
+	fmt.Fprintf(&content, "action: %s\n", event.Action)
+	fmt.Fprintf(&content, "subject: %s\n", event.Subject)
+	fmt.Fprintf(&content, "account_uuids: %s\n", strings.Join(event.AccountUuids, ","))
+	fmt.Fprintf(&content, "entry_ids: %s\n", strings.Join(entryIds, ","))
+	fmt.Fprintf(&content, "trace_id: %s\n", event.TraceId)
+	fmt.Fprintf(&content, "created_at: %s\n", event.CreatedAt.UTC().Format(time.RFC3339Nano))
+	fmt.Fprintf(&content, "prev_hash: %s\n", hex.EncodeToString(event.PrevHash))
+	hash := sha256.Sum256([]byte(content.String()))
+	return hash[:]
+}
+
+// GetAuditLog returns a page of events of the audit log of the tenant of the
+// caller matching the filter, the latest first. Only admins may read it; the
+// read is recorded in the log as well.
+func (s *SleepDiaryService) GetAuditLog(caller api.Principal, filter api.AuditLogFilterDto) (api.PageDto[api.AuditEventDto], api.Error) {
+	errs := filter.Validate()
+	if len(errs) > 0 {
+		return api.PageDto[api.AuditEventDto]{}, api.NewValidationError("invalid filter data", errs)
+	}
+	if !caller.HasScope(api.SCOPE_ADMIN) {
+		return api.PageDto[api.AuditEventDto]{}, api.NewError("audit log not accessible", api.ERR_FORBIDDEN)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return api.PageDto[api.AuditEventDto]{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	count, err := countAuditEventsByFilter(tx, filter)
+	if err != nil {
+		log.Printf("Counting audit events by filter %v failed: %v\n", filter, err)
+		return api.PageDto[api.AuditEventDto]{}, api.NewError("count failed", api.ERR_UNKNOWN)
+	}
+
+	events, err := getAuditEventsByFilter(tx, filter)
+	if err != nil {
+		log.Printf("Reading audit events by filter %v failed: %v\n", filter, err)
+		return api.PageDto[api.AuditEventDto]{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	accountUuids := []string{}
+	if filter.AccountUuid != "" {
+		accountUuids = append(accountUuids, filter.AccountUuid)
+	}
+	entryIds := []int64{}
+	if filter.EntryId != nil {
+		entryIds = append(entryIds, *filter.EntryId)
+	}
+	if err := recordAudit(tx, caller, api.ReadAuditLogAuditAction, accountUuids, entryIds); err != nil {
+		log.Printf("Recording read of audit log failed: %v\n", err)
+		return api.PageDto[api.AuditEventDto]{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing read of audit log failed: %v\n", err)
+		return api.PageDto[api.AuditEventDto]{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	items := make([]api.AuditEventDto, len(events))
+	for i, event := range events {
+		items[i] = toAuditEventDto(event)
+	}
+	return api.PageDto[api.AuditEventDto]{
+		TotalCount: count,
+		PageSize:   filter.PageSize,
+		PageNumber: filter.PageNumber,
+		Items:      items,
+	}, nil
+}
+
+// VerifyAuditLog walks the hash chain of the audit log of the tenant of the
+// caller, computing the hash of every event again, and reports the first
+// event that does not match, along with the number of events not chained
+// yet. Only admins may verify it.
+func (s *SleepDiaryService) VerifyAuditLog(caller api.Principal) (api.AuditLogVerificationDto, api.Error) {
+	if !caller.HasScope(api.SCOPE_ADMIN) {
+		return api.AuditLogVerificationDto{}, api.NewError("audit log not accessible", api.ERR_FORBIDDEN)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return api.AuditLogVerificationDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	pendingCount, err := countUnchainedAuditEvents(tx)
+	if err != nil {
+		log.Printf("Counting unchained audit events failed: %v\n", err)
+		return api.AuditLogVerificationDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	result := api.AuditLogVerificationDto{Valid: true, PendingCount: pendingCount}
+	var lastSeq int64
+	var prevHash []byte
+	for {
+		events, err := getAuditEventsAfter(tx, lastSeq, int(api.MAX_PAGE_SIZE))
+		if err != nil {
+			log.Printf("Reading audit events after %d failed: %v\n", lastSeq, err)
+			return api.AuditLogVerificationDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+		}
+		for _, event := range events {
+			result.EventsCount++
+			if event.ChainSeq.Int64 != lastSeq+1 ||
+				!bytes.Equal(event.PrevHash, prevHash) ||
+				!bytes.Equal(event.Hash, hashAuditEvent(caller.Tenant(), event)) {
+				result.Valid = false
+				result.BrokenAtId = &event.Id
+				return result, nil
+			}
+			lastSeq = event.ChainSeq.Int64
+			prevHash = event.Hash
+		}
+		if len(events) < int(api.MAX_PAGE_SIZE) {
+			return result, nil
+		}
+	}
+}
+
+func toAuditEventDto(event AuditEvent) api.AuditEventDto {
+	return api.AuditEventDto{
+		Id:           event.Id,
+		Action:       event.Action,
+		Subject:      event.Subject,
+		AccountUuids: event.AccountUuids,
+		EntryIds:     event.EntryIds,
+		TraceId:      event.TraceId,
+		CreatedAt:    event.CreatedAt,
+		PrevHash:     hex.EncodeToString(event.PrevHash),
+		Hash:         hex.EncodeToString(event.Hash),
+	}
+}
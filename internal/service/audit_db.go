@@ -0,0 +1,182 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mabzd/snorlax/api"
+)
+
+// AuditEvent is an event of the audit log of the tenant of the transaction it
+// is read or written in. ChainSeq, PrevHash and Hash are unset until the
+// event is chained.
+type AuditEvent struct {
+	Id           int64
+	TenantId     string
+	Action       api.AuditAction
+	Subject      string
+	AccountUuids []string
+	EntryIds     []int64
+	TraceId      string
+	CreatedAt    time.Time
+	ChainSeq     sql.NullInt64
+	PrevHash     []byte
+	Hash         []byte
+}
+
+const auditEventColumns = "id, tenant_id, action, subject, account_uuids, entry_ids, trace_id, created_at, chain_seq, prev_hash, hash"
+
+// insertAuditEvent inserts an event to be chained later.
+func insertAuditEvent(db queryer, event AuditEvent) (AuditEvent, error) {
+	query := `
+		INSERT INTO audit_log (action, subject, account_uuids, entry_ids, trace_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	err := db.QueryRow(
+		query,
+		event.Action,
+		event.Subject,
+		pq.Array(event.AccountUuids),
+		pq.Array(event.EntryIds),
+		event.TraceId,
+		event.CreatedAt,
+	).Scan(&event.Id)
+	return event, err
+}
+
+// lockAuditChain takes a lock held until the end of the transaction, so that
+// events are chained by one chainer at a time.
+func lockAuditChain(db queryer) error {
+	_, err := db.Exec("SELECT pg_advisory_xact_lock(hashtextextended('snorlax.audit_log.chain', 0))")
+	return err
+}
+
+// getUnchainedAuditEvents returns up to limit events of all tenants not
+// chained yet, in order of their IDs.
+func getUnchainedAuditEvents(db queryer, limit int) ([]AuditEvent, error) {
+	query := fmt.Sprintf("SELECT %s FROM audit_log WHERE chain_seq IS NULL ORDER BY id LIMIT $1", auditEventColumns)
+	return queryAuditEvents(db, query, limit)
+}
+
+// getLastChainedAuditEvent returns the last chained event of a tenant, or
+// an event with unset ChainSeq and hashes if none is chained yet.
+func getLastChainedAuditEvent(db queryer, tenantId string) (AuditEvent, error) {
+	query := fmt.Sprintf("SELECT %s FROM audit_log WHERE tenant_id = $1 AND chain_seq IS NOT NULL ORDER BY chain_seq DESC LIMIT 1", auditEventColumns)
+	events, err := queryAuditEvents(db, query, tenantId)
+	if err != nil || len(events) == 0 {
+		return AuditEvent{TenantId: tenantId}, err
+	}
+	return events[0], nil
+}
+
+func chainAuditEvent(db queryer, event AuditEvent) error {
+	_, err := db.Exec(
+		"UPDATE audit_log SET chain_seq = $2, prev_hash = $3, hash = $4 WHERE id = $1",
+		event.Id,
+		event.ChainSeq,
+		event.PrevHash,
+		event.Hash,
+	)
+	return err
+}
+
+func countUnchainedAuditEvents(db queryer) (int64, error) {
+	var count int64
+	err := db.QueryRow("SELECT count(*) FROM audit_log WHERE chain_seq IS NULL").Scan(&count)
+	return count, err
+}
+
+func countAuditEventsByFilter(db queryer, filter api.AuditLogFilterDto) (int64, error) {
+	whereClause, args := buildAuditWhereClause(filter)
+	query := fmt.Sprintf("SELECT count(*) FROM audit_log %s", whereClause)
+	var count int64
+	err := db.QueryRow(query, args...).Scan(&count)
+	return count, err
+}
+
+// getAuditEventsByFilter returns a page of events matching the filter, the
+// latest first.
+func getAuditEventsByFilter(db queryer, filter api.AuditLogFilterDto) ([]AuditEvent, error) {
+	whereClause, args := buildAuditWhereClause(filter)
+	query := fmt.Sprintf(
+		"SELECT %s FROM audit_log %s ORDER BY id DESC LIMIT %d OFFSET %d",
+		auditEventColumns,
+		whereClause,
+		filter.PageSize,
+		(filter.PageNumber-1)*filter.PageSize)
+	return queryAuditEvents(db, query, args...)
+}
+
+// getAuditEventsAfter returns up to limit chained events following the event
+// at given position of the hash chain, in the order of the chain.
+func getAuditEventsAfter(db queryer, afterSeq int64, limit int) ([]AuditEvent, error) {
+	query := fmt.Sprintf("SELECT %s FROM audit_log WHERE chain_seq > $1 ORDER BY chain_seq LIMIT $2", auditEventColumns)
+	return queryAuditEvents(db, query, afterSeq, limit)
+}
+
+func queryAuditEvents(db queryer, query string, args ...any) ([]AuditEvent, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var event AuditEvent
+		err := rows.Scan(
+			&event.Id,
+			&event.TenantId,
+			&event.Action,
+			&event.Subject,
+			pq.Array(&event.AccountUuids),
+			pq.Array(&event.EntryIds),
+			&event.TraceId,
+			&event.CreatedAt,
+			&event.ChainSeq,
+			&event.PrevHash,
+			&event.Hash,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func buildAuditWhereClause(filter api.AuditLogFilterDto) (string, []any) {
+	whereClauses := []string{"TRUE"}
+	args := []any{}
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		whereClauses = append(whereClauses, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Subject != "" {
+		add("subject = $%d", filter.Subject)
+	}
+	if filter.AccountUuid != "" {
+		add("account_uuids @> ARRAY[$%d::uuid]", filter.AccountUuid)
+	}
+	if filter.EntryId != nil {
+		add("entry_ids @> ARRAY[$%d::bigint]", *filter.EntryId)
+	}
+	if filter.Action != nil {
+		add("action = $%d", *filter.Action)
+	}
+	if filter.TraceId != "" {
+		add("trace_id = $%d", filter.TraceId)
+	}
+	if filter.FromDate != nil {
+		add("created_at >= $%d", *filter.FromDate)
+	}
+	if filter.ToDate != nil {
+		add("created_at < $%d", *filter.ToDate)
+	}
+	return "WHERE " + strings.Join(whereClauses, " AND "), args
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mabzd/snorlax/api"
+)
+
+// Maximum number of changes read at once.
+const MAX_CHANGES_BATCH = 100
+
+// recordEntryChange records a change of an entry in the change log, queues
+// it in the outbox and for delivery to subscribed webhooks, all in the
+// transaction of the change itself. Queued payloads are stored in plain
+// text, so they leave out comments, which are encrypted at rest.
+func recordEntryChange(tx queryer, entry SleepDiaryEntry, changeType api.ChangeType) error {
+	change, err := insertSleepDiaryEntryChange(tx, entry, changeType)
+	if err != nil {
+		return err
+	}
+
+	var entryPtr *SleepDiaryEntry
+	if changeType != api.CHANGE_DELETED {
+		entry.Comments = sql.NullString{}
+		entryPtr = &entry
+	}
+	dto, err := toEntryChangeDto(change, entryPtr)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(dto)
+	if err != nil {
+		return err
+	}
+	if err := insertOutboxEvent(tx, change, payload); err != nil {
+		return err
+	}
+	return insertWebhookDeliveries(tx, change, payload)
+}
+
+// GetEntryChanges returns up to MAX_CHANGES_BATCH changes of an account made
+// after the change with given ID.
+func (s *SleepDiaryService) GetEntryChanges(caller api.Principal, accountUuid string, afterId int64) ([]api.EntryChangeDto, api.Error) {
+	if err := api.ValidateAccountUuid(accountUuid); err != nil {
+		return nil, api.NewValidationError("invalid account", []error{err})
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if serviceErr := s.checkAccountAccess(tx, caller, accountUuid, readAccess); serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	changes, entries, err := getSleepDiaryEntryChanges(tx, s.commentKeys(tx), accountUuid, afterId, MAX_CHANGES_BATCH)
+	if err != nil {
+		log.Printf("Reading changes of account %s failed: %v\n", accountUuid, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	// Polls without changes, which streams make on every keepalive, disclose
+	// no data and are not recorded.
+	if len(changes) > 0 {
+		if err := recordAudit(tx, caller, api.ListChangesAuditAction, []string{accountUuid}, changedEntryIds(changes)); err != nil {
+			log.Printf("Recording read of changes of account %s failed: %v\n", accountUuid, err)
+			return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing read of changes of account %s failed: %v\n", accountUuid, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	dtos := make([]api.EntryChangeDto, len(changes))
+	for i, change := range changes {
+		dto, err := toEntryChangeDto(change, entries[i])
+		if err != nil {
+			log.Printf("Converting change %d to DTO failed: %v\n", change.Id, err)
+			return nil, api.NewError("conversion failed", api.ERR_UNKNOWN)
+		}
+		dtos[i] = dto
+	}
+	return dtos, nil
+}
+
+// changedEntryIds returns IDs of entries changed by changes, each once.
+func changedEntryIds(changes []SleepDiaryEntryChange) []int64 {
+	entryIds := []int64{}
+	for _, change := range changes {
+		if !slices.Contains(entryIds, change.EntryId) {
+			entryIds = append(entryIds, change.EntryId)
+		}
+	}
+	return entryIds
+}
+
+// GetLatestEntryChangeId returns ID of the last change of an account, or 0
+// if there was none.
+func (s *SleepDiaryService) GetLatestEntryChangeId(caller api.Principal, accountUuid string) (int64, api.Error) {
+	if err := api.ValidateAccountUuid(accountUuid); err != nil {
+		return 0, api.NewValidationError("invalid account", []error{err})
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return 0, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if serviceErr := s.checkAccountAccess(tx, caller, accountUuid, readAccess); serviceErr != nil {
+		return 0, serviceErr
+	}
+
+	id, err := getLatestSleepDiaryEntryChangeId(tx, accountUuid)
+	if err != nil {
+		log.Printf("Reading latest change of account %s failed: %v\n", accountUuid, err)
+		return 0, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	return id, nil
+}
+
+// SubscribeEntryChanges returns a channel signalled after changes of an
+// account are committed, possibly by another process. Signals carry no data
+// and may be coalesced or spurious; subscribers read changes with
+// GetEntryChanges. The returned function cancels the subscription.
+func (s *SleepDiaryService) SubscribeEntryChanges(caller api.Principal, accountUuid string) (<-chan struct{}, func(), api.Error) {
+	if err := api.ValidateAccountUuid(accountUuid); err != nil {
+		return nil, nil, api.NewValidationError("invalid account", []error{err})
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return nil, nil, api.NewError("subscribe failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if serviceErr := s.checkAccountAccess(tx, caller, accountUuid, readAccess); serviceErr != nil {
+		return nil, nil, serviceErr
+	}
+
+	signal, cancel, err := s.changes.subscribe(accountUuid)
+	if err != nil {
+		log.Printf("Listening to changes failed: %v\n", err)
+		return nil, nil, api.NewError("subscribe failed", api.ERR_UNKNOWN)
+	}
+	return signal, cancel, nil
+}
+
+// changeNotifier fans out notifications of ENTRY_CHANGES_CHANNEL to
+// subscribers of accounts. The database connection is opened with the first
+// subscription and kept for the lifetime of the process.
+type changeNotifier struct {
+	connStr     string
+	mu          sync.Mutex
+	listener    *pq.Listener
+	subscribers map[string]map[chan struct{}]struct{}
+}
+
+func newChangeNotifier(connStr string) *changeNotifier {
+	return &changeNotifier{
+		connStr:     connStr,
+		subscribers: map[string]map[chan struct{}]struct{}{},
+	}
+}
+
+func (n *changeNotifier) subscribe(accountUuid string) (<-chan struct{}, func(), error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.listener == nil {
+		listener := pq.NewListener(n.connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				log.Printf("Change listener event %d: %v\n", event, err)
+			}
+		})
+		if err := listener.Listen(ENTRY_CHANGES_CHANNEL); err != nil {
+			listener.Close()
+			return nil, nil, err
+		}
+		n.listener = listener
+		go n.dispatch(listener)
+	}
+
+	signal := make(chan struct{}, 1)
+	if n.subscribers[accountUuid] == nil {
+		n.subscribers[accountUuid] = map[chan struct{}]struct{}{}
+	}
+	n.subscribers[accountUuid][signal] = struct{}{}
+
+	cancel := func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.subscribers[accountUuid], signal)
+		if len(n.subscribers[accountUuid]) == 0 {
+			delete(n.subscribers, accountUuid)
+		}
+	}
+	return signal, cancel, nil
+}
+
+func (n *changeNotifier) dispatch(listener *pq.Listener) {
+	for notification := range listener.Notify {
+		n.mu.Lock()
+		if notification == nil {
+			// Notifications may have been lost while reconnecting; let every
+			// subscriber catch up from the change log.
+			for _, signals := range n.subscribers {
+				notifyAll(signals)
+			}
+		} else {
+			notifyAll(n.subscribers[notification.Extra])
+		}
+		n.mu.Unlock()
+	}
+}
+
+func notifyAll(signals map[chan struct{}]struct{}) {
+	for signal := range signals {
+		select {
+		case signal <- struct{}{}:
+		default:
+		}
+	}
+}
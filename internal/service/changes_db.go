@@ -0,0 +1,176 @@
+package service
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// Postgres channel notified with the account UUID of every committed change.
+const ENTRY_CHANGES_CHANNEL = "sleep_diary_entry_changes"
+
+// SleepDiaryEntryChange is a record of the change log. EntryUuid is missing
+// only in changes of entries deleted before entries had UUIDs.
+type SleepDiaryEntryChange struct {
+	Id          int64
+	EntryId     int64
+	EntryUuid   sql.NullString
+	AccountUuid string
+	ChangeType  api.ChangeType
+	Version     int64
+	ChangedAt   time.Time
+}
+
+// insertSleepDiaryEntryChange records a change and notifies listeners; it is
+// meant to run in the transaction of the change itself, so the notification
+// is delivered only once the change is committed.
+func insertSleepDiaryEntryChange(db queryer, entry SleepDiaryEntry, changeType api.ChangeType) (SleepDiaryEntryChange, error) {
+	change := SleepDiaryEntryChange{
+		EntryId:     entry.Id,
+		EntryUuid:   sql.NullString{String: entry.Uuid, Valid: entry.Uuid != ""},
+		AccountUuid: entry.AccountUuid,
+		ChangeType:  changeType,
+		Version:     entry.Version.Int64,
+		ChangedAt:   time.Now().UTC(),
+	}
+	query := `
+		INSERT INTO sleep_diary_entry_changes (entry_id, entry_uuid, account_uuid, change_type, version, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	err := db.QueryRow(query, change.EntryId, change.EntryUuid, change.AccountUuid, change.ChangeType, change.Version, change.ChangedAt).Scan(&change.Id)
+	if err != nil {
+		return SleepDiaryEntryChange{}, err
+	}
+
+	_, err = db.Exec("SELECT pg_notify($1, $2)", ENTRY_CHANGES_CHANNEL, entry.AccountUuid)
+	return change, err
+}
+
+// getSleepDiaryEntryChanges returns changes of an account after given change
+// ID in order, along with the current state of still existing entries.
+func getSleepDiaryEntryChanges(db queryer, keys *commentKeys, accountUuid string, afterId int64, limit int) ([]SleepDiaryEntryChange, []*SleepDiaryEntry, error) {
+	query := `
+		SELECT
+			c.id, c.entry_id, c.entry_uuid, c.account_uuid, c.change_type, c.version, c.changed_at,
+			e.id, e.uuid, e.account_uuid, e.timezone, e.in_bed_at, e.tried_to_sleep_at,
+			e.sleep_delay_in_min, e.awakenings_count, e.awakenings_total_duration_in_min,
+			e.final_wake_up_at, e.out_of_bed_at, e.sleep_quality, e.comments,
+			e.created_at, e.updated_at, e.version, e.comments_key_id
+		FROM sleep_diary_entry_changes c
+		LEFT JOIN sleep_diary_entries e ON e.id = c.entry_id
+		WHERE c.account_uuid = $1 AND c.id > $2
+		ORDER BY c.id
+		LIMIT $3
+	`
+	rows, err := db.Query(query, accountUuid, afterId, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	changes := []SleepDiaryEntryChange{}
+	entries := []*SleepDiaryEntry{}
+	for rows.Next() {
+		var change SleepDiaryEntryChange
+		var entry nullableSleepDiaryEntry
+		err := rows.Scan(
+			&change.Id,
+			&change.EntryId,
+			&change.EntryUuid,
+			&change.AccountUuid,
+			&change.ChangeType,
+			&change.Version,
+			&change.ChangedAt,
+			&entry.Id,
+			&entry.Uuid,
+			&entry.AccountUuid,
+			&entry.Timezone,
+			&entry.InBedAt,
+			&entry.TriedToSleepAt,
+			&entry.SleepDelayInMin,
+			&entry.AwakeningsCount,
+			&entry.AwakeningsTotalDurationInMin,
+			&entry.FinalWakeUpAt,
+			&entry.OutOfBedAt,
+			&entry.SleepQuality,
+			&entry.Comments,
+			&entry.CreatedAt,
+			&entry.UpdatedAt,
+			&entry.Version,
+			&entry.CommentsKeyId,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		changes = append(changes, change)
+		entries = append(entries, entry.toEntry())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		if err := keys.decryptEntry(entry); err != nil {
+			return nil, nil, err
+		}
+	}
+	return changes, entries, nil
+}
+
+func getLatestSleepDiaryEntryChangeId(db queryer, accountUuid string) (int64, error) {
+	query := "SELECT COALESCE(MAX(id), 0) FROM sleep_diary_entry_changes WHERE account_uuid = $1"
+	var id int64
+	err := db.QueryRow(query, accountUuid).Scan(&id)
+	return id, err
+}
+
+// nullableSleepDiaryEntry scans an outer-joined entry.
+type nullableSleepDiaryEntry struct {
+	Id                           sql.NullInt64
+	Uuid                         sql.NullString
+	AccountUuid                  sql.NullString
+	Timezone                     sql.NullString
+	InBedAt                      sql.NullTime
+	TriedToSleepAt               sql.NullTime
+	SleepDelayInMin              sql.NullInt32
+	AwakeningsCount              sql.NullInt32
+	AwakeningsTotalDurationInMin sql.NullInt32
+	FinalWakeUpAt                sql.NullTime
+	OutOfBedAt                   sql.NullTime
+	SleepQuality                 sql.NullInt32
+	Comments                     sql.NullString
+	CreatedAt                    sql.NullTime
+	UpdatedAt                    sql.NullTime
+	Version                      sql.NullInt64
+	CommentsKeyId                sql.NullInt64
+}
+
+func (e nullableSleepDiaryEntry) toEntry() *SleepDiaryEntry {
+	if !e.Id.Valid {
+		return nil
+	}
+	return &SleepDiaryEntry{
+		Id:                           e.Id.Int64,
+		Uuid:                         e.Uuid.String,
+		AccountUuid:                  e.AccountUuid.String,
+		Timezone:                     e.Timezone.String,
+		InBedAt:                      e.InBedAt,
+		TriedToSleepAt:               e.TriedToSleepAt.Time,
+		SleepDelayInMin:              e.SleepDelayInMin,
+		AwakeningsCount:              e.AwakeningsCount,
+		AwakeningsTotalDurationInMin: e.AwakeningsTotalDurationInMin,
+		FinalWakeUpAt:                e.FinalWakeUpAt.Time,
+		OutOfBedAt:                   e.OutOfBedAt,
+		SleepQuality:                 api.SleepQuality(e.SleepQuality.Int32),
+		Comments:                     e.Comments,
+		CreatedAt:                    e.CreatedAt.Time,
+		UpdatedAt:                    e.UpdatedAt.Time,
+		Version:                      e.Version,
+		CommentsKeyId:                e.CommentsKeyId,
+	}
+}
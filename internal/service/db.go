@@ -5,15 +5,33 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/mabzd/snorlax/api"
 )
 
 var ErrConflict = errors.New("sql: conflict")
 
-func getSleepDiaryEntryById(db *sql.DB, id int64) (SleepDiaryEntry, error) {
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Columns of sleep_diary_entries in the order of SleepDiaryEntry fields.
+const entryColumns = `
+	id, account_uuid, timezone, in_bed_at, tried_to_sleep_at, sleep_delay_in_min,
+	awakenings_count, awakenings_total_duration_in_min, final_wake_up_at,
+	out_of_bed_at, sleep_quality, comments, created_at, updated_at, version, uuid,
+	comments_key_id`
+
+// Functions reading or writing comments of entries and drafts decrypt and
+// encrypt them with keys, the comment keys of the transaction db.
+
+func getSleepDiaryEntryById(db queryer, keys *commentKeys, id int64) (SleepDiaryEntry, error) {
 	query := `
-		SELECT *
+		SELECT ` + entryColumns + `
 		FROM sleep_diary_entries
 		WHERE id = $1
 	`
@@ -36,16 +54,22 @@ func getSleepDiaryEntryById(db *sql.DB, id int64) (SleepDiaryEntry, error) {
 		&entry.CreatedAt,
 		&entry.UpdatedAt,
 		&entry.Version,
+		&entry.Uuid,
+		&entry.CommentsKeyId,
 	)
-	return entry, err
+	if err != nil {
+		return SleepDiaryEntry{}, err
+	}
+	return entry, keys.decryptEntry(&entry)
 }
 
-func getSleepDiaryEntriesByFilter(db *sql.DB, filter api.SleepDiaryFilterDto) ([]SleepDiaryEntry, error) {
+func getSleepDiaryEntriesByFilter(db queryer, keys *commentKeys, filter api.SleepDiaryFilterDto) ([]SleepDiaryEntry, error) {
 	whereClause, args := buildWhereClause(filter)
 	limitClause := buildLimitClause(filter)
 
 	query := fmt.Sprintf(
-		"SELECT * FROM sleep_diary_entries %s ORDER BY tried_to_sleep_at %s",
+		"SELECT %s FROM sleep_diary_entries %s ORDER BY tried_to_sleep_at %s",
+		entryColumns,
 		whereClause,
 		limitClause)
 
@@ -76,17 +100,27 @@ func getSleepDiaryEntriesByFilter(db *sql.DB, filter api.SleepDiaryFilterDto) ([
 			&entry.CreatedAt,
 			&entry.UpdatedAt,
 			&entry.Version,
+			&entry.Uuid,
+			&entry.CommentsKeyId,
 		)
 		if err != nil {
 			return nil, err
 		}
 		entries = append(entries, entry)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return entries, rows.Err()
+	for i := range entries {
+		if err := keys.decryptEntry(&entries[i]); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
 }
 
-func countSleepDiaryEntriesByFilter(db *sql.DB, filter api.SleepDiaryFilterDto) (int64, error) {
+func countSleepDiaryEntriesByFilter(db queryer, filter api.SleepDiaryFilterDto) (int64, error) {
 	whereClause, args := buildWhereClause(filter)
 	query := fmt.Sprintf("SELECT count(*) FROM sleep_diary_entries %s", whereClause)
 	row := db.QueryRow(query, args...)
@@ -136,7 +170,27 @@ func buildLimitClause(filter api.SleepDiaryFilterDto) string {
 		(filter.PageNumber-1)*filter.PageSize)
 }
 
-func insertSleepDiaryEntry(db *sql.DB, entry SleepDiaryEntry) (SleepDiaryEntry, error) {
+// getOverlappingSleepDiaryEntryId returns ID of the earliest entry of an
+// account whose sleep period overlaps given one.
+func getOverlappingSleepDiaryEntryId(db queryer, accountUuid string, from time.Time, to time.Time) (int64, error) {
+	query := `
+		SELECT id
+		FROM sleep_diary_entries
+		WHERE account_uuid = $1 AND tried_to_sleep_at < $3 AND final_wake_up_at > $2
+		ORDER BY tried_to_sleep_at
+		LIMIT 1
+	`
+	var id int64
+	err := db.QueryRow(query, accountUuid, from, to).Scan(&id)
+	return id, err
+}
+
+func insertSleepDiaryEntry(db queryer, keys *commentKeys, entry SleepDiaryEntry) (SleepDiaryEntry, error) {
+	comments, commentsKeyId, err := keys.encrypt(entry.Comments)
+	if err != nil {
+		return SleepDiaryEntry{}, err
+	}
+
 	query := `
 		INSERT INTO sleep_diary_entries (
 			account_uuid,
@@ -152,16 +206,18 @@ func insertSleepDiaryEntry(db *sql.DB, entry SleepDiaryEntry) (SleepDiaryEntry,
 			comments, 
 			created_at, 
 			updated_at, 
-			version
+			version,
+			uuid,
+			comments_key_id
 		)
 		VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
+			COALESCE(NULLIF($15, '')::uuid, gen_random_uuid()), $16
 		)
-		RETURNING id
+		RETURNING id, uuid
 	`
 
-	var id int64
-	err := db.QueryRow(
+	err = db.QueryRow(
 		query,
 		entry.AccountUuid,
 		entry.Timezone,
@@ -173,27 +229,27 @@ func insertSleepDiaryEntry(db *sql.DB, entry SleepDiaryEntry) (SleepDiaryEntry,
 		entry.FinalWakeUpAt,
 		entry.OutOfBedAt,
 		entry.SleepQuality,
-		entry.Comments,
+		comments,
 		entry.CreatedAt,
 		entry.UpdatedAt,
 		entry.Version,
-	).Scan(&id)
+		entry.Uuid,
+		commentsKeyId,
+	).Scan(&entry.Id, &entry.Uuid)
 	if err != nil {
 		return SleepDiaryEntry{}, err
 	}
-
-	entry.Id = id
 	return entry, nil
 }
 
-func updateSleepDiaryEntry(db *sql.DB, entry SleepDiaryEntry) (SleepDiaryEntry, error) {
-	tx, err := db.Begin()
+// updateSleepDiaryEntryInTx updates an entry and records the change. When
+// entry.Version is set, the update fails with ErrConflict unless it is the
+// current version; the caller must roll back tx then.
+func updateSleepDiaryEntryInTx(tx queryer, keys *commentKeys, entry SleepDiaryEntry) (SleepDiaryEntry, error) {
+	comments, commentsKeyId, err := keys.encrypt(entry.Comments)
 	if err != nil {
 		return SleepDiaryEntry{}, err
 	}
-	defer func() {
-		tx.Rollback()
-	}()
 
 	query := `
 		UPDATE sleep_diary_entries
@@ -208,13 +264,15 @@ func updateSleepDiaryEntry(db *sql.DB, entry SleepDiaryEntry) (SleepDiaryEntry,
 			out_of_bed_at = $8,
 			sleep_quality = $9,
 			comments = $10,
-			updated_at = $11,
+			comments_key_id = $11,
+			updated_at = $12,
 			version = version + 1
-		WHERE id = $12
-		RETURNING version, account_uuid
+		WHERE id = $13
+		RETURNING version, account_uuid, uuid
 	`
 	var newVersion int64
 	var accountUuid string
+	var entryUuid string
 	err = tx.QueryRow(
 		query,
 		entry.Timezone,
@@ -226,10 +284,11 @@ func updateSleepDiaryEntry(db *sql.DB, entry SleepDiaryEntry) (SleepDiaryEntry,
 		entry.FinalWakeUpAt,
 		entry.OutOfBedAt,
 		entry.SleepQuality,
-		entry.Comments,
+		comments,
+		commentsKeyId,
 		entry.UpdatedAt,
 		entry.Id,
-	).Scan(&newVersion, &accountUuid)
+	).Scan(&newVersion, &accountUuid, &entryUuid)
 	if err != nil {
 		return SleepDiaryEntry{}, err
 	}
@@ -240,5 +299,26 @@ func updateSleepDiaryEntry(db *sql.DB, entry SleepDiaryEntry) (SleepDiaryEntry,
 
 	entry.Version = sql.NullInt64{Int64: newVersion, Valid: true}
 	entry.AccountUuid = accountUuid
-	return entry, tx.Commit()
+	entry.Uuid = entryUuid
+	if err := recordEntryChange(tx, entry, api.CHANGE_UPDATED); err != nil {
+		return SleepDiaryEntry{}, err
+	}
+	return entry, nil
+}
+
+func deleteSleepDiaryEntry(db queryer, id int64) (SleepDiaryEntry, error) {
+	query := `
+		DELETE FROM sleep_diary_entries
+		WHERE id = $1
+		RETURNING uuid, account_uuid, version
+	`
+	entry := SleepDiaryEntry{Id: id}
+	err := db.QueryRow(query, id).Scan(&entry.Uuid, &entry.AccountUuid, &entry.Version)
+	return entry, err
+}
+
+func getSleepDiaryEntryAccountUuid(db queryer, id int64) (string, error) {
+	var accountUuid string
+	err := db.QueryRow("SELECT account_uuid FROM sleep_diary_entries WHERE id = $1", id).Scan(&accountUuid)
+	return accountUuid, err
 }
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"database/sql"
+	"log"
+	"slices"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// ImportDrafts stores imported entries as drafts. Imported data usually lacks
+// the self-rated sleep quality, so drafts have to be confirmed by the user
+// before they become diary entries. Entries overlapping an existing diary
+// entry are skipped, entries overlapping an existing draft are merged into it.
+func (s *SleepDiaryService) ImportDrafts(caller api.Principal, dto api.ImportDto) (api.ImportResultDto, api.Error) {
+	errs := dto.Validate()
+	if len(errs) > 0 {
+		return api.ImportResultDto{}, api.NewValidationError("invalid import data", errs)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting import transaction failed: %v\n", err)
+		return api.ImportResultDto{}, api.NewError("import failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	// Validation ties entries to the import account; every account is
+	// checked anyway, so that no entry is written to another account.
+	accountUuids := []string{dto.AccountUuid}
+	for _, entryDto := range dto.Entries {
+		if !slices.Contains(accountUuids, entryDto.AccountUuid) {
+			accountUuids = append(accountUuids, entryDto.AccountUuid)
+		}
+	}
+	for _, accountUuid := range accountUuids {
+		if serviceErr := s.checkAccountAccess(tx, caller, accountUuid, writeAccess); serviceErr != nil {
+			return api.ImportResultDto{}, serviceErr
+		}
+	}
+
+	result := api.ImportResultDto{
+		Source:  dto.Source,
+		Records: make([]api.ImportRecordDto, 0, len(dto.Entries)),
+	}
+	drafts := map[int64]SleepDiaryDraft{}
+	draftIds := []int64{}
+	keys := s.commentKeys(tx)
+
+	for i, entryDto := range dto.Entries {
+		imported := fromImportedSleepDiaryEntryDto(entryDto, dto.Source)
+		record := api.ImportRecordDto{Index: i}
+
+		entryId, err := getOverlappingSleepDiaryEntryId(tx, imported.AccountUuid, imported.TriedToSleepAt, imported.FinalWakeUpAt)
+		if err == nil {
+			record.Status = api.SkippedImportRecordStatus
+			record.EntryId = &entryId
+			result.SkippedCount++
+			result.Records = append(result.Records, record)
+			continue
+		}
+		if err != sql.ErrNoRows {
+			log.Printf("Looking up entries overlapping %v failed: %v\n", entryDto, err)
+			return api.ImportResultDto{}, api.NewError("import failed", api.ERR_UNKNOWN)
+		}
+
+		draft, err := getOverlappingSleepDiaryDraft(tx, keys, imported.AccountUuid, imported.TriedToSleepAt, imported.FinalWakeUpAt)
+		switch err {
+		case nil:
+			mergeSleepDiaryDraft(&draft, imported)
+			if err := updateSleepDiaryDraft(tx, keys, draft); err != nil {
+				log.Printf("Merging %v into draft %d failed: %v\n", entryDto, draft.Id, err)
+				return api.ImportResultDto{}, api.NewError("import failed", api.ERR_UNKNOWN)
+			}
+			record.Status = api.MergedImportRecordStatus
+			result.MergedCount++
+		case sql.ErrNoRows:
+			draft, err = insertSleepDiaryDraft(tx, keys, imported)
+			if err != nil {
+				log.Printf("Inserting draft %v failed: %v\n", entryDto, err)
+				return api.ImportResultDto{}, api.NewError("import failed", api.ERR_UNKNOWN)
+			}
+			record.Status = api.CreatedImportRecordStatus
+			result.CreatedCount++
+		default:
+			log.Printf("Looking up drafts overlapping %v failed: %v\n", entryDto, err)
+			return api.ImportResultDto{}, api.NewError("import failed", api.ERR_UNKNOWN)
+		}
+
+		if _, ok := drafts[draft.Id]; !ok {
+			draftIds = append(draftIds, draft.Id)
+		}
+		drafts[draft.Id] = draft
+		record.DraftId = &draft.Id
+		result.Records = append(result.Records, record)
+	}
+
+	if err := recordAudit(tx, caller, api.ImportDraftsAuditAction, accountUuids, []int64{}); err != nil {
+		log.Printf("Recording import failed: %v\n", err)
+		return api.ImportResultDto{}, api.NewError("import failed", api.ERR_UNKNOWN)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing import failed: %v\n", err)
+		return api.ImportResultDto{}, api.NewError("import failed", api.ERR_UNKNOWN)
+	}
+
+	result.Drafts = make([]api.SleepDiaryDraftDto, len(draftIds))
+	for i, id := range draftIds {
+		draftDto, err := toSleepDiaryDraftDto(drafts[id])
+		if err != nil {
+			log.Printf("Converting draft %d to DTO failed: %v\n", id, err)
+			return api.ImportResultDto{}, api.NewError("conversion failed", api.ERR_UNKNOWN)
+		}
+		result.Drafts[i] = draftDto
+	}
+
+	return result, nil
+}
+
+func (s *SleepDiaryService) GetDraftsByAccount(caller api.Principal, accountUuid string) ([]api.SleepDiaryDraftDto, api.Error) {
+	if err := api.ValidateAccountUuid(accountUuid); err != nil {
+		return nil, api.NewValidationError("invalid account", []error{err})
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if serviceErr := s.checkAccountAccess(tx, caller, accountUuid, readAccess); serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	drafts, err := getSleepDiaryDraftsByAccount(tx, s.commentKeys(tx), accountUuid)
+	if err != nil {
+		log.Printf("Reading drafts of account %s failed: %v\n", accountUuid, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	if err := recordAudit(tx, caller, api.ListDraftsAuditAction, []string{accountUuid}, []int64{}); err != nil {
+		log.Printf("Recording read of drafts of account %s failed: %v\n", accountUuid, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing read of drafts of account %s failed: %v\n", accountUuid, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	dtos := make([]api.SleepDiaryDraftDto, len(drafts))
+	for i, draft := range drafts {
+		dto, err := toSleepDiaryDraftDto(draft)
+		if err != nil {
+			log.Printf("Converting draft %d to DTO failed: %v\n", draft.Id, err)
+			return nil, api.NewError("conversion failed", api.ERR_UNKNOWN)
+		}
+		dtos[i] = dto
+	}
+
+	return dtos, nil
+}
+
+// ConfirmDraft turns a draft into a diary entry using the sleep quality (and
+// optionally comments) provided by the user. The draft is removed.
+func (s *SleepDiaryService) ConfirmDraft(caller api.Principal, id int64, dto api.ConfirmSleepDiaryDraftDto) (api.SleepDiaryEntryDto, api.Error) {
+	errs := dto.Validate()
+	if len(errs) > 0 {
+		return api.SleepDiaryEntryDto{}, api.NewValidationError("invalid confirm data", errs)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting confirm transaction failed: %v\n", err)
+		return api.SleepDiaryEntryDto{}, api.NewError("confirm failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	keys := s.commentKeys(tx)
+	draft, err := getSleepDiaryDraftById(tx, keys, id, true)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return api.SleepDiaryEntryDto{}, api.NewError("draft not found", api.ERR_NOT_FOUND)
+		}
+		log.Printf("Reading draft by ID %d failed: %v\n", id, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if serviceErr := s.checkDraftAccess(tx, caller, draft.AccountUuid); serviceErr != nil {
+		return api.SleepDiaryEntryDto{}, serviceErr
+	}
+
+	createdEntry, err := insertSleepDiaryEntry(tx, keys, fromConfirmedSleepDiaryDraft(draft, dto))
+	if err != nil {
+		log.Printf("Inserting entry from draft %d failed: %v\n", id, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+
+	if err := recordEntryChange(tx, createdEntry, api.CHANGE_CREATED); err != nil {
+		log.Printf("Recording creation of entry %d failed: %v\n", createdEntry.Id, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("confirm failed", api.ERR_UNKNOWN)
+	}
+
+	if _, err := deleteSleepDiaryDraft(tx, id); err != nil {
+		log.Printf("Deleting draft %d failed: %v\n", id, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("confirm failed", api.ERR_UNKNOWN)
+	}
+
+	if err := recordAudit(tx, caller, api.ConfirmDraftAuditAction, []string{createdEntry.AccountUuid}, []int64{createdEntry.Id}); err != nil {
+		log.Printf("Recording confirmation of draft %d failed: %v\n", id, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("confirm failed", api.ERR_UNKNOWN)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing confirmation of draft %d failed: %v\n", id, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("confirm failed", api.ERR_UNKNOWN)
+	}
+
+	createdDto, err := toSleepDiaryEntryDto(createdEntry)
+	if err != nil {
+		log.Printf("Converting entry %d to DTO failed: %v\n", createdEntry.Id, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("conversion failed", api.ERR_UNKNOWN)
+	}
+
+	return createdDto, nil
+}
+
+func (s *SleepDiaryService) DeleteDraft(caller api.Principal, id int64) api.Error {
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting delete transaction failed: %v\n", err)
+		return api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	accountUuid, err := deleteSleepDiaryDraft(tx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return api.NewError("draft not found", api.ERR_NOT_FOUND)
+		}
+		log.Printf("Deleting draft %d failed: %v\n", id, err)
+		return api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+	// The deletion is rolled back unless the caller may write the account.
+	if serviceErr := s.checkDraftAccess(tx, caller, accountUuid); serviceErr != nil {
+		return serviceErr
+	}
+
+	if err := recordAudit(tx, caller, api.DeleteDraftAuditAction, []string{accountUuid}, []int64{}); err != nil {
+		log.Printf("Recording deletion of draft %d failed: %v\n", id, err)
+		return api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing deletion of draft %d failed: %v\n", id, err)
+		return api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+	return nil
+}
+
+// checkDraftAccess fails with ERR_NOT_FOUND unless the caller may write
+// entries of the account of a draft, so that drafts of other accounts cannot
+// be told from missing ones.
+func (s *SleepDiaryService) checkDraftAccess(tx queryer, caller api.Principal, accountUuid string) api.Error {
+	accessible, serviceErr := s.canAccess(tx, caller, accountUuid, writeAccess)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	if !accessible {
+		return api.NewError("draft not found", api.ERR_NOT_FOUND)
+	}
+	return nil
+}
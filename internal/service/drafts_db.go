@@ -0,0 +1,208 @@
+package service
+
+import "time"
+
+const selectDraftColumns = `
+	id,
+	account_uuid,
+	source,
+	timezone,
+	in_bed_at,
+	tried_to_sleep_at,
+	sleep_delay_in_min,
+	awakenings_count,
+	awakenings_total_duration_in_min,
+	final_wake_up_at,
+	out_of_bed_at,
+	COALESCE(sleep_quality, 0),
+	comments,
+	created_at,
+	comments_key_id
+`
+
+func getSleepDiaryDraftById(db queryer, keys *commentKeys, id int64, forUpdate bool) (SleepDiaryDraft, error) {
+	query := "SELECT " + selectDraftColumns + " FROM sleep_diary_drafts WHERE id = $1"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	return scanDecryptedSleepDiaryDraft(db.QueryRow(query, id), keys)
+}
+
+func getSleepDiaryDraftsByAccount(db queryer, keys *commentKeys, accountUuid string) ([]SleepDiaryDraft, error) {
+	query := "SELECT " + selectDraftColumns + " FROM sleep_diary_drafts WHERE account_uuid = $1 ORDER BY tried_to_sleep_at"
+	rows, err := db.Query(query, accountUuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := []SleepDiaryDraft{}
+	for rows.Next() {
+		draft, err := scanSleepDiaryDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, draft)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range drafts {
+		if err := keys.decryptEntry(&drafts[i].SleepDiaryEntry); err != nil {
+			return nil, err
+		}
+	}
+	return drafts, nil
+}
+
+func insertSleepDiaryDraft(db queryer, keys *commentKeys, draft SleepDiaryDraft) (SleepDiaryDraft, error) {
+	comments, commentsKeyId, err := keys.encrypt(draft.Comments)
+	if err != nil {
+		return SleepDiaryDraft{}, err
+	}
+
+	query := `
+		INSERT INTO sleep_diary_drafts (
+			account_uuid,
+			source,
+			timezone,
+			in_bed_at,
+			tried_to_sleep_at,
+			sleep_delay_in_min,
+			awakenings_count,
+			awakenings_total_duration_in_min,
+			final_wake_up_at,
+			out_of_bed_at,
+			sleep_quality,
+			comments,
+			created_at,
+			comments_key_id
+		)
+		VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, 0), $12, $13, $14
+		)
+		RETURNING id
+	`
+
+	var id int64
+	err = db.QueryRow(
+		query,
+		draft.AccountUuid,
+		draft.Source,
+		draft.Timezone,
+		draft.InBedAt,
+		draft.TriedToSleepAt,
+		draft.SleepDelayInMin,
+		draft.AwakeningsCount,
+		draft.AwakeningsTotalDurationInMin,
+		draft.FinalWakeUpAt,
+		draft.OutOfBedAt,
+		draft.SleepQuality,
+		comments,
+		draft.CreatedAt,
+		commentsKeyId,
+	).Scan(&id)
+	if err != nil {
+		return SleepDiaryDraft{}, err
+	}
+
+	draft.Id = id
+	return draft, nil
+}
+
+// getOverlappingSleepDiaryDraft returns the earliest draft of an account whose
+// sleep period overlaps given one. The draft is locked for update.
+func getOverlappingSleepDiaryDraft(db queryer, keys *commentKeys, accountUuid string, from time.Time, to time.Time) (SleepDiaryDraft, error) {
+	query := "SELECT " + selectDraftColumns + `
+		FROM sleep_diary_drafts
+		WHERE account_uuid = $1 AND tried_to_sleep_at < $3 AND final_wake_up_at > $2
+		ORDER BY tried_to_sleep_at
+		LIMIT 1
+		FOR UPDATE
+	`
+	return scanDecryptedSleepDiaryDraft(db.QueryRow(query, accountUuid, from, to), keys)
+}
+
+func updateSleepDiaryDraft(db queryer, keys *commentKeys, draft SleepDiaryDraft) error {
+	comments, commentsKeyId, err := keys.encrypt(draft.Comments)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE sleep_diary_drafts
+		SET
+			timezone = $1,
+			in_bed_at = $2,
+			tried_to_sleep_at = $3,
+			sleep_delay_in_min = $4,
+			awakenings_count = $5,
+			awakenings_total_duration_in_min = $6,
+			final_wake_up_at = $7,
+			out_of_bed_at = $8,
+			sleep_quality = NULLIF($9, 0),
+			comments = $10,
+			comments_key_id = $11
+		WHERE id = $12
+	`
+	_, err = db.Exec(
+		query,
+		draft.Timezone,
+		draft.InBedAt,
+		draft.TriedToSleepAt,
+		draft.SleepDelayInMin,
+		draft.AwakeningsCount,
+		draft.AwakeningsTotalDurationInMin,
+		draft.FinalWakeUpAt,
+		draft.OutOfBedAt,
+		draft.SleepQuality,
+		comments,
+		commentsKeyId,
+		draft.Id,
+	)
+	return err
+}
+
+// deleteSleepDiaryDraft deletes a draft and returns its account.
+func deleteSleepDiaryDraft(db queryer, id int64) (string, error) {
+	var accountUuid string
+	err := db.QueryRow("DELETE FROM sleep_diary_drafts WHERE id = $1 RETURNING account_uuid", id).Scan(&accountUuid)
+	return accountUuid, err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSleepDiaryDraft(row scanner) (SleepDiaryDraft, error) {
+	var draft SleepDiaryDraft
+	err := row.Scan(
+		&draft.Id,
+		&draft.AccountUuid,
+		&draft.Source,
+		&draft.Timezone,
+		&draft.InBedAt,
+		&draft.TriedToSleepAt,
+		&draft.SleepDelayInMin,
+		&draft.AwakeningsCount,
+		&draft.AwakeningsTotalDurationInMin,
+		&draft.FinalWakeUpAt,
+		&draft.OutOfBedAt,
+		&draft.SleepQuality,
+		&draft.Comments,
+		&draft.CreatedAt,
+		&draft.CommentsKeyId,
+	)
+	return draft, err
+}
+
+// scanDecryptedSleepDiaryDraft scans a single draft and decrypts its
+// comments.
+func scanDecryptedSleepDiaryDraft(row scanner, keys *commentKeys) (SleepDiaryDraft, error) {
+	draft, err := scanSleepDiaryDraft(row)
+	if err != nil {
+		return SleepDiaryDraft{}, err
+	}
+	return draft, keys.decryptEntry(&draft.SleepDiaryEntry)
+}
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// Number of bytes of master and data keys, selecting AES-256.
+const encryptionKeySize = 32
+
+// Number of comments re-encrypted in a transaction by RotateDataKeys.
+const rotationBatchSize = 500
+
+var errEncryptionDisabled = errors.New("comments are encrypted but no encryption key is set")
+
+// masterKeys wrap data keys of tenants. New data keys are wrapped by the
+// current key; previous keys only unwrap data keys wrapped before the current
+// key replaced them. Keys are told apart by their fingerprints.
+type masterKeys struct {
+	currentId string
+	keys      map[string]cipher.AEAD
+}
+
+// newMasterKeys parses the base64 current master key and comma-separated
+// previous ones. It returns nil when no current key is set, which disables
+// encryption.
+func newMasterKeys(current string, previous string) (*masterKeys, error) {
+	if current == "" {
+		return nil, nil
+	}
+	m := &masterKeys{keys: map[string]cipher.AEAD{}}
+	for i, encoded := range append([]string{current}, strings.Split(previous, ",")...) {
+		encoded = strings.TrimSpace(encoded)
+		if encoded == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != encryptionKeySize {
+			return nil, fmt.Errorf("encryption key %d is not base64 of %d bytes", i+1, encryptionKeySize)
+		}
+		aead, err := newAead(key)
+		if err != nil {
+			return nil, err
+		}
+		fingerprint := sha256.Sum256(key)
+		id := hex.EncodeToString(fingerprint[:8])
+		if i == 0 {
+			m.currentId = id
+		}
+		m.keys[id] = aead
+	}
+	return m, nil
+}
+
+// newDataKey generates a data key wrapped by the current master key.
+func (m *masterKeys) newDataKey() (DataKey, error) {
+	key := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return DataKey{}, err
+	}
+	wrapped, err := seal(m.keys[m.currentId], key)
+	if err != nil {
+		return DataKey{}, err
+	}
+	return DataKey{MasterKeyId: m.currentId, WrappedKey: wrapped, CreatedAt: time.Now().UTC()}, nil
+}
+
+func (m *masterKeys) unwrap(key DataKey) (cipher.AEAD, error) {
+	master, ok := m.keys[key.MasterKeyId]
+	if !ok {
+		return nil, fmt.Errorf("data key %d is wrapped by unknown master key %s", key.Id, key.MasterKeyId)
+	}
+	plain, err := open(master, key.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key %d failed: %w", key.Id, err)
+	}
+	return newAead(plain)
+}
+
+// commentKeys encrypts and decrypts comments with data keys of the tenant of
+// a transaction. Keys are read on first use, which must not happen while rows
+// of another query are open in the transaction; the current key is created
+// on first encryption if the tenant has none.
+type commentKeys struct {
+	tx     queryer
+	master *masterKeys
+	keys   map[int64]DataKey
+	aeads  map[int64]cipher.AEAD
+	// ID of the current key of the tenant; zero when it has none.
+	currentId int64
+}
+
+func (s *SleepDiaryService) commentKeys(tx queryer) *commentKeys {
+	return &commentKeys{tx: tx, master: s.masterKeys}
+}
+
+// encrypt returns comments encrypted with the current key along with ID of
+// the key. Comments are returned as they are when encryption is disabled.
+func (k *commentKeys) encrypt(comments sql.NullString) (sql.NullString, sql.NullInt64, error) {
+	if !comments.Valid || k.master == nil {
+		return comments, sql.NullInt64{}, nil
+	}
+	keyId, err := k.currentKeyId()
+	if err != nil {
+		return sql.NullString{}, sql.NullInt64{}, err
+	}
+	aead, err := k.aead(keyId)
+	if err != nil {
+		return sql.NullString{}, sql.NullInt64{}, err
+	}
+	sealed, err := seal(aead, []byte(comments.String))
+	if err != nil {
+		return sql.NullString{}, sql.NullInt64{}, err
+	}
+	return sql.NullString{String: base64.StdEncoding.EncodeToString(sealed), Valid: true}, sql.NullInt64{Int64: keyId, Valid: true}, nil
+}
+
+// decrypt returns comments encrypted with the key with keyId in plain text;
+// comments without a key are plain text already.
+func (k *commentKeys) decrypt(comments sql.NullString, keyId sql.NullInt64) (sql.NullString, error) {
+	if !comments.Valid || !keyId.Valid {
+		return comments, nil
+	}
+	aead, err := k.aead(keyId.Int64)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(comments.String)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	plain, err := open(aead, sealed)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("decrypting comments with data key %d failed: %w", keyId.Int64, err)
+	}
+	return sql.NullString{String: string(plain), Valid: true}, nil
+}
+
+// decryptEntry decrypts comments of an entry read from the database.
+func (k *commentKeys) decryptEntry(entry *SleepDiaryEntry) error {
+	comments, err := k.decrypt(entry.Comments, entry.CommentsKeyId)
+	if err != nil {
+		return err
+	}
+	entry.Comments = comments
+	entry.CommentsKeyId = sql.NullInt64{}
+	return nil
+}
+
+func (k *commentKeys) load() error {
+	if k.keys != nil {
+		return nil
+	}
+	if k.master == nil {
+		return errEncryptionDisabled
+	}
+	keys, err := getDataKeys(k.tx)
+	if err != nil {
+		return err
+	}
+	k.keys = map[int64]DataKey{}
+	k.aeads = map[int64]cipher.AEAD{}
+	for _, key := range keys {
+		k.keys[key.Id] = key
+		if !key.RetiredAt.Valid {
+			k.currentId = key.Id
+		}
+	}
+	return nil
+}
+
+// currentKeyId returns ID of the current key of the tenant, creating the key
+// if there is none. A key created concurrently by another transaction is
+// used instead of a new one.
+func (k *commentKeys) currentKeyId() (int64, error) {
+	if err := k.load(); err != nil {
+		return 0, err
+	}
+	if k.currentId != 0 {
+		return k.currentId, nil
+	}
+
+	key, err := k.master.newDataKey()
+	if err != nil {
+		return 0, err
+	}
+	key, err = insertDataKey(k.tx, key)
+	if err == sql.ErrNoRows {
+		k.keys = nil
+		if err := k.load(); err != nil {
+			return 0, err
+		}
+		if k.currentId == 0 {
+			return 0, errors.New("current data key not found")
+		}
+		return k.currentId, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	k.keys[key.Id] = key
+	k.currentId = key.Id
+	return key.Id, nil
+}
+
+func (k *commentKeys) aead(keyId int64) (cipher.AEAD, error) {
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+	if aead, ok := k.aeads[keyId]; ok {
+		return aead, nil
+	}
+	key, ok := k.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("data key %d not found", keyId)
+	}
+	aead, err := k.master.unwrap(key)
+	if err != nil {
+		return nil, err
+	}
+	k.aeads[keyId] = aead
+	return aead, nil
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plain text with a random nonce, which precedes the result.
+func seal(aead cipher.AEAD, plain []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// RotateDataKeys replaces the data key of the tenant of the caller with a new
+// one wrapped by the current master key and re-encrypts comments of entries
+// and drafts with it batch by batch, including comments stored in plain text
+// before encryption was enabled. Retired keys nothing is encrypted with
+// anymore are deleted, so that previous master keys may be dropped once all
+// tenants are rotated. Only admins may rotate keys.
+func (s *SleepDiaryService) RotateDataKeys(caller api.Principal) (api.DataKeyRotationDto, api.Error) {
+	if !caller.HasScope(api.SCOPE_ADMIN) {
+		return api.DataKeyRotationDto{}, api.NewError("admin scope required", api.ERR_FORBIDDEN)
+	}
+	if s.masterKeys == nil {
+		return api.DataKeyRotationDto{}, api.NewError("encryption key not set", api.ERR_INVALID)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting rotation transaction failed: %v\n", err)
+		return api.DataKeyRotationDto{}, api.NewError("rotation failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if err := retireDataKeys(tx, time.Now().UTC()); err != nil {
+		log.Printf("Retiring data keys failed: %v\n", err)
+		return api.DataKeyRotationDto{}, api.NewError("rotation failed", api.ERR_UNKNOWN)
+	}
+	keyId, err := s.commentKeys(tx).currentKeyId()
+	if err != nil {
+		log.Printf("Creating data key failed: %v\n", err)
+		return api.DataKeyRotationDto{}, api.NewError("rotation failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing data key %d failed: %v\n", keyId, err)
+		return api.DataKeyRotationDto{}, api.NewError("rotation failed", api.ERR_UNKNOWN)
+	}
+
+	result := api.DataKeyRotationDto{KeyId: keyId}
+	if result.ReencryptedEntries, err = s.reencryptComments(caller, entriesCommentsTable); err != nil {
+		log.Printf("Re-encrypting comments of entries failed: %v\n", err)
+		return api.DataKeyRotationDto{}, api.NewError("rotation failed", api.ERR_UNKNOWN)
+	}
+	if result.ReencryptedDrafts, err = s.reencryptComments(caller, draftsCommentsTable); err != nil {
+		log.Printf("Re-encrypting comments of drafts failed: %v\n", err)
+		return api.DataKeyRotationDto{}, api.NewError("rotation failed", api.ERR_UNKNOWN)
+	}
+
+	tx, err = s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting rotation transaction failed: %v\n", err)
+		return api.DataKeyRotationDto{}, api.NewError("rotation failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if result.DeletedKeys, err = deleteUnusedDataKeys(tx); err != nil {
+		log.Printf("Deleting unused data keys failed: %v\n", err)
+		return api.DataKeyRotationDto{}, api.NewError("rotation failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing deletion of data keys failed: %v\n", err)
+		return api.DataKeyRotationDto{}, api.NewError("rotation failed", api.ERR_UNKNOWN)
+	}
+	return result, nil
+}
+
+// reencryptComments encrypts comments of table not encrypted with the
+// current key with it, a batch per transaction, and returns their number.
+// Comments written concurrently are encrypted with the current key already.
+func (s *SleepDiaryService) reencryptComments(caller api.Principal, table string) (int64, error) {
+	var count int64
+	for {
+		batchCount, err := s.reencryptCommentsBatch(caller, table)
+		if err != nil || batchCount == 0 {
+			return count, err
+		}
+		count += batchCount
+	}
+}
+
+func (s *SleepDiaryService) reencryptCommentsBatch(caller api.Principal, table string) (int64, error) {
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	keys := s.commentKeys(tx)
+	keyId, err := keys.currentKeyId()
+	if err != nil {
+		return 0, err
+	}
+	comments, err := getCommentsNotEncryptedWith(tx, table, keyId, rotationBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, comment := range comments {
+		plain, err := keys.decrypt(comment.Comments, comment.KeyId)
+		if err != nil {
+			return 0, fmt.Errorf("row %d: %w", comment.Id, err)
+		}
+		if comment.Comments, comment.KeyId, err = keys.encrypt(plain); err != nil {
+			return 0, err
+		}
+		if err := updateComments(tx, table, comment); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(comments)), tx.Commit()
+}
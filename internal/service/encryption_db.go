@@ -0,0 +1,119 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DataKey is a data key of a tenant, wrapped by the master key with
+// MasterKeyId.
+type DataKey struct {
+	Id          int64
+	MasterKeyId string
+	WrappedKey  []byte
+	CreatedAt   time.Time
+	RetiredAt   sql.NullTime
+}
+
+// storedComment is a comment of a row of sleep_diary_entries or
+// sleep_diary_drafts as stored.
+type storedComment struct {
+	Id       int64
+	Comments sql.NullString
+	KeyId    sql.NullInt64
+}
+
+// Tables holding comments encrypted with data keys.
+const (
+	entriesCommentsTable = "sleep_diary_entries"
+	draftsCommentsTable  = "sleep_diary_drafts"
+)
+
+func getDataKeys(db queryer) ([]DataKey, error) {
+	rows, err := db.Query("SELECT id, master_key_id, wrapped_key, created_at, retired_at FROM data_keys ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []DataKey{}
+	for rows.Next() {
+		var key DataKey
+		if err := rows.Scan(&key.Id, &key.MasterKeyId, &key.WrappedKey, &key.CreatedAt, &key.RetiredAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// insertDataKey inserts the current data key of the tenant of the
+// transaction. It fails with sql.ErrNoRows when the tenant already has one.
+func insertDataKey(db queryer, key DataKey) (DataKey, error) {
+	query := `
+		INSERT INTO data_keys (master_key_id, wrapped_key, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (tenant_id) WHERE retired_at IS NULL DO NOTHING
+		RETURNING id
+	`
+	err := db.QueryRow(query, key.MasterKeyId, key.WrappedKey, key.CreatedAt).Scan(&key.Id)
+	return key, err
+}
+
+func retireDataKeys(db queryer, now time.Time) error {
+	_, err := db.Exec("UPDATE data_keys SET retired_at = $1 WHERE retired_at IS NULL", now)
+	return err
+}
+
+// deleteUnusedDataKeys deletes retired keys nothing is encrypted with anymore.
+func deleteUnusedDataKeys(db queryer) (int64, error) {
+	query := fmt.Sprintf(`
+		DELETE FROM data_keys k
+		WHERE retired_at IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM %s WHERE comments_key_id = k.id)
+		AND NOT EXISTS (SELECT 1 FROM %s WHERE comments_key_id = k.id)
+	`, entriesCommentsTable, draftsCommentsTable)
+	result, err := db.Exec(query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// getCommentsNotEncryptedWith returns up to limit comments of table stored
+// in plain text or encrypted with a key other than given one, locked for
+// update.
+func getCommentsNotEncryptedWith(db queryer, table string, keyId int64, limit int) ([]storedComment, error) {
+	query := fmt.Sprintf(`
+		SELECT id, comments, comments_key_id
+		FROM %s
+		WHERE comments IS NOT NULL AND comments_key_id IS DISTINCT FROM $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE
+	`, table)
+	rows, err := db.Query(query, keyId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []storedComment{}
+	for rows.Next() {
+		var comment storedComment
+		if err := rows.Scan(&comment.Id, &comment.Comments, &comment.KeyId); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// updateComments replaces a comment of table without changing the version of
+// the row, as its content stays the same.
+func updateComments(db queryer, table string, comment storedComment) error {
+	query := fmt.Sprintf("UPDATE %s SET comments = $1, comments_key_id = $2 WHERE id = $3", table)
+	_, err := db.Exec(query, comment.Comments, comment.KeyId, comment.Id)
+	return err
+}
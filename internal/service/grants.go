@@ -0,0 +1,156 @@
+package service
+
+import (
+	"database/sql"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// CreateGrant gives a principal access to the diary of an account. Only the
+// owner of the account or an admin may give it.
+func (s *SleepDiaryService) CreateGrant(caller api.Principal, dto api.CreateGrantDto) (api.GrantDto, api.Error) {
+	errs := dto.Validate()
+	if len(errs) > 0 {
+		return api.GrantDto{}, api.NewValidationError("invalid grant data", errs)
+	}
+	if !s.isOwner(caller, dto.AccountUuid) {
+		return api.GrantDto{}, api.NewError("account not accessible", api.ERR_FORBIDDEN)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting insert transaction failed: %v\n", err)
+		return api.GrantDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	grant, err := insertGrant(tx, fromCreateGrantDto(dto))
+	if err != nil {
+		log.Printf("Inserting grant of %s to %s failed: %v\n", dto.AccountUuid, dto.Grantee, err)
+		return api.GrantDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+	if err := recordAudit(tx, caller, api.CreateGrantAuditAction, []string{dto.AccountUuid}, []int64{}); err != nil {
+		log.Printf("Recording grant of %s to %s failed: %v\n", dto.AccountUuid, dto.Grantee, err)
+		return api.GrantDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing grant of %s to %s failed: %v\n", dto.AccountUuid, dto.Grantee, err)
+		return api.GrantDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+	return toGrantDto(grant), nil
+}
+
+// GetGrantsByAccount returns all grants given by an account, including
+// expired and revoked ones.
+func (s *SleepDiaryService) GetGrantsByAccount(caller api.Principal, accountUuid string) ([]api.GrantDto, api.Error) {
+	if err := api.ValidateAccountUuid(accountUuid); err != nil {
+		return nil, api.NewValidationError("invalid grant filter", []error{err})
+	}
+	if !s.isOwner(caller, accountUuid) {
+		return nil, api.NewError("account not accessible", api.ERR_FORBIDDEN)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	grants, err := getGrantsByAccount(tx, accountUuid)
+	if err != nil {
+		log.Printf("Reading grants of %s failed: %v\n", accountUuid, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if err := recordAudit(tx, caller, api.ListGrantsAuditAction, []string{accountUuid}, []int64{}); err != nil {
+		log.Printf("Recording read of grants of %s failed: %v\n", accountUuid, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing read of grants of %s failed: %v\n", accountUuid, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	return toGrantDtos(grants), nil
+}
+
+// GetReceivedGrants returns grants given to the caller that are in force.
+func (s *SleepDiaryService) GetReceivedGrants(caller api.Principal) ([]api.GrantDto, api.Error) {
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	grants, err := getActiveGrantsByGrantee(tx, caller.Subject, time.Now().UTC())
+	if err != nil {
+		log.Printf("Reading grants received by %s failed: %v\n", caller.Subject, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	accountUuids := []string{}
+	for _, grant := range grants {
+		if !slices.Contains(accountUuids, grant.AccountUuid) {
+			accountUuids = append(accountUuids, grant.AccountUuid)
+		}
+	}
+	if err := recordAudit(tx, caller, api.ListReceivedGrantsAuditAction, accountUuids, []int64{}); err != nil {
+		log.Printf("Recording read of grants received by %s failed: %v\n", caller.Subject, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing read of grants received by %s failed: %v\n", caller.Subject, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	return toGrantDtos(grants), nil
+}
+
+// RevokeGrant ends a grant immediately. The grant is kept, marked revoked, so
+// that the account owner can still see who had access and when.
+func (s *SleepDiaryService) RevokeGrant(caller api.Principal, id int64) api.Error {
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting revoke transaction failed: %v\n", err)
+		return api.NewError("revoke failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	grant, err := getGrantById(tx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return api.NewError("grant not found", api.ERR_NOT_FOUND)
+		}
+		log.Printf("Reading grant %d failed: %v\n", id, err)
+		return api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if !s.isOwner(caller, grant.AccountUuid) {
+		return api.NewError("grant not found", api.ERR_NOT_FOUND)
+	}
+
+	if err := revokeGrant(tx, id, time.Now().UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			return api.NewError("grant not found", api.ERR_NOT_FOUND)
+		}
+		log.Printf("Revoking grant %d failed: %v\n", id, err)
+		return api.NewError("revoke failed", api.ERR_UNKNOWN)
+	}
+	if err := recordAudit(tx, caller, api.RevokeGrantAuditAction, []string{grant.AccountUuid}, []int64{}); err != nil {
+		log.Printf("Recording revocation of grant %d failed: %v\n", id, err)
+		return api.NewError("revoke failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing revocation of grant %d failed: %v\n", id, err)
+		return api.NewError("revoke failed", api.ERR_UNKNOWN)
+	}
+	return nil
+}
+
+func toGrantDtos(grants []Grant) []api.GrantDto {
+	dtos := make([]api.GrantDto, len(grants))
+	for i, grant := range grants {
+		dtos[i] = toGrantDto(grant)
+	}
+	return dtos
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mabzd/snorlax/api"
+)
+
+type Grant struct {
+	Id          int64
+	AccountUuid string
+	Grantee     string
+	Access      api.GrantAccess
+	ValidFrom   time.Time
+	ValidTo     time.Time
+	CreatedAt   time.Time
+	RevokedAt   sql.NullTime
+}
+
+func insertGrant(db queryer, grant Grant) (Grant, error) {
+	query := `
+		INSERT INTO account_grants (account_uuid, grantee, access, valid_from, valid_to, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	err := db.QueryRow(
+		query,
+		grant.AccountUuid,
+		grant.Grantee,
+		grant.Access,
+		grant.ValidFrom,
+		grant.ValidTo,
+		grant.CreatedAt,
+	).Scan(&grant.Id)
+	return grant, err
+}
+
+func getGrantById(db queryer, id int64) (Grant, error) {
+	query := `
+		SELECT id, account_uuid, grantee, access, valid_from, valid_to, created_at, revoked_at
+		FROM account_grants
+		WHERE id = $1
+	`
+	var grant Grant
+	err := db.QueryRow(query, id).Scan(
+		&grant.Id,
+		&grant.AccountUuid,
+		&grant.Grantee,
+		&grant.Access,
+		&grant.ValidFrom,
+		&grant.ValidTo,
+		&grant.CreatedAt,
+		&grant.RevokedAt,
+	)
+	return grant, err
+}
+
+// getGrantsByAccount returns all grants given by an account, revoked and
+// expired ones included.
+func getGrantsByAccount(db queryer, accountUuid string) ([]Grant, error) {
+	query := `
+		SELECT id, account_uuid, grantee, access, valid_from, valid_to, created_at, revoked_at
+		FROM account_grants
+		WHERE account_uuid = $1
+		ORDER BY id
+	`
+	return queryGrants(db, query, accountUuid)
+}
+
+// getActiveGrantsByGrantee returns grants given to a principal in force at
+// given time.
+func getActiveGrantsByGrantee(db queryer, grantee string, at time.Time) ([]Grant, error) {
+	query := `
+		SELECT id, account_uuid, grantee, access, valid_from, valid_to, created_at, revoked_at
+		FROM account_grants
+		WHERE grantee = $1 AND revoked_at IS NULL AND valid_from <= $2 AND valid_to > $2
+		ORDER BY id
+	`
+	return queryGrants(db, query, grantee, at)
+}
+
+// getGrantsByGrantee returns all grants given to a principal, revoked and
+// expired ones included.
+func getGrantsByGrantee(db queryer, grantee string) ([]Grant, error) {
+	query := `
+		SELECT id, account_uuid, grantee, access, valid_from, valid_to, created_at, revoked_at
+		FROM account_grants
+		WHERE lower(grantee) = lower($1)
+		ORDER BY id
+	`
+	return queryGrants(db, query, grantee)
+}
+
+func queryGrants(db queryer, query string, args ...any) ([]Grant, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []Grant{}
+	for rows.Next() {
+		var grant Grant
+		err := rows.Scan(
+			&grant.Id,
+			&grant.AccountUuid,
+			&grant.Grantee,
+			&grant.Access,
+			&grant.ValidFrom,
+			&grant.ValidTo,
+			&grant.CreatedAt,
+			&grant.RevokedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+	return grants, rows.Err()
+}
+
+// getGrantedAccounts returns those of given accounts a principal holds a
+// grant in force at given time to; write access requires a read-write grant.
+func getGrantedAccounts(db queryer, grantee string, accountUuids []string, write bool, at time.Time) ([]string, error) {
+	query := `
+		SELECT DISTINCT account_uuid
+		FROM account_grants
+		WHERE grantee = $1
+			AND account_uuid = ANY($2::uuid[])
+			AND revoked_at IS NULL
+			AND valid_from <= $3
+			AND valid_to > $3
+			AND (access = $4 OR NOT $5)
+	`
+	rows, err := db.Query(query, grantee, pq.Array(accountUuids), at, api.ReadWriteGrantAccess, write)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	granted := []string{}
+	for rows.Next() {
+		var accountUuid string
+		if err := rows.Scan(&accountUuid); err != nil {
+			return nil, err
+		}
+		granted = append(granted, accountUuid)
+	}
+	return granted, rows.Err()
+}
+
+// revokeGrant marks a grant revoked; revoking it again keeps the time of the
+// first revocation.
+func revokeGrant(db queryer, id int64, revokedAt time.Time) error {
+	result, err := db.Exec("UPDATE account_grants SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1", id, revokedAt)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
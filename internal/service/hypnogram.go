@@ -0,0 +1,214 @@
+package service
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+func (s *SleepDiaryService) GetHypnogram(caller api.Principal, entryId int64) (api.HypnogramDto, api.Error) {
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return api.HypnogramDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if serviceErr := s.checkEntryAccess(tx, caller, entryId, readAccess); serviceErr != nil {
+		return api.HypnogramDto{}, serviceErr
+	}
+
+	entry, err := getSleepDiaryEntryById(tx, s.commentKeys(tx), entryId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return api.HypnogramDto{}, api.NewError("entry not found", api.ERR_NOT_FOUND)
+		}
+		log.Printf("Reading entry by ID %d failed: %v\n", entryId, err)
+		return api.HypnogramDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	segments, err := getSleepStageSegmentsByEntryId(tx, entryId)
+	if err != nil {
+		log.Printf("Reading sleep stages of entry %d failed: %v\n", entryId, err)
+		return api.HypnogramDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	if err := recordAudit(tx, caller, api.ReadHypnogramAuditAction, []string{entry.AccountUuid}, []int64{entryId}); err != nil {
+		log.Printf("Recording read of hypnogram of entry %d failed: %v\n", entryId, err)
+		return api.HypnogramDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing read of hypnogram of entry %d failed: %v\n", entryId, err)
+		return api.HypnogramDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	dto, err := toHypnogramDto(entry, segments)
+	if err != nil {
+		log.Printf("Converting hypnogram of entry %d to DTO failed: %v\n", entryId, err)
+		return api.HypnogramDto{}, api.NewError("conversion failed", api.ERR_UNKNOWN)
+	}
+
+	return dto, nil
+}
+
+// ReplaceHypnogram replaces all sleep stages recorded for an entry.
+func (s *SleepDiaryService) ReplaceHypnogram(caller api.Principal, entryId int64, dto api.UploadHypnogramDto) (api.HypnogramDto, api.Error) {
+	errs := dto.Validate()
+	if len(errs) > 0 {
+		return api.HypnogramDto{}, api.NewValidationError("invalid hypnogram data", errs)
+	}
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting hypnogram transaction failed: %v\n", err)
+		return api.HypnogramDto{}, api.NewError("upload failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if serviceErr := s.checkEntryAccess(tx, caller, entryId, writeAccess); serviceErr != nil {
+		return api.HypnogramDto{}, serviceErr
+	}
+
+	entry, err := getSleepDiaryEntryById(tx, s.commentKeys(tx), entryId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return api.HypnogramDto{}, api.NewError("entry not found", api.ERR_NOT_FOUND)
+		}
+		log.Printf("Reading entry by ID %d failed: %v\n", entryId, err)
+		return api.HypnogramDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	segments := make([]SleepStageSegment, len(dto.Segments))
+	for i, segmentDto := range dto.Segments {
+		segments[i] = fromSleepStageSegmentDto(segmentDto)
+		segments[i].EntryId = entryId
+	}
+
+	if err := replaceSleepStageSegments(tx, entryId, segments); err != nil {
+		log.Printf("Replacing sleep stages of entry %d failed: %v\n", entryId, err)
+		return api.HypnogramDto{}, api.NewError("upload failed", api.ERR_UNKNOWN)
+	}
+
+	storedSegments, err := getSleepStageSegmentsByEntryId(tx, entryId)
+	if err != nil {
+		log.Printf("Reading sleep stages of entry %d failed: %v\n", entryId, err)
+		return api.HypnogramDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	if err := recordAudit(tx, caller, api.ReplaceHypnogramAuditAction, []string{entry.AccountUuid}, []int64{entryId}); err != nil {
+		log.Printf("Recording upload of hypnogram of entry %d failed: %v\n", entryId, err)
+		return api.HypnogramDto{}, api.NewError("upload failed", api.ERR_UNKNOWN)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing hypnogram of entry %d failed: %v\n", entryId, err)
+		return api.HypnogramDto{}, api.NewError("upload failed", api.ERR_UNKNOWN)
+	}
+
+	hypnogramDto, err := toHypnogramDto(entry, storedSegments)
+	if err != nil {
+		log.Printf("Converting hypnogram of entry %d to DTO failed: %v\n", entryId, err)
+		return api.HypnogramDto{}, api.NewError("conversion failed", api.ERR_UNKNOWN)
+	}
+
+	return hypnogramDto, nil
+}
+
+func toHypnogramDto(entry SleepDiaryEntry, segments []SleepStageSegment) (api.HypnogramDto, error) {
+	tz, err := time.LoadLocation(entry.Timezone)
+	if err != nil {
+		return api.HypnogramDto{}, err
+	}
+
+	dto := api.HypnogramDto{
+		EntryId:    entry.Id,
+		Segments:   make([]api.SleepStageSegmentDto, len(segments)),
+		Subjective: subjectiveSleepMetrics(entry),
+	}
+	for i, segment := range segments {
+		dto.Segments[i] = toSleepStageSegmentDto(segment, tz)
+	}
+
+	if objective, ok := objectiveSleepMetrics(entry, segments); ok {
+		dto.Objective = &objective
+		dto.Discrepancy = &api.SleepMetricsDto{
+			SleepOnsetLatencyInMin:   dto.Subjective.SleepOnsetLatencyInMin - objective.SleepOnsetLatencyInMin,
+			WakeAfterSleepOnsetInMin: dto.Subjective.WakeAfterSleepOnsetInMin - objective.WakeAfterSleepOnsetInMin,
+			TotalSleepTimeInMin:      dto.Subjective.TotalSleepTimeInMin - objective.TotalSleepTimeInMin,
+		}
+	}
+
+	return dto, nil
+}
+
+// subjectiveSleepMetrics derives the measures from the diary: time between
+// trying to sleep and the final awakening minus the reported sleep onset
+// latency and awakenings. Missing values are treated as zero.
+func subjectiveSleepMetrics(entry SleepDiaryEntry) api.SleepMetricsDto {
+	sol := int(entry.SleepDelayInMin.Int32)
+	waso := int(entry.AwakeningsTotalDurationInMin.Int32)
+	period := int(entry.FinalWakeUpAt.Sub(entry.TriedToSleepAt) / time.Minute)
+	return api.SleepMetricsDto{
+		SleepOnsetLatencyInMin:   sol,
+		WakeAfterSleepOnsetInMin: waso,
+		TotalSleepTimeInMin:      max(period-sol-waso, 0),
+	}
+}
+
+// objectiveSleepMetrics derives the measures from recorded sleep stages. Sleep
+// onset is the start of the first non-wake segment, latency is counted from
+// the time the person tried to sleep, and WASO is the wake time between sleep
+// onset and the end of the last non-wake segment. Returns false when there is
+// no sleep recorded.
+func objectiveSleepMetrics(entry SleepDiaryEntry, segments []SleepStageSegment) (api.SleepMetricsDto, bool) {
+	var onset, offset time.Time
+	var sleep time.Duration
+	for _, segment := range segments {
+		if segment.Stage == api.WakeSleepStage {
+			continue
+		}
+		if onset.IsZero() || segment.StartAt.Before(onset) {
+			onset = segment.StartAt
+		}
+		if segment.EndAt.After(offset) {
+			offset = segment.EndAt
+		}
+		sleep += segment.EndAt.Sub(segment.StartAt)
+	}
+	if onset.IsZero() {
+		return api.SleepMetricsDto{}, false
+	}
+
+	var wake time.Duration
+	for _, segment := range segments {
+		if segment.Stage != api.WakeSleepStage {
+			continue
+		}
+		start := maxTime(segment.StartAt, onset)
+		end := minTime(segment.EndAt, offset)
+		if end.After(start) {
+			wake += end.Sub(start)
+		}
+	}
+
+	return api.SleepMetricsDto{
+		SleepOnsetLatencyInMin:   max(int(onset.Sub(entry.TriedToSleepAt)/time.Minute), 0),
+		WakeAfterSleepOnsetInMin: int(wake / time.Minute),
+		TotalSleepTimeInMin:      int(sleep / time.Minute),
+	}, true
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
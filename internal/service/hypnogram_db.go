@@ -0,0 +1,52 @@
+package service
+
+func getSleepStageSegmentsByEntryId(db queryer, entryId int64) ([]SleepStageSegment, error) {
+	query := `
+		SELECT id, entry_id, stage, start_at, end_at, source_device
+		FROM sleep_stage_segments
+		WHERE entry_id = $1
+		ORDER BY start_at
+	`
+	rows, err := db.Query(query, entryId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	segments := []SleepStageSegment{}
+	for rows.Next() {
+		var segment SleepStageSegment
+		err := rows.Scan(
+			&segment.Id,
+			&segment.EntryId,
+			&segment.Stage,
+			&segment.StartAt,
+			&segment.EndAt,
+			&segment.SourceDevice,
+		)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, rows.Err()
+}
+
+func replaceSleepStageSegments(db queryer, entryId int64, segments []SleepStageSegment) error {
+	if _, err := db.Exec("DELETE FROM sleep_stage_segments WHERE entry_id = $1", entryId); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO sleep_stage_segments (entry_id, stage, start_at, end_at, source_device)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	for _, segment := range segments {
+		_, err := db.Exec(query, entryId, segment.Stage, segment.StartAt, segment.EndAt, segment.SourceDevice)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 
 type SleepDiaryEntry struct {
 	Id                           int64
+	Uuid                         string
 	AccountUuid                  string
 	Timezone                     string
 	InBedAt                      sql.NullTime
@@ -23,10 +24,30 @@ type SleepDiaryEntry struct {
 	CreatedAt                    time.Time
 	UpdatedAt                    time.Time
 	Version                      sql.NullInt64
+	// Data key Comments are encrypted with as read from the database; not
+	// set once they are decrypted.
+	CommentsKeyId sql.NullInt64
+}
+
+// SleepDiaryDraft reuses the entry columns; Version is unused and a zero
+// SleepQuality is stored as NULL.
+type SleepDiaryDraft struct {
+	SleepDiaryEntry
+	Source string
+}
+
+type SleepStageSegment struct {
+	Id           int64
+	EntryId      int64
+	Stage        api.SleepStage
+	StartAt      time.Time
+	EndAt        time.Time
+	SourceDevice sql.NullString
 }
 
 func fromCreateSleepDiaryEntryDto(dto api.CreateSleepDiaryEntryDto) SleepDiaryEntry {
 	entry := SleepDiaryEntry{
+		Uuid:        dto.Uuid,
 		AccountUuid: dto.AccountUuid,
 		CreatedAt:   time.Now().UTC(),
 		UpdatedAt:   time.Now().UTC(),
@@ -45,9 +66,83 @@ func fromUpdateSleepDiaryEntryDto(dto api.UpdateSleepDiaryEntryDto) SleepDiaryEn
 	return entry
 }
 
+func fromImportedSleepDiaryEntryDto(dto api.CreateSleepDiaryEntryDto, source api.ImportSource) SleepDiaryDraft {
+	draft := SleepDiaryDraft{
+		SleepDiaryEntry: SleepDiaryEntry{
+			AccountUuid: dto.AccountUuid,
+			CreatedAt:   time.Now().UTC(),
+		},
+		Source: string(source),
+	}
+	assignDtoToEntry(dto.SleepDiaryEntryDataDto, &draft.SleepDiaryEntry)
+	return draft
+}
+
+func fromConfirmedSleepDiaryDraft(draft SleepDiaryDraft, dto api.ConfirmSleepDiaryDraftDto) SleepDiaryEntry {
+	entry := draft.SleepDiaryEntry
+	entry.Id = 0
+	entry.SleepQuality = dto.SleepQuality
+	if dto.Comments != nil {
+		entry.Comments = toNullString(dto.Comments)
+	}
+	entry.CreatedAt = time.Now().UTC()
+	entry.UpdatedAt = entry.CreatedAt
+	entry.Version = sql.NullInt64{Int64: 1, Valid: true}
+	return entry
+}
+
+// mergeSleepDiaryDraft extends the sleep period of dst to cover src and fills
+// the values missing in dst with the ones from src.
+func mergeSleepDiaryDraft(dst *SleepDiaryDraft, src SleepDiaryDraft) {
+	if src.TriedToSleepAt.Before(dst.TriedToSleepAt) {
+		dst.TriedToSleepAt = src.TriedToSleepAt
+	}
+	if src.FinalWakeUpAt.After(dst.FinalWakeUpAt) {
+		dst.FinalWakeUpAt = src.FinalWakeUpAt
+	}
+	if !dst.InBedAt.Valid || (src.InBedAt.Valid && src.InBedAt.Time.Before(dst.InBedAt.Time)) {
+		dst.InBedAt = src.InBedAt
+	}
+	if !dst.OutOfBedAt.Valid || (src.OutOfBedAt.Valid && src.OutOfBedAt.Time.After(dst.OutOfBedAt.Time)) {
+		dst.OutOfBedAt = src.OutOfBedAt
+	}
+	if dst.InBedAt.Valid && dst.InBedAt.Time.After(dst.TriedToSleepAt) {
+		dst.InBedAt.Time = dst.TriedToSleepAt
+	}
+	if dst.OutOfBedAt.Valid && dst.OutOfBedAt.Time.Before(dst.FinalWakeUpAt) {
+		dst.OutOfBedAt.Time = dst.FinalWakeUpAt
+	}
+	if !dst.SleepDelayInMin.Valid {
+		dst.SleepDelayInMin = src.SleepDelayInMin
+	}
+	if !dst.AwakeningsCount.Valid {
+		dst.AwakeningsCount = src.AwakeningsCount
+	}
+	if !dst.AwakeningsTotalDurationInMin.Valid {
+		dst.AwakeningsTotalDurationInMin = src.AwakeningsTotalDurationInMin
+	}
+	if dst.SleepQuality == 0 {
+		dst.SleepQuality = src.SleepQuality
+	}
+	if !dst.Comments.Valid {
+		dst.Comments = src.Comments
+	}
+}
+
+func toSleepDiaryDraftDto(draft SleepDiaryDraft) (api.SleepDiaryDraftDto, error) {
+	dto := api.SleepDiaryDraftDto{
+		Id:          draft.Id,
+		AccountUuid: draft.AccountUuid,
+		Source:      api.ImportSource(draft.Source),
+	}
+	err := assignEntryToDto(draft.SleepDiaryEntry, &dto.SleepDiaryEntryDataDto)
+	return dto, err
+}
+
 func toSleepDiaryEntryDto(entry SleepDiaryEntry) (api.SleepDiaryEntryDto, error) {
 	dto := api.SleepDiaryEntryDto{
 		Id:          entry.Id,
+		Uuid:        entry.Uuid,
 		AccountUuid: entry.AccountUuid,
 		Version:     entry.Version.Int64,
 	}
@@ -92,6 +187,24 @@ func assignDtoToEntry(src api.SleepDiaryEntryDataDto, dst *SleepDiaryEntry) {
 	dst.Comments = toNullString(src.Comments)
 }
 
+func fromSleepStageSegmentDto(dto api.SleepStageSegmentDto) SleepStageSegment {
+	return SleepStageSegment{
+		Stage:        dto.Stage,
+		StartAt:      dto.StartAt,
+		EndAt:        dto.EndAt,
+		SourceDevice: toNullString(dto.SourceDevice),
+	}
+}
+
+func toSleepStageSegmentDto(segment SleepStageSegment, tz *time.Location) api.SleepStageSegmentDto {
+	return api.SleepStageSegmentDto{
+		Stage:        segment.Stage,
+		StartAt:      segment.StartAt.In(tz),
+		EndAt:        segment.EndAt.In(tz),
+		SourceDevice: fromNullString(segment.SourceDevice),
+	}
+}
+
 func toNullTime(t *time.Time) sql.NullTime {
 	if t != nil {
 		return sql.NullTime{Time: *t, Valid: true}
@@ -142,3 +255,119 @@ func fromNullString(s sql.NullString) *string {
 	}
 	return nil
 }
+
+func toEntryChangeDto(change SleepDiaryEntryChange, entry *SleepDiaryEntry) (api.EntryChangeDto, error) {
+	dto := api.EntryChangeDto{
+		Id:          change.Id,
+		Type:        change.ChangeType,
+		EntryId:     change.EntryId,
+		EntryUuid:   change.EntryUuid.String,
+		AccountUuid: change.AccountUuid,
+		Version:     change.Version,
+		ChangedAt:   change.ChangedAt,
+	}
+	if entry != nil {
+		entryDto, err := toSleepDiaryEntryDto(*entry)
+		if err != nil {
+			return api.EntryChangeDto{}, err
+		}
+		dto.Entry = &entryDto
+	}
+	return dto, nil
+}
+
+func fromCreateWebhookDto(dto api.CreateWebhookDto) Webhook {
+	webhook := Webhook{
+		Url:          dto.Url,
+		Secret:       dto.Secret,
+		EventTypes:   make([]string, len(dto.EventTypes)),
+		AccountUuids: dto.AccountUuids,
+		CreatedAt:    time.Now().UTC(),
+	}
+	for i, eventType := range dto.EventTypes {
+		webhook.EventTypes[i] = string(eventType)
+	}
+	if webhook.AccountUuids == nil {
+		webhook.AccountUuids = []string{}
+	}
+	return webhook
+}
+
+func toWebhookDto(webhook Webhook) api.WebhookDto {
+	dto := api.WebhookDto{
+		Id:           webhook.Id,
+		Url:          webhook.Url,
+		EventTypes:   make([]api.ChangeType, len(webhook.EventTypes)),
+		AccountUuids: webhook.AccountUuids,
+		CreatedAt:    webhook.CreatedAt,
+	}
+	for i, eventType := range webhook.EventTypes {
+		dto.EventTypes[i] = api.ChangeType(eventType)
+	}
+	if dto.AccountUuids == nil {
+		dto.AccountUuids = []string{}
+	}
+	return dto
+}
+
+func toWebhookDeliveryDto(delivery WebhookDelivery) api.WebhookDeliveryDto {
+	dto := api.WebhookDeliveryDto{
+		Id:             delivery.Id,
+		WebhookId:      delivery.WebhookId,
+		ChangeId:       delivery.ChangeId,
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: fromNullInt32(delivery.LastStatusCode),
+		LastError:      fromNullString(delivery.LastError),
+		CreatedAt:      delivery.CreatedAt,
+		DeliveredAt:    fromNullTime(delivery.DeliveredAt, time.UTC),
+	}
+	if delivery.Status == api.PendingWebhookDeliveryStatus {
+		dto.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	return dto
+}
+
+func fromCreateGrantDto(dto api.CreateGrantDto) Grant {
+	now := time.Now().UTC()
+	grant := Grant{
+		AccountUuid: dto.AccountUuid,
+		Grantee:     dto.Grantee,
+		Access:      dto.Access,
+		ValidFrom:   now,
+		ValidTo:     dto.ValidTo,
+		CreatedAt:   now,
+	}
+	if dto.ValidFrom != nil {
+		grant.ValidFrom = *dto.ValidFrom
+	}
+	return grant
+}
+
+func toGrantDto(grant Grant) api.GrantDto {
+	return api.GrantDto{
+		Id:          grant.Id,
+		AccountUuid: grant.AccountUuid,
+		Grantee:     grant.Grantee,
+		Access:      grant.Access,
+		ValidFrom:   grant.ValidFrom,
+		ValidTo:     grant.ValidTo,
+		CreatedAt:   grant.CreatedAt,
+		RevokedAt:   fromNullTime(grant.RevokedAt, time.UTC),
+	}
+}
+
+func toApiKeyDto(key ApiKey) api.ApiKeyDto {
+	return api.ApiKeyDto{
+		Id:          key.Id,
+		Name:        key.Name,
+		Prefix:      key.Prefix,
+		Scopes:      key.Scopes,
+		AccountUuid: key.AccountUuid.String,
+		ExpiresAt:   fromNullTime(key.ExpiresAt, time.UTC),
+		CreatedAt:   key.CreatedAt,
+		RotatedAt:   fromNullTime(key.RotatedAt, time.UTC),
+		RevokedAt:   fromNullTime(key.RevokedAt, time.UTC),
+	}
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// ClaimOutboxEvents returns up to limit unpublished events due now, oldest
+// first. They are not returned again until lease passes, unless their
+// attempt is recorded first.
+func (s *SleepDiaryService) ClaimOutboxEvents(limit int, lease time.Duration) ([]OutboxEvent, api.Error) {
+	events, err := claimOutboxEvents(s.db, time.Now().UTC(), lease, limit)
+	if err != nil {
+		log.Printf("Claiming outbox events failed: %v\n", err)
+		return nil, api.NewError("claim failed", api.ERR_UNKNOWN)
+	}
+	return events, nil
+}
+
+// RecordOutboxEventAttempt records an attempt to publish a claimed event. An
+// attempt without error marks the event as published; a failed one is
+// retried at nextAttemptAt.
+func (s *SleepDiaryService) RecordOutboxEventAttempt(id int64, attemptErr error, nextAttemptAt time.Time) api.Error {
+	var err error
+	if attemptErr == nil {
+		err = markOutboxEventPublished(s.db, id, time.Now().UTC())
+	} else {
+		err = markOutboxEventFailed(s.db, id, attemptErr.Error(), nextAttemptAt)
+	}
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return api.NewError("outbox event not found", api.ERR_NOT_FOUND)
+		}
+		log.Printf("Recording attempt of outbox event %d failed: %v\n", id, err)
+		return api.NewError("update failed", api.ERR_UNKNOWN)
+	}
+	return nil
+}
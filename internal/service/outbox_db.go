@@ -0,0 +1,129 @@
+package service
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// OutboxEvent is an entry change waiting to be published; AggregateId is the
+// ID of the changed entry.
+type OutboxEvent struct {
+	Id            int64
+	AggregateId   int64
+	EventType     api.ChangeType
+	Payload       []byte
+	Attempts      int
+	LastError     sql.NullString
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	PublishedAt   sql.NullTime
+}
+
+// insertOutboxEvent queues a change for publication; it is meant to run in
+// the transaction of the change itself.
+func insertOutboxEvent(db queryer, change SleepDiaryEntryChange, payload []byte) error {
+	query := `
+		INSERT INTO outbox (aggregate_id, event_type, payload, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $4)
+	`
+	_, err := db.Exec(query, change.EntryId, change.ChangeType, string(payload), change.ChangedAt)
+	return err
+}
+
+// claimOutboxEvents picks unpublished events due at now in order of creation
+// and postpones their next attempt by lease, so that concurrent relays do not
+// pick them again while they are being published.
+func claimOutboxEvents(db queryer, now time.Time, lease time.Duration, limit int) ([]OutboxEvent, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE outbox
+			SET next_attempt_at = $2
+			WHERE id IN (
+				SELECT id
+				FROM outbox
+				WHERE published_at IS NULL AND next_attempt_at <= $1
+				ORDER BY id
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT
+			id, aggregate_id, event_type, payload, attempts, last_error,
+			next_attempt_at, created_at, published_at
+		FROM claimed
+		ORDER BY id
+	`
+	rows, err := db.Query(query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []OutboxEvent{}
+	for rows.Next() {
+		var event OutboxEvent
+		var payload string
+		err := rows.Scan(
+			&event.Id,
+			&event.AggregateId,
+			&event.EventType,
+			&payload,
+			&event.Attempts,
+			&event.LastError,
+			&event.NextAttemptAt,
+			&event.CreatedAt,
+			&event.PublishedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		event.Payload = []byte(payload)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func markOutboxEventPublished(db queryer, id int64, publishedAt time.Time) error {
+	query := `
+		UPDATE outbox
+		SET attempts = attempts + 1, last_error = NULL, published_at = $1
+		WHERE id = $2
+	`
+	result, err := db.Exec(query, publishedAt, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// markOutboxEventFailed records a failed attempt of an event published
+// neither before nor in the meantime.
+func markOutboxEventFailed(db queryer, id int64, lastError string, nextAttemptAt time.Time) error {
+	query := `
+		UPDATE outbox
+		SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2
+		WHERE id = $3 AND published_at IS NULL
+	`
+	result, err := db.Exec(query, lastError, nextAttemptAt, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// RateLimit allows Burst requests at once, refilled at Burst per Period.
+type RateLimit struct {
+	Burst  int
+	Period time.Duration
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Time until a request is allowed again; zero when allowed.
+	RetryAfter time.Duration
+	// Time until the bucket is full again.
+	ResetAfter time.Duration
+}
+
+// TokenBucket is the state of a rate limited key: tokens left when last
+// updated.
+type TokenBucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// NewTokenBucket returns a full bucket.
+func NewTokenBucket(limit RateLimit, now time.Time) TokenBucket {
+	return TokenBucket{Tokens: float64(limit.Burst), UpdatedAt: now}
+}
+
+// Take refills the bucket for the time passed since its last update and takes
+// a token from it, if there is one.
+func (b *TokenBucket) Take(limit RateLimit, now time.Time) RateLimitResult {
+	return b.take(limit, now, 1)
+}
+
+// Peek refills the bucket like Take, but tells whether a request is allowed
+// without taking a token.
+func (b *TokenBucket) Peek(limit RateLimit, now time.Time) RateLimitResult {
+	return b.take(limit, now, 0)
+}
+
+func (b *TokenBucket) take(limit RateLimit, now time.Time, tokens float64) RateLimitResult {
+	rate := float64(limit.Burst) / limit.Period.Seconds()
+	elapsed := max(now.Sub(b.UpdatedAt).Seconds(), 0)
+	b.Tokens = min(b.Tokens+elapsed*rate, float64(limit.Burst))
+	b.UpdatedAt = now
+
+	result := RateLimitResult{Allowed: b.Tokens >= 1}
+	if result.Allowed {
+		b.Tokens -= tokens
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.Tokens) / rate)
+	}
+	result.Remaining = int(math.Floor(b.Tokens))
+	result.ResetAfter = secondsToDuration((float64(limit.Burst) - b.Tokens) / rate)
+	return result
+}
+
+// TakeRateLimitToken takes a token from the bucket of key stored in the
+// database, so that the limit holds across all replicas of the server.
+func (s *SleepDiaryService) TakeRateLimitToken(key string, limit RateLimit) (RateLimitResult, api.Error) {
+	return s.updateRateLimitBucket(key, limit, (*TokenBucket).Take)
+}
+
+// PeekRateLimitToken tells whether the bucket of key stored in the database
+// has a token left, without taking it.
+func (s *SleepDiaryService) PeekRateLimitToken(key string, limit RateLimit) (RateLimitResult, api.Error) {
+	return s.updateRateLimitBucket(key, limit, (*TokenBucket).Peek)
+}
+
+func (s *SleepDiaryService) updateRateLimitBucket(
+	key string,
+	limit RateLimit,
+	take func(*TokenBucket, RateLimit, time.Time) RateLimitResult,
+) (RateLimitResult, api.Error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Starting rate limit transaction failed: %v\n", err)
+		return RateLimitResult{}, api.NewError("rate limit failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	bucket, err := lockTokenBucket(tx, key, NewTokenBucket(limit, now))
+	if err != nil {
+		log.Printf("Reading rate limit bucket %s failed: %v\n", key, err)
+		return RateLimitResult{}, api.NewError("rate limit failed", api.ERR_UNKNOWN)
+	}
+	result := take(&bucket, limit, now)
+	if err := updateTokenBucket(tx, key, bucket); err != nil {
+		log.Printf("Updating rate limit bucket %s failed: %v\n", key, err)
+		return RateLimitResult{}, api.NewError("rate limit failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing rate limit bucket %s failed: %v\n", key, err)
+		return RateLimitResult{}, api.NewError("rate limit failed", api.ERR_UNKNOWN)
+	}
+	return result, nil
+}
+
+// DeleteIdleRateLimitBuckets removes buckets not updated since given time.
+// Buckets idle for their whole period are full again, so removing them
+// changes no limit.
+func (s *SleepDiaryService) DeleteIdleRateLimitBuckets(idleSince time.Time) api.Error {
+	if err := deleteTokenBucketsUpdatedBefore(s.db, idleSince); err != nil {
+		log.Printf("Deleting idle rate limit buckets failed: %v\n", err)
+		return api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+	return nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
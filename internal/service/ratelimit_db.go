@@ -0,0 +1,38 @@
+package service
+
+import "time"
+
+// lockTokenBucket returns the bucket of key, creating it as given when
+// missing, and locks it until the end of tx.
+func lockTokenBucket(tx queryer, key string, initial TokenBucket) (TokenBucket, error) {
+	insert := `
+		INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO NOTHING
+	`
+	if _, err := tx.Exec(insert, key, initial.Tokens, initial.UpdatedAt); err != nil {
+		return TokenBucket{}, err
+	}
+
+	var bucket TokenBucket
+	err := tx.QueryRow(
+		"SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE",
+		key,
+	).Scan(&bucket.Tokens, &bucket.UpdatedAt)
+	return bucket, err
+}
+
+func updateTokenBucket(tx queryer, key string, bucket TokenBucket) error {
+	_, err := tx.Exec(
+		"UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE key = $1",
+		key,
+		bucket.Tokens,
+		bucket.UpdatedAt,
+	)
+	return err
+}
+
+func deleteTokenBucketsUpdatedBefore(db queryer, before time.Time) error {
+	_, err := db.Exec("DELETE FROM rate_limit_buckets WHERE updated_at < $1", before)
+	return err
+}
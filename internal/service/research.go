@@ -0,0 +1,168 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// Dates of entries of a participant are shifted by 1 to
+// MAX_RESEARCH_DATE_SHIFT_WEEKS weeks back or forth.
+const MAX_RESEARCH_DATE_SHIFT_WEEKS = 26
+
+// researchParticipant is an account as it appears in a research dataset.
+type researchParticipant struct {
+	Id         string
+	ShiftWeeks int
+}
+
+// ExportResearchDataset returns a de-identified dataset of entries selected
+// by the filter within the tenant of the caller. Accounts are replaced by
+// participant IDs and dates are shifted per participant, both derived from
+// the study, so that exports of a study are consistent with each other but
+// cannot be linked to exports of other studies. Fails with ERR_INVALID when
+// the dataset has fewer participants than the minimum cohort size. Only
+// admins may export it.
+func (s *SleepDiaryService) ExportResearchDataset(caller api.Principal, filter api.ResearchExportFilterDto) (api.ResearchDatasetDto, api.Error) {
+	errs := filter.Validate()
+	if len(errs) > 0 {
+		return api.ResearchDatasetDto{}, api.NewValidationError("invalid research export", errs)
+	}
+	if !caller.HasScope(api.SCOPE_ADMIN) {
+		return api.ResearchDatasetDto{}, api.NewError("research export not accessible", api.ERR_FORBIDDEN)
+	}
+	if s.researchExportSecret == "" {
+		return api.ResearchDatasetDto{}, api.NewError("research export not enabled", api.ERR_FORBIDDEN)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return api.ResearchDatasetDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	entries, err := getResearchSleepDiaryEntries(tx, filter)
+	if err != nil {
+		log.Printf("Reading entries of study %s failed: %v\n", filter.StudyId, err)
+		return api.ResearchDatasetDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	accountUuids := []string{}
+	entryIds := make([]int64, len(entries))
+	for i, entry := range entries {
+		// Entries are ordered by account.
+		if i == 0 || entry.AccountUuid != entries[i-1].AccountUuid {
+			accountUuids = append(accountUuids, entry.AccountUuid)
+		}
+		entryIds[i] = entry.Id
+	}
+	if len(accountUuids) < s.researchMinCohortSize {
+		return api.ResearchDatasetDto{}, api.NewError("cohort smaller than minimum size", api.ERR_INVALID)
+	}
+
+	if err := recordAudit(tx, caller, api.ExportResearchAuditAction, accountUuids, entryIds); err != nil {
+		log.Printf("Recording export of study %s failed: %v\n", filter.StudyId, err)
+		return api.ResearchDatasetDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing export of study %s failed: %v\n", filter.StudyId, err)
+		return api.ResearchDatasetDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	studyKey := s.studyKey(caller.Tenant(), filter.StudyId)
+	participants := map[string]researchParticipant{}
+	for _, accountUuid := range accountUuids {
+		participants[accountUuid] = newResearchParticipant(studyKey, accountUuid)
+	}
+
+	dataset := api.ResearchDatasetDto{
+		StudyId:           filter.StudyId,
+		ExportedAt:        time.Now().UTC(),
+		ParticipantsCount: len(participants),
+		Comments:          filter.Comments,
+		Entries:           make([]api.ResearchEntryDto, len(entries)),
+	}
+	for i, entry := range entries {
+		participant := participants[entry.AccountUuid]
+		dto, err := toResearchEntryDto(entry, participant, filter.Comments)
+		if err != nil {
+			log.Printf("Converting entry %d to research DTO failed: %v\n", entry.Id, err)
+			return api.ResearchDatasetDto{}, api.NewError("conversion failed", api.ERR_UNKNOWN)
+		}
+		dataset.Entries[i] = dto
+	}
+	// Ordered by participant rather than account, so that the order does not
+	// reveal accounts either.
+	sort.SliceStable(dataset.Entries, func(i, j int) bool {
+		return dataset.Entries[i].ParticipantId < dataset.Entries[j].ParticipantId
+	})
+	return dataset, nil
+}
+
+// studyKey derives the key of a study of a tenant from the research export
+// secret.
+func (s *SleepDiaryService) studyKey(tenantId string, studyId string) []byte {
+	mac := hmac.New(sha256.New, []byte(s.researchExportSecret))
+	mac.Write([]byte(tenantId))
+	mac.Write([]byte{0})
+	mac.Write([]byte(studyId))
+	return mac.Sum(nil)
+}
+
+// newResearchParticipant derives the participant of an account from
+// HMAC-SHA256 of the account UUID keyed by the study key: its ID from the
+// first half of the MAC and its date shift from the rest.
+func newResearchParticipant(studyKey []byte, accountUuid string) researchParticipant {
+	mac := hmac.New(sha256.New, studyKey)
+	mac.Write([]byte(strings.ToLower(accountUuid)))
+	sum := mac.Sum(nil)
+
+	weeks := int(binary.BigEndian.Uint64(sum[16:24])%MAX_RESEARCH_DATE_SHIFT_WEEKS) + 1
+	if sum[24]&1 == 1 {
+		weeks = -weeks
+	}
+	return researchParticipant{Id: hex.EncodeToString(sum[:16]), ShiftWeeks: weeks}
+}
+
+// toResearchEntryDto converts an entry with times shifted by whole weeks of
+// the local calendar of the entry, so that weekdays and local times of day
+// stay the same across daylight saving time changes. Identifiers and
+// timestamps of the entry itself are left out.
+func toResearchEntryDto(entry SleepDiaryEntry, participant researchParticipant, comments api.ResearchCommentsMode) (api.ResearchEntryDto, error) {
+	tz, err := time.LoadLocation(entry.Timezone)
+	if err != nil {
+		return api.ResearchEntryDto{}, err
+	}
+	shift := func(t time.Time) time.Time {
+		return t.In(tz).AddDate(0, 0, 7*participant.ShiftWeeks)
+	}
+	entry.TriedToSleepAt = shift(entry.TriedToSleepAt)
+	entry.FinalWakeUpAt = shift(entry.FinalWakeUpAt)
+	if entry.InBedAt.Valid {
+		entry.InBedAt.Time = shift(entry.InBedAt.Time)
+	}
+	if entry.OutOfBedAt.Valid {
+		entry.OutOfBedAt.Time = shift(entry.OutOfBedAt.Time)
+	}
+	hasComments := entry.Comments.Valid
+	entry.Comments = sql.NullString{}
+
+	dto := api.ResearchEntryDto{ParticipantId: participant.Id}
+	if err := assignEntryToDto(entry, &dto.SleepDiaryEntryDataDto); err != nil {
+		return api.ResearchEntryDto{}, err
+	}
+	if hasComments && comments == api.RedactResearchCommentsMode {
+		redacted := api.REDACTED_COMMENTS
+		dto.Comments = &redacted
+	}
+	return dto, nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mabzd/snorlax/api"
+)
+
+// getResearchSleepDiaryEntries returns entries selected by the filter ordered
+// by account. Comments are returned as stored, possibly encrypted, as
+// research datasets only tell whether there are any.
+func getResearchSleepDiaryEntries(db queryer, filter api.ResearchExportFilterDto) ([]SleepDiaryEntry, error) {
+	whereClauses := []string{"TRUE"}
+	args := []any{}
+	if len(filter.AccountUuid) > 0 {
+		args = append(args, pq.Array(filter.AccountUuid))
+		whereClauses = append(whereClauses, fmt.Sprintf("account_uuid = ANY($%d::uuid[])", len(args)))
+	}
+	if filter.FromDate != nil {
+		args = append(args, *filter.FromDate)
+		whereClauses = append(whereClauses, fmt.Sprintf("tried_to_sleep_at >= $%d", len(args)))
+	}
+	if filter.ToDate != nil {
+		args = append(args, *filter.ToDate)
+		whereClauses = append(whereClauses, fmt.Sprintf("tried_to_sleep_at < $%d", len(args)))
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM sleep_diary_entries WHERE %s ORDER BY account_uuid, tried_to_sleep_at",
+		entryColumns,
+		strings.Join(whereClauses, " AND "))
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []SleepDiaryEntry{}
+	for rows.Next() {
+		var entry SleepDiaryEntry
+		err := rows.Scan(
+			&entry.Id,
+			&entry.AccountUuid,
+			&entry.Timezone,
+			&entry.InBedAt,
+			&entry.TriedToSleepAt,
+			&entry.SleepDelayInMin,
+			&entry.AwakeningsCount,
+			&entry.AwakeningsTotalDurationInMin,
+			&entry.FinalWakeUpAt,
+			&entry.OutOfBedAt,
+			&entry.SleepQuality,
+			&entry.Comments,
+			&entry.CreatedAt,
+			&entry.UpdatedAt,
+			&entry.Version,
+			&entry.Uuid,
+			&entry.CommentsKeyId,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
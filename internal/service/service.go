@@ -3,14 +3,32 @@ package service
 import (
 	"database/sql"
 	"log"
+	"strconv"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/mabzd/snorlax/api"
 	"github.com/mabzd/snorlax/internal/config"
 	"github.com/mabzd/snorlax/internal/database"
 )
 
 type SleepDiaryService struct {
-	db *sql.DB
+	db      *sql.DB
+	changes *changeNotifier
+	// Secret signing receipts of account deletions; receipts are not signed
+	// when it is empty.
+	deletionReceiptSecret string
+	// Master keys wrapping data keys encrypting comments; comments are not
+	// encrypted when nil.
+	masterKeys *masterKeys
+	// Secret deriving keys of studies of research datasets; research
+	// exports are disabled when it is empty.
+	researchExportSecret  string
+	researchMinCohortSize int
+	// Maximum sizes of an uploaded import file and of a file extracted from
+	// an uploaded archive, in bytes.
+	importMaxSize          int64
+	importMaxExtractedSize int64
 }
 
 func NewSleepDiaryService(cfg config.Config) *SleepDiaryService {
@@ -18,13 +36,70 @@ func NewSleepDiaryService(cfg config.Config) *SleepDiaryService {
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
+	masterKeys, err := newMasterKeys(cfg.EncryptionKey, cfg.PreviousEncryptionKeys)
+	if err != nil {
+		log.Fatalf("Failed to read encryption keys: %v", err)
+	}
 	return &SleepDiaryService{
-		db: db,
+		db:                     db,
+		changes:                newChangeNotifier(database.ConnString(cfg)),
+		deletionReceiptSecret:  cfg.DeletionReceiptSecret,
+		masterKeys:             masterKeys,
+		researchExportSecret:   cfg.ResearchExportSecret,
+		researchMinCohortSize:  cfg.ResearchMinCohortSize,
+		importMaxSize:          int64(cfg.ImportMaxSizeInMb) << 20,
+		importMaxExtractedSize: int64(cfg.ImportMaxExtractedSizeInMb) << 20,
+	}
+}
+
+// ImportMaxSizes returns the maximum sizes of an uploaded import file and of
+// a file extracted from an uploaded archive, in bytes.
+func (s *SleepDiaryService) ImportMaxSizes() (int64, int64) {
+	return s.importMaxSize, s.importMaxExtractedSize
+}
+
+// ResolveEntryId returns ID of the entry referenced either by its numeric ID
+// or by its UUID, failing with ERR_NOT_FOUND for entries the caller may not
+// access. Numeric IDs of admins are returned as they are, without checking
+// that the entry exists.
+func (s *SleepDiaryService) ResolveEntryId(caller api.Principal, ref string) (int64, api.Error) {
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return 0, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	id, err := strconv.ParseInt(ref, 10, 64)
+	if err != nil {
+		if _, err := uuid.Parse(ref); err != nil {
+			return 0, api.NewError("invalid ID format", api.ERR_INVALID)
+		}
+		id, err = getSleepDiaryEntryIdByUuid(tx, ref)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return 0, api.NewError("entry not found", api.ERR_NOT_FOUND)
+			}
+			log.Printf("Reading entry by UUID %s failed: %v\n", ref, err)
+			return 0, api.NewError("read failed", api.ERR_UNKNOWN)
+		}
+	}
+
+	if serviceErr := s.checkEntryAccess(tx, caller, id, readAccess); serviceErr != nil {
+		return 0, serviceErr
 	}
+	return id, nil
 }
 
-func (s *SleepDiaryService) GetEntryById(id int64) (api.SleepDiaryEntryDto, api.Error) {
-	entry, err := getSleepDiaryEntryById(s.db, id)
+func (s *SleepDiaryService) GetEntryById(caller api.Principal, id int64) (api.SleepDiaryEntryDto, api.Error) {
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return api.SleepDiaryEntryDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	entry, err := getSleepDiaryEntryById(tx, s.commentKeys(tx), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return api.SleepDiaryEntryDto{}, api.NewError("entry not found", api.ERR_NOT_FOUND)
@@ -32,6 +107,22 @@ func (s *SleepDiaryService) GetEntryById(id int64) (api.SleepDiaryEntryDto, api.
 		log.Printf("Reading entry by ID %d failed: %v\n", id, err)
 		return api.SleepDiaryEntryDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
 	}
+	accessible, serviceErr := s.canAccess(tx, caller, entry.AccountUuid, readAccess)
+	if serviceErr != nil {
+		return api.SleepDiaryEntryDto{}, serviceErr
+	}
+	if !accessible {
+		return api.SleepDiaryEntryDto{}, api.NewError("entry not found", api.ERR_NOT_FOUND)
+	}
+
+	if err := recordAudit(tx, caller, api.ReadEntryAuditAction, []string{entry.AccountUuid}, []int64{id}); err != nil {
+		log.Printf("Recording read of entry %d failed: %v\n", id, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing read of entry %d failed: %v\n", id, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
 
 	dto, err := toSleepDiaryEntryDto(entry)
 	if err != nil {
@@ -42,24 +133,59 @@ func (s *SleepDiaryService) GetEntryById(id int64) (api.SleepDiaryEntryDto, api.
 	return dto, nil
 }
 
-func (s *SleepDiaryService) GetEntriesByFilter(filter api.SleepDiaryFilterDto) (api.PageDto[api.SleepDiaryEntryDto], api.Error) {
+// GetEntriesByFilter returns a page of entries matching the filter. Accounts
+// of the filter the caller neither owns nor holds a grant to are left out.
+func (s *SleepDiaryService) GetEntriesByFilter(caller api.Principal, filter api.SleepDiaryFilterDto) (api.PageDto[api.SleepDiaryEntryDto], api.Error) {
 	errs := filter.Validate()
 	if len(errs) > 0 {
 		return api.PageDto[api.SleepDiaryEntryDto]{}, api.NewValidationError("invalid filter data", errs)
 	}
 
-	count, err := countSleepDiaryEntriesByFilter(s.db, filter)
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return api.PageDto[api.SleepDiaryEntryDto]{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	accessible, serviceErr := s.accessibleAccounts(tx, caller, filter.AccountUuid, readAccess)
+	if serviceErr != nil {
+		return api.PageDto[api.SleepDiaryEntryDto]{}, serviceErr
+	}
+	filter.AccountUuid = accessible
+	if len(filter.AccountUuid) == 0 {
+		return api.PageDto[api.SleepDiaryEntryDto]{
+			PageSize:   filter.PageSize,
+			PageNumber: filter.PageNumber,
+			Items:      []api.SleepDiaryEntryDto{},
+		}, nil
+	}
+
+	count, err := countSleepDiaryEntriesByFilter(tx, filter)
 	if err != nil {
 		log.Printf("Counting entries by filter %v failed: %v\n", filter, err)
 		return api.PageDto[api.SleepDiaryEntryDto]{}, api.NewError("count failed", api.ERR_UNKNOWN)
 	}
 
-	entries, err := getSleepDiaryEntriesByFilter(s.db, filter)
+	entries, err := getSleepDiaryEntriesByFilter(tx, s.commentKeys(tx), filter)
 	if err != nil {
 		log.Printf("Reading entries by filter %v failed: %v\n", filter, err)
 		return api.PageDto[api.SleepDiaryEntryDto]{}, api.NewError("read failed", api.ERR_UNKNOWN)
 	}
 
+	entryIds := make([]int64, len(entries))
+	for i, entry := range entries {
+		entryIds[i] = entry.Id
+	}
+	if err := recordAudit(tx, caller, api.ListEntriesAuditAction, filter.AccountUuid, entryIds); err != nil {
+		log.Printf("Recording read of entries by filter %v failed: %v\n", filter, err)
+		return api.PageDto[api.SleepDiaryEntryDto]{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing read of entries by filter %v failed: %v\n", filter, err)
+		return api.PageDto[api.SleepDiaryEntryDto]{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
 	items := make([]api.SleepDiaryEntryDto, len(entries))
 	for i, entry := range entries {
 		dto, err := toSleepDiaryEntryDto(entry)
@@ -78,19 +204,51 @@ func (s *SleepDiaryService) GetEntriesByFilter(filter api.SleepDiaryFilterDto) (
 	}, nil
 }
 
-func (s *SleepDiaryService) CreateEntry(dto api.CreateSleepDiaryEntryDto) (api.SleepDiaryEntryDto, api.Error) {
+func (s *SleepDiaryService) CreateEntry(caller api.Principal, dto api.CreateSleepDiaryEntryDto) (api.SleepDiaryEntryDto, api.Error) {
 	errs := dto.Validate()
 	if len(errs) > 0 {
 		return api.SleepDiaryEntryDto{}, api.NewValidationError("invalid create data", errs)
 	}
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting insert transaction failed: %v\n", err)
+		return api.SleepDiaryEntryDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	accessible, serviceErr := s.canAccess(tx, caller, dto.AccountUuid, writeAccess)
+	if serviceErr != nil {
+		return api.SleepDiaryEntryDto{}, serviceErr
+	}
+	if !accessible {
+		return api.SleepDiaryEntryDto{}, api.NewError("account not accessible", api.ERR_FORBIDDEN)
+	}
 
 	entry := fromCreateSleepDiaryEntryDto(dto)
-	createdEntry, err := insertSleepDiaryEntry(s.db, entry)
+	createdEntry, err := insertSleepDiaryEntry(tx, s.commentKeys(tx), entry)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return api.SleepDiaryEntryDto{}, api.NewError("entry with this UUID already exists", api.ERR_CONFLICT)
+		}
 		log.Printf("Inserting entry %v failed: %v\n", dto, err)
 		return api.SleepDiaryEntryDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
 	}
 
+	if err := recordEntryChange(tx, createdEntry, api.CHANGE_CREATED); err != nil {
+		log.Printf("Recording creation of entry %d failed: %v\n", createdEntry.Id, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+
+	if err := recordAudit(tx, caller, api.CreateEntryAuditAction, []string{createdEntry.AccountUuid}, []int64{createdEntry.Id}); err != nil {
+		log.Printf("Recording creation of entry %d in audit log failed: %v\n", createdEntry.Id, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing entry %v failed: %v\n", dto, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+
 	createdDto, err := toSleepDiaryEntryDto(createdEntry)
 	if err != nil {
 		log.Printf("Converting entry %d to DTO failed: %v\n", createdEntry.Id, err)
@@ -100,15 +258,28 @@ func (s *SleepDiaryService) CreateEntry(dto api.CreateSleepDiaryEntryDto) (api.S
 	return createdDto, nil
 }
 
-func (s *SleepDiaryService) UpdateEntry(id int64, dto api.UpdateSleepDiaryEntryDto) (api.SleepDiaryEntryDto, api.Error) {
+func (s *SleepDiaryService) UpdateEntry(caller api.Principal, id int64, dto api.UpdateSleepDiaryEntryDto) (api.SleepDiaryEntryDto, api.Error) {
 	errs := dto.Validate()
 	if len(errs) > 0 {
 		return api.SleepDiaryEntryDto{}, api.NewValidationError("invalid update data", errs)
 	}
 
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting update transaction failed: %v\n", err)
+		return api.SleepDiaryEntryDto{}, api.NewError("update failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	// The account of an entry never changes, so it is checked before the
+	// update.
+	if serviceErr := s.checkEntryAccess(tx, caller, id, writeAccess); serviceErr != nil {
+		return api.SleepDiaryEntryDto{}, serviceErr
+	}
+
 	entry := fromUpdateSleepDiaryEntryDto(dto)
 	entry.Id = id
-	updatedEntry, err := updateSleepDiaryEntry(s.db, entry)
+	updatedEntry, err := updateSleepDiaryEntryInTx(tx, s.commentKeys(tx), entry)
 	if err != nil {
 		if err == ErrConflict {
 			return api.SleepDiaryEntryDto{}, api.NewError("version conflict", api.ERR_CONFLICT)
@@ -120,6 +291,15 @@ func (s *SleepDiaryService) UpdateEntry(id int64, dto api.UpdateSleepDiaryEntryD
 		return api.SleepDiaryEntryDto{}, api.NewError("update failed", api.ERR_UNKNOWN)
 	}
 
+	if err := recordAudit(tx, caller, api.UpdateEntryAuditAction, []string{updatedEntry.AccountUuid}, []int64{id}); err != nil {
+		log.Printf("Recording update of entry %d failed: %v\n", id, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("update failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing update of entry %d failed: %v\n", id, err)
+		return api.SleepDiaryEntryDto{}, api.NewError("update failed", api.ERR_UNKNOWN)
+	}
+
 	updatedDto, err := toSleepDiaryEntryDto(updatedEntry)
 	if err != nil {
 		log.Printf("Converting entry %d to DTO failed: %v\n", updatedDto.Id, err)
@@ -128,3 +308,89 @@ func (s *SleepDiaryService) UpdateEntry(id int64, dto api.UpdateSleepDiaryEntryD
 
 	return updatedDto, nil
 }
+
+// DeleteEntry removes an entry along with its sleep stages.
+func (s *SleepDiaryService) DeleteEntry(caller api.Principal, id int64) api.Error {
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting delete transaction failed: %v\n", err)
+		return api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if serviceErr := s.checkEntryAccess(tx, caller, id, writeAccess); serviceErr != nil {
+		return serviceErr
+	}
+
+	deletedEntry, err := deleteSleepDiaryEntry(tx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return api.NewError("entry not found", api.ERR_NOT_FOUND)
+		}
+		log.Printf("Deleting entry %d failed: %v\n", id, err)
+		return api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+
+	if err := recordEntryChange(tx, deletedEntry, api.CHANGE_DELETED); err != nil {
+		log.Printf("Recording deletion of entry %d failed: %v\n", id, err)
+		return api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+
+	if err := recordAudit(tx, caller, api.DeleteEntryAuditAction, []string{deletedEntry.AccountUuid}, []int64{id}); err != nil {
+		log.Printf("Recording deletion of entry %d in audit log failed: %v\n", id, err)
+		return api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing deletion of entry %d failed: %v\n", id, err)
+		return api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+	return nil
+}
+
+// GetAllEntriesByAccount reads all entries of an account in given date range
+// page by page. Unlike filters, which leave out accounts the caller may not
+// access, it fails with ERR_NOT_FOUND for such an account.
+func (s *SleepDiaryService) GetAllEntriesByAccount(caller api.Principal, accountUuid string, fromDate *time.Time, toDate *time.Time) ([]api.SleepDiaryEntryDto, api.Error) {
+	if err := api.ValidateAccountUuid(accountUuid); err != nil {
+		return nil, api.NewValidationError("invalid account", []error{err})
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	serviceErr := s.checkAccountAccess(tx, caller, accountUuid, readAccess)
+	tx.Rollback()
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	return s.GetAllEntriesByAccounts(caller, []string{accountUuid}, fromDate, toDate)
+}
+
+// GetAllEntriesByAccounts reads all entries of several accounts in given date
+// range page by page.
+func (s *SleepDiaryService) GetAllEntriesByAccounts(caller api.Principal, accountUuids []string, fromDate *time.Time, toDate *time.Time) ([]api.SleepDiaryEntryDto, api.Error) {
+	filter := api.SleepDiaryFilterDto{
+		AccountUuid: accountUuids,
+		FromDate:    fromDate,
+		ToDate:      toDate,
+		PageSize:    api.MAX_PAGE_SIZE,
+		PageNumber:  1,
+	}
+
+	entries := []api.SleepDiaryEntryDto{}
+	for {
+		page, err := s.GetEntriesByFilter(caller, filter)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, page.Items...)
+		if int64(len(entries)) >= page.TotalCount || len(page.Items) == 0 {
+			return entries, nil
+		}
+		filter.PageNumber++
+	}
+}
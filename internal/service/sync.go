@@ -0,0 +1,372 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// Prefix of sync tokens, versioning their format.
+const syncTokenPrefix = "v1."
+
+// GetSyncChanges returns entries of an account changed since the change
+// encoded in token, up to MAX_CHANGES_BATCH changes at once. Without a token
+// it returns all entries of the account.
+func (s *SleepDiaryService) GetSyncChanges(caller api.Principal, accountUuid string, token string) (api.SyncDto, api.Error) {
+	errs := []error{}
+	if err := api.ValidateAccountUuid(accountUuid); err != nil {
+		errs = append(errs, err)
+	}
+	afterId, ok := decodeSyncToken(token)
+	if !ok {
+		errs = append(errs, api.NewFieldError("/since", api.RULE_FORMAT, map[string]any{"format": "sync-token"}, "invalid sync token '%s'", token))
+	}
+	if len(errs) > 0 {
+		return api.SyncDto{}, api.NewValidationError("invalid sync request", errs)
+	}
+
+	if token == "" {
+		return s.getSyncSnapshot(caller, accountUuid)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return api.SyncDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if serviceErr := s.checkAccountAccess(tx, caller, accountUuid, readAccess); serviceErr != nil {
+		return api.SyncDto{}, serviceErr
+	}
+
+	changes, entries, err := getSleepDiaryEntryChanges(tx, s.commentKeys(tx), accountUuid, afterId, MAX_CHANGES_BATCH)
+	if err != nil {
+		log.Printf("Reading changes of account %s failed: %v\n", accountUuid, err)
+		return api.SyncDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	if err := recordAudit(tx, caller, api.PullSyncAuditAction, []string{accountUuid}, changedEntryIds(changes)); err != nil {
+		log.Printf("Recording sync of account %s failed: %v\n", accountUuid, err)
+		return api.SyncDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing sync of account %s failed: %v\n", accountUuid, err)
+		return api.SyncDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	dto := api.SyncDto{
+		Entries:   []api.SleepDiaryEntryDto{},
+		Deleted:   []api.TombstoneDto{},
+		NextToken: token,
+		HasMore:   len(changes) == MAX_CHANGES_BATCH,
+	}
+	// Several changes of an entry collapse into its current state; entries
+	// deleted by now collapse into a tombstone.
+	seen := map[int64]int{}
+	for i, change := range changes {
+		dto.NextToken = encodeSyncToken(change.Id)
+		index, ok := seen[change.EntryId]
+		if entries[i] == nil {
+			tombstone := api.TombstoneDto{
+				Id:        change.EntryId,
+				Uuid:      change.EntryUuid.String,
+				Version:   change.Version,
+				DeletedAt: change.ChangedAt,
+			}
+			if ok {
+				dto.Deleted[index] = tombstone
+			} else {
+				seen[change.EntryId] = len(dto.Deleted)
+				dto.Deleted = append(dto.Deleted, tombstone)
+			}
+			continue
+		}
+		if ok {
+			continue
+		}
+
+		entryDto, err := toSleepDiaryEntryDto(*entries[i])
+		if err != nil {
+			log.Printf("Converting entry %d to DTO failed: %v\n", change.EntryId, err)
+			return api.SyncDto{}, api.NewError("conversion failed", api.ERR_UNKNOWN)
+		}
+		seen[change.EntryId] = len(dto.Entries)
+		dto.Entries = append(dto.Entries, entryDto)
+	}
+	return dto, nil
+}
+
+// getSyncSnapshot returns all entries of an account with a token of the
+// latest change. The token is taken first, so that changes made while
+// entries are read are returned again by the next sync.
+func (s *SleepDiaryService) getSyncSnapshot(caller api.Principal, accountUuid string) (api.SyncDto, api.Error) {
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return api.SyncDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if serviceErr := s.checkAccountAccess(tx, caller, accountUuid, readAccess); serviceErr != nil {
+		return api.SyncDto{}, serviceErr
+	}
+
+	latestId, err := getLatestSleepDiaryEntryChangeId(tx, accountUuid)
+	if err != nil {
+		log.Printf("Reading latest change of account %s failed: %v\n", accountUuid, err)
+		return api.SyncDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	entries, err := getAllSleepDiaryEntriesByAccount(tx, s.commentKeys(tx), accountUuid)
+	if err != nil {
+		log.Printf("Reading entries of account %s failed: %v\n", accountUuid, err)
+		return api.SyncDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	entryIds := make([]int64, len(entries))
+	for i, entry := range entries {
+		entryIds[i] = entry.Id
+	}
+	if err := recordAudit(tx, caller, api.PullSyncAuditAction, []string{accountUuid}, entryIds); err != nil {
+		log.Printf("Recording sync of account %s failed: %v\n", accountUuid, err)
+		return api.SyncDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing sync of account %s failed: %v\n", accountUuid, err)
+		return api.SyncDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	dto := api.SyncDto{
+		Entries:   make([]api.SleepDiaryEntryDto, len(entries)),
+		Deleted:   []api.TombstoneDto{},
+		NextToken: encodeSyncToken(latestId),
+	}
+	for i, entry := range entries {
+		entryDto, err := toSleepDiaryEntryDto(entry)
+		if err != nil {
+			log.Printf("Converting entry %d to DTO failed: %v\n", entry.Id, err)
+			return api.SyncDto{}, api.NewError("conversion failed", api.ERR_UNKNOWN)
+		}
+		dto.Entries[i] = entryDto
+	}
+	return dto, nil
+}
+
+// PushSyncChanges applies changes made by a client in order. Each change is
+// applied in its own transaction; changes conflicting with the server state
+// are skipped and reported along with the current entry.
+func (s *SleepDiaryService) PushSyncChanges(caller api.Principal, dto api.SyncPushDto) (api.SyncPushResultDto, api.Error) {
+	errs := dto.Validate()
+	if len(errs) > 0 {
+		return api.SyncPushResultDto{}, api.NewValidationError("invalid sync push", errs)
+	}
+	if serviceErr := s.checkSyncAccess(caller, dto.AccountUuid); serviceErr != nil {
+		return api.SyncPushResultDto{}, serviceErr
+	}
+
+	results := make([]api.SyncResultDto, len(dto.Changes))
+	for i, change := range dto.Changes {
+		var entry *SleepDiaryEntry
+		var err error
+		switch {
+		case change.Version == nil:
+			entry, err = s.createSyncedEntry(caller, dto.AccountUuid, change)
+		case change.Deleted:
+			err = s.deleteSyncedEntry(caller, dto.AccountUuid, change)
+		default:
+			entry, err = s.updateSyncedEntry(caller, dto.AccountUuid, change)
+		}
+
+		results[i] = api.SyncResultDto{Uuid: change.Uuid, Status: api.AppliedSyncStatus}
+		if err == ErrConflict {
+			results[i].Status = api.ConflictSyncStatus
+			entry, err = s.getSyncedEntry(caller, dto.AccountUuid, change.Uuid)
+			if err == nil && entry != nil {
+				err = s.audit(caller, api.ReadEntryAuditAction, []string{entry.AccountUuid}, []int64{entry.Id})
+			}
+		}
+		if serviceErr, ok := err.(api.Error); ok {
+			return api.SyncPushResultDto{}, serviceErr
+		}
+		if err != nil {
+			log.Printf("Applying change of entry %s failed: %v\n", change.Uuid, err)
+			return api.SyncPushResultDto{}, api.NewError("push failed", api.ERR_UNKNOWN)
+		}
+		if entry != nil {
+			entryDto, err := toSleepDiaryEntryDto(*entry)
+			if err != nil {
+				log.Printf("Converting entry %d to DTO failed: %v\n", entry.Id, err)
+				return api.SyncPushResultDto{}, api.NewError("conversion failed", api.ERR_UNKNOWN)
+			}
+			results[i].Entry = &entryDto
+		}
+	}
+	return api.SyncPushResultDto{Results: results}, nil
+}
+
+// checkSyncAccess fails unless the caller may write entries of an account,
+// before any change of a push is applied.
+func (s *SleepDiaryService) checkSyncAccess(caller api.Principal, accountUuid string) api.Error {
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return api.NewError("push failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	return s.checkAccountAccess(tx, caller, accountUuid, writeAccess)
+}
+
+// createSyncedEntry creates an entry with client-generated UUID; a taken
+// UUID is a conflict.
+func (s *SleepDiaryService) createSyncedEntry(caller api.Principal, accountUuid string, change api.SyncChangeDto) (*SleepDiaryEntry, error) {
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if serviceErr := s.checkAccountAccess(tx, caller, accountUuid, writeAccess); serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	entry := fromCreateSleepDiaryEntryDto(api.CreateSleepDiaryEntryDto{
+		AccountUuid:            accountUuid,
+		SleepDiaryEntryDataDto: *change.Entry,
+	})
+	entry.Uuid = change.Uuid
+	createdEntry, err := insertSleepDiaryEntry(tx, s.commentKeys(tx), entry)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	if err := recordEntryChange(tx, createdEntry, api.CHANGE_CREATED); err != nil {
+		return nil, err
+	}
+	if err := recordAudit(tx, caller, api.CreateEntryAuditAction, []string{accountUuid}, []int64{createdEntry.Id}); err != nil {
+		return nil, err
+	}
+	return &createdEntry, tx.Commit()
+}
+
+// updateSyncedEntry updates an entry of an account with the optimistic
+// locking of UpdateEntry; a missing entry is a conflict.
+func (s *SleepDiaryService) updateSyncedEntry(caller api.Principal, accountUuid string, change api.SyncChangeDto) (*SleepDiaryEntry, error) {
+	current, err := s.getSyncedEntry(caller, accountUuid, change.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrConflict
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if serviceErr := s.checkAccountAccess(tx, caller, accountUuid, writeAccess); serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	entry := fromUpdateSleepDiaryEntryDto(api.UpdateSleepDiaryEntryDto{
+		Version:                change.Version,
+		SleepDiaryEntryDataDto: *change.Entry,
+	})
+	entry.Id = current.Id
+	updatedEntry, err := updateSleepDiaryEntryInTx(tx, s.commentKeys(tx), entry)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	if err := recordAudit(tx, caller, api.UpdateEntryAuditAction, []string{updatedEntry.AccountUuid}, []int64{updatedEntry.Id}); err != nil {
+		return nil, err
+	}
+	return &updatedEntry, tx.Commit()
+}
+
+// deleteSyncedEntry deletes an entry of an account if it is still in the
+// version known to the client. Deleting a deleted entry succeeds.
+func (s *SleepDiaryService) deleteSyncedEntry(caller api.Principal, accountUuid string, change api.SyncChangeDto) error {
+	current, err := s.getSyncedEntry(caller, accountUuid, change.Uuid)
+	if err != nil || current == nil {
+		return err
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if serviceErr := s.checkAccountAccess(tx, caller, accountUuid, writeAccess); serviceErr != nil {
+		return serviceErr
+	}
+
+	deletedEntry, err := deleteSleepDiaryEntry(tx, current.Id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	if deletedEntry.Version.Int64 != *change.Version {
+		return ErrConflict
+	}
+	if err := recordEntryChange(tx, deletedEntry, api.CHANGE_DELETED); err != nil {
+		return err
+	}
+	if err := recordAudit(tx, caller, api.DeleteEntryAuditAction, []string{deletedEntry.AccountUuid}, []int64{deletedEntry.Id}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// getSyncedEntry returns an entry of an account by UUID, or nil if there is
+// no such entry. Entries of other accounts are not revealed.
+func (s *SleepDiaryService) getSyncedEntry(caller api.Principal, accountUuid string, uuid string) (*SleepDiaryEntry, error) {
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	entry, err := getSleepDiaryEntryByUuid(tx, s.commentKeys(tx), uuid)
+	if err == sql.ErrNoRows || (err == nil && !strings.EqualFold(entry.AccountUuid, accountUuid)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func encodeSyncToken(changeId int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(syncTokenPrefix + strconv.FormatInt(changeId, 10)))
+}
+
+// decodeSyncToken returns ID of the change encoded in token; an empty token
+// decodes to 0.
+func decodeSyncToken(token string) (int64, bool) {
+	if token == "" {
+		return 0, true
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(decoded), syncTokenPrefix) {
+		return 0, false
+	}
+	changeId, err := strconv.ParseInt(strings.TrimPrefix(string(decoded), syncTokenPrefix), 10, 64)
+	if err != nil || changeId < 0 {
+		return 0, false
+	}
+	return changeId, true
+}
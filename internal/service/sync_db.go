@@ -0,0 +1,53 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+func getSleepDiaryEntryByUuid(db queryer, keys *commentKeys, uuid string) (SleepDiaryEntry, error) {
+	query := `
+		SELECT ` + entryColumns + `
+		FROM sleep_diary_entries
+		WHERE uuid = $1
+	`
+	row := db.QueryRow(query, uuid)
+
+	var entry SleepDiaryEntry
+	err := row.Scan(
+		&entry.Id,
+		&entry.AccountUuid,
+		&entry.Timezone,
+		&entry.InBedAt,
+		&entry.TriedToSleepAt,
+		&entry.SleepDelayInMin,
+		&entry.AwakeningsCount,
+		&entry.AwakeningsTotalDurationInMin,
+		&entry.FinalWakeUpAt,
+		&entry.OutOfBedAt,
+		&entry.SleepQuality,
+		&entry.Comments,
+		&entry.CreatedAt,
+		&entry.UpdatedAt,
+		&entry.Version,
+		&entry.Uuid,
+		&entry.CommentsKeyId,
+	)
+	if err != nil {
+		return SleepDiaryEntry{}, err
+	}
+	return entry, keys.decryptEntry(&entry)
+}
+
+func getSleepDiaryEntryIdByUuid(db queryer, uuid string) (int64, error) {
+	var id int64
+	err := db.QueryRow("SELECT id FROM sleep_diary_entries WHERE uuid = $1", uuid).Scan(&id)
+	return id, err
+}
+
+// isUniqueViolation tells whether err is caused by a duplicate key.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
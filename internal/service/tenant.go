@@ -0,0 +1,31 @@
+package service
+
+import (
+	"database/sql"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// TENANT_ROLE is the database role tenant transactions run as. The service
+// may connect as the owner of the tables or a superuser, which row-level
+// security policies do not restrict; this role they do.
+const TENANT_ROLE = "snorlax_tenant"
+
+// beginTenantTx starts a transaction seeing and writing only rows of the
+// tenant of the caller. Tables with tenant data must only be accessed in such
+// transactions; elsewhere their rows are not visible at all.
+func (s *SleepDiaryService) beginTenantTx(caller api.Principal) (*sql.Tx, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("SELECT set_config('snorlax.tenant_id', $1, true)", caller.Tenant()); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if _, err := tx.Exec("SET LOCAL ROLE " + TENANT_ROLE); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
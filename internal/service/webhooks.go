@@ -0,0 +1,217 @@
+package service
+
+import (
+	"database/sql"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// Maximum number of deliveries returned from the delivery log.
+const MAX_WEBHOOK_DELIVERIES = 100
+
+// CreateWebhook registers a webhook of the tenant of the caller; only admins
+// may manage webhooks.
+func (s *SleepDiaryService) CreateWebhook(caller api.Principal, dto api.CreateWebhookDto) (api.WebhookDto, api.Error) {
+	if !caller.HasScope(api.SCOPE_ADMIN) {
+		return api.WebhookDto{}, api.NewError("admin scope required", api.ERR_FORBIDDEN)
+	}
+	errs := dto.Validate()
+	if len(errs) > 0 {
+		return api.WebhookDto{}, api.NewValidationError("invalid webhook data", errs)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting insert transaction failed: %v\n", err)
+		return api.WebhookDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	webhook, err := insertWebhook(tx, fromCreateWebhookDto(dto))
+	if err != nil {
+		log.Printf("Inserting webhook for %s failed: %v\n", dto.Url, err)
+		return api.WebhookDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing webhook for %s failed: %v\n", dto.Url, err)
+		return api.WebhookDto{}, api.NewError("insert failed", api.ERR_UNKNOWN)
+	}
+	return toWebhookDto(webhook), nil
+}
+
+func (s *SleepDiaryService) GetWebhooks(caller api.Principal) ([]api.WebhookDto, api.Error) {
+	if !caller.HasScope(api.SCOPE_ADMIN) {
+		return nil, api.NewError("admin scope required", api.ERR_FORBIDDEN)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	webhooks, err := getWebhooks(tx)
+	if err != nil {
+		log.Printf("Reading webhooks failed: %v\n", err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	dtos := make([]api.WebhookDto, len(webhooks))
+	for i, webhook := range webhooks {
+		dtos[i] = toWebhookDto(webhook)
+	}
+	return dtos, nil
+}
+
+// DeleteWebhook removes a webhook along with its pending deliveries and
+// delivery log.
+func (s *SleepDiaryService) DeleteWebhook(caller api.Principal, id int64) api.Error {
+	if !caller.HasScope(api.SCOPE_ADMIN) {
+		return api.NewError("admin scope required", api.ERR_FORBIDDEN)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting delete transaction failed: %v\n", err)
+		return api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if err := deleteWebhook(tx, id); err != nil {
+		if err == sql.ErrNoRows {
+			return api.NewError("webhook not found", api.ERR_NOT_FOUND)
+		}
+		log.Printf("Deleting webhook %d failed: %v\n", id, err)
+		return api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing deletion of webhook %d failed: %v\n", id, err)
+		return api.NewError("delete failed", api.ERR_UNKNOWN)
+	}
+	return nil
+}
+
+// GetWebhookDeliveries returns the most recent MAX_WEBHOOK_DELIVERIES
+// deliveries of a webhook, optionally only those in given status.
+func (s *SleepDiaryService) GetWebhookDeliveries(caller api.Principal, webhookId int64, status api.WebhookDeliveryStatus) ([]api.WebhookDeliveryDto, api.Error) {
+	if !caller.HasScope(api.SCOPE_ADMIN) {
+		return nil, api.NewError("admin scope required", api.ERR_FORBIDDEN)
+	}
+	statuses := []api.WebhookDeliveryStatus{api.PendingWebhookDeliveryStatus, api.DeliveredWebhookDeliveryStatus, api.DeadWebhookDeliveryStatus}
+	if status != "" && !slices.Contains(statuses, status) {
+		err := api.NewFieldError("/status", api.RULE_ENUM, map[string]any{"enum": statuses}, "unknown status '%s'", status)
+		return nil, api.NewValidationError("invalid delivery filter", []error{err})
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting read transaction failed: %v\n", err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	exists, err := webhookExists(tx, webhookId)
+	if err != nil {
+		log.Printf("Reading webhook %d failed: %v\n", webhookId, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if !exists {
+		return nil, api.NewError("webhook not found", api.ERR_NOT_FOUND)
+	}
+
+	deliveries, err := getWebhookDeliveries(tx, webhookId, status, MAX_WEBHOOK_DELIVERIES)
+	if err != nil {
+		log.Printf("Reading deliveries of webhook %d failed: %v\n", webhookId, err)
+		return nil, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+
+	dtos := make([]api.WebhookDeliveryDto, len(deliveries))
+	for i, delivery := range deliveries {
+		dtos[i] = toWebhookDeliveryDto(delivery)
+	}
+	return dtos, nil
+}
+
+// RedeliverWebhookDelivery queues a dead delivery for one more attempt.
+func (s *SleepDiaryService) RedeliverWebhookDelivery(caller api.Principal, id int64) (api.WebhookDeliveryDto, api.Error) {
+	if !caller.HasScope(api.SCOPE_ADMIN) {
+		return api.WebhookDeliveryDto{}, api.NewError("admin scope required", api.ERR_FORBIDDEN)
+	}
+
+	tx, err := s.beginTenantTx(caller)
+	if err != nil {
+		log.Printf("Starting redeliver transaction failed: %v\n", err)
+		return api.WebhookDeliveryDto{}, api.NewError("redeliver failed", api.ERR_UNKNOWN)
+	}
+	defer tx.Rollback()
+
+	if err := resetWebhookDelivery(tx, id, time.Now().UTC()); err != nil {
+		if err == ErrConflict {
+			if _, err := getWebhookDeliveryById(tx, id); err == sql.ErrNoRows {
+				return api.WebhookDeliveryDto{}, api.NewError("delivery not found", api.ERR_NOT_FOUND)
+			}
+			return api.WebhookDeliveryDto{}, api.NewError("only dead deliveries can be redelivered", api.ERR_CONFLICT)
+		}
+		log.Printf("Resetting delivery %d failed: %v\n", id, err)
+		return api.WebhookDeliveryDto{}, api.NewError("redeliver failed", api.ERR_UNKNOWN)
+	}
+
+	delivery, err := getWebhookDeliveryById(tx, id)
+	if err != nil {
+		log.Printf("Reading delivery %d failed: %v\n", id, err)
+		return api.WebhookDeliveryDto{}, api.NewError("read failed", api.ERR_UNKNOWN)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Committing redelivery of delivery %d failed: %v\n", id, err)
+		return api.WebhookDeliveryDto{}, api.NewError("redeliver failed", api.ERR_UNKNOWN)
+	}
+	return toWebhookDeliveryDto(delivery), nil
+}
+
+// ClaimWebhookDeliveries returns up to limit deliveries due now. They are not
+// returned again until lease passes, unless their attempt is recorded first.
+func (s *SleepDiaryService) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]WebhookDelivery, api.Error) {
+	deliveries, err := claimWebhookDeliveries(s.db, time.Now().UTC(), lease, limit)
+	if err != nil {
+		log.Printf("Claiming webhook deliveries failed: %v\n", err)
+		return nil, api.NewError("claim failed", api.ERR_UNKNOWN)
+	}
+	return deliveries, nil
+}
+
+// RecordWebhookDeliveryAttempt records an attempt of a claimed delivery. An
+// attempt without error marks the delivery as delivered; a failed one is
+// retried at nextAttemptAt, or dead-lettered when it is nil.
+func (s *SleepDiaryService) RecordWebhookDeliveryAttempt(id int64, statusCode int, attemptErr error, nextAttemptAt *time.Time) api.Error {
+	now := time.Now().UTC()
+	delivery := WebhookDelivery{Id: id, NextAttemptAt: now}
+	if statusCode != 0 {
+		delivery.LastStatusCode = sql.NullInt32{Int32: int32(statusCode), Valid: true}
+	}
+
+	switch {
+	case attemptErr == nil:
+		delivery.Status = api.DeliveredWebhookDeliveryStatus
+		delivery.DeliveredAt = sql.NullTime{Time: now, Valid: true}
+	case nextAttemptAt != nil:
+		delivery.Status = api.PendingWebhookDeliveryStatus
+		delivery.LastError = sql.NullString{String: attemptErr.Error(), Valid: true}
+		delivery.NextAttemptAt = *nextAttemptAt
+	default:
+		delivery.Status = api.DeadWebhookDeliveryStatus
+		delivery.LastError = sql.NullString{String: attemptErr.Error(), Valid: true}
+	}
+
+	if err := updateWebhookDeliveryAttempt(s.db, delivery); err != nil {
+		if err == sql.ErrNoRows {
+			return api.NewError("delivery not found", api.ERR_NOT_FOUND)
+		}
+		log.Printf("Recording attempt of delivery %d failed: %v\n", id, err)
+		return api.NewError("update failed", api.ERR_UNKNOWN)
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mabzd/snorlax/api"
+)
+
+type Webhook struct {
+	Id           int64
+	Url          string
+	Secret       string
+	EventTypes   []string
+	AccountUuids []string
+	CreatedAt    time.Time
+}
+
+// WebhookDelivery is a payload queued for a webhook. Url and Secret are
+// those of the webhook at the time the delivery is claimed.
+type WebhookDelivery struct {
+	Id             int64
+	WebhookId      int64
+	ChangeId       int64
+	EventType      api.ChangeType
+	Payload        []byte
+	Status         api.WebhookDeliveryStatus
+	Attempts       int
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	DeliveredAt    sql.NullTime
+	Url            string
+	Secret         string
+}
+
+func insertWebhook(db queryer, webhook Webhook) (Webhook, error) {
+	query := `
+		INSERT INTO webhooks (url, secret, event_types, account_uuids, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	err := db.QueryRow(
+		query,
+		webhook.Url,
+		webhook.Secret,
+		pq.Array(webhook.EventTypes),
+		pq.Array(webhook.AccountUuids),
+		webhook.CreatedAt,
+	).Scan(&webhook.Id)
+	return webhook, err
+}
+
+func getWebhooks(db queryer) ([]Webhook, error) {
+	rows, err := db.Query("SELECT id, url, secret, event_types, account_uuids, created_at FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		err := rows.Scan(
+			&webhook.Id,
+			&webhook.Url,
+			&webhook.Secret,
+			pq.Array(&webhook.EventTypes),
+			pq.Array(&webhook.AccountUuids),
+			&webhook.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func webhookExists(db queryer, id int64) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1)", id).Scan(&exists)
+	return exists, err
+}
+
+func deleteWebhook(db queryer, id int64) error {
+	result, err := db.Exec("DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// insertWebhookDeliveries queues a change for every webhook subscribed to its
+// type and account.
+func insertWebhookDeliveries(db queryer, change SleepDiaryEntryChange, payload []byte) error {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, change_id, event_type, payload, status, next_attempt_at, created_at)
+		SELECT id, $1, $2, $3, $4, $5, $5
+		FROM webhooks
+		WHERE $2 = ANY (event_types)
+			AND (cardinality(account_uuids) = 0 OR $6::uuid = ANY (account_uuids))
+	`
+	_, err := db.Exec(
+		query,
+		change.Id,
+		change.ChangeType,
+		string(payload),
+		api.PendingWebhookDeliveryStatus,
+		change.ChangedAt,
+		change.AccountUuid,
+	)
+	return err
+}
+
+const webhookDeliveryColumns = `
+	d.id, d.webhook_id, d.change_id, d.event_type, d.payload, d.status, d.attempts,
+	d.last_status_code, d.last_error, d.next_attempt_at, d.created_at, d.delivered_at,
+	w.url, w.secret
+`
+
+// claimWebhookDeliveries picks pending deliveries due at now and postpones
+// their next attempt by lease, so that concurrent workers do not pick them
+// again while they are being delivered.
+func claimWebhookDeliveries(db queryer, now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE webhook_deliveries
+			SET next_attempt_at = $2
+			WHERE id IN (
+				SELECT id
+				FROM webhook_deliveries
+				WHERE status = $3 AND next_attempt_at <= $1
+				ORDER BY next_attempt_at, id
+				LIMIT $4
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT ` + webhookDeliveryColumns + `
+		FROM claimed d
+		JOIN webhooks w ON w.id = d.webhook_id
+		ORDER BY d.id
+	`
+	rows, err := db.Query(query, now, now.Add(lease), api.PendingWebhookDeliveryStatus, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhookDeliveries(rows)
+}
+
+func getWebhookDeliveries(db queryer, webhookId int64, status api.WebhookDeliveryStatus, limit int) ([]WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.webhook_id = $1 AND ($2 = '' OR d.status = $2)
+		ORDER BY d.id DESC
+		LIMIT $3
+	`
+	rows, err := db.Query(query, webhookId, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhookDeliveries(rows)
+}
+
+func getWebhookDeliveryById(db queryer, id int64) (WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.id = $1
+	`
+	rows, err := db.Query(query, id)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	if len(deliveries) == 0 {
+		return WebhookDelivery{}, sql.ErrNoRows
+	}
+	return deliveries[0], nil
+}
+
+// updateWebhookDeliveryAttempt records the outcome of an attempt; delivery is
+// the claimed delivery with updated status, error and next attempt time.
+func updateWebhookDeliveryAttempt(db queryer, delivery WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET
+			status = $1,
+			attempts = attempts + 1,
+			last_status_code = $2,
+			last_error = $3,
+			next_attempt_at = $4,
+			delivered_at = $5
+		WHERE id = $6
+	`
+	result, err := db.Exec(
+		query,
+		delivery.Status,
+		delivery.LastStatusCode,
+		delivery.LastError,
+		delivery.NextAttemptAt,
+		delivery.DeliveredAt,
+		delivery.Id,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// resetWebhookDelivery queues a dead delivery again.
+func resetWebhookDelivery(db queryer, id int64, now time.Time) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, next_attempt_at = $2
+		WHERE id = $3 AND status = $4
+	`
+	result, err := db.Exec(query, api.PendingWebhookDeliveryStatus, now, id, api.DeadWebhookDeliveryStatus)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var delivery WebhookDelivery
+		var payload string
+		err := rows.Scan(
+			&delivery.Id,
+			&delivery.WebhookId,
+			&delivery.ChangeId,
+			&delivery.EventType,
+			&payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.LastStatusCode,
+			&delivery.LastError,
+			&delivery.NextAttemptAt,
+			&delivery.CreatedAt,
+			&delivery.DeliveredAt,
+			&delivery.Url,
+			&delivery.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+		delivery.Payload = []byte(payload)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,247 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/database"
+	"github.com/mabzd/snorlax/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDeletionReceiptSecret = "test-deletion-receipt-secret"
+
+func TestExportAccount(t *testing.T) {
+	ctx := context.Background()
+	c := client.New(srv.URL)
+	accountUuid := uuid.NewString()
+	entry := mustCreateRandomEntryOfAccount(t, accountUuid)
+	_, err := c.UploadHypnogram(ctx, entry.Id, api.UploadHypnogramDto{Segments: []api.SleepStageSegmentDto{
+		newSegment(api.LightSleepStage, entry.TriedToSleepAt, 60),
+		newSegment(api.DeepSleepStage, entry.TriedToSleepAt.Add(time.Hour), 60),
+	}})
+	assert.NoError(t, err)
+	mustImport(t, "fitbit", accountUuid, fitbitExport)
+	grant, err := c.CreateGrant(ctx, api.CreateGrantDto{AccountUuid: accountUuid, Grantee: "clinician-1", Access: api.ReadGrantAccess, ValidTo: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	mustCreateRandomEntryOfAccount(t, uuid.NewString())
+
+	content, err := c.ExportAccount(ctx, accountUuid)
+	assert.NoError(t, err)
+	files := mustUnzip(t, content)
+
+	export := mustDecodeJson[api.AccountExportDto](t, files["account.json"])
+	assert.Equal(t, accountUuid, export.AccountUuid)
+	assert.Equal(t, api.DEFAULT_TENANT, export.TenantId)
+	if assert.Len(t, export.Entries, 1) {
+		assertEqualEntryDto(t, entry, export.Entries[0], true)
+	}
+	assert.Len(t, export.SleepStages, 2)
+	for _, stage := range export.SleepStages {
+		assert.Equal(t, entry.Id, stage.EntryId)
+	}
+	assert.Len(t, export.Drafts, 1)
+	if assert.Len(t, export.Changes, 1) {
+		assert.Equal(t, api.CHANGE_CREATED, export.Changes[0].Type)
+		assert.Equal(t, entry.Id, export.Changes[0].EntryId)
+	}
+	if assert.Len(t, export.Grants, 1) {
+		assert.Equal(t, grant.Id, export.Grants[0].Id)
+	}
+	assert.Empty(t, export.ReceivedGrants)
+	assert.Empty(t, export.ApiKeys)
+
+	entries := mustReadCsv(t, files["entries.csv"])
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, []string{"id", "uuid", "account_uuid", "version"}, entries[0][:4])
+		assert.Equal(t, entry.Uuid, entries[1][1])
+		assert.Equal(t, *entry.Comments, entries[1][len(entries[1])-1])
+	}
+	assert.Len(t, mustReadCsv(t, files["sleep_stages.csv"]), 3)
+	assert.Len(t, mustReadCsv(t, files["drafts.csv"]), 2)
+	assert.Len(t, mustReadCsv(t, files["changes.csv"]), 2)
+	assert.Len(t, mustReadCsv(t, files["grants.csv"]), 2)
+	assert.Len(t, mustReadCsv(t, files["received_grants.csv"]), 1)
+	assert.Len(t, mustReadCsv(t, files["api_keys.csv"]), 1)
+}
+
+func TestExportAccountOfOtherAccountForbidden(t *testing.T) {
+	ctx := context.Background()
+	accountUuid := uuid.NewString()
+	owner := client.New(authSrv.URL, client.WithBearerToken(newToken(accountUuid)))
+	other := client.New(authSrv.URL, client.WithBearerToken(newToken(uuid.NewString())))
+
+	_, err := owner.ExportAccount(ctx, accountUuid)
+	assert.NoError(t, err)
+	_, err = other.ExportAccount(ctx, accountUuid)
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+	_, err = other.DeleteAccount(ctx, accountUuid, "")
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+}
+
+func TestDeleteAccount(t *testing.T) {
+	ctx := context.Background()
+	c := client.New(srv.URL)
+	accountUuid := uuid.NewString()
+	entry := mustCreateRandomEntryOfAccount(t, accountUuid)
+	_, err := c.UploadHypnogram(ctx, entry.Id, api.UploadHypnogramDto{Segments: []api.SleepStageSegmentDto{
+		newSegment(api.LightSleepStage, entry.TriedToSleepAt, 60),
+	}})
+	assert.NoError(t, err)
+	deleted := mustCreateRandomEntryOfAccount(t, accountUuid)
+	assert.NoError(t, c.DeleteEntry(ctx, deleted.Id))
+	mustImport(t, "fitbit", accountUuid, fitbitExport)
+	_, err = c.CreateGrant(ctx, api.CreateGrantDto{AccountUuid: accountUuid, Grantee: "clinician-1", Access: api.ReadGrantAccess, ValidTo: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	_, err = c.CreateGrant(ctx, api.CreateGrantDto{AccountUuid: uuid.NewString(), Grantee: accountUuid, Access: api.ReadGrantAccess, ValidTo: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	webhook := mustCreateWebhook(t, "http://127.0.0.1:1/", accountUuid, api.CHANGE_UPDATED)
+	otherUuid := uuid.NewString()
+	other := mustCreateRandomEntryOfAccount(t, otherUuid)
+
+	receipt, err := c.DeleteAccount(ctx, accountUuid, api.DeleteAccountDeletionMode)
+	assert.NoError(t, err)
+	assert.Equal(t, accountUuid, receipt.AccountUuid)
+	assert.Equal(t, api.DEFAULT_TENANT, receipt.TenantId)
+	assert.Equal(t, api.DeleteAccountDeletionMode, receipt.Mode)
+	assert.Equal(t, api.SystemPrincipal.Subject, receipt.RequestedBy)
+	assert.Equal(t, int64(1), receipt.Rows["sleep_diary_entries"])
+	assert.Equal(t, int64(1), receipt.Rows["sleep_stage_segments"])
+	assert.Equal(t, int64(3), receipt.Rows["sleep_diary_entry_changes"])
+	assert.Equal(t, int64(1), receipt.Rows["sleep_diary_drafts"])
+	assert.Equal(t, int64(2), receipt.Rows["account_grants"])
+	assert.Equal(t, int64(1), receipt.Rows["webhooks"])
+	assert.NotEmpty(t, receipt.Signature)
+	assert.NoError(t, c.VerifyDeletionReceipt(ctx, receipt))
+
+	page, err := c.GetEntriesByFilter(ctx, api.SleepDiaryFilterDto{AccountUuid: []string{accountUuid}, PageSize: 10, PageNumber: 1})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Items)
+	synced, err := c.GetSyncChanges(ctx, accountUuid, "")
+	assert.NoError(t, err)
+	assert.Empty(t, synced.Entries)
+	assert.Empty(t, synced.Deleted)
+	drafts, err := c.GetDrafts(ctx, accountUuid)
+	assert.NoError(t, err)
+	assert.Empty(t, drafts)
+	grants, err := c.GetGrants(ctx, accountUuid)
+	assert.NoError(t, err)
+	assert.Empty(t, grants)
+	webhooks, err := c.GetWebhooks(ctx)
+	assert.NoError(t, err)
+	for _, hook := range webhooks {
+		assert.NotEqual(t, webhook.Id, hook.Id)
+	}
+
+	read, err := c.GetEntryById(ctx, other.Id)
+	assert.NoError(t, err)
+	assertEqualEntryDto(t, other, read, true)
+}
+
+func TestDeleteAccountReceiptTampered(t *testing.T) {
+	ctx := context.Background()
+	c := client.New(srv.URL)
+	accountUuid := uuid.NewString()
+	mustCreateRandomEntryOfAccount(t, accountUuid)
+	receipt, err := c.DeleteAccount(ctx, accountUuid, "")
+	assert.NoError(t, err)
+	assert.Equal(t, api.DeleteAccountDeletionMode, receipt.Mode)
+
+	tampered := receipt
+	tampered.DeletedAt = receipt.DeletedAt.Add(-24 * time.Hour)
+	err = c.VerifyDeletionReceipt(ctx, tampered)
+	assert.Equal(t, api.ERR_INVALID, client.ErrorCode(err))
+	tampered = receipt
+	tampered.Rows = map[string]int64{"sleep_diary_entries": 2}
+	err = c.VerifyDeletionReceipt(ctx, tampered)
+	assert.Equal(t, api.ERR_INVALID, client.ErrorCode(err))
+	tampered = receipt
+	tampered.Signature = ""
+	err = c.VerifyDeletionReceipt(ctx, tampered)
+	assert.Equal(t, api.ERR_INVALID, client.ErrorCode(err))
+}
+
+func TestPseudonymizeAccount(t *testing.T) {
+	ctx := context.Background()
+	c := client.New(srv.URL)
+	accountUuid := uuid.NewString()
+	entry := mustCreateRandomEntryOfAccount(t, accountUuid)
+	_, err := c.UploadHypnogram(ctx, entry.Id, api.UploadHypnogramDto{Segments: []api.SleepStageSegmentDto{
+		newSegment(api.LightSleepStage, entry.TriedToSleepAt, 60),
+	}})
+	assert.NoError(t, err)
+	mustImport(t, "fitbit", accountUuid, fitbitExport)
+
+	receipt, err := c.DeleteAccount(ctx, accountUuid, api.PseudonymizeAccountDeletionMode)
+	assert.NoError(t, err)
+	assert.Equal(t, api.PseudonymizeAccountDeletionMode, receipt.Mode)
+	assert.Equal(t, int64(1), receipt.Rows["sleep_diary_entries"])
+	assert.Equal(t, int64(1), receipt.Rows["sleep_stage_segments"])
+	assert.Equal(t, int64(1), receipt.Rows["sleep_diary_drafts"])
+	assert.NoError(t, c.VerifyDeletionReceipt(ctx, receipt))
+
+	page, err := c.GetEntriesByFilter(ctx, api.SleepDiaryFilterDto{AccountUuid: []string{accountUuid}, PageSize: 10, PageNumber: 1})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Items)
+
+	// The entry is kept for statistics, under a pseudonym.
+	db, err := sql.Open("postgres", database.ConnString(testCfg))
+	assert.NoError(t, err)
+	defer db.Close()
+	var pseudonymAccountUuid, pseudonymUuid string
+	var comments, sourceDevice sql.NullString
+	var sleepQuality int
+	err = db.QueryRow(
+		`SELECT e.account_uuid, e.uuid, e.comments, e.sleep_quality, s.source_device
+		FROM sleep_diary_entries e JOIN sleep_stage_segments s ON s.entry_id = e.id
+		WHERE e.id = $1`,
+		entry.Id,
+	).Scan(&pseudonymAccountUuid, &pseudonymUuid, &comments, &sleepQuality, &sourceDevice)
+	assert.NoError(t, err)
+	assert.NotEqual(t, accountUuid, pseudonymAccountUuid)
+	assert.NotEqual(t, entry.Uuid, pseudonymUuid)
+	assert.False(t, comments.Valid)
+	assert.False(t, sourceDevice.Valid)
+	assert.Equal(t, int(entry.SleepQuality), sleepQuality)
+	var changes int
+	err = db.QueryRow("SELECT count(*) FROM sleep_diary_entry_changes WHERE account_uuid = $1", accountUuid).Scan(&changes)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, changes)
+}
+
+func TestDeleteAccountInvalidMode(t *testing.T) {
+	_, err := client.New(srv.URL).DeleteAccount(context.Background(), uuid.NewString(), "forget")
+	assert.Equal(t, api.ERR_INVALID, client.ErrorCode(err))
+	_, err = client.New(srv.URL).DeleteAccount(context.Background(), "not-a-uuid", "")
+	assert.Equal(t, api.ERR_INVALID, client.ErrorCode(err))
+}
+
+func mustUnzip(t *testing.T, content []byte) map[string][]byte {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("Reading archive failed: %v", err)
+	}
+	files := map[string][]byte{}
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		assert.NoError(t, err)
+		files[file.Name], err = io.ReadAll(rc)
+		assert.NoError(t, err)
+		rc.Close()
+	}
+	return files
+}
+
+func mustReadCsv(t *testing.T, content []byte) [][]string {
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	assert.NoError(t, err)
+	return records
+}
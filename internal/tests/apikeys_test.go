@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/internal/database"
+	"github.com/mabzd/snorlax/pkg/client"
+	"github.com/mabzd/snorlax/pkg/rest"
+	"github.com/stretchr/testify/assert"
+)
+
+// apiKeySrv serves the API requiring API keys; keys are managed through srv,
+// which runs without authentication.
+var apiKeySrv *httptest.Server
+
+func newApiKeyServer(cfg config.Config) *httptest.Server {
+	cfg.ApiKeys = true
+	return httptest.NewServer(rest.NewServerHandler(cfg))
+}
+
+func TestApiKeyScopesLimitRoutes(t *testing.T) {
+	ctx := context.Background()
+	accountUuid := uuid.NewString()
+	entry, err := client.New(srv.URL).CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: accountUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	c := newApiKeyClient(t, api.CreateApiKeyDto{Name: "reader", Scopes: []string{api.SCOPE_READ_ENTRIES}, AccountUuid: accountUuid})
+
+	read, err := c.GetEntryById(ctx, entry.Id)
+	assert.NoError(t, err)
+	assertEqualEntryDto(t, entry, read, true)
+
+	_, err = c.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: accountUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+	_, err = c.GetSleepCalendar(ctx, accountUuid, nil, nil)
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+	_, err = c.GetApiKeys(ctx)
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+}
+
+func TestApiKeyScopesAllowRoutes(t *testing.T) {
+	ctx := context.Background()
+	accountUuid := uuid.NewString()
+	c := newApiKeyClient(t, api.CreateApiKeyDto{
+		Name:        "writer",
+		Scopes:      []string{api.SCOPE_READ_ENTRIES, api.SCOPE_WRITE_ENTRIES, api.SCOPE_EXPORT},
+		AccountUuid: accountUuid,
+	})
+
+	entry, err := c.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: accountUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	_, err = c.UpdateEntry(ctx, entry.Id, api.UpdateSleepDiaryEntryDto{SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	_, err = c.GetSleepCalendar(ctx, accountUuid, nil, nil)
+	assert.NoError(t, err)
+	err = c.DeleteEntry(ctx, entry.Id)
+	assert.NoError(t, err)
+}
+
+func TestApiKeyAccountRestriction(t *testing.T) {
+	ctx := context.Background()
+	ownUuid := uuid.NewString()
+	otherUuid := uuid.NewString()
+	unauthenticated := client.New(srv.URL)
+	_, err := unauthenticated.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: ownUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	other, err := unauthenticated.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: otherUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	filter := api.SleepDiaryFilterDto{AccountUuid: []string{ownUuid, otherUuid}, PageSize: 10, PageNumber: 1}
+
+	restricted := newApiKeyClient(t, api.CreateApiKeyDto{
+		Name:        "restricted",
+		Scopes:      []string{api.SCOPE_READ_ENTRIES, api.SCOPE_WRITE_ENTRIES},
+		AccountUuid: ownUuid,
+	})
+	_, err = restricted.GetEntryById(ctx, other.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	_, err = restricted.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: otherUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+	page, err := restricted.GetEntriesByFilter(ctx, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.TotalCount)
+
+	unrestricted := newApiKeyClient(t, api.CreateApiKeyDto{Name: "unrestricted", Scopes: []string{api.SCOPE_READ_ENTRIES}})
+	page, err = unrestricted.GetEntriesByFilter(ctx, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), page.TotalCount)
+}
+
+func TestApiKeyAccountRestrictionOverridesAdminScope(t *testing.T) {
+	ctx := context.Background()
+	accountUuid := uuid.NewString()
+	other, err := client.New(srv.URL).CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: uuid.NewString(), SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	key, err := client.New(srv.URL).CreateApiKey(ctx, api.CreateApiKeyDto{Name: "restricted", Scopes: []string{api.SCOPE_READ_ENTRIES}, AccountUuid: accountUuid})
+	assert.NoError(t, err)
+
+	// Keys are validated when created, so the admin scope is added behind
+	// the back of the API.
+	db, err := sql.Open("postgres", database.ConnString(testCfg))
+	assert.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("UPDATE api_keys SET scopes = array_append(scopes, $2) WHERE id = $1", key.Id, api.SCOPE_ADMIN)
+	assert.NoError(t, err)
+
+	c := client.New(apiKeySrv.URL, client.WithApiKey(key.Key))
+	_, err = c.GetEntryById(ctx, other.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	_, err = c.GetApiKeys(ctx)
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+}
+
+func TestApiKeyRequired(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := client.New(apiKeySrv.URL).GetEntryById(ctx, 1)
+	assert.Equal(t, api.ERR_UNAUTHORIZED, client.ErrorCode(err))
+	_, err = client.New(apiKeySrv.URL, client.WithApiKey("snx_unknown")).GetEntryById(ctx, 1)
+	assert.Equal(t, api.ERR_UNAUTHORIZED, client.ErrorCode(err))
+
+	expiresAt := time.Now().Add(-time.Minute)
+	expired := newApiKeyClient(t, api.CreateApiKeyDto{Name: "expired", Scopes: []string{api.SCOPE_READ_ENTRIES}, ExpiresAt: &expiresAt})
+	_, err = expired.GetEntryById(ctx, 1)
+	assert.Equal(t, api.ERR_UNAUTHORIZED, client.ErrorCode(err))
+}
+
+func TestApiKeyRotateAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	admin := client.New(srv.URL)
+	key, err := admin.CreateApiKey(ctx, api.CreateApiKeyDto{Name: "rotated", Scopes: []string{api.SCOPE_READ_ENTRIES}})
+	assert.NoError(t, err)
+	filter := api.SleepDiaryFilterDto{AccountUuid: []string{uuid.NewString()}, PageSize: 10, PageNumber: 1}
+
+	rotated, err := admin.RotateApiKey(ctx, key.Id)
+	assert.NoError(t, err)
+	assert.NotEqual(t, key.Key, rotated.Key)
+	assert.NotNil(t, rotated.RotatedAt)
+	_, err = client.New(apiKeySrv.URL, client.WithApiKey(key.Key)).GetEntriesByFilter(ctx, filter)
+	assert.Equal(t, api.ERR_UNAUTHORIZED, client.ErrorCode(err))
+	c := client.New(apiKeySrv.URL, client.WithApiKey(rotated.Key))
+	_, err = c.GetEntriesByFilter(ctx, filter)
+	assert.NoError(t, err)
+
+	err = admin.RevokeApiKey(ctx, key.Id)
+	assert.NoError(t, err)
+	_, err = c.GetEntriesByFilter(ctx, filter)
+	assert.Equal(t, api.ERR_UNAUTHORIZED, client.ErrorCode(err))
+	_, err = admin.RotateApiKey(ctx, key.Id)
+	assert.Equal(t, api.ERR_CONFLICT, client.ErrorCode(err))
+}
+
+func TestApiKeyAdminManagesKeys(t *testing.T) {
+	ctx := context.Background()
+	admin := newApiKeyClient(t, api.CreateApiKeyDto{Name: "admin", Scopes: []string{api.SCOPE_ADMIN}})
+
+	key, err := admin.CreateApiKey(ctx, api.CreateApiKeyDto{Name: "partner", Scopes: []string{api.SCOPE_EXPORT}})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, key.Key)
+	assert.Equal(t, key.Key[:len(key.Prefix)], key.Prefix)
+
+	keys, err := admin.GetApiKeys(ctx)
+	assert.NoError(t, err)
+	found := false
+	for _, listed := range keys {
+		assert.Empty(t, listed.Key)
+		found = found || listed.Id == key.Id
+	}
+	assert.True(t, found)
+}
+
+func TestApiKeyValidation(t *testing.T) {
+	_, err := client.New(srv.URL).CreateApiKey(context.Background(), api.CreateApiKeyDto{
+		Scopes:      []string{api.SCOPE_ADMIN, "entries:delete"},
+		AccountUuid: uuid.NewString(),
+	})
+
+	assert.Equal(t, api.ERR_INVALID, client.ErrorCode(err))
+	var apiErr *client.Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		fields := []string{}
+		for _, fieldErr := range apiErr.Errors {
+			fields = append(fields, fieldErr.Field)
+		}
+		assert.ElementsMatch(t, []string{"/name", "/scopes/0", "/scopes/1"}, fields)
+	}
+}
+
+func newApiKeyClient(t *testing.T, dto api.CreateApiKeyDto) *client.Client {
+	key, err := client.New(srv.URL).CreateApiKey(context.Background(), dto)
+	assert.NoError(t, err)
+	return client.New(apiKeySrv.URL, client.WithApiKey(key.Key))
+}
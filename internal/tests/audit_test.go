@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLogRecordsAccessWithTraceId(t *testing.T) {
+	ownerUuid := uuid.NewString()
+	owner := newGatewayClient(ownerUuid)
+	createTraceId := uuid.NewString()
+	entry, err := owner.CreateEntry(client.WithTraceId(context.Background(), createTraceId), api.CreateSleepDiaryEntryDto{
+		AccountUuid:            ownerUuid,
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+	assert.NoError(t, err)
+	readTraceId := uuid.NewString()
+	_, err = owner.GetEntryById(client.WithTraceId(context.Background(), readTraceId), entry.Id)
+	assert.NoError(t, err)
+
+	admin := newGatewayClient(uuid.NewString(), api.SCOPE_ADMIN)
+	page, err := admin.GetAuditLog(context.Background(), api.AuditLogFilterDto{AccountUuid: ownerUuid})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), page.TotalCount)
+	if assert.Len(t, page.Items, 2) {
+		read, created := page.Items[0], page.Items[1]
+		assert.Equal(t, api.ReadEntryAuditAction, read.Action)
+		assert.Equal(t, readTraceId, read.TraceId)
+		assert.Equal(t, api.CreateEntryAuditAction, created.Action)
+		assert.Equal(t, createTraceId, created.TraceId)
+		for _, event := range page.Items {
+			assert.Equal(t, ownerUuid, event.Subject)
+			assert.Equal(t, []string{ownerUuid}, event.AccountUuids)
+			assert.Equal(t, []int64{entry.Id}, event.EntryIds)
+		}
+	}
+
+	page, err = admin.GetAuditLog(context.Background(), api.AuditLogFilterDto{TraceId: readTraceId})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.TotalCount)
+
+	action := api.ReadAuditLogAuditAction
+	entryId := entry.Id
+	page, err = admin.GetAuditLog(context.Background(), api.AuditLogFilterDto{Action: &action, EntryId: &entryId})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), page.TotalCount)
+}
+
+func TestAuditLogSkipsRejectedOperations(t *testing.T) {
+	ownerUuid := uuid.NewString()
+	entry, err := newGatewayClient(ownerUuid).CreateEntry(context.Background(), api.CreateSleepDiaryEntryDto{
+		AccountUuid:            ownerUuid,
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+	assert.NoError(t, err)
+
+	_, err = newGatewayClient(uuid.NewString()).GetEntryById(context.Background(), entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+
+	entryId := entry.Id
+	page, err := newGatewayClient(uuid.NewString(), api.SCOPE_ADMIN).GetAuditLog(context.Background(), api.AuditLogFilterDto{EntryId: &entryId})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, api.CreateEntryAuditAction, page.Items[0].Action)
+	}
+}
+
+func TestAuditLogRequiresAdmin(t *testing.T) {
+	accountUuid := uuid.NewString()
+	_, err := newGatewayClient(accountUuid).GetAuditLog(context.Background(), api.AuditLogFilterDto{AccountUuid: accountUuid})
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+	_, err = newGatewayClient(accountUuid).VerifyAuditLog(context.Background())
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+}
+
+func TestAuditLogRejectsInvalidFilter(t *testing.T) {
+	action := api.AuditAction("entry.peek")
+	_, err := client.New(srv.URL).GetAuditLog(context.Background(), api.AuditLogFilterDto{AccountUuid: "not-a-uuid", Action: &action})
+	assert.Equal(t, api.ERR_INVALID, client.ErrorCode(err))
+}
+
+func TestVerifyAuditLogDetectsTampering(t *testing.T) {
+	// A tenant of its own, so that the broken chain does not affect others.
+	tenant := uuid.NewString()
+	ownerUuid := uuid.NewString()
+	entry, err := newTenantClient(tenant, ownerUuid).CreateEntry(context.Background(), api.CreateSleepDiaryEntryDto{
+		AccountUuid:            ownerUuid,
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+	assert.NoError(t, err)
+	admin := newTenantClient(tenant, uuid.NewString(), api.SCOPE_ADMIN)
+	result := mustVerifyChainedAuditLog(t, admin)
+	assert.Equal(t, api.AuditLogVerificationDto{Valid: true, EventsCount: 1}, result)
+
+	db := mustOpenDb(t)
+	_, err = db.Exec("UPDATE audit_log SET subject = 'someone else' WHERE entry_ids @> ARRAY[$1::bigint]", entry.Id)
+	assert.ErrorContains(t, err, "append-only")
+	_, err = db.Exec("DELETE FROM audit_log WHERE tenant_id = $1", tenant)
+	assert.ErrorContains(t, err, "append-only")
+
+	// Tampering around the trigger, as a superuser could, breaks the chain.
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.Exec("ALTER TABLE audit_log DISABLE TRIGGER audit_log_chain_only")
+	assert.NoError(t, err)
+	var eventId int64
+	err = tx.QueryRow("UPDATE audit_log SET subject = 'someone else' WHERE entry_ids @> ARRAY[$1::bigint] RETURNING id", entry.Id).Scan(&eventId)
+	assert.NoError(t, err)
+	_, err = tx.Exec("ALTER TABLE audit_log ENABLE TRIGGER audit_log_chain_only")
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	result, err = admin.VerifyAuditLog(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, &eventId, result.BrokenAtId)
+}
+
+func TestAuditLogSkipsEmptyChangePolls(t *testing.T) {
+	accountUuid := uuid.NewString()
+	events := mustOpenChangeStream(t, accountUuid, "")
+	entry := mustCreateRandomEntryOfAccount(t, accountUuid)
+	change := mustReceiveChange(t, events, api.CHANGE_CREATED)
+	assert.Equal(t, entry.Id, change.EntryId)
+
+	// The stream polls before and after the change; only the poll returning
+	// it is recorded.
+	action := api.ListChangesAuditAction
+	admin := newGatewayClient(uuid.NewString(), api.SCOPE_ADMIN)
+	page, err := admin.GetAuditLog(context.Background(), api.AuditLogFilterDto{AccountUuid: accountUuid, Action: &action})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, []int64{entry.Id}, page.Items[0].EntryIds)
+	}
+}
+
+// mustVerifyChainedAuditLog waits until the events of the tenant of the
+// client are chained and returns the verification of the chain.
+func mustVerifyChainedAuditLog(t *testing.T, client *client.Client) api.AuditLogVerificationDto {
+	var result api.AuditLogVerificationDto
+	assert.Eventually(t, func() bool {
+		var err error
+		result, err = client.VerifyAuditLog(context.Background())
+		return err == nil && result.PendingCount == 0
+	}, 5*time.Second, 50*time.Millisecond)
+	return result
+}
@@ -0,0 +1,372 @@
+package tests
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/pkg/auth"
+	"github.com/mabzd/snorlax/pkg/client"
+	"github.com/mabzd/snorlax/pkg/rest"
+	"github.com/stretchr/testify/assert"
+)
+
+const testJwtIssuer = "https://issuer.test"
+const testJwtAudience = "snorlax-test"
+
+var authRsaKey = mustGenerateRsaKey()
+var authEcKey = mustGenerateEcKey()
+
+// Account of the tokens of newTestClaims.
+var authAccountUuid = uuid.NewString()
+
+// authSrv serves the API with authentication enabled; its JWKS file holds
+// the public keys of authRsaKey ("rsa-1") and authEcKey ("ec-1").
+var authSrv *httptest.Server
+
+func newAuthServer(cfg config.Config) *httptest.Server {
+	return httptest.NewServer(rest.NewServerHandler(newAuthConfig(cfg)))
+}
+
+// newAuthConfig returns cfg with authentication by tokens signed with
+// authRsaKey and authEcKey.
+func newAuthConfig(cfg config.Config) config.Config {
+	dir, err := os.MkdirTemp("", "snorlax-jwks")
+	if err != nil {
+		log.Fatalf("Could not create JWKS dir: %s", err)
+	}
+	cfg.JwksFile = filepath.Join(dir, "jwks.json")
+	cfg.JwtIssuer = testJwtIssuer
+	cfg.JwtAudience = testJwtAudience
+	jwks := mustMashal(newJwks(rsaJwk("rsa-1", &authRsaKey.PublicKey), ecJwk("ec-1", &authEcKey.PublicKey)))
+	if err := os.WriteFile(cfg.JwksFile, jwks, 0o600); err != nil {
+		log.Fatalf("Could not write JWKS: %s", err)
+	}
+	return cfg
+}
+
+func TestAuthAcceptsValidTokens(t *testing.T) {
+	tokens := map[string]string{
+		"RS256": signToken(auth.ALG_RS256, "rsa-1", authRsaKey, newTestClaims()),
+		"ES256": signToken(auth.ALG_ES256, "ec-1", authEcKey, newTestClaims()),
+	}
+	for alg, token := range tokens {
+		t.Run(alg, func(t *testing.T) {
+			c := client.New(authSrv.URL, client.WithBearerToken(token))
+			created, err := c.CreateEntry(context.Background(), api.CreateSleepDiaryEntryDto{
+				AccountUuid:            authAccountUuid,
+				SleepDiaryEntryDataDto: newMinimalRandomEntryData(),
+			})
+			assert.NoError(t, err)
+			_, err = c.GetEntryById(context.Background(), created.Id)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAuthRejectsRequestsWithoutToken(t *testing.T) {
+	resp, err := http.Get(authSrv.URL + "/sleep_diary/entries?account_uuid=" + authAccountUuid)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+	assert.Equal(t, api.ERR_UNAUTHORIZED, mustDecode[api.ErrorDto](resp.Body).Code)
+}
+
+func TestAuthRejectsInvalidTokens(t *testing.T) {
+	otherKey := mustGenerateRsaKey()
+	expired := newTestClaims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	notYetValid := newTestClaims()
+	notYetValid["nbf"] = time.Now().Add(time.Hour).Unix()
+	noExpiry := newTestClaims()
+	delete(noExpiry, "exp")
+	wrongIssuer := newTestClaims()
+	wrongIssuer["iss"] = "https://other-issuer.test"
+	wrongAudience := newTestClaims()
+	wrongAudience["aud"] = []string{"other-service"}
+	noSubject := newTestClaims()
+	delete(noSubject, "sub")
+
+	tokens := map[string]string{
+		"malformed":      "not-a-token",
+		"expired":        signToken(auth.ALG_RS256, "rsa-1", authRsaKey, expired),
+		"not yet valid":  signToken(auth.ALG_RS256, "rsa-1", authRsaKey, notYetValid),
+		"no expiry":      signToken(auth.ALG_RS256, "rsa-1", authRsaKey, noExpiry),
+		"wrong issuer":   signToken(auth.ALG_RS256, "rsa-1", authRsaKey, wrongIssuer),
+		"wrong audience": signToken(auth.ALG_RS256, "rsa-1", authRsaKey, wrongAudience),
+		"no subject":     signToken(auth.ALG_RS256, "rsa-1", authRsaKey, noSubject),
+		"unknown key":    signToken(auth.ALG_RS256, "rsa-2", otherKey, newTestClaims()),
+		"foreign key":    signToken(auth.ALG_RS256, "rsa-1", otherKey, newTestClaims()),
+		"key algorithm":  signToken(auth.ALG_ES256, "rsa-1", authEcKey, newTestClaims()),
+		"alg none":       encodeSegment(map[string]any{"alg": "none"}) + "." + encodeSegment(newTestClaims()) + ".",
+	}
+	for name, token := range tokens {
+		t.Run(name, func(t *testing.T) {
+			c := client.New(authSrv.URL, client.WithBearerToken(token))
+			_, err := c.GetEntryById(context.Background(), 1)
+
+			var apiErr *client.Error
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+				assert.Equal(t, api.ERR_UNAUTHORIZED, apiErr.Code)
+			}
+		})
+	}
+}
+
+func TestAuthKeepsOpenApiSpecPublic(t *testing.T) {
+	resp, err := http.Get(authSrv.URL + "/openapi.json")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestAuthVerifierReturnsPrincipal(t *testing.T) {
+	keys := auth.NewFileKeySet(mustWriteJwks(t, rsaJwk("rsa-1", &authRsaKey.PublicKey)))
+	verifier := auth.NewVerifier(keys, testJwtIssuer, testJwtAudience)
+
+	claims := newTestClaims()
+	claims["scope"] = "entries:read entries:write"
+	principal, err := verifier.Verify(context.Background(), signToken(auth.ALG_RS256, "rsa-1", authRsaKey, claims))
+	assert.NoError(t, err)
+	assert.Equal(t, authAccountUuid, principal.Subject)
+	assert.Equal(t, authAccountUuid, principal.AccountUuid)
+	assert.Equal(t, []string{"entries:read", "entries:write"}, principal.Scopes)
+
+	delete(claims, "scope")
+	claims["scp"] = []string{"entries:read"}
+	principal, err = verifier.Verify(context.Background(), signToken(auth.ALG_RS256, "rsa-1", authRsaKey, claims))
+	assert.NoError(t, err)
+	assert.True(t, principal.HasScope("entries:read"))
+}
+
+func TestAuthVerifierReadsAccountFromConfiguredClaim(t *testing.T) {
+	keys := auth.NewFileKeySet(mustWriteJwks(t, rsaJwk("rsa-1", &authRsaKey.PublicKey)))
+	verifier := auth.NewVerifier(keys, testJwtIssuer, testJwtAudience, auth.WithAccountClaim("account_uuid"))
+	accountUuid := uuid.NewString()
+
+	claims := newTestClaims()
+	claims["account_uuid"] = accountUuid
+	principal, err := verifier.Verify(context.Background(), signToken(auth.ALG_RS256, "rsa-1", authRsaKey, claims))
+	assert.NoError(t, err)
+	assert.Equal(t, authAccountUuid, principal.Subject)
+	assert.Equal(t, accountUuid, principal.AccountUuid)
+}
+
+func TestAuthKeySetPicksUpRotatedKeys(t *testing.T) {
+	newKey := mustGenerateEcKey()
+	var fetches atomic.Int32
+	var rotated atomic.Bool
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		jwks := newJwks(rsaJwk("rsa-1", &authRsaKey.PublicKey))
+		if rotated.Load() {
+			jwks = newJwks(ecJwk("ec-2", &newKey.PublicKey))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(issuer.Close)
+
+	keys := auth.NewUrlKeySet(issuer.URL, auth.WithCaching(time.Hour, 0))
+	verifier := auth.NewVerifier(keys, testJwtIssuer, testJwtAudience)
+	ctx := context.Background()
+
+	_, err := verifier.Verify(ctx, signToken(auth.ALG_RS256, "rsa-1", authRsaKey, newTestClaims()))
+	assert.NoError(t, err)
+	_, err = verifier.Verify(ctx, signToken(auth.ALG_RS256, "rsa-1", authRsaKey, newTestClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load(), "keys should be cached")
+
+	rotated.Store(true)
+	_, err = verifier.Verify(ctx, signToken(auth.ALG_ES256, "ec-2", newKey, newTestClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), fetches.Load(), "unknown key should trigger a fetch")
+
+	_, err = verifier.Verify(ctx, signToken(auth.ALG_RS256, "rsa-1", authRsaKey, newTestClaims()))
+	assert.Error(t, err, "rotated out key should be rejected")
+}
+
+func TestAuthKeySetKeepsKeysWhenFetchFails(t *testing.T) {
+	var failing atomic.Bool
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(newJwks(rsaJwk("rsa-1", &authRsaKey.PublicKey)))
+	}))
+	t.Cleanup(issuer.Close)
+
+	keys := auth.NewUrlKeySet(issuer.URL, auth.WithCaching(0, 0))
+	verifier := auth.NewVerifier(keys, testJwtIssuer, testJwtAudience)
+	token := signToken(auth.ALG_RS256, "rsa-1", authRsaKey, newTestClaims())
+
+	_, err := verifier.Verify(context.Background(), token)
+	assert.NoError(t, err)
+	failing.Store(true)
+	_, err = verifier.Verify(context.Background(), token)
+	assert.NoError(t, err)
+}
+
+func TestAuthKeySetServesKnownKeysWhileFetching(t *testing.T) {
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(newJwks(rsaJwk("rsa-1", &authRsaKey.PublicKey)))
+	}))
+	t.Cleanup(issuer.Close)
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	})
+
+	keys := auth.NewUrlKeySet(issuer.URL, auth.WithCaching(time.Hour, 0))
+	verifier := auth.NewVerifier(keys, testJwtIssuer, testJwtAudience)
+	ctx := context.Background()
+	token := signToken(auth.ALG_RS256, "rsa-1", authRsaKey, newTestClaims())
+	_, err := verifier.Verify(ctx, token)
+	assert.NoError(t, err)
+
+	// A token signed with an unknown key waits for a fetch, which hangs.
+	unknown := make(chan error)
+	go func() {
+		_, err := verifier.Verify(ctx, signToken(auth.ALG_ES256, "ec-2", mustGenerateEcKey(), newTestClaims()))
+		unknown <- err
+	}()
+	assert.Eventually(t, func() bool { return fetches.Load() == 2 }, time.Second, 10*time.Millisecond)
+
+	known := make(chan error)
+	go func() {
+		_, err := verifier.Verify(ctx, token)
+		known <- err
+	}()
+	select {
+	case err := <-known:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("token signed with a known key waited for the fetch")
+	}
+
+	close(release)
+	assert.Error(t, <-unknown)
+}
+
+// newToken returns a token of given account, signed with authRsaKey.
+func newToken(accountUuid string, scopes ...string) string {
+	claims := newTestClaims()
+	claims["sub"] = accountUuid
+	claims["scope"] = strings.Join(scopes, " ")
+	return signToken(auth.ALG_RS256, "rsa-1", authRsaKey, claims)
+}
+
+func newTestClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss": testJwtIssuer,
+		"sub": authAccountUuid,
+		"aud": testJwtAudience,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+}
+
+// signToken returns a compact JWT; ES256 signatures are r and s padded to 32
+// bytes each.
+func signToken(alg string, kid string, key crypto.Signer, claims map[string]any) string {
+	signingInput := encodeSegment(map[string]any{"alg": alg, "typ": "JWT", "kid": kid}) + "." + encodeSegment(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			log.Fatalf("Could not sign token: %s", err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeSegment(v any) string {
+	return base64.RawURLEncoding.EncodeToString(mustMashal(v))
+}
+
+func newJwks(keys ...map[string]string) map[string]any {
+	return map[string]any{"keys": keys}
+}
+
+func rsaJwk(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": auth.ALG_RS256,
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJwk(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"use": "sig",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func mustWriteJwks(t *testing.T, keys ...map[string]string) string {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, mustMashal(newJwks(keys...)), 0o600))
+	return path
+}
+
+func mustGenerateRsaKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("Could not generate RSA key: %s", err)
+	}
+	return key
+}
+
+func mustGenerateEcKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		log.Fatalf("Could not generate EC key: %s", err)
+	}
+	return key
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSleepCalendar(t *testing.T) {
+	accountUuid := uuid.NewString()
+	warsaw, _ := time.LoadLocation("Europe/Warsaw")
+	sleepAt := time.Date(2025, 4, 15, 23, 0, 0, 0, warsaw)
+	entry := mustCreateEntry(t, api.CreateSleepDiaryEntryDto{
+		AccountUuid: accountUuid,
+		SleepDiaryEntryDataDto: api.SleepDiaryEntryDataDto{
+			Timezone:       toPtr("Europe/Warsaw"),
+			TriedToSleepAt: sleepAt,
+			FinalWakeUpAt:  sleepAt.Add(8 * time.Hour),
+			SleepQuality:   api.GoodSleepQuality,
+		},
+	})
+
+	resp := mustGet(t, fmt.Sprintf("/sleep_diary/accounts/%s/calendar.ics", accountUuid))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/calendar"))
+	body, _ := io.ReadAll(resp.Body)
+	calendar := string(body)
+
+	assert.Contains(t, calendar, "BEGIN:VTIMEZONE\r\nTZID:Europe/Warsaw\r\n")
+	assert.Contains(t, calendar, fmt.Sprintf("UID:sleep-diary-entry-%d@snorlax\r\n", entry.Id))
+	assert.Contains(t, calendar, "DTSTART;TZID=Europe/Warsaw:20250415T230000\r\n")
+	assert.Contains(t, calendar, "DTEND;TZID=Europe/Warsaw:20250416T070000\r\n")
+	assert.Contains(t, calendar, "SUMMARY:Sleep (Good)\r\n")
+}
+
+func TestGetSleepCalendarOfInaccessibleAccount(t *testing.T) {
+	accountUuid := uuid.NewString()
+	mustCreateRandomEntryOfAccount(t, accountUuid)
+	path := fmt.Sprintf("/sleep_diary/accounts/%s/calendar.ics", accountUuid)
+
+	resp := mustGetWithToken(t, authSrv.URL+path, newToken(accountUuid))
+	resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+
+	resp = mustGetWithToken(t, authSrv.URL+path, newToken(uuid.NewString()))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusNotFound, resp)
+	assert.Equal(t, api.ERR_NOT_FOUND, mustDecode[api.ErrorDto](resp.Body).Code)
+}
+
+func TestGetSleepCalendarInvalidAccountUuid(t *testing.T) {
+	resp := mustGet(t, "/sleep_diary/accounts/invalid/calendar.ics")
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/stretchr/testify/assert"
+)
+
+type sseEvent struct {
+	Id    string
+	Event string
+	Data  string
+}
+
+func TestChangeStreamPushesEntryLifecycle(t *testing.T) {
+	accountUuid := uuid.NewString()
+	events := mustOpenChangeStream(t, accountUuid, "")
+
+	entry := mustCreateEntry(t, api.CreateSleepDiaryEntryDto{
+		AccountUuid:            accountUuid,
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+	created := mustReceiveChange(t, events, api.CHANGE_CREATED)
+	assert.Equal(t, entry.Id, created.EntryId)
+	assert.Equal(t, accountUuid, created.AccountUuid)
+	assert.Equal(t, int64(1), created.Version)
+
+	resp := mustPut(t, fmt.Sprintf("/sleep_diary/entries/%d", entry.Id), api.UpdateSleepDiaryEntryDto{
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+	resp.Body.Close()
+	updated := mustReceiveChange(t, events, api.CHANGE_UPDATED)
+	assert.Equal(t, int64(2), updated.Version)
+	if assert.NotNil(t, updated.Entry) {
+		assert.Equal(t, int64(2), updated.Entry.Version)
+	}
+
+	resp = mustDelete(t, fmt.Sprintf("/sleep_diary/entries/%d", entry.Id))
+	resp.Body.Close()
+	deleted := mustReceiveChange(t, events, api.CHANGE_DELETED)
+	assert.Equal(t, entry.Id, deleted.EntryId)
+	assert.Nil(t, deleted.Entry)
+	assert.Less(t, created.Id, updated.Id)
+	assert.Less(t, updated.Id, deleted.Id)
+}
+
+func TestChangeStreamIgnoresOtherAccounts(t *testing.T) {
+	accountUuid := uuid.NewString()
+	events := mustOpenChangeStream(t, accountUuid, "")
+
+	mustCreateRandomEntry(t)
+	entry := mustCreateEntry(t, api.CreateSleepDiaryEntryDto{
+		AccountUuid:            accountUuid,
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+
+	change := mustReceiveChange(t, events, api.CHANGE_CREATED)
+	assert.Equal(t, entry.Id, change.EntryId)
+}
+
+func TestChangeStreamResumesFromLastEventId(t *testing.T) {
+	accountUuid := uuid.NewString()
+	events := mustOpenChangeStream(t, accountUuid, "")
+	first := mustCreateEntry(t, api.CreateSleepDiaryEntryDto{
+		AccountUuid:            accountUuid,
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+	firstChange := mustReceiveChange(t, events, api.CHANGE_CREATED)
+	assert.Equal(t, first.Id, firstChange.EntryId)
+
+	// Changes made while disconnected are replayed on resume.
+	second := mustCreateRandomEntryOfAccount(t, accountUuid)
+	third := mustCreateRandomEntryOfAccount(t, accountUuid)
+	resumed := mustOpenChangeStream(t, accountUuid, fmt.Sprint(firstChange.Id))
+
+	assert.Equal(t, second.Id, mustReceiveChange(t, resumed, api.CHANGE_CREATED).EntryId)
+	assert.Equal(t, third.Id, mustReceiveChange(t, resumed, api.CHANGE_CREATED).EntryId)
+}
+
+func TestChangeStreamHidesOtherAccounts(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, authSrv.URL+"/sleep_diary/changes/stream?account_uuid="+uuid.NewString(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+newToken(uuid.NewString()))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusNotFound, resp)
+}
+
+func TestChangeStreamInvalidAccount(t *testing.T) {
+	resp := mustGet(t, "/sleep_diary/changes/stream?account_uuid=invalid")
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+}
+
+func TestChangeStreamInvalidLastEventId(t *testing.T) {
+	resp := mustGet(t, fmt.Sprintf("/sleep_diary/changes/stream?account_uuid=%s&last_event_id=abc", uuid.NewString()))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+}
+
+func mustCreateRandomEntryOfAccount(t *testing.T, accountUuid string) api.SleepDiaryEntryDto {
+	return mustCreateEntry(t, api.CreateSleepDiaryEntryDto{
+		AccountUuid:            accountUuid,
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+}
+
+// mustOpenChangeStream connects to the change stream and returns a channel
+// of received events. The stream is closed when the test ends.
+func mustOpenChangeStream(t *testing.T, accountUuid string, lastEventId string) <-chan sseEvent {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/sleep_diary/changes/stream?account_uuid="+accountUuid, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	assertHttpStatusCode(t, http.StatusOK, resp)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan sseEvent)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var event sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if event.Event != "" {
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+				event = sseEvent{}
+			case strings.HasPrefix(line, "id: "):
+				event.Id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event.Event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.Data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return events
+}
+
+func mustReceiveChange(t *testing.T, events <-chan sseEvent, changeType api.ChangeType) api.EntryChangeDto {
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatalf("Stream closed before %s event", changeType)
+		}
+		assert.Equal(t, string(changeType), event.Event)
+		var change api.EntryChangeDto
+		if err := json.Unmarshal([]byte(event.Data), &change); err != nil {
+			t.Fatalf("Failed to decode change: %v", err)
+		}
+		assert.Equal(t, fmt.Sprint(change.Id), event.Id)
+		assert.Equal(t, changeType, change.Type)
+		return change
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No %s event received", changeType)
+		return api.EntryChangeDto{}
+	}
+}
@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCreateGetAndUpdateEntry(t *testing.T) {
+	ctx := context.Background()
+	c := client.New(srv.URL)
+
+	created, err := c.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{
+		AccountUuid:            uuid.NewString(),
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+	assert.NoError(t, err)
+
+	read, err := c.GetEntryById(ctx, created.Id)
+	assert.NoError(t, err)
+	assertEqualEntryDto(t, created, read, true)
+
+	data := newRandomEntryData()
+	updated, err := c.UpdateEntry(ctx, created.Id, api.UpdateSleepDiaryEntryDto{
+		Version:                &created.Version,
+		SleepDiaryEntryDataDto: data,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, created.Version+1, updated.Version)
+}
+
+func TestClientReturnsTypedError(t *testing.T) {
+	ctx := client.WithTraceId(context.Background(), "client-test-trace")
+	c := client.New(srv.URL)
+
+	_, err := c.GetEntryById(ctx, -1)
+
+	var apiErr *client.Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, api.ERR_NOT_FOUND, apiErr.Code)
+	assert.Equal(t, "client-test-trace", apiErr.TraceId)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+}
+
+func TestClientReturnsValidationErrors(t *testing.T) {
+	c := client.New(srv.URL)
+	data := newRandomEntryData()
+	data.SleepQuality = 0
+
+	_, err := c.CreateEntry(context.Background(), api.CreateSleepDiaryEntryDto{
+		AccountUuid:            uuid.NewString(),
+		SleepDiaryEntryDataDto: data,
+	})
+
+	var apiErr *client.Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, api.ERR_INVALID, apiErr.Code)
+	assert.Equal(t, "/sleep_quality", apiErr.Errors[0].Field)
+}
+
+func TestClientIteratesOverAllPages(t *testing.T) {
+	c := client.New(srv.URL)
+	accountUuid := uuid.NewString()
+	sleepAt := time.Date(2025, 3, 1, 22, 0, 0, 0, time.UTC)
+	created := map[int64]bool{}
+	for i := range 5 {
+		entry := mustCreateEntry(t, api.CreateSleepDiaryEntryDto{
+			AccountUuid:            accountUuid,
+			SleepDiaryEntryDataDto: newRandomEntryDataForSleepAt(sleepAt.AddDate(0, 0, i)),
+		})
+		created[entry.Id] = true
+	}
+
+	iterated := map[int64]bool{}
+	filter := api.SleepDiaryFilterDto{AccountUuid: []string{accountUuid}, PageSize: 2}
+	for entry, err := range c.AllEntriesByFilter(context.Background(), filter) {
+		assert.NoError(t, err)
+		iterated[entry.Id] = true
+	}
+
+	assert.Equal(t, created, iterated)
+}
+
+func TestClientIteratorStopsOnError(t *testing.T) {
+	c := client.New(srv.URL)
+	filter := api.SleepDiaryFilterDto{AccountUuid: []string{"invalid"}}
+
+	count := 0
+	for _, err := range c.AllEntriesByFilter(context.Background(), filter) {
+		assert.Equal(t, api.ERR_INVALID, client.ErrorCode(err))
+		count++
+	}
+
+	assert.Equal(t, 1, count)
+}
+
+func TestClientRetriesOnServerError(t *testing.T) {
+	var calls atomic.Int32
+	traceIds := make(chan string, 3)
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceIds <- r.Header.Get(client.TRACE_HEADER)
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": 7}`))
+	}))
+	defer flaky.Close()
+	c := client.New(flaky.URL, client.WithRetries(3, time.Millisecond))
+
+	entry, err := c.GetEntryById(context.Background(), 7)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), entry.Id)
+	assert.Equal(t, int32(3), calls.Load())
+	first := <-traceIds
+	assert.NotEmpty(t, first)
+	assert.Equal(t, first, <-traceIds)
+	assert.Equal(t, first, <-traceIds)
+}
+
+func TestClientDoesNotRetryPost(t *testing.T) {
+	var calls atomic.Int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	c := client.New(failing.URL, client.WithRetries(3, time.Millisecond))
+
+	_, err := c.CreateEntry(context.Background(), api.CreateSleepDiaryEntryDto{})
+
+	assert.Equal(t, api.ERR_UNKNOWN, client.ErrorCode(err))
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestClientRetriesRateLimitedPost(t *testing.T) {
+	var calls atomic.Int32
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"code": "ERR_RATE_LIMITED", "message": "rate limit of writes exceeded"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 7}`))
+	}))
+	defer limited.Close()
+	c := client.New(limited.URL, client.WithRetries(3, time.Millisecond))
+
+	entry, err := c.CreateEntry(context.Background(), api.CreateSleepDiaryEntryDto{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), entry.Id)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestClientDoesNotWaitLongRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"code": "ERR_RATE_LIMITED", "message": "rate limit of reads exceeded"}`))
+	}))
+	defer limited.Close()
+	c := client.New(limited.URL, client.WithRetries(3, time.Millisecond))
+
+	_, err := c.GetEntryById(context.Background(), 7)
+
+	assert.Equal(t, api.ERR_RATE_LIMITED, client.ErrorCode(err))
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -67,3 +67,43 @@ func runCreateAndGetSleepDiary(t *testing.T, dto api.CreateSleepDiaryEntryDto) {
 	retrievedEntry := mustGetEntryById(t, createdEntry.Id)
 	assertEqualEntryDto(t, createdEntry, retrievedEntry, true)
 }
+
+func TestCreateWithClientUuid(t *testing.T) {
+	entryUuid := uuid.NewString()
+	createdEntry := mustCreateEntry(t, api.CreateSleepDiaryEntryDto{
+		AccountUuid:            uuid.NewString(),
+		Uuid:                   entryUuid,
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+	if createdEntry.Uuid != entryUuid {
+		t.Fatalf("Expected UUID %s, got %s", entryUuid, createdEntry.Uuid)
+	}
+
+	retrievedEntry := mustGetEntryByUuid(t, entryUuid)
+	assertEqualEntryDto(t, createdEntry, retrievedEntry, true)
+}
+
+func TestCreateWithTakenUuid(t *testing.T) {
+	existingEntry := mustCreateRandomEntry(t)
+	dto := api.CreateSleepDiaryEntryDto{
+		AccountUuid:            uuid.NewString(),
+		Uuid:                   existingEntry.Uuid,
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	}
+
+	createResp := mustPost(t, "/sleep_diary/entries", dto)
+	defer createResp.Body.Close()
+	assertHttpStatusCode(t, http.StatusConflict, createResp)
+}
+
+func TestCreateInvalidUuid(t *testing.T) {
+	dto := api.CreateSleepDiaryEntryDto{
+		AccountUuid:            uuid.NewString(),
+		Uuid:                   "invalid",
+		SleepDiaryEntryDataDto: newMinimalRandomEntryData(),
+	}
+
+	createResp := mustPost(t, "/sleep_diary/entries", dto)
+	defer createResp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, createResp)
+}
@@ -0,0 +1,31 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestDeleteEntry(t *testing.T) {
+	entry := mustCreateRandomEntry(t)
+
+	resp := mustDelete(t, fmt.Sprintf("/sleep_diary/entries/%d", entry.Id))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusNoContent, resp)
+
+	getResp := mustGet(t, fmt.Sprintf("/sleep_diary/entries/%d", entry.Id))
+	defer getResp.Body.Close()
+	assertHttpStatusCode(t, http.StatusNotFound, getResp)
+}
+
+func TestDeleteEntryNotFound(t *testing.T) {
+	resp := mustDelete(t, "/sleep_diary/entries/-1")
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusNotFound, resp)
+}
+
+func TestDeleteEntryInvalidId(t *testing.T) {
+	resp := mustDelete(t, "/sleep_diary/entries/abc")
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+}
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/database"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// Base64 of the master key of tests.
+const testEncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func TestCommentsEncryptedAtRest(t *testing.T) {
+	data := newRandomEntryData()
+	data.Comments = toPtr("Woke up with a headache")
+	entry := mustCreateEntry(t, api.CreateSleepDiaryEntryDto{AccountUuid: uuid.NewString(), SleepDiaryEntryDataDto: data})
+	assert.Equal(t, "Woke up with a headache", *entry.Comments)
+
+	comments, keyId := mustReadStoredComments(t, entry.Id)
+	assert.NotContains(t, comments.String, "headache")
+	assert.True(t, keyId.Valid)
+
+	read := mustGetEntryById(t, entry.Id)
+	assert.Equal(t, "Woke up with a headache", *read.Comments)
+}
+
+func TestCommentsLeftOutOfQueuedPayloads(t *testing.T) {
+	data := newRandomEntryData()
+	data.Comments = toPtr("Woke up with a headache")
+	accountUuid := uuid.NewString()
+	mustCreateWebhook(t, "http://127.0.0.1:1/", accountUuid, api.CHANGE_CREATED)
+	entry := mustCreateEntry(t, api.CreateSleepDiaryEntryDto{AccountUuid: accountUuid, SleepDiaryEntryDataDto: data})
+
+	db := mustOpenDb(t)
+	for _, query := range []string{
+		"SELECT payload FROM outbox WHERE aggregate_id = $1",
+		"SELECT payload FROM webhook_deliveries WHERE change_id IN (SELECT id FROM sleep_diary_entry_changes WHERE entry_id = $1)",
+	} {
+		var payload string
+		assert.NoError(t, db.QueryRow(query, entry.Id).Scan(&payload))
+		assert.NotContains(t, payload, "headache")
+	}
+}
+
+func TestRotateDataKeys(t *testing.T) {
+	encrypted := mustCreateRandomEntry(t)
+	_, oldKeyId := mustReadStoredComments(t, encrypted.Id)
+
+	// Comments written before encryption was enabled are plain text.
+	plain := mustCreateRandomEntry(t)
+	db := mustOpenDb(t)
+	_, err := db.Exec("UPDATE sleep_diary_entries SET comments = 'Written in plain text', comments_key_id = NULL WHERE id = $1", plain.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Written in plain text", *mustGetEntryById(t, plain.Id).Comments)
+
+	result, serviceErr := service.NewSleepDiaryService(testCfg).RotateDataKeys(api.SystemPrincipal)
+	assert.Nil(t, serviceErr)
+	assert.NotEqual(t, oldKeyId.Int64, result.KeyId)
+	assert.GreaterOrEqual(t, result.ReencryptedEntries, int64(2))
+	assert.GreaterOrEqual(t, result.DeletedKeys, int64(1))
+
+	for _, entry := range []api.SleepDiaryEntryDto{encrypted, plain} {
+		comments, keyId := mustReadStoredComments(t, entry.Id)
+		assert.Equal(t, result.KeyId, keyId.Int64)
+		assert.NotContains(t, comments.String, "plain text")
+	}
+	assert.Equal(t, *encrypted.Comments, *mustGetEntryById(t, encrypted.Id).Comments)
+	assert.Equal(t, "Written in plain text", *mustGetEntryById(t, plain.Id).Comments)
+
+	var oldKeys int
+	err = db.QueryRow("SELECT count(*) FROM data_keys WHERE id = $1", oldKeyId.Int64).Scan(&oldKeys)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, oldKeys)
+}
+
+func TestRotateDataKeysRequiresAdmin(t *testing.T) {
+	caller := api.Principal{Subject: "user", AccountUuid: uuid.NewString()}
+	_, serviceErr := service.NewSleepDiaryService(testCfg).RotateDataKeys(caller)
+	assert.Equal(t, api.ERR_FORBIDDEN, serviceErr.ToErrorDto().Code)
+}
+
+// mustOpenDb connects to the database of tests as its owner, which is not
+// restricted by row-level security.
+func mustOpenDb(t *testing.T) *sql.DB {
+	db, err := sql.Open("postgres", database.ConnString(testCfg))
+	if err != nil {
+		t.Fatalf("Opening database failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func mustReadStoredComments(t *testing.T, entryId int64) (sql.NullString, sql.NullInt64) {
+	var comments sql.NullString
+	var keyId sql.NullInt64
+	err := mustOpenDb(t).QueryRow("SELECT comments, comments_key_id FROM sleep_diary_entries WHERE id = $1", entryId).Scan(&comments, &keyId)
+	if err != nil {
+		t.Fatalf("Reading comments of entry %d failed: %v", entryId, err)
+	}
+	return comments, keyId
+}
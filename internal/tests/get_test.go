@@ -12,6 +12,27 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestGetEntryByUuid(t *testing.T) {
+	createdEntry := mustCreateRandomEntry(t)
+	assert.NotEmpty(t, createdEntry.Uuid)
+
+	retrievedEntry := mustGetEntryByUuid(t, createdEntry.Uuid)
+	assertEqualEntryDto(t, createdEntry, retrievedEntry, true)
+	assert.Equal(t, createdEntry.Uuid, mustGetEntryById(t, createdEntry.Id).Uuid)
+}
+
+func TestGetEntryByUnknownUuid(t *testing.T) {
+	resp := mustGet(t, fmt.Sprintf("/sleep_diary/entries/%s", uuid.NewString()))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusNotFound, resp)
+}
+
+func TestGetEntryByInvalidId(t *testing.T) {
+	resp := mustGet(t, "/sleep_diary/entries/not-an-id")
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+}
+
 func TestGetOneEntryByFilter(t *testing.T) {
 	createdEntry := mustCreateRandomEntry(t)
 	page := mustGetEntriesByQuery(t, fmt.Sprintf("?account_uuid=%s", createdEntry.AccountUuid))
@@ -0,0 +1,209 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantsGiveReadAccess(t *testing.T) {
+	ctx := context.Background()
+	ownerUuid := uuid.NewString()
+	clinicianUuid := uuid.NewString()
+	owner := client.New(authSrv.URL, client.WithBearerToken(newToken(ownerUuid)))
+	clinician := client.New(authSrv.URL, client.WithBearerToken(newToken(clinicianUuid)))
+	entry, err := owner.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: ownerUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+
+	grant, err := owner.CreateGrant(ctx, api.CreateGrantDto{
+		AccountUuid: ownerUuid,
+		Grantee:     clinicianUuid,
+		Access:      api.ReadGrantAccess,
+		ValidTo:     time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, ownerUuid, grant.AccountUuid)
+	assert.Nil(t, grant.RevokedAt)
+
+	read, err := clinician.GetEntryById(ctx, entry.Id)
+	assert.NoError(t, err)
+	assertEqualEntryDto(t, entry, read, true)
+	_, err = clinician.UpdateEntry(ctx, entry.Id, api.UpdateSleepDiaryEntryDto{SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+	err = clinician.DeleteEntry(ctx, entry.Id)
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+	_, err = clinician.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: ownerUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+
+	received, err := clinician.GetReceivedGrants(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, grant.Id, received[0].Id)
+	}
+}
+
+func TestGrantsGiveReadWriteAccess(t *testing.T) {
+	ctx := context.Background()
+	ownerUuid := uuid.NewString()
+	coachUuid := uuid.NewString()
+	owner := client.New(authSrv.URL, client.WithBearerToken(newToken(ownerUuid)))
+	coach := client.New(authSrv.URL, client.WithBearerToken(newToken(coachUuid)))
+	_, err := owner.CreateGrant(ctx, api.CreateGrantDto{
+		AccountUuid: ownerUuid,
+		Grantee:     coachUuid,
+		Access:      api.ReadWriteGrantAccess,
+		ValidTo:     time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	entry, err := coach.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: ownerUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	_, err = coach.UpdateEntry(ctx, entry.Id, api.UpdateSleepDiaryEntryDto{SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	err = coach.DeleteEntry(ctx, entry.Id)
+	assert.NoError(t, err)
+}
+
+func TestGrantsFilterEntriesOfSeveralAccounts(t *testing.T) {
+	ctx := context.Background()
+	grantedUuid := uuid.NewString()
+	otherUuid := uuid.NewString()
+	researcherUuid := uuid.NewString()
+	granted := client.New(authSrv.URL, client.WithBearerToken(newToken(grantedUuid)))
+	other := client.New(authSrv.URL, client.WithBearerToken(newToken(otherUuid)))
+	researcher := client.New(authSrv.URL, client.WithBearerToken(newToken(researcherUuid)))
+	_, err := granted.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: grantedUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	_, err = other.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: otherUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	_, err = granted.CreateGrant(ctx, api.CreateGrantDto{
+		AccountUuid: grantedUuid,
+		Grantee:     researcherUuid,
+		Access:      api.ReadGrantAccess,
+		ValidTo:     time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	filter := api.SleepDiaryFilterDto{AccountUuid: []string{grantedUuid, otherUuid}, PageSize: 10, PageNumber: 1}
+	page, err := researcher.GetEntriesByFilter(ctx, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.TotalCount)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, grantedUuid, page.Items[0].AccountUuid)
+	}
+}
+
+func TestGrantsOutsideValidityWindowGiveNoAccess(t *testing.T) {
+	ctx := context.Background()
+	ownerUuid := uuid.NewString()
+	granteeUuid := uuid.NewString()
+	owner := client.New(authSrv.URL, client.WithBearerToken(newToken(ownerUuid)))
+	grantee := client.New(authSrv.URL, client.WithBearerToken(newToken(granteeUuid)))
+	entry, err := owner.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: ownerUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+
+	expiredFrom := time.Now().Add(-2 * time.Hour)
+	futureFrom := time.Now().Add(time.Hour)
+	for _, dto := range []api.CreateGrantDto{
+		{AccountUuid: ownerUuid, Grantee: granteeUuid, Access: api.ReadGrantAccess, ValidFrom: &expiredFrom, ValidTo: time.Now().Add(-time.Hour)},
+		{AccountUuid: ownerUuid, Grantee: granteeUuid, Access: api.ReadGrantAccess, ValidFrom: &futureFrom, ValidTo: time.Now().Add(2 * time.Hour)},
+	} {
+		_, err := owner.CreateGrant(ctx, dto)
+		assert.NoError(t, err)
+	}
+
+	_, err = grantee.GetEntryById(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	received, err := grantee.GetReceivedGrants(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, received)
+
+	grants, err := owner.GetGrants(ctx, ownerUuid)
+	assert.NoError(t, err)
+	assert.Len(t, grants, 2)
+}
+
+func TestGrantsRevoke(t *testing.T) {
+	ctx := context.Background()
+	ownerUuid := uuid.NewString()
+	granteeUuid := uuid.NewString()
+	owner := client.New(authSrv.URL, client.WithBearerToken(newToken(ownerUuid)))
+	grantee := client.New(authSrv.URL, client.WithBearerToken(newToken(granteeUuid)))
+	entry, err := owner.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: ownerUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	grant, err := owner.CreateGrant(ctx, api.CreateGrantDto{
+		AccountUuid: ownerUuid,
+		Grantee:     granteeUuid,
+		Access:      api.ReadWriteGrantAccess,
+		ValidTo:     time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	_, err = grantee.GetEntryById(ctx, entry.Id)
+	assert.NoError(t, err)
+
+	err = grantee.RevokeGrant(ctx, grant.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	err = owner.RevokeGrant(ctx, grant.Id)
+	assert.NoError(t, err)
+
+	_, err = grantee.GetEntryById(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	grants, err := owner.GetGrants(ctx, ownerUuid)
+	assert.NoError(t, err)
+	if assert.Len(t, grants, 1) {
+		assert.NotNil(t, grants[0].RevokedAt)
+	}
+}
+
+func TestGrantsManagedOnlyByOwner(t *testing.T) {
+	ctx := context.Background()
+	ownerUuid := uuid.NewString()
+	other := client.New(authSrv.URL, client.WithBearerToken(newToken(uuid.NewString())))
+	admin := client.New(authSrv.URL, client.WithBearerToken(newToken(uuid.NewString(), api.SCOPE_ADMIN)))
+	dto := api.CreateGrantDto{
+		AccountUuid: ownerUuid,
+		Grantee:     uuid.NewString(),
+		Access:      api.ReadGrantAccess,
+		ValidTo:     time.Now().Add(time.Hour),
+	}
+
+	_, err := other.CreateGrant(ctx, dto)
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+	_, err = other.GetGrants(ctx, ownerUuid)
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+
+	_, err = admin.CreateGrant(ctx, dto)
+	assert.NoError(t, err)
+	grants, err := admin.GetGrants(ctx, ownerUuid)
+	assert.NoError(t, err)
+	assert.Len(t, grants, 1)
+}
+
+func TestGrantsValidation(t *testing.T) {
+	ctx := context.Background()
+	ownerUuid := uuid.NewString()
+	owner := client.New(authSrv.URL, client.WithBearerToken(newToken(ownerUuid)))
+	validFrom := time.Now().Add(time.Hour)
+
+	_, err := owner.CreateGrant(ctx, api.CreateGrantDto{
+		AccountUuid: ownerUuid,
+		Access:      "write",
+		ValidFrom:   &validFrom,
+		ValidTo:     time.Now(),
+	})
+
+	assert.Equal(t, api.ERR_INVALID, client.ErrorCode(err))
+	var apiErr *client.Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		fields := []string{}
+		for _, fieldErr := range apiErr.Errors {
+			fields = append(fields, fieldErr.Field)
+		}
+		assert.ElementsMatch(t, []string{"/grantee", "/access", "/valid_from"}, fields)
+	}
+}
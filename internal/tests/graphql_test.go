@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/stretchr/testify/assert"
+)
+
+type graphQLResult struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code   api.ErrorCode       `json:"code"`
+			Errors []api.FieldErrorDto `json:"errors"`
+		} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func TestGraphQLEntryByUuid(t *testing.T) {
+	entry := mustCreateRandomEntry(t)
+
+	result := mustQueryGraphQL(t, api.GraphQLRequestDto{
+		Query:     `query Entry($id: ID!) { entry(id: $id) { id uuid } }`,
+		Variables: map[string]any{"id": entry.Uuid},
+	})
+
+	assert.Empty(t, result.Errors)
+	var data struct {
+		Entry struct {
+			Id   string
+			Uuid string
+		}
+	}
+	assert.NoError(t, json.Unmarshal(result.Data, &data))
+	assert.Equal(t, strconv.FormatInt(entry.Id, 10), data.Entry.Id)
+	assert.Equal(t, entry.Uuid, data.Entry.Uuid)
+}
+
+func TestGraphQLEntriesByFilter(t *testing.T) {
+	accountUuid := uuid.NewString()
+	sleepAt := time.Date(2025, 7, 1, 23, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		mustCreateEntry(t, api.CreateSleepDiaryEntryDto{
+			AccountUuid:            accountUuid,
+			SleepDiaryEntryDataDto: newRandomEntryDataForSleepAt(sleepAt.AddDate(0, 0, i)),
+		})
+	}
+
+	result := mustQueryGraphQL(t, api.GraphQLRequestDto{
+		Query: `query Entries($accountUuid: ID!) {
+			entries(filter: {accountUuid: [$accountUuid], pageSize: 2}) {
+				totalCount
+				pageSize
+				items { id accountUuid triedToSleepAt metrics { totalSleepTimeInMin sleepEfficiency } }
+			}
+		}`,
+		Variables: map[string]any{"accountUuid": accountUuid},
+	})
+
+	assert.Empty(t, result.Errors)
+	var data struct {
+		Entries struct {
+			TotalCount int64
+			PageSize   int64
+			Items      []struct {
+				Id          string
+				AccountUuid string
+				Metrics     struct{ TotalSleepTimeInMin *int }
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(result.Data, &data))
+	assert.Equal(t, int64(3), data.Entries.TotalCount)
+	assert.Equal(t, int64(2), data.Entries.PageSize)
+	if assert.Len(t, data.Entries.Items, 2) {
+		assert.Equal(t, accountUuid, data.Entries.Items[0].AccountUuid)
+		assert.NotNil(t, data.Entries.Items[0].Metrics.TotalSleepTimeInMin)
+	}
+}
+
+func TestGraphQLAccountsWithSummary(t *testing.T) {
+	accountUuids := []string{uuid.NewString(), uuid.NewString()}
+	sleepAt := time.Date(2025, 7, 10, 23, 0, 0, 0, time.UTC)
+	for i, accountUuid := range accountUuids {
+		for day := range i + 1 {
+			mustCreateEntry(t, api.CreateSleepDiaryEntryDto{
+				AccountUuid:            accountUuid,
+				SleepDiaryEntryDataDto: newRandomEntryDataForSleepAt(sleepAt.AddDate(0, 0, day)),
+			})
+		}
+	}
+
+	result := mustQueryGraphQL(t, api.GraphQLRequestDto{
+		Query: `query Accounts($uuids: [ID!]!) {
+			accounts(uuids: $uuids) {
+				uuid
+				entries(fromDate: "2025-07-10T00:00:00Z", toDate: "2025-07-17T00:00:00Z") { id }
+				summary(fromDate: "2025-07-10T00:00:00Z", toDate: "2025-07-17T00:00:00Z") { recordedNights totalNights }
+			}
+		}`,
+		Variables: map[string]any{"uuids": accountUuids},
+	})
+
+	assert.Empty(t, result.Errors)
+	var data struct {
+		Accounts []struct {
+			Uuid    string
+			Entries []struct{ Id string }
+			Summary struct {
+				RecordedNights int
+				TotalNights    int
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(result.Data, &data))
+	if assert.Len(t, data.Accounts, 2) {
+		for i, account := range data.Accounts {
+			assert.Equal(t, accountUuids[i], account.Uuid)
+			assert.Len(t, account.Entries, i+1)
+			assert.Equal(t, i+1, account.Summary.RecordedNights)
+			assert.Equal(t, 7, account.Summary.TotalNights)
+		}
+	}
+}
+
+func TestGraphQLInvalidAccountError(t *testing.T) {
+	result := mustQueryGraphQL(t, api.GraphQLRequestDto{
+		Query: `{ account(uuid: "invalid") { uuid } }`,
+	})
+
+	if assert.Len(t, result.Errors, 1) {
+		assert.Equal(t, api.ERR_INVALID, result.Errors[0].Extensions.Code)
+		assert.Equal(t, api.RULE_FORMAT, result.Errors[0].Extensions.Errors[0].Rule)
+	}
+}
+
+func TestGraphQLEntryNotFoundError(t *testing.T) {
+	result := mustQueryGraphQL(t, api.GraphQLRequestDto{
+		Query: `{ entry(id: "-1") { id } }`,
+	})
+
+	if assert.Len(t, result.Errors, 1) {
+		assert.Equal(t, api.ERR_NOT_FOUND, result.Errors[0].Extensions.Code)
+	}
+}
+
+func TestGraphQLRejectsTooComplexQuery(t *testing.T) {
+	fields := []string{}
+	for i := range 20 {
+		fields = append(fields, fmt.Sprintf("e%d: entries { id metrics { totalSleepTimeInMin timeInBedInMin sleepEfficiency } }", i))
+	}
+
+	result := mustQueryGraphQL(t, api.GraphQLRequestDto{
+		Query: fmt.Sprintf(`{ accounts(uuids: ["%s"]) { %s } }`, uuid.NewString(), strings.Join(fields, " ")),
+	})
+
+	assert.Equal(t, "null", string(result.Data))
+	if assert.Len(t, result.Errors, 1) {
+		assert.Contains(t, result.Errors[0].Message, "complexity")
+		assert.Equal(t, api.ERR_INVALID, result.Errors[0].Extensions.Code)
+	}
+}
+
+func TestGraphQLMissingQuery(t *testing.T) {
+	resp := mustPost(t, "/graphql", api.GraphQLRequestDto{})
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+	dto := mustDecode[api.ErrorDto](resp.Body)
+	assert.Equal(t, "/query", dto.Errors[0].Field)
+}
+
+func mustQueryGraphQL(t *testing.T, request api.GraphQLRequestDto) graphQLResult {
+	resp := mustPost(t, "/graphql", request)
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+	return mustDecode[graphQLResult](resp.Body)
+}
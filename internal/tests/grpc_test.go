@@ -0,0 +1,263 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/pkg/rpc"
+	"github.com/mabzd/snorlax/pkg/rpc/sleepdiarypb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestGrpcCreateAndGetEntry(t *testing.T) {
+	client := sleepdiarypb.NewSleepDiaryServiceClient(grpcConn)
+	ctx := context.Background()
+	data := newRandomGrpcEntryData(time.Date(2025, 5, 1, 22, 30, 0, 0, time.UTC))
+
+	created, err := client.CreateEntry(ctx, &sleepdiarypb.CreateEntryRequest{
+		AccountUuid: uuid.NewString(),
+		Data:        data,
+	})
+	assert.NoError(t, err)
+	assert.NotZero(t, created.Id)
+	assert.Equal(t, int64(1), created.Version)
+	assert.True(t, data.TriedToSleepAt.AsTime().Equal(created.Data.TriedToSleepAt.AsTime()))
+	assert.Equal(t, data.SleepQuality, created.Data.SleepQuality)
+
+	read, err := client.GetEntry(ctx, &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Id{Id: created.Id}})
+	assert.NoError(t, err)
+	assert.Equal(t, created.AccountUuid, read.AccountUuid)
+
+	// Entry created through gRPC is the same entry the REST API serves.
+	restEntry := mustGetEntryById(t, created.Id)
+	assert.Equal(t, created.AccountUuid, restEntry.AccountUuid)
+}
+
+func TestGrpcEntryByUuid(t *testing.T) {
+	client := sleepdiarypb.NewSleepDiaryServiceClient(grpcConn)
+	ctx := context.Background()
+	accountUuid := uuid.NewString()
+	entryUuid := uuid.NewString()
+
+	created, err := client.CreateEntry(ctx, &sleepdiarypb.CreateEntryRequest{
+		AccountUuid: accountUuid,
+		Uuid:        entryUuid,
+		Data:        newRandomGrpcEntryData(time.Date(2025, 5, 1, 22, 30, 0, 0, time.UTC)),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, entryUuid, created.Uuid)
+
+	read, err := client.GetEntry(ctx, &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Uuid{Uuid: entryUuid}})
+	assert.NoError(t, err)
+	assert.Equal(t, created.Id, read.Id)
+
+	updated, err := client.UpdateEntry(ctx, &sleepdiarypb.UpdateEntryRequest{
+		Entry: &sleepdiarypb.UpdateEntryRequest_Uuid{Uuid: entryUuid},
+		Data:  newRandomGrpcEntryData(time.Date(2025, 5, 2, 22, 30, 0, 0, time.UTC)),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, created.Id, updated.Id)
+	assert.Equal(t, entryUuid, updated.Uuid)
+	assert.Equal(t, int64(2), updated.Version)
+
+	_, err = client.CreateEntry(ctx, &sleepdiarypb.CreateEntryRequest{
+		AccountUuid: accountUuid,
+		Uuid:        entryUuid,
+		Data:        newRandomGrpcEntryData(time.Date(2025, 5, 3, 22, 30, 0, 0, time.UTC)),
+	})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	_, err = client.GetEntry(ctx, &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Uuid{Uuid: uuid.NewString()}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.GetEntry(ctx, &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Uuid{Uuid: "123"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGrpcUpdateEntryVersionConflict(t *testing.T) {
+	client := sleepdiarypb.NewSleepDiaryServiceClient(grpcConn)
+	entry := mustCreateRandomEntry(t)
+	staleVersion := entry.Version - 1
+
+	_, err := client.UpdateEntry(context.Background(), &sleepdiarypb.UpdateEntryRequest{
+		Entry:   &sleepdiarypb.UpdateEntryRequest_Id{Id: entry.Id},
+		Version: &staleVersion,
+		Data:    newRandomGrpcEntryData(entry.TriedToSleepAt),
+	})
+
+	assert.Equal(t, codes.Aborted, status.Code(err))
+}
+
+func TestGrpcGetEntryNotFound(t *testing.T) {
+	client := sleepdiarypb.NewSleepDiaryServiceClient(grpcConn)
+
+	_, err := client.GetEntry(context.Background(), &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Id{Id: -1}})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGrpcValidationErrorDetails(t *testing.T) {
+	client := sleepdiarypb.NewSleepDiaryServiceClient(grpcConn)
+	data := newRandomGrpcEntryData(time.Now())
+	data.SleepQuality = sleepdiarypb.SleepQuality_SLEEP_QUALITY_UNSPECIFIED
+
+	_, err := client.CreateEntry(context.Background(), &sleepdiarypb.CreateEntryRequest{
+		AccountUuid: uuid.NewString(),
+		Data:        data,
+	})
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	if assert.Len(t, st.Details(), 1) {
+		badRequest := st.Details()[0].(*errdetails.BadRequest)
+		assert.Equal(t, "/sleep_quality", badRequest.FieldViolations[0].Field)
+		assert.Equal(t, string(api.RULE_MINIMUM), badRequest.FieldViolations[0].Reason)
+	}
+}
+
+func TestGrpcEntryWithoutData(t *testing.T) {
+	client := sleepdiarypb.NewSleepDiaryServiceClient(grpcConn)
+	ctx := context.Background()
+
+	_, err := client.CreateEntry(ctx, &sleepdiarypb.CreateEntryRequest{AccountUuid: uuid.NewString()})
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	if assert.Len(t, st.Details(), 1) {
+		badRequest := st.Details()[0].(*errdetails.BadRequest)
+		assert.Equal(t, "/data", badRequest.FieldViolations[0].Field)
+		assert.Equal(t, string(api.RULE_REQUIRED), badRequest.FieldViolations[0].Reason)
+	}
+
+	created, err := client.CreateEntry(ctx, &sleepdiarypb.CreateEntryRequest{
+		AccountUuid: uuid.NewString(),
+		Data:        newRandomGrpcEntryData(time.Date(2025, 5, 3, 22, 30, 0, 0, time.UTC)),
+	})
+	assert.NoError(t, err)
+	_, err = client.UpdateEntry(ctx, &sleepdiarypb.UpdateEntryRequest{Entry: &sleepdiarypb.UpdateEntryRequest_Id{Id: created.Id}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// The server is still up.
+	_, err = client.GetEntry(ctx, &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Id{Id: created.Id}})
+	assert.NoError(t, err)
+}
+
+func TestGrpcListEntriesStreamsAllPages(t *testing.T) {
+	client := sleepdiarypb.NewSleepDiaryServiceClient(grpcConn)
+	accountUuid := uuid.NewString()
+	sleepAt := time.Date(2025, 6, 1, 23, 0, 0, 0, time.UTC)
+	created := map[int64]bool{}
+	for i := range 3 {
+		entry := mustCreateEntry(t, api.CreateSleepDiaryEntryDto{
+			AccountUuid:            accountUuid,
+			SleepDiaryEntryDataDto: newRandomEntryDataForSleepAt(sleepAt.AddDate(0, 0, i)),
+		})
+		created[entry.Id] = true
+	}
+
+	stream, err := client.ListEntries(context.Background(), &sleepdiarypb.ListEntriesRequest{
+		AccountUuid: []string{accountUuid},
+	})
+	assert.NoError(t, err)
+
+	streamed := map[int64]bool{}
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			break
+		}
+		streamed[entry.Id] = true
+	}
+	assert.Equal(t, created, streamed)
+}
+
+func TestGrpcListEntriesInvalidFilter(t *testing.T) {
+	client := sleepdiarypb.NewSleepDiaryServiceClient(grpcConn)
+
+	stream, err := client.ListEntries(context.Background(), &sleepdiarypb.ListEntriesRequest{})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func newRandomGrpcEntryData(sleepAt time.Time) *sleepdiarypb.SleepDiaryEntryData {
+	dto := newRandomEntryDataForSleepAt(sleepAt)
+	return &sleepdiarypb.SleepDiaryEntryData{
+		Timezone:       dto.Timezone,
+		TriedToSleepAt: timestamppb.New(dto.TriedToSleepAt),
+		FinalWakeUpAt:  timestamppb.New(dto.FinalWakeUpAt),
+		SleepQuality:   sleepdiarypb.SleepQuality(dto.SleepQuality),
+	}
+}
+
+func TestGrpcAuthenticatesCalls(t *testing.T) {
+	client := sleepdiarypb.NewSleepDiaryServiceClient(newAuthGrpcConn(t))
+	ownerUuid := uuid.NewString()
+	owner := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+newToken(ownerUuid))
+	other := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+newToken(uuid.NewString()))
+
+	_, err := client.GetEntry(context.Background(), &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Id{Id: 1}})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	invalid := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer not-a-token")
+	_, err = client.GetEntry(invalid, &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Id{Id: 1}})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	created, err := client.CreateEntry(owner, &sleepdiarypb.CreateEntryRequest{
+		AccountUuid: ownerUuid,
+		Data:        newRandomGrpcEntryData(time.Date(2025, 5, 1, 22, 30, 0, 0, time.UTC)),
+	})
+	assert.NoError(t, err)
+	_, err = client.GetEntry(owner, &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Id{Id: created.Id}})
+	assert.NoError(t, err)
+	_, err = client.GetEntry(other, &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Id{Id: created.Id}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	stream, err := client.ListEntries(other, &sleepdiarypb.ListEntriesRequest{AccountUuid: []string{ownerUuid}})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+	stream, err = client.ListEntries(context.Background(), &sleepdiarypb.ListEntriesRequest{AccountUuid: []string{ownerUuid}})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+// newAuthGrpcConn returns a connection to a gRPC server authenticating calls
+// with tokens of newToken. The server is stopped when the test ends.
+func newAuthGrpcConn(t *testing.T) *grpc.ClientConn {
+	return newGrpcConn(t, newAuthConfig(testCfg))
+}
+
+// newGrpcConn returns a connection to a gRPC server configured with cfg. The
+// server is stopped when the test ends.
+func newGrpcConn(t *testing.T, cfg config.Config) *grpc.ClientConn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err)
+	}
+	server := rpc.NewServer(cfg)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Could not connect to gRPC server: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadAndGetHypnogram(t *testing.T) {
+	sleepAt := time.Date(2025, 4, 15, 22, 0, 0, 0, time.UTC)
+	entry := mustCreateEntry(t, api.CreateSleepDiaryEntryDto{
+		AccountUuid: uuid.NewString(),
+		SleepDiaryEntryDataDto: api.SleepDiaryEntryDataDto{
+			TriedToSleepAt:               sleepAt,
+			SleepDelayInMin:              toPtr(60),
+			AwakeningsTotalDurationInMin: toPtr(60),
+			FinalWakeUpAt:                sleepAt.Add(8 * time.Hour),
+			SleepQuality:                 api.PoorSleepQuality,
+		},
+	})
+
+	upload := api.UploadHypnogramDto{
+		Segments: []api.SleepStageSegmentDto{
+			newSegment(api.WakeSleepStage, sleepAt, 20),
+			newSegment(api.LightSleepStage, sleepAt.Add(20*time.Minute), 100),
+			newSegment(api.WakeSleepStage, sleepAt.Add(120*time.Minute), 10),
+			newSegment(api.DeepSleepStage, sleepAt.Add(130*time.Minute), 150),
+			newSegment(api.RemSleepStage, sleepAt.Add(280*time.Minute), 190),
+			newSegment(api.WakeSleepStage, sleepAt.Add(470*time.Minute), 10),
+		},
+	}
+
+	uploadResp := mustPut(t, fmt.Sprintf("/sleep_diary/entries/%d/hypnogram", entry.Id), upload)
+	defer uploadResp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, uploadResp)
+
+	hypnogram := mustGetHypnogram(t, entry.Id)
+	assert.Equal(t, len(upload.Segments), len(hypnogram.Segments))
+	assert.Equal(t, api.SleepMetricsDto{SleepOnsetLatencyInMin: 60, WakeAfterSleepOnsetInMin: 60, TotalSleepTimeInMin: 360}, hypnogram.Subjective)
+	assert.Equal(t, api.SleepMetricsDto{SleepOnsetLatencyInMin: 20, WakeAfterSleepOnsetInMin: 10, TotalSleepTimeInMin: 440}, *hypnogram.Objective)
+	assert.Equal(t, api.SleepMetricsDto{SleepOnsetLatencyInMin: 40, WakeAfterSleepOnsetInMin: 50, TotalSleepTimeInMin: -80}, *hypnogram.Discrepancy)
+}
+
+func TestGetEmptyHypnogram(t *testing.T) {
+	entry := mustCreateRandomEntry(t)
+	hypnogram := mustGetHypnogram(t, entry.Id)
+	assert.Equal(t, 0, len(hypnogram.Segments))
+	assert.Nil(t, hypnogram.Objective)
+	assert.Nil(t, hypnogram.Discrepancy)
+}
+
+func TestUploadOverlappingHypnogram(t *testing.T) {
+	entry := mustCreateRandomEntry(t)
+	upload := api.UploadHypnogramDto{
+		Segments: []api.SleepStageSegmentDto{
+			newSegment(api.LightSleepStage, entry.TriedToSleepAt, 60),
+			newSegment(api.DeepSleepStage, entry.TriedToSleepAt.Add(30*time.Minute), 60),
+		},
+	}
+
+	resp := mustPut(t, fmt.Sprintf("/sleep_diary/entries/%d/hypnogram", entry.Id), upload)
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+}
+
+func TestUploadHypnogramForNonExistingEntry(t *testing.T) {
+	upload := api.UploadHypnogramDto{
+		Segments: []api.SleepStageSegmentDto{newSegment(api.LightSleepStage, time.Now(), 60)},
+	}
+
+	resp := mustPut(t, fmt.Sprintf("/sleep_diary/entries/%v/hypnogram", 99999999999999999), upload)
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusNotFound, resp)
+}
+
+func newSegment(stage api.SleepStage, startAt time.Time, minutes int) api.SleepStageSegmentDto {
+	return api.SleepStageSegmentDto{
+		Stage:        stage,
+		StartAt:      startAt,
+		EndAt:        startAt.Add(time.Duration(minutes) * time.Minute),
+		SourceDevice: toPtr("Test Band"),
+	}
+}
+
+func mustGetHypnogram(t *testing.T, entryId int64) api.HypnogramDto {
+	resp := mustGet(t, fmt.Sprintf("/sleep_diary/entries/%d/hypnogram", entryId))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+	return mustDecode[api.HypnogramDto](resp.Body)
+}
@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// Maximum size of import files and of files extracted from them in tests.
+const TEST_IMPORT_MAX_SIZE_MB = 1
+
+const appleHealthExport = `<?xml version="1.0" encoding="UTF-8"?>
+<HealthData locale="en_US">
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" value="HKCategoryValueSleepAnalysisInBed" startDate="2025-04-15 22:30:00 +0200" endDate="2025-04-16 07:10:00 +0200"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" value="HKCategoryValueSleepAnalysisAsleepCore" startDate="2025-04-15 22:50:00 +0200" endDate="2025-04-16 02:00:00 +0200"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" value="HKCategoryValueSleepAnalysisAwake" startDate="2025-04-16 02:00:00 +0200" endDate="2025-04-16 02:12:00 +0200"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" value="HKCategoryValueSleepAnalysisAsleepDeep" startDate="2025-04-16 02:12:00 +0200" endDate="2025-04-16 06:55:00 +0200"/>
+ <Record type="HKQuantityTypeIdentifierStepCount" value="120" startDate="2025-04-16 08:00:00 +0200" endDate="2025-04-16 08:10:00 +0200"/>
+</HealthData>`
+
+const fitbitExport = `[
+  {
+    "logId": 26589710670,
+    "dateOfSleep": "2025-04-16",
+    "startTime": "2025-04-15T23:10:00.000",
+    "endTime": "2025-04-16T07:00:00.000",
+    "minutesToFallAsleep": 5,
+    "minutesAsleep": 420,
+    "minutesAwake": 45,
+    "minutesAfterWakeup": 10,
+    "timeInBed": 470,
+    "efficiency": 93,
+    "type": "stages",
+    "levels": {
+      "data": [
+        { "dateTime": "2025-04-15T23:10:00.000", "level": "wake", "seconds": 300 },
+        { "dateTime": "2025-04-15T23:15:00.000", "level": "light", "seconds": 7200 },
+        { "dateTime": "2025-04-16T01:15:00.000", "level": "wake", "seconds": 900 },
+        { "dateTime": "2025-04-16T01:30:00.000", "level": "deep", "seconds": 9000 },
+        { "dateTime": "2025-04-16T04:00:00.000", "level": "wake", "seconds": 600 },
+        { "dateTime": "2025-04-16T04:10:00.000", "level": "rem", "seconds": 9000 },
+        { "dateTime": "2025-04-16T06:40:00.000", "level": "wake", "seconds": 1200 }
+      ]
+    },
+    "mainSleep": true
+  }
+]`
+
+func TestImportAppleHealthAndConfirmDraft(t *testing.T) {
+	accountUuid := uuid.NewString()
+	result := mustImport(t, "apple_health", accountUuid, appleHealthExport)
+
+	assert.Equal(t, api.AppleHealthImportSource, result.Source)
+	assert.Equal(t, 1, len(result.Drafts))
+	draft := result.Drafts[0]
+	assert.Equal(t, "2025-04-15T22:30:00+02:00", draft.TriedToSleepAt.Format(time.RFC3339))
+	assert.Equal(t, "2025-04-16T06:55:00+02:00", draft.FinalWakeUpAt.Format(time.RFC3339))
+	assert.Equal(t, 20, *draft.SleepDelayInMin)
+	assert.Equal(t, 1, *draft.AwakeningsCount)
+	assert.Equal(t, 12, *draft.AwakeningsTotalDurationInMin)
+	assert.Equal(t, api.SleepQuality(0), draft.SleepQuality)
+
+	confirmResp := mustPost(t, fmt.Sprintf("/sleep_diary/drafts/%d/confirm", draft.Id), api.ConfirmSleepDiaryDraftDto{
+		SleepQuality: api.GoodSleepQuality,
+	})
+	defer confirmResp.Body.Close()
+	assertHttpStatusCode(t, http.StatusCreated, confirmResp)
+	entry := mustDecode[api.SleepDiaryEntryDto](confirmResp.Body)
+
+	assert.Equal(t, api.GoodSleepQuality, entry.SleepQuality)
+	assertValuesEqualTimeMsPrec(t, &draft.TriedToSleepAt, &entry.TriedToSleepAt, "TriedToSleepAt")
+	assert.Equal(t, 0, len(mustGetDrafts(t, accountUuid)))
+}
+
+func TestConfirmDraftWithoutSleepQuality(t *testing.T) {
+	result := mustImport(t, "apple_health", uuid.NewString(), appleHealthExport)
+
+	confirmResp := mustPost(t, fmt.Sprintf("/sleep_diary/drafts/%d/confirm", result.Drafts[0].Id), api.ConfirmSleepDiaryDraftDto{})
+	defer confirmResp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, confirmResp)
+}
+
+func TestDeleteDraft(t *testing.T) {
+	accountUuid := uuid.NewString()
+	result := mustImport(t, "apple_health", accountUuid, appleHealthExport)
+
+	deleteResp := mustDelete(t, fmt.Sprintf("/sleep_diary/drafts/%d", result.Drafts[0].Id))
+	defer deleteResp.Body.Close()
+	assertHttpStatusCode(t, http.StatusNoContent, deleteResp)
+	assert.Equal(t, 0, len(mustGetDrafts(t, accountUuid)))
+}
+
+func TestImportFitbit(t *testing.T) {
+	result := mustImport(t, "fitbit", uuid.NewString(), fitbitExport)
+
+	assert.Equal(t, 1, result.CreatedCount)
+	assert.Equal(t, 1, len(result.Drafts))
+	draft := result.Drafts[0]
+	assert.Equal(t, "2025-04-15T23:10:00+02:00", draft.TriedToSleepAt.Format(time.RFC3339))
+	assert.Equal(t, "2025-04-16T06:50:00+02:00", draft.FinalWakeUpAt.Format(time.RFC3339))
+	assert.Equal(t, "2025-04-16T07:00:00+02:00", draft.OutOfBedAt.Format(time.RFC3339))
+	assert.Equal(t, 5, *draft.SleepDelayInMin)
+	assert.Equal(t, 2, *draft.AwakeningsCount)
+	assert.Equal(t, 45, *draft.AwakeningsTotalDurationInMin)
+}
+
+func TestImportMergesOverlappingDrafts(t *testing.T) {
+	accountUuid := uuid.NewString()
+	first := mustImport(t, "apple_health", accountUuid, appleHealthExport)
+	second := mustImport(t, "fitbit", accountUuid, fitbitExport)
+
+	assert.Equal(t, 0, second.CreatedCount)
+	assert.Equal(t, 1, second.MergedCount)
+	assert.Equal(t, api.MergedImportRecordStatus, second.Records[0].Status)
+	assert.Equal(t, first.Drafts[0].Id, *second.Records[0].DraftId)
+	assert.Equal(t, 1, len(mustGetDrafts(t, accountUuid)))
+}
+
+func TestImportSkipsRecordsAlreadyInDiary(t *testing.T) {
+	accountUuid := uuid.NewString()
+	first := mustImport(t, "apple_health", accountUuid, appleHealthExport)
+	confirmResp := mustPost(t, fmt.Sprintf("/sleep_diary/drafts/%d/confirm", first.Drafts[0].Id), api.ConfirmSleepDiaryDraftDto{
+		SleepQuality: api.AverageSleepQuality,
+	})
+	defer confirmResp.Body.Close()
+	assertHttpStatusCode(t, http.StatusCreated, confirmResp)
+	entry := mustDecode[api.SleepDiaryEntryDto](confirmResp.Body)
+
+	second := mustImport(t, "fitbit", accountUuid, fitbitExport)
+
+	assert.Equal(t, 1, second.SkippedCount)
+	assert.Equal(t, api.SkippedImportRecordStatus, second.Records[0].Status)
+	assert.Equal(t, entry.Id, *second.Records[0].EntryId)
+	assert.Equal(t, 0, len(mustGetDrafts(t, accountUuid)))
+}
+
+func TestImportUnknownSource(t *testing.T) {
+	resp := mustSend(t, http.MethodPost, fmt.Sprintf("/sleep_diary/imports/unknown?account_uuid=%s", uuid.NewString()), "application/xml", []byte(appleHealthExport))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+}
+
+func TestImportRejectsTooLargeFile(t *testing.T) {
+	export := strings.Replace(appleHealthExport, "</HealthData>", strings.Repeat(" ", TEST_IMPORT_MAX_SIZE_MB<<20)+"</HealthData>", 1)
+	resp := mustSend(t, http.MethodPost, fmt.Sprintf("/sleep_diary/imports/apple_health?account_uuid=%s", uuid.NewString()), "application/xml", []byte(export))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+	assert.Equal(t, "import file too large", mustDecode[api.ErrorDto](resp.Body).Message)
+}
+
+func TestImportRejectsTooLargeExtractedFile(t *testing.T) {
+	// Compresses to a few kilobytes, well below the size of the upload.
+	export := strings.Replace(appleHealthExport, "</HealthData>", strings.Repeat(" ", TEST_IMPORT_MAX_SIZE_MB<<20)+"</HealthData>", 1)
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	file, err := writer.Create("apple_health_export/export.xml")
+	assert.NoError(t, err)
+	_, err = file.Write([]byte(export))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	resp := mustSend(t, http.MethodPost, fmt.Sprintf("/sleep_diary/imports/apple_health?account_uuid=%s", uuid.NewString()), "application/zip", archive.Bytes())
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+	assert.Equal(t, "import file too large", mustDecode[api.ErrorDto](resp.Body).Message)
+}
+
+func mustImport(t *testing.T, source string, accountUuid string, body string) api.ImportResultDto {
+	resp := mustSend(t, http.MethodPost, fmt.Sprintf("/sleep_diary/imports/%s?account_uuid=%s&timezone=Europe/Warsaw", source, accountUuid), "application/octet-stream", []byte(body))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusCreated, resp)
+	return mustDecode[api.ImportResultDto](resp.Body)
+}
+
+func mustGetDrafts(t *testing.T, accountUuid string) []api.SleepDiaryDraftDto {
+	resp := mustGet(t, fmt.Sprintf("/sleep_diary/drafts?account_uuid=%s", accountUuid))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+	return mustDecode[[]api.SleepDiaryDraftDto](resp.Body)
+}
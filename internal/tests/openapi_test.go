@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/pkg/openapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOpenApiSpecDocumentsResponses requests every operation of the spec as
+// several callers and checks that the spec documents the status of each
+// response.
+func TestOpenApiSpecDocumentsResponses(t *testing.T) {
+	spec := mustGetOpenApiSpec(t)
+	assert.NotEmpty(t, spec.Paths)
+
+	cfg := testCfg
+	cfg.RateLimitReads = 1
+	cfg.RateLimitWrites = 1
+	cfg.RateLimitExports = 1
+	cfg.RateLimitStore = "memory"
+	limitedSrv := newAuthServer(cfg)
+	defer limitedSrv.Close()
+
+	for path, operations := range spec.Paths {
+		for method, operation := range operations {
+			name := strings.ToUpper(method) + " " + path
+			public := path == "/openapi.json"
+
+			declared := []string{}
+			for _, param := range operation.Parameters {
+				if param.In == "path" {
+					declared = append(declared, param.Name)
+				}
+			}
+			assert.ElementsMatch(t, openapi.PathParams(path), declared, "path parameters of %s", name)
+			assert.NotEmpty(t, operation.OperationId, name)
+
+			// Trusted callers may access any account.
+			resp := mustSendToOperation(t, srv.URL, method, path, operation, uuid.NewString(), "")
+			assertDocumentedStatus(t, operation, name, resp)
+			if resp.StatusCode == http.StatusNotFound {
+				errorDto := mustDecode[api.ErrorDto](resp.Body)
+				assert.NotEqual(t, "path not found", errorDto.Message, name)
+			}
+			resp.Body.Close()
+
+			// Callers without credentials are rejected before the handler.
+			resp = mustSendToOperation(t, authSrv.URL, method, path, operation, uuid.NewString(), "")
+			if public {
+				assert.Equal(t, http.StatusOK, resp.StatusCode, name)
+			} else {
+				assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, name)
+			}
+			assertDocumentedStatus(t, operation, name, resp)
+			resp.Body.Close()
+
+			// Callers are refused accounts they have no access to.
+			resp = mustSendToOperation(t, authSrv.URL, method, path, operation, uuid.NewString(), newToken(uuid.NewString()))
+			assertDocumentedStatus(t, operation, name, resp)
+			resp.Body.Close()
+
+			// Each caller is limited to a request of every route class.
+			token := newToken(uuid.NewString())
+			accountUuid := uuid.NewString()
+			resp = mustSendToOperation(t, limitedSrv.URL, method, path, operation, accountUuid, token)
+			resp.Body.Close()
+			resp = mustSendToOperation(t, limitedSrv.URL, method, path, operation, accountUuid, token)
+			assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode, name)
+			assertDocumentedStatus(t, operation, name, resp)
+			resp.Body.Close()
+		}
+	}
+}
+
+func TestOpenApiSpecConstraints(t *testing.T) {
+	spec := mustGetOpenApiSpec(t)
+
+	entry := spec.Components.Schemas["CreateSleepDiaryEntryDto"]
+	assert.NotNil(t, entry)
+	assert.Equal(t, int64(api.MAX_COMMENT_LENGTH), *entry.Properties["comments"].MaxLength)
+	assert.Equal(t, int64(api.VeryPoorSleepQuality), *entry.Properties["sleep_quality"].Minimum)
+	assert.Equal(t, int64(api.ExcellentSleepQuality), *entry.Properties["sleep_quality"].Maximum)
+	assert.Contains(t, entry.Required, "account_uuid")
+	assert.NotContains(t, entry.Required, "comments")
+
+	var pageSize *openapi.Schema
+	for _, param := range spec.Paths["/sleep_diary/entries"]["get"].Parameters {
+		if param.Name == "page_size" {
+			pageSize = param.Schema
+		}
+	}
+	assert.NotNil(t, pageSize)
+	assert.Equal(t, api.MAX_PAGE_SIZE, *pageSize.Maximum)
+
+	errorDto := spec.Components.Schemas["ErrorDto"]
+	assert.NotNil(t, errorDto)
+	assert.Contains(t, errorDto.Properties["code"].Enum, string(api.ERR_NOT_FOUND))
+	assert.Contains(t, spec.Components.Schemas, "PageDtoSleepDiaryEntryDto")
+}
+
+func mustGetOpenApiSpec(t *testing.T) openapi.Document {
+	resp := mustGet(t, "/openapi.json")
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+	return mustDecode[openapi.Document](resp.Body)
+}
+
+// mustSendToOperation requests an operation of the spec with an empty JSON
+// object as body, filling in path and required query parameters; account
+// UUIDs are set to accountUuid. The request carries token when not empty.
+func mustSendToOperation(t *testing.T, baseUrl string, method string, path string, operation *openapi.JsonOperation, accountUuid string, token string) *http.Response {
+	values := map[string]string{
+		"id":           "0",
+		"account_uuid": accountUuid,
+		"source":       string(api.FitbitImportSource),
+		"study_id":     "study",
+	}
+	query := url.Values{}
+	for _, param := range operation.Parameters {
+		switch {
+		case param.In == "path":
+			path = strings.ReplaceAll(path, "{"+param.Name+"}", values[param.Name])
+		case param.In == "query" && param.Required:
+			query.Set(param.Name, values[param.Name])
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(method), baseUrl+path, strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	return resp
+}
+
+func assertDocumentedStatus(t *testing.T, operation *openapi.JsonOperation, name string, resp *http.Response) {
+	t.Helper()
+	assert.Contains(t, operation.Responses, strconv.Itoa(resp.StatusCode), "status of %s is not documented", name)
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/pkg/outbox"
+	"github.com/stretchr/testify/assert"
+)
+
+var outboxPublisher = &recordingPublisher{
+	events:   map[string][]outbox.Event{},
+	failures: map[string]int{},
+}
+
+// recordingPublisher records published events by account and fails
+// publishing events of an account as many times as requested.
+type recordingPublisher struct {
+	mu       sync.Mutex
+	events   map[string][]outbox.Event
+	failures map[string]int
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event outbox.Event) error {
+	var change api.EntryChangeDto
+	if err := json.Unmarshal(event.Payload, &change); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures[change.AccountUuid] > 0 {
+		p.failures[change.AccountUuid]--
+		return fmt.Errorf("sink unavailable")
+	}
+	p.events[change.AccountUuid] = append(p.events[change.AccountUuid], event)
+	return nil
+}
+
+func (p *recordingPublisher) failNext(accountUuid string, count int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures[accountUuid] = count
+}
+
+func (p *recordingPublisher) published(accountUuid string) []outbox.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]outbox.Event{}, p.events[accountUuid]...)
+}
+
+func TestOutboxPublishesEntryChanges(t *testing.T) {
+	accountUuid := uuid.NewString()
+	entry := mustCreateRandomEntryOfAccount(t, accountUuid)
+	resp := mustPut(t, fmt.Sprintf("/sleep_diary/entries/%d", entry.Id), api.UpdateSleepDiaryEntryDto{
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+	resp.Body.Close()
+
+	events := mustAwaitPublishedEvents(t, accountUuid, 2)
+	assert.Equal(t, api.CHANGE_CREATED, events[0].Type)
+	assert.Equal(t, api.CHANGE_UPDATED, events[1].Type)
+	assert.Less(t, events[0].Id, events[1].Id)
+	for _, event := range events {
+		assert.Equal(t, entry.Id, event.AggregateId)
+		change := mustDecodeJson[api.EntryChangeDto](t, event.Payload)
+		assert.Equal(t, entry.Id, change.EntryId)
+		assert.Equal(t, event.Type, change.Type)
+	}
+}
+
+func TestOutboxRetriesFailedPublication(t *testing.T) {
+	accountUuid := uuid.NewString()
+	outboxPublisher.failNext(accountUuid, 3)
+
+	entry := mustCreateRandomEntryOfAccount(t, accountUuid)
+
+	events := mustAwaitPublishedEvents(t, accountUuid, 1)
+	assert.Equal(t, entry.Id, events[0].AggregateId)
+	assert.Equal(t, api.CHANGE_CREATED, events[0].Type)
+}
+
+func mustAwaitPublishedEvents(t *testing.T, accountUuid string, count int) []outbox.Event {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if events := outboxPublisher.published(accountUuid); len(events) >= count {
+			return events
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d published events of account %s, got %d", count, accountUuid, len(outboxPublisher.published(accountUuid)))
+	return nil
+}
+
+func mustDecodeJson[T any](t *testing.T, data []byte) T {
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Decoding %s failed: %v", data, err)
+	}
+	return v
+}
@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/pkg/client"
+	"github.com/mabzd/snorlax/pkg/rest"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAccountHeader = "X-Account-Uuid"
+const testScopesHeader = "X-Scopes"
+const testTenantHeader = "X-Tenant-Id"
+
+// gatewaySrv serves the API behind a trusted gateway passing the caller in
+// testAccountHeader, testScopesHeader and testTenantHeader.
+var gatewaySrv *httptest.Server
+
+func newGatewayServer(cfg config.Config) *httptest.Server {
+	cfg.AccountHeader = testAccountHeader
+	cfg.ScopesHeader = testScopesHeader
+	cfg.TenantHeader = testTenantHeader
+	return httptest.NewServer(rest.NewServerHandler(cfg))
+}
+
+func TestOwnershipHidesEntriesOfOtherAccounts(t *testing.T) {
+	ctx := context.Background()
+	ownerUuid := uuid.NewString()
+	owner := client.New(authSrv.URL, client.WithBearerToken(newToken(ownerUuid)))
+	other := client.New(authSrv.URL, client.WithBearerToken(newToken(uuid.NewString())))
+	entry, err := owner.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{
+		AccountUuid:            ownerUuid,
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+	assert.NoError(t, err)
+
+	_, err = other.GetEntryById(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	_, err = other.GetEntryByUuid(ctx, entry.Uuid)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	_, err = other.UpdateEntry(ctx, entry.Id, api.UpdateSleepDiaryEntryDto{SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	err = other.DeleteEntry(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	_, err = other.GetHypnogram(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+
+	read, err := owner.GetEntryById(ctx, entry.Id)
+	assert.NoError(t, err)
+	assertEqualEntryDto(t, entry, read, true)
+}
+
+func TestOwnershipFiltersEntriesOfOtherAccounts(t *testing.T) {
+	ctx := context.Background()
+	ownerUuid := uuid.NewString()
+	otherUuid := uuid.NewString()
+	owner := client.New(authSrv.URL, client.WithBearerToken(newToken(ownerUuid)))
+	other := client.New(authSrv.URL, client.WithBearerToken(newToken(otherUuid)))
+	_, err := owner.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: ownerUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	_, err = other.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: otherUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+
+	filter := api.SleepDiaryFilterDto{AccountUuid: []string{ownerUuid, otherUuid}, PageSize: 10, PageNumber: 1}
+	page, err := other.GetEntriesByFilter(ctx, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.TotalCount)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, otherUuid, page.Items[0].AccountUuid)
+	}
+
+	filter.AccountUuid = []string{ownerUuid}
+	page, err = other.GetEntriesByFilter(ctx, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), page.TotalCount)
+	assert.Empty(t, page.Items)
+}
+
+func TestOwnershipForbidsCreatingEntriesOfOtherAccounts(t *testing.T) {
+	c := client.New(authSrv.URL, client.WithBearerToken(newToken(uuid.NewString())))
+
+	_, err := c.CreateEntry(context.Background(), api.CreateSleepDiaryEntryDto{
+		AccountUuid:            uuid.NewString(),
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+}
+
+func TestOwnershipAdminScopeAccessesAllAccounts(t *testing.T) {
+	ctx := context.Background()
+	ownerUuid := uuid.NewString()
+	owner := client.New(authSrv.URL, client.WithBearerToken(newToken(ownerUuid)))
+	admin := client.New(authSrv.URL, client.WithBearerToken(newToken(uuid.NewString(), api.SCOPE_ADMIN)))
+	entry, err := owner.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: ownerUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+
+	_, err = admin.GetEntryById(ctx, entry.Id)
+	assert.NoError(t, err)
+	page, err := admin.GetEntriesByFilter(ctx, api.SleepDiaryFilterDto{AccountUuid: []string{ownerUuid}, PageSize: 10, PageNumber: 1})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	_, err = admin.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: ownerUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+}
+
+func TestOwnershipWithGatewayHeaders(t *testing.T) {
+	ctx := context.Background()
+	ownerUuid := uuid.NewString()
+	owner := newGatewayClient(ownerUuid)
+	entry, err := owner.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: ownerUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+
+	_, err = owner.GetEntryById(ctx, entry.Id)
+	assert.NoError(t, err)
+	_, err = newGatewayClient(uuid.NewString()).GetEntryById(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	_, err = newGatewayClient(uuid.NewString(), api.SCOPE_ADMIN).GetEntryById(ctx, entry.Id)
+	assert.NoError(t, err)
+
+	_, err = client.New(gatewaySrv.URL).GetEntryById(ctx, entry.Id)
+	assert.Equal(t, api.ERR_UNAUTHORIZED, client.ErrorCode(err))
+}
+
+func newGatewayClient(accountUuid string, scopes ...string) *client.Client {
+	headers := http.Header{}
+	headers.Set(testAccountHeader, accountUuid)
+	headers.Set(testScopesHeader, strings.Join(scopes, " "))
+	return client.New(gatewaySrv.URL, client.WithHttpClient(&http.Client{Transport: headerTransport{headers}}))
+}
+
+// headerTransport adds headers to every request, as a gateway does.
+type headerTransport struct {
+	headers http.Header
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestOwnershipHidesSyncOfOtherAccounts(t *testing.T) {
+	ctx := context.Background()
+	ownerUuid := uuid.NewString()
+	owner := client.New(authSrv.URL, client.WithBearerToken(newToken(ownerUuid)))
+	other := client.New(authSrv.URL, client.WithBearerToken(newToken(uuid.NewString())))
+	entry, err := owner.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: ownerUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+
+	_, err = other.GetSyncChanges(ctx, ownerUuid, "")
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	sync, err := owner.GetSyncChanges(ctx, ownerUuid, "")
+	assert.NoError(t, err)
+	_, err = other.GetSyncChanges(ctx, ownerUuid, sync.NextToken)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+
+	_, err = other.PushSyncChanges(ctx, api.SyncPushDto{
+		AccountUuid: ownerUuid,
+		Changes: []api.SyncChangeDto{
+			{Uuid: uuid.NewString(), Entry: toPtr(newRandomEntryData())},
+			{Uuid: entry.Uuid, Version: &entry.Version, Deleted: true},
+		},
+	})
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+
+	read, err := owner.GetEntryById(ctx, entry.Id)
+	assert.NoError(t, err)
+	assertEqualEntryDto(t, entry, read, true)
+}
+
+func TestOwnershipHidesDraftsOfOtherAccounts(t *testing.T) {
+	ctx := context.Background()
+	ownerUuid := uuid.NewString()
+	owner := client.New(authSrv.URL, client.WithBearerToken(newToken(ownerUuid)))
+	other := client.New(authSrv.URL, client.WithBearerToken(newToken(uuid.NewString())))
+	result, err := owner.Import(ctx, api.AppleHealthImportSource, ownerUuid, "", strings.NewReader(appleHealthExport))
+	assert.NoError(t, err)
+	draftId := result.Drafts[0].Id
+
+	_, err = other.Import(ctx, api.AppleHealthImportSource, ownerUuid, "", strings.NewReader(appleHealthExport))
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	_, err = other.GetDrafts(ctx, ownerUuid)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	_, err = other.ConfirmDraft(ctx, draftId, api.ConfirmSleepDiaryDraftDto{SleepQuality: api.GoodSleepQuality})
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	err = other.DeleteDraft(ctx, draftId)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+
+	drafts, err := owner.GetDrafts(ctx, ownerUuid)
+	assert.NoError(t, err)
+	if assert.Len(t, drafts, 1) {
+		assert.Equal(t, draftId, drafts[0].Id)
+	}
+}
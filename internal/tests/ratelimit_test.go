@@ -0,0 +1,221 @@
+package tests
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/pkg/client"
+	"github.com/mabzd/snorlax/pkg/rpc/sleepdiarypb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testReadsRateLimit = 3
+const testWritesRateLimit = 2
+const testAuthFailuresRateLimit = 2
+
+// rateLimitSrv serves the API with authentication and low rate limits kept
+// in memory; sharedRateLimitSrvs are two replicas keeping them in the
+// database.
+var rateLimitSrv *httptest.Server
+var sharedRateLimitSrvs [2]*httptest.Server
+
+func newRateLimitServer(cfg config.Config, store string) *httptest.Server {
+	cfg.RateLimitReads = testReadsRateLimit
+	cfg.RateLimitWrites = testWritesRateLimit
+	cfg.RateLimitStore = store
+	return newAuthServer(cfg)
+}
+
+func TestRateLimitRejectsRequestsOverLimit(t *testing.T) {
+	accountUuid := uuid.NewString()
+	token := newToken(accountUuid)
+	path := "/sleep_diary/entries?account_uuid=" + accountUuid
+
+	for i := range testReadsRateLimit {
+		resp := mustGetWithToken(t, rateLimitSrv.URL+path, token)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, strconv.Itoa(testReadsRateLimit), resp.Header.Get("RateLimit-Limit"))
+		assert.Equal(t, strconv.Itoa(testReadsRateLimit-1-i), resp.Header.Get("RateLimit-Remaining"))
+		assert.Equal(t, strconv.Itoa(testReadsRateLimit)+";w=60", resp.Header.Get("RateLimit-Policy"))
+	}
+
+	resp := mustGetWithToken(t, rateLimitSrv.URL+path, token)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, api.ERR_RATE_LIMITED, mustDecode[api.ErrorDto](resp.Body).Code)
+	assert.Equal(t, "0", resp.Header.Get("RateLimit-Remaining"))
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.True(t, retryAfter > 0 && retryAfter <= 60/testReadsRateLimit, "Retry-After %d", retryAfter)
+	reset, err := strconv.Atoi(resp.Header.Get("RateLimit-Reset"))
+	assert.NoError(t, err)
+	assert.True(t, reset > 0 && reset <= 60, "RateLimit-Reset %d", reset)
+}
+
+func TestRateLimitPerCallerAndRouteClass(t *testing.T) {
+	ctx := context.Background()
+	accountUuid := uuid.NewString()
+	limited := client.New(rateLimitSrv.URL, client.WithBearerToken(newToken(accountUuid)), client.WithRetries(0, 0))
+	filter := api.SleepDiaryFilterDto{AccountUuid: []string{accountUuid}, PageSize: 10, PageNumber: 1}
+	for range testReadsRateLimit {
+		_, err := limited.GetEntriesByFilter(ctx, filter)
+		assert.NoError(t, err)
+	}
+	_, err := limited.GetEntriesByFilter(ctx, filter)
+	assert.Equal(t, api.ERR_RATE_LIMITED, client.ErrorCode(err))
+
+	// Writes are limited separately.
+	_, err = limited.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: accountUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+
+	otherUuid := uuid.NewString()
+	other := client.New(rateLimitSrv.URL, client.WithBearerToken(newToken(otherUuid)), client.WithRetries(0, 0))
+	_, err = other.GetEntriesByFilter(ctx, api.SleepDiaryFilterDto{AccountUuid: []string{otherUuid}, PageSize: 10, PageNumber: 1})
+	assert.NoError(t, err)
+}
+
+func TestRateLimitSharedAcrossReplicas(t *testing.T) {
+	accountUuid := uuid.NewString()
+	token := newToken(accountUuid)
+	path := "/sleep_diary/entries?account_uuid=" + accountUuid
+
+	for i := range testReadsRateLimit {
+		resp := mustGetWithToken(t, sharedRateLimitSrvs[i%2].URL+path, token)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, strconv.Itoa(testReadsRateLimit-1-i), resp.Header.Get("RateLimit-Remaining"))
+	}
+	for _, replica := range sharedRateLimitSrvs {
+		resp := mustGetWithToken(t, replica.URL+path, token)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	}
+}
+
+func TestRateLimitCountsFailedAuthenticationsPerIp(t *testing.T) {
+	cfg := testCfg
+	cfg.RateLimitAuthFailures = testAuthFailuresRateLimit
+	limitedSrv := newAuthServer(cfg)
+	defer limitedSrv.Close()
+	accountUuid := uuid.NewString()
+	path := "/sleep_diary/entries?account_uuid=" + accountUuid
+
+	// Authenticated requests take no token.
+	for range testAuthFailuresRateLimit + 1 {
+		resp := mustGetWithToken(t, limitedSrv.URL+path, newToken(accountUuid))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	for range testAuthFailuresRateLimit {
+		resp := mustGetWithToken(t, limitedSrv.URL+path, "invalid")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	// The address is rejected before its credentials are checked.
+	for _, token := range []string{"invalid", newToken(accountUuid)} {
+		resp := mustGetWithToken(t, limitedSrv.URL+path, token)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, api.ERR_RATE_LIMITED, mustDecode[api.ErrorDto](resp.Body).Code)
+		assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+		resp.Body.Close()
+	}
+}
+
+func TestGrpcRateLimitRejectsCallsOverLimit(t *testing.T) {
+	cfg := newAuthConfig(testCfg)
+	cfg.RateLimitReads = testReadsRateLimit
+	cfg.RateLimitWrites = testWritesRateLimit
+	cfg.RateLimitStore = "memory"
+	client := sleepdiarypb.NewSleepDiaryServiceClient(newGrpcConn(t, cfg))
+	accountUuid := uuid.NewString()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+newToken(accountUuid))
+
+	for i := range testReadsRateLimit {
+		var header metadata.MD
+		_, err := client.GetEntry(ctx, &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Id{Id: math.MaxInt64}}, grpc.Header(&header))
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		assert.Equal(t, []string{strconv.Itoa(testReadsRateLimit - 1 - i)}, header.Get("ratelimit-remaining"))
+	}
+
+	var header metadata.MD
+	_, err := client.GetEntry(ctx, &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Id{Id: math.MaxInt64}}, grpc.Header(&header))
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	if assert.Len(t, header.Get("retry-after"), 1) {
+		retryAfter, err := strconv.Atoi(header.Get("retry-after")[0])
+		assert.NoError(t, err)
+		assert.True(t, retryAfter > 0 && retryAfter <= 60/testReadsRateLimit, "retry-after %d", retryAfter)
+	}
+	if assert.Len(t, st.Details(), 1) {
+		retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+		if assert.True(t, ok) {
+			assert.Positive(t, retryInfo.RetryDelay.AsDuration())
+		}
+	}
+
+	// Streamed reads count as reads; writes are limited separately.
+	stream, err := client.ListEntries(ctx, &sleepdiarypb.ListEntriesRequest{AccountUuid: []string{accountUuid}})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = client.CreateEntry(ctx, &sleepdiarypb.CreateEntryRequest{
+		AccountUuid: accountUuid,
+		Data:        newRandomGrpcEntryData(time.Date(2025, 5, 1, 22, 30, 0, 0, time.UTC)),
+	})
+	assert.NoError(t, err)
+}
+
+func TestGrpcRateLimitCountsFailedAuthenticationsPerIp(t *testing.T) {
+	cfg := newAuthConfig(testCfg)
+	cfg.RateLimitAuthFailures = testAuthFailuresRateLimit
+	client := sleepdiarypb.NewSleepDiaryServiceClient(newGrpcConn(t, cfg))
+	valid := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+newToken(uuid.NewString()))
+	invalid := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer invalid")
+
+	// Authenticated calls take no token.
+	for range testAuthFailuresRateLimit + 1 {
+		_, err := client.GetEntry(valid, &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Id{Id: math.MaxInt64}})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+
+	for range testAuthFailuresRateLimit {
+		_, err := client.GetEntry(invalid, &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Id{Id: math.MaxInt64}})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	}
+
+	// The address is rejected before its credentials are checked.
+	for _, ctx := range []context.Context{invalid, valid} {
+		var header metadata.MD
+		_, err := client.GetEntry(ctx, &sleepdiarypb.GetEntryRequest{Entry: &sleepdiarypb.GetEntryRequest_Id{Id: math.MaxInt64}}, grpc.Header(&header))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.NotEmpty(t, header.Get("retry-after"))
+	}
+}
+
+func mustGetWithToken(t *testing.T, url string, token string) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	return resp
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetHtmlSleepReport(t *testing.T) {
+	accountUuid := mustCreateReportEntries(t)
+
+	resp := mustGet(t, fmt.Sprintf("/sleep_diary/accounts/%s/report?from_date=2025-04-01T00:00:00Z", accountUuid))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Contains(t, string(body), "Sleep diary report")
+	assert.Contains(t, string(body), "3 of 14")
+	assert.Contains(t, string(body), "<svg")
+}
+
+func TestGetPdfSleepReport(t *testing.T) {
+	accountUuid := mustCreateReportEntries(t)
+
+	resp := mustGet(t, fmt.Sprintf("/sleep_diary/accounts/%s/report?format=pdf&from_date=2025-04-01T00:00:00Z", accountUuid))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+	assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.True(t, bytes.HasPrefix(body, []byte("%PDF-")))
+}
+
+func TestGetSleepReportOfInaccessibleAccount(t *testing.T) {
+	accountUuid := mustCreateReportEntries(t)
+	path := fmt.Sprintf("/sleep_diary/accounts/%s/report?from_date=2025-04-01T00:00:00Z", accountUuid)
+
+	resp := mustGetWithToken(t, authSrv.URL+path, newToken(accountUuid))
+	resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+
+	resp = mustGetWithToken(t, authSrv.URL+path, newToken(uuid.NewString()))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusNotFound, resp)
+	assert.Equal(t, api.ERR_NOT_FOUND, mustDecode[api.ErrorDto](resp.Body).Code)
+}
+
+func TestGetSleepReportUnknownFormat(t *testing.T) {
+	resp := mustGet(t, fmt.Sprintf("/sleep_diary/accounts/%s/report?format=docx", uuid.NewString()))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+}
+
+func mustCreateReportEntries(t *testing.T) string {
+	accountUuid := uuid.NewString()
+	for _, day := range []int{1, 2, 5} {
+		sleepAt := time.Date(2025, 4, day, 23, 0, 0, 0, time.UTC)
+		mustCreateEntry(t, api.CreateSleepDiaryEntryDto{
+			AccountUuid:            accountUuid,
+			SleepDiaryEntryDataDto: newRandomEntryDataForSleepAt(sleepAt),
+		})
+	}
+	// Outside of the two-week period.
+	mustCreateEntry(t, api.CreateSleepDiaryEntryDto{
+		AccountUuid:            accountUuid,
+		SleepDiaryEntryDataDto: newRandomEntryDataForSleepAt(time.Date(2025, 4, 20, 23, 0, 0, 0, time.UTC)),
+	})
+	return accountUuid
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+const testResearchExportSecret = "test-research-export-secret"
+
+// Minimum number of participants of research datasets of tests.
+const TEST_RESEARCH_MIN_COHORT_SIZE = 3
+
+func TestExportResearchDataset(t *testing.T) {
+	ctx := context.Background()
+	// A tenant of its own, so that datasets hold entries of this test only.
+	tenant := uuid.NewString()
+	accountUuids := []string{}
+	entries := map[api.SleepQuality]api.SleepDiaryEntryDataDto{}
+	for i := range TEST_RESEARCH_MIN_COHORT_SIZE {
+		accountUuid := uuid.NewString()
+		data := newRandomEntryData()
+		data.SleepQuality = api.SleepQuality(i + 1)
+		_, err := newTenantClient(tenant, accountUuid).CreateEntry(ctx, api.CreateSleepDiaryEntryDto{
+			AccountUuid:            accountUuid,
+			SleepDiaryEntryDataDto: data,
+		})
+		assert.NoError(t, err)
+		accountUuids = append(accountUuids, accountUuid)
+		entries[data.SleepQuality] = data
+	}
+	admin := newTenantClient(tenant, uuid.NewString(), api.SCOPE_ADMIN)
+
+	content, err := admin.ExportResearchDataset(ctx, api.ResearchExportFilterDto{
+		StudyId:  "study-a",
+		Comments: api.RedactResearchCommentsMode,
+	})
+	assert.NoError(t, err)
+	files := mustUnzip(t, content)
+	for _, file := range files {
+		for _, accountUuid := range accountUuids {
+			assert.NotContains(t, string(file), accountUuid)
+		}
+	}
+
+	var dataset api.ResearchDatasetDto
+	assert.NoError(t, json.Unmarshal(files["dataset.json"], &dataset))
+	assert.Equal(t, "study-a", dataset.StudyId)
+	assert.Equal(t, TEST_RESEARCH_MIN_COHORT_SIZE, dataset.ParticipantsCount)
+	assert.Equal(t, api.RedactResearchCommentsMode, dataset.Comments)
+	assert.Len(t, dataset.Entries, TEST_RESEARCH_MIN_COHORT_SIZE)
+	participantIds := map[string]bool{}
+	for _, entry := range dataset.Entries {
+		participantIds[entry.ParticipantId] = true
+		original := entries[entry.SleepQuality]
+		assert.Equal(t, original.Timezone, entry.Timezone)
+		assert.Equal(t, toPtr(api.REDACTED_COMMENTS), entry.Comments)
+		assertShiftedByWeeks(t, original.TriedToSleepAt, entry.TriedToSleepAt, *entry.Timezone)
+		assertShiftedByWeeks(t, original.FinalWakeUpAt, entry.FinalWakeUpAt, *entry.Timezone)
+		assertShiftedByWeeks(t, *original.InBedAt, *entry.InBedAt, *entry.Timezone)
+		assertShiftedByWeeks(t, *original.OutOfBedAt, *entry.OutOfBedAt, *entry.Timezone)
+	}
+	assert.Len(t, participantIds, TEST_RESEARCH_MIN_COHORT_SIZE)
+
+	records := mustReadCsv(t, files["entries.csv"])
+	assert.Len(t, records, TEST_RESEARCH_MIN_COHORT_SIZE+1)
+	assert.Equal(t, "participant_id", records[0][0])
+
+	// Another export of the study has the same participants, other studies
+	// have other ones.
+	sameStudy := mustExportResearchDataset(t, admin, api.ResearchExportFilterDto{StudyId: "study-a", Comments: api.StripResearchCommentsMode})
+	otherStudy := mustExportResearchDataset(t, admin, api.ResearchExportFilterDto{StudyId: "study-b", Comments: api.StripResearchCommentsMode})
+	for i, entry := range sameStudy.Entries {
+		assert.Equal(t, dataset.Entries[i].ParticipantId, entry.ParticipantId)
+		assert.Equal(t, dataset.Entries[i].TriedToSleepAt, entry.TriedToSleepAt)
+		assert.Nil(t, entry.Comments)
+	}
+	for _, entry := range otherStudy.Entries {
+		assert.NotContains(t, participantIds, entry.ParticipantId)
+	}
+
+	action := api.ExportResearchAuditAction
+	page, err := admin.GetAuditLog(ctx, api.AuditLogFilterDto{Action: &action})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), page.TotalCount)
+	if assert.NotEmpty(t, page.Items) {
+		assert.ElementsMatch(t, accountUuids, page.Items[0].AccountUuids)
+	}
+}
+
+func TestExportResearchDatasetEnforcesMinCohortSize(t *testing.T) {
+	ctx := context.Background()
+	tenant := uuid.NewString()
+	accountUuids := []string{}
+	for range TEST_RESEARCH_MIN_COHORT_SIZE {
+		accountUuid := uuid.NewString()
+		_, err := newTenantClient(tenant, accountUuid).CreateEntry(ctx, api.CreateSleepDiaryEntryDto{
+			AccountUuid:            accountUuid,
+			SleepDiaryEntryDataDto: newRandomEntryData(),
+		})
+		assert.NoError(t, err)
+		accountUuids = append(accountUuids, accountUuid)
+	}
+	admin := newTenantClient(tenant, uuid.NewString(), api.SCOPE_ADMIN)
+
+	_, err := admin.ExportResearchDataset(ctx, api.ResearchExportFilterDto{
+		StudyId:     "study-a",
+		AccountUuid: accountUuids[1:],
+		Comments:    api.StripResearchCommentsMode,
+	})
+	assert.Equal(t, api.ERR_INVALID, client.ErrorCode(err))
+
+	toDate := time.Now().Add(-7 * 24 * time.Hour)
+	_, err = admin.ExportResearchDataset(ctx, api.ResearchExportFilterDto{
+		StudyId:  "study-a",
+		ToDate:   &toDate,
+		Comments: api.StripResearchCommentsMode,
+	})
+	assert.Equal(t, api.ERR_INVALID, client.ErrorCode(err))
+
+	dataset := mustExportResearchDataset(t, admin, api.ResearchExportFilterDto{
+		StudyId:     "study-a",
+		AccountUuid: accountUuids,
+		Comments:    api.StripResearchCommentsMode,
+	})
+	assert.Equal(t, TEST_RESEARCH_MIN_COHORT_SIZE, dataset.ParticipantsCount)
+}
+
+func TestExportResearchDatasetRequiresAdmin(t *testing.T) {
+	accountUuid := uuid.NewString()
+	_, err := newGatewayClient(accountUuid).ExportResearchDataset(context.Background(), api.ResearchExportFilterDto{
+		StudyId:     "study-a",
+		AccountUuid: []string{accountUuid},
+		Comments:    api.StripResearchCommentsMode,
+	})
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+}
+
+func TestExportResearchDatasetRejectsInvalidFilter(t *testing.T) {
+	_, err := client.New(srv.URL).ExportResearchDataset(context.Background(), api.ResearchExportFilterDto{
+		StudyId:     strings.Repeat("s", api.MAX_STUDY_ID_LENGTH+1),
+		AccountUuid: []string{"not-a-uuid"},
+		Comments:    api.ResearchCommentsMode("keep"),
+	})
+	assert.Equal(t, api.ERR_INVALID, client.ErrorCode(err))
+}
+
+func mustExportResearchDataset(t *testing.T, c *client.Client, filter api.ResearchExportFilterDto) api.ResearchDatasetDto {
+	content, err := c.ExportResearchDataset(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("Exporting research dataset failed: %v", err)
+	}
+	var dataset api.ResearchDatasetDto
+	assert.NoError(t, json.Unmarshal(mustUnzip(t, content)["dataset.json"], &dataset))
+	return dataset
+}
+
+// assertShiftedByWeeks asserts that a time was shifted by whole weeks within
+// the allowed range while keeping the local time of day in the timezone.
+func assertShiftedByWeeks(t *testing.T, original time.Time, shifted time.Time, timezone string) {
+	tz, err := time.LoadLocation(timezone)
+	assert.NoError(t, err)
+	original, shifted = original.In(tz), shifted.In(tz)
+	assert.Equal(t, original.Weekday(), shifted.Weekday())
+	assert.Equal(t, original.Hour(), shifted.Hour())
+	assert.Equal(t, original.Minute(), shifted.Minute())
+
+	originalDate := time.Date(original.Year(), original.Month(), original.Day(), 0, 0, 0, 0, time.UTC)
+	shiftedDate := time.Date(shifted.Year(), shifted.Month(), shifted.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(shiftedDate.Sub(originalDate).Hours() / 24)
+	assert.NotZero(t, days)
+	assert.Zero(t, days%7)
+	assert.LessOrEqual(t, max(days, -days), 7*26)
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"log"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -19,14 +20,28 @@ import (
 	_ "github.com/lib/pq"
 	"github.com/mabzd/snorlax/api"
 	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auditlog"
 	"github.com/mabzd/snorlax/pkg/dbm"
+	"github.com/mabzd/snorlax/pkg/outbox"
 	"github.com/mabzd/snorlax/pkg/rest"
+	"github.com/mabzd/snorlax/pkg/rpc"
+	"github.com/mabzd/snorlax/pkg/webhook"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 	"gotest.tools/v3/assert"
 )
 
+// Attempts after which the webhook worker of tests dead-letters a delivery.
+const TEST_WEBHOOK_MAX_ATTEMPTS = 3
+
 var srv *httptest.Server
+var grpcConn *grpc.ClientConn
+
+// Config of the servers of tests, for tests querying the database directly.
+var testCfg config.Config
 
 func TestMain(m *testing.M) {
 	log.SetOutput(io.Discard)
@@ -60,19 +75,70 @@ func TestMain(m *testing.M) {
 
 	port, _ := dbContainer.MappedPort(ctx, "5432")
 	cfg := config.Config{
-		ApiPort:            "8080",
-		DbHost:             "localhost",
-		DbPort:             port.Port(),
-		DbUser:             DB_USER,
-		DbPass:             DB_PASS,
-		DbName:             DB_NAME,
-		ServerTimeoutInSec: 5,
+		ApiPort:                    "8080",
+		DbHost:                     "localhost",
+		DbPort:                     port.Port(),
+		DbUser:                     DB_USER,
+		DbPass:                     DB_PASS,
+		DbName:                     DB_NAME,
+		ServerTimeoutInSec:         5,
+		DeletionReceiptSecret:      testDeletionReceiptSecret,
+		EncryptionKey:              testEncryptionKey,
+		ResearchExportSecret:       testResearchExportSecret,
+		ResearchMinCohortSize:      TEST_RESEARCH_MIN_COHORT_SIZE,
+		ImportMaxSizeInMb:          TEST_IMPORT_MAX_SIZE_MB,
+		ImportMaxExtractedSizeInMb: TEST_IMPORT_MAX_SIZE_MB,
 	}
 
+	testCfg = cfg
 	dbm.UpgradeDatabaseIfNeeded(cfg)
 	handler := rest.NewServerHandler(cfg)
 	srv = httptest.NewServer(handler)
 	defer srv.Close()
+	authSrv = newAuthServer(cfg)
+	defer authSrv.Close()
+	gatewaySrv = newGatewayServer(cfg)
+	defer gatewaySrv.Close()
+	apiKeySrv = newApiKeyServer(cfg)
+	defer apiKeySrv.Close()
+	rateLimitSrv = newRateLimitServer(cfg, "memory")
+	defer rateLimitSrv.Close()
+	for i := range sharedRateLimitSrvs {
+		sharedRateLimitSrvs[i] = newRateLimitServer(cfg, "postgres")
+		defer sharedRateLimitSrvs[i].Close()
+	}
+
+	grpcListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatalf("Could not listen: %s", err)
+	}
+	grpcSrv := rpc.NewServer(cfg)
+	go grpcSrv.Serve(grpcListener)
+	defer grpcSrv.Stop()
+	grpcConn, err = grpc.NewClient(grpcListener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Could not connect to gRPC server: %s", err)
+	}
+	defer grpcConn.Close()
+
+	workerCtx, stopWorker := context.WithCancel(ctx)
+	defer stopWorker()
+	go webhook.NewWorker(
+		service.NewSleepDiaryService(cfg),
+		webhook.WithRetries(TEST_WEBHOOK_MAX_ATTEMPTS, 10*time.Millisecond),
+		webhook.WithPollInterval(50*time.Millisecond),
+	).Run(workerCtx)
+	go outbox.NewRelay(
+		service.NewSleepDiaryService(cfg),
+		outboxPublisher,
+		outbox.WithBackoff(10*time.Millisecond, 50*time.Millisecond),
+		outbox.WithPollInterval(50*time.Millisecond),
+	).Run(workerCtx)
+	go auditlog.NewChainer(
+		service.NewSleepDiaryService(cfg),
+		auditlog.WithPollInterval(50*time.Millisecond),
+	).Run(workerCtx)
+
 	code := m.Run()
 	os.Exit(code)
 }
@@ -181,6 +247,13 @@ func mustGetEntryById(t *testing.T, id int64) api.SleepDiaryEntryDto {
 	return mustDecode[api.SleepDiaryEntryDto](resp.Body)
 }
 
+func mustGetEntryByUuid(t *testing.T, entryUuid string) api.SleepDiaryEntryDto {
+	resp := mustGet(t, fmt.Sprintf("/sleep_diary/entries/%s", entryUuid))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+	return mustDecode[api.SleepDiaryEntryDto](resp.Body)
+}
+
 func assertHttpStatusCode(t *testing.T, expectedStatusCode int, resp *http.Response) {
 	if expectedStatusCode != resp.StatusCode {
 		body, _ := io.ReadAll(resp.Body)
@@ -208,14 +281,23 @@ func mustGet(t *testing.T, path string) *http.Response {
 	return resp
 }
 
+func mustDelete(t *testing.T, path string) *http.Response {
+	return mustSend(t, http.MethodDelete, path, "", nil)
+}
+
 func mustSendJson(t *testing.T, method string, path string, payload interface{}) *http.Response {
+	return mustSend(t, method, path, "application/json", mustMashal(payload))
+}
+
+func mustSend(t *testing.T, method string, path string, contentType string, body []byte) *http.Response {
 	url := srv.URL + path
-	json := mustMashal(payload)
-	req, err := http.NewRequest(method, url, bytes.NewReader(json))
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
 	if err != nil {
 		t.Fatalf("Failed to create request: %v", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		t.Fatalf("Failed to make request: %v", err)
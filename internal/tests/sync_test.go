@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncWithoutTokenReturnsAllEntries(t *testing.T) {
+	accountUuid := uuid.NewString()
+	entry1 := mustCreateRandomEntryOfAccount(t, accountUuid)
+	entry2 := mustCreateRandomEntryOfAccount(t, accountUuid)
+
+	sync := mustSync(t, accountUuid, "")
+
+	assert.ElementsMatch(t, []int64{entry1.Id, entry2.Id}, syncedEntryIds(sync))
+	assert.Empty(t, sync.Deleted)
+	assert.NotEmpty(t, sync.NextToken)
+	assert.False(t, sync.HasMore)
+	for _, entry := range sync.Entries {
+		assert.NotEmpty(t, entry.Uuid)
+	}
+}
+
+func TestSyncReturnsChangesSinceToken(t *testing.T) {
+	accountUuid := uuid.NewString()
+	unchanged := mustCreateRandomEntryOfAccount(t, accountUuid)
+	updated := mustCreateRandomEntryOfAccount(t, accountUuid)
+	deleted := mustCreateRandomEntryOfAccount(t, accountUuid)
+	token := mustSync(t, accountUuid, "").NextToken
+
+	created := mustCreateRandomEntryOfAccount(t, accountUuid)
+	for range 2 {
+		resp := mustPut(t, fmt.Sprintf("/sleep_diary/entries/%d", updated.Id), api.UpdateSleepDiaryEntryDto{
+			SleepDiaryEntryDataDto: newRandomEntryData(),
+		})
+		resp.Body.Close()
+	}
+	resp := mustDelete(t, fmt.Sprintf("/sleep_diary/entries/%d", deleted.Id))
+	resp.Body.Close()
+
+	sync := mustSync(t, accountUuid, token)
+
+	assert.ElementsMatch(t, []int64{updated.Id, created.Id}, syncedEntryIds(sync))
+	assert.NotContains(t, syncedEntryIds(sync), unchanged.Id)
+	for _, entry := range sync.Entries {
+		if entry.Id == updated.Id {
+			assert.Equal(t, int64(3), entry.Version)
+		}
+	}
+	if assert.Len(t, sync.Deleted, 1) {
+		assert.Equal(t, deleted.Id, sync.Deleted[0].Id)
+		assert.Equal(t, deleted.Uuid, sync.Deleted[0].Uuid)
+	}
+
+	next := mustSync(t, accountUuid, sync.NextToken)
+	assert.Empty(t, next.Entries)
+	assert.Empty(t, next.Deleted)
+	assert.Equal(t, sync.NextToken, next.NextToken)
+}
+
+func TestSyncCollapsesCreatedAndDeletedEntry(t *testing.T) {
+	accountUuid := uuid.NewString()
+	token := mustSync(t, accountUuid, "").NextToken
+	entry := mustCreateRandomEntryOfAccount(t, accountUuid)
+	resp := mustDelete(t, fmt.Sprintf("/sleep_diary/entries/%d", entry.Id))
+	resp.Body.Close()
+
+	sync := mustSync(t, accountUuid, token)
+
+	assert.Empty(t, sync.Entries)
+	if assert.Len(t, sync.Deleted, 1) {
+		assert.Equal(t, entry.Id, sync.Deleted[0].Id)
+	}
+}
+
+func TestSyncInvalidToken(t *testing.T) {
+	query := url.Values{"account_uuid": {uuid.NewString()}, "since": {"not-a-token"}}
+	resp := mustGet(t, "/sleep_diary/sync?"+query.Encode())
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+}
+
+func TestSyncPushCreatesUpdatesAndDeletes(t *testing.T) {
+	accountUuid := uuid.NewString()
+	token := mustSync(t, accountUuid, "").NextToken
+	createdUuid := uuid.NewString()
+	existing := mustCreateRandomEntryOfAccount(t, accountUuid)
+	deleted := mustCreateRandomEntryOfAccount(t, accountUuid)
+	update := newRandomEntryData()
+
+	result := mustPushSync(t, api.SyncPushDto{
+		AccountUuid: accountUuid,
+		Changes: []api.SyncChangeDto{
+			{Uuid: createdUuid, Entry: toPtr(newRandomEntryData())},
+			{Uuid: existing.Uuid, Version: &existing.Version, Entry: &update},
+			{Uuid: deleted.Uuid, Version: &deleted.Version, Deleted: true},
+		},
+	})
+
+	if assert.Len(t, result.Results, 3) {
+		for _, r := range result.Results {
+			assert.Equal(t, api.AppliedSyncStatus, r.Status)
+		}
+		assert.Equal(t, createdUuid, result.Results[0].Entry.Uuid)
+		assert.Equal(t, int64(1), result.Results[0].Entry.Version)
+		assert.Equal(t, existing.Version+1, result.Results[1].Entry.Version)
+		assert.Equal(t, update.SleepQuality, result.Results[1].Entry.SleepQuality)
+		assert.Nil(t, result.Results[2].Entry)
+	}
+
+	resp := mustGet(t, fmt.Sprintf("/sleep_diary/entries/%d", deleted.Id))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusNotFound, resp)
+
+	sync := mustSync(t, accountUuid, token)
+	assert.Contains(t, syncedEntryIds(sync), result.Results[0].Entry.Id)
+	assert.Contains(t, syncedEntryIds(sync), existing.Id)
+	if assert.Len(t, sync.Deleted, 1) {
+		assert.Equal(t, deleted.Uuid, sync.Deleted[0].Uuid)
+	}
+}
+
+func TestSyncPushReportsConflicts(t *testing.T) {
+	accountUuid := uuid.NewString()
+	entry := mustCreateRandomEntryOfAccount(t, accountUuid)
+	outdated := entry.Version
+	resp := mustPut(t, fmt.Sprintf("/sleep_diary/entries/%d", entry.Id), api.UpdateSleepDiaryEntryDto{
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+	resp.Body.Close()
+
+	result := mustPushSync(t, api.SyncPushDto{
+		AccountUuid: accountUuid,
+		Changes: []api.SyncChangeDto{
+			{Uuid: entry.Uuid, Version: &outdated, Entry: toPtr(newRandomEntryData())},
+			{Uuid: entry.Uuid, Version: &outdated, Deleted: true},
+			{Uuid: entry.Uuid, Entry: toPtr(newRandomEntryData())},
+			{Uuid: uuid.NewString(), Version: &outdated, Entry: toPtr(newRandomEntryData())},
+		},
+	})
+
+	if assert.Len(t, result.Results, 4) {
+		for _, r := range result.Results {
+			assert.Equal(t, api.ConflictSyncStatus, r.Status)
+		}
+		for _, r := range result.Results[:3] {
+			if assert.NotNil(t, r.Entry) {
+				assert.Equal(t, entry.Id, r.Entry.Id)
+				assert.Equal(t, outdated+1, r.Entry.Version)
+			}
+		}
+		assert.Nil(t, result.Results[3].Entry)
+	}
+}
+
+func TestSyncPushDoesNotRevealOtherAccounts(t *testing.T) {
+	entry := mustCreateRandomEntry(t)
+
+	result := mustPushSync(t, api.SyncPushDto{
+		AccountUuid: uuid.NewString(),
+		Changes: []api.SyncChangeDto{
+			{Uuid: entry.Uuid, Version: &entry.Version, Entry: toPtr(newRandomEntryData())},
+			{Uuid: entry.Uuid, Version: &entry.Version, Deleted: true},
+		},
+	})
+
+	assert.Equal(t, api.ConflictSyncStatus, result.Results[0].Status)
+	assert.Nil(t, result.Results[0].Entry)
+	assert.Equal(t, api.AppliedSyncStatus, result.Results[1].Status)
+
+	resp := mustGet(t, fmt.Sprintf("/sleep_diary/entries/%d", entry.Id))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+	assertEqualEntryDto(t, entry, mustDecode[api.SleepDiaryEntryDto](resp.Body), true)
+}
+
+func TestSyncPushValidation(t *testing.T) {
+	invalid := newRandomEntryData()
+	invalid.SleepQuality = 0
+
+	resp := mustPost(t, "/sleep_diary/sync", api.SyncPushDto{
+		AccountUuid: uuid.NewString(),
+		Changes: []api.SyncChangeDto{
+			{Uuid: "not-a-uuid", Entry: toPtr(newRandomEntryData())},
+			{Uuid: uuid.NewString(), Deleted: true},
+			{Uuid: uuid.NewString(), Entry: &invalid},
+		},
+	})
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+
+	dto := mustDecode[api.ErrorDto](resp.Body)
+	fields := []string{}
+	for _, fieldErr := range dto.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	assert.ElementsMatch(t, []string{"/changes/0/uuid", "/changes/1/version", "/changes/2/entry/sleep_quality"}, fields)
+}
+
+func mustSync(t *testing.T, accountUuid string, since string) api.SyncDto {
+	query := url.Values{"account_uuid": {accountUuid}}
+	if since != "" {
+		query.Set("since", since)
+	}
+	resp := mustGet(t, "/sleep_diary/sync?"+query.Encode())
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+	return mustDecode[api.SyncDto](resp.Body)
+}
+
+func mustPushSync(t *testing.T, dto api.SyncPushDto) api.SyncPushResultDto {
+	resp := mustPost(t, "/sleep_diary/sync", dto)
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+	return mustDecode[api.SyncPushResultDto](resp.Body)
+}
+
+func syncedEntryIds(sync api.SyncDto) []int64 {
+	ids := make([]int64, len(sync.Entries))
+	for i, entry := range sync.Entries {
+		ids[i] = entry.Id
+	}
+	return ids
+}
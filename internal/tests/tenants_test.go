@@ -0,0 +1,260 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/database"
+	"github.com/mabzd/snorlax/pkg/auth"
+	"github.com/mabzd/snorlax/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantsIsolateEntriesOfSameAccount(t *testing.T) {
+	ctx := context.Background()
+	accountUuid := uuid.NewString()
+	tenantA := newTenantClient(uuid.NewString(), accountUuid)
+	tenantB := newTenantClient(uuid.NewString(), accountUuid)
+	entry, err := tenantA.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: accountUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+
+	_, err = tenantB.GetEntryById(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	_, err = tenantB.GetEntryByUuid(ctx, entry.Uuid)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	_, err = tenantB.UpdateEntry(ctx, entry.Id, api.UpdateSleepDiaryEntryDto{SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	err = tenantB.DeleteEntry(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	_, err = tenantB.GetHypnogram(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+
+	filter := api.SleepDiaryFilterDto{AccountUuid: []string{accountUuid}, PageSize: 10, PageNumber: 1}
+	page, err := tenantB.GetEntriesByFilter(ctx, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), page.TotalCount)
+	assert.Empty(t, page.Items)
+
+	other, err := tenantB.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: accountUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	page, err = tenantA.GetEntriesByFilter(ctx, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.TotalCount)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, entry.Id, page.Items[0].Id)
+	}
+	page, err = tenantB.GetEntriesByFilter(ctx, filter)
+	assert.NoError(t, err)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, other.Id, page.Items[0].Id)
+	}
+
+	read, err := tenantA.GetEntryById(ctx, entry.Id)
+	assert.NoError(t, err)
+	assertEqualEntryDto(t, entry, read, true)
+}
+
+func TestTenantsIsolateAdmins(t *testing.T) {
+	ctx := context.Background()
+	accountUuid := uuid.NewString()
+	owner := newTenantClient(uuid.NewString(), accountUuid)
+	admin := newTenantClient(uuid.NewString(), uuid.NewString(), api.SCOPE_ADMIN)
+	entry, err := owner.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: accountUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+
+	_, err = admin.GetEntryById(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	err = admin.DeleteEntry(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	page, err := admin.GetEntriesByFilter(ctx, api.SleepDiaryFilterDto{AccountUuid: []string{accountUuid}, PageSize: 10, PageNumber: 1})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Items)
+}
+
+func TestTenantsIsolateSyncAndGrants(t *testing.T) {
+	ctx := context.Background()
+	accountUuid := uuid.NewString()
+	granteeUuid := uuid.NewString()
+	tenant := uuid.NewString()
+	owner := newTenantClient(tenant, accountUuid)
+	entry, err := owner.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: accountUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	_, err = owner.CreateGrant(ctx, api.CreateGrantDto{
+		AccountUuid: accountUuid,
+		Grantee:     granteeUuid,
+		Access:      api.ReadGrantAccess,
+		ValidTo:     time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	// The grantee of the same subject in another tenant holds no grant.
+	grantee := newTenantClient(uuid.NewString(), granteeUuid)
+	_, err = grantee.GetEntryById(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	received, err := grantee.GetReceivedGrants(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, received)
+
+	synced, err := newTenantClient(uuid.NewString(), accountUuid).GetSyncChanges(ctx, accountUuid, "")
+	assert.NoError(t, err)
+	assert.Empty(t, synced.Entries)
+	synced, err = owner.GetSyncChanges(ctx, accountUuid, "")
+	assert.NoError(t, err)
+	assert.Len(t, synced.Entries, 1)
+}
+
+func TestTenantsFromGatewayHeader(t *testing.T) {
+	ctx := context.Background()
+	accountUuid := uuid.NewString()
+	tenant := uuid.NewString()
+	owner := newGatewayTenantClient(tenant, accountUuid)
+	entry, err := owner.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: accountUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+
+	_, err = owner.GetEntryById(ctx, entry.Id)
+	assert.NoError(t, err)
+	_, err = newGatewayClient(accountUuid).GetEntryById(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	_, err = client.New(srv.URL).GetEntryById(ctx, entry.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+}
+
+func TestTenantsVerifierReadsTenantClaim(t *testing.T) {
+	keys := auth.NewFileKeySet(mustWriteJwks(t, rsaJwk("rsa-1", &authRsaKey.PublicKey)))
+	claims := newTestClaims()
+	claims["org"] = "clinic-1"
+	token := signToken(auth.ALG_RS256, "rsa-1", authRsaKey, claims)
+
+	principal, err := auth.NewVerifier(keys, testJwtIssuer, testJwtAudience, auth.WithTenantClaim("org")).Verify(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, "clinic-1", principal.TenantId)
+
+	principal, err = auth.NewVerifier(keys, testJwtIssuer, testJwtAudience).Verify(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, api.DEFAULT_TENANT, principal.Tenant())
+}
+
+func TestTenantsIsolateWebhooks(t *testing.T) {
+	ctx := context.Background()
+	received := make(chan receivedWebhook, 10)
+	receiver := newWebhookReceiver(t, received, nil)
+	tenantA := uuid.NewString()
+	tenantB := uuid.NewString()
+	adminA := newTenantClient(tenantA, uuid.NewString(), api.SCOPE_ADMIN)
+	adminB := newTenantClient(tenantB, uuid.NewString(), api.SCOPE_ADMIN)
+
+	_, err := newTenantClient(tenantA, uuid.NewString()).CreateWebhook(ctx, api.CreateWebhookDto{
+		Url:        receiver.URL,
+		Secret:     testWebhookSecret,
+		EventTypes: []api.ChangeType{api.CHANGE_CREATED},
+	})
+	assert.Equal(t, api.ERR_FORBIDDEN, client.ErrorCode(err))
+
+	// A webhook of all accounts receives changes of its tenant only.
+	hook, err := adminA.CreateWebhook(ctx, api.CreateWebhookDto{
+		Url:        receiver.URL,
+		Secret:     testWebhookSecret,
+		EventTypes: []api.ChangeType{api.CHANGE_CREATED},
+	})
+	assert.NoError(t, err)
+	t.Cleanup(func() { adminA.DeleteWebhook(ctx, hook.Id) })
+
+	accountB := uuid.NewString()
+	_, err = newTenantClient(tenantB, accountB).CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: accountB, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	accountA := uuid.NewString()
+	entryA, err := newTenantClient(tenantA, accountA).CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: accountA, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	assert.Equal(t, entryA.Id, mustReceiveWebhook(t, received).Change.EntryId)
+
+	webhooks, err := adminB.GetWebhooks(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, webhooks)
+	_, err = adminB.GetWebhookDeliveries(ctx, hook.Id, "")
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+	err = adminB.DeleteWebhook(ctx, hook.Id)
+	assert.Equal(t, api.ERR_NOT_FOUND, client.ErrorCode(err))
+
+	deliveries, err := adminA.GetWebhookDeliveries(ctx, hook.Id, "")
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+}
+
+// Row-level security holds even for queries without any filter, as a broken
+// WHERE clause would issue.
+func TestTenantsRowLevelSecurity(t *testing.T) {
+	ctx := context.Background()
+	accountUuid := uuid.NewString()
+	tenant := uuid.NewString()
+	owner := newTenantClient(tenant, accountUuid)
+	entry, err := owner.CreateEntry(ctx, api.CreateSleepDiaryEntryDto{AccountUuid: accountUuid, SleepDiaryEntryDataDto: newRandomEntryData()})
+	assert.NoError(t, err)
+	_, err = owner.UploadHypnogram(ctx, entry.Id, api.UploadHypnogramDto{Segments: []api.SleepStageSegmentDto{
+		newSegment(api.LightSleepStage, entry.TriedToSleepAt, 60),
+	}})
+	assert.NoError(t, err)
+	admin := newTenantClient(tenant, uuid.NewString(), api.SCOPE_ADMIN)
+	hook, err := admin.CreateWebhook(ctx, api.CreateWebhookDto{
+		Url:          "http://localhost/hook",
+		Secret:       testWebhookSecret,
+		EventTypes:   []api.ChangeType{api.CHANGE_DELETED},
+		AccountUuids: []string{accountUuid},
+	})
+	assert.NoError(t, err)
+	t.Cleanup(func() { admin.DeleteWebhook(ctx, hook.Id) })
+
+	db, err := sql.Open("postgres", database.ConnString(testCfg))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	countAs := func(t *testing.T, tenant string, table string) int {
+		tx, err := db.Begin()
+		assert.NoError(t, err)
+		defer tx.Rollback()
+		_, err = tx.Exec("SELECT set_config('snorlax.tenant_id', $1, true)", tenant)
+		assert.NoError(t, err)
+		_, err = tx.Exec("SET LOCAL ROLE snorlax_tenant")
+		assert.NoError(t, err)
+
+		var count int
+		var others int
+		err = tx.QueryRow(
+			"SELECT count(*), count(*) FILTER (WHERE tenant_id <> $1) FROM "+table,
+			tenant,
+		).Scan(&count, &others)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, others)
+		return count
+	}
+	for _, table := range []string{"sleep_diary_entries", "sleep_stage_segments", "outbox", "webhooks"} {
+		t.Run(table, func(t *testing.T) {
+			assert.Equal(t, 1, countAs(t, tenant, table))
+			assert.Equal(t, 0, countAs(t, uuid.NewString(), table))
+			assert.Equal(t, 0, countAs(t, "", table))
+		})
+	}
+}
+
+// newTenantClient returns a client of authSrv with a token of given tenant
+// and account.
+func newTenantClient(tenant string, accountUuid string, scopes ...string) *client.Client {
+	claims := newTestClaims()
+	claims["sub"] = accountUuid
+	claims["tenant_id"] = tenant
+	if len(scopes) > 0 {
+		claims["scp"] = scopes
+	}
+	return client.New(authSrv.URL, client.WithBearerToken(signToken(auth.ALG_RS256, "rsa-1", authRsaKey, claims)))
+}
+
+func newGatewayTenantClient(tenant string, accountUuid string) *client.Client {
+	headers := http.Header{}
+	headers.Set(testAccountHeader, accountUuid)
+	headers.Set(testTenantHeader, tenant)
+	return client.New(gatewaySrv.URL, client.WithHttpClient(&http.Client{Transport: headerTransport{headers}}))
+}
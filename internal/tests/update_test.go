@@ -45,6 +45,25 @@ func TestUpdateMinimalEntry(t *testing.T) {
 	assertEqualEntryDto(t, retrievedEntry, updatedEntry, false)
 }
 
+func TestUpdateEntryByUuid(t *testing.T) {
+	createdEntry := mustCreateRandomEntry(t)
+
+	updateDto := api.UpdateSleepDiaryEntryDto{
+		Version:                toPtr(createdEntry.Version),
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	}
+	updateResp := mustPut(t, fmt.Sprintf("/sleep_diary/entries/%s", createdEntry.Uuid), updateDto)
+	defer updateResp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, updateResp)
+	updatedEntry := mustDecode[api.SleepDiaryEntryDto](updateResp.Body)
+
+	assert.Equal(t, createdEntry.Id, updatedEntry.Id)
+	assert.Equal(t, createdEntry.Uuid, updatedEntry.Uuid)
+	assert.Equal(t, createdEntry.Version+1, updatedEntry.Version)
+	retrievedEntry := mustGetEntryById(t, createdEntry.Id)
+	assertEqualEntryDto(t, retrievedEntry, updatedEntry, true)
+}
+
 func TestUpdateNonExistingEntry(t *testing.T) {
 	updateDto := api.UpdateSleepDiaryEntryDto{
 		SleepDiaryEntryDataDto: newRandomEntryData(),
@@ -1,12 +1,17 @@
 package tests
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/mabzd/snorlax/api"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestEmptyData(t *testing.T) {
@@ -116,3 +121,124 @@ func runUpdateAndAssertBadRequest(t *testing.T, data api.SleepDiaryEntryDataDto)
 	defer updateResp.Body.Close()
 	assertHttpStatusCode(t, http.StatusBadRequest, updateResp)
 }
+
+func TestValidationErrorsPointAtFields(t *testing.T) {
+	data := newRandomEntryData()
+	data.SleepQuality = api.ExcellentSleepQuality + 1
+	data.Comments = toPtr(strings.Repeat("x", api.MAX_COMMENT_LENGTH+1))
+	createDto := api.CreateSleepDiaryEntryDto{
+		AccountUuid:            uuid.NewString(),
+		SleepDiaryEntryDataDto: data,
+	}
+
+	resp := mustPost(t, "/sleep_diary/entries", createDto)
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+	errorDto := mustDecode[api.ErrorDto](resp.Body)
+
+	assert.Len(t, errorDto.Errors, 2)
+	assert.Len(t, errorDto.Details, 2)
+	assert.Equal(t, "/sleep_quality", errorDto.Errors[0].Field)
+	assert.Equal(t, api.RULE_MAXIMUM, errorDto.Errors[0].Rule)
+	assert.EqualValues(t, api.ExcellentSleepQuality, errorDto.Errors[0].Params["maximum"])
+	assert.Equal(t, errorDto.Details[0], errorDto.Errors[0].Message)
+	assert.Equal(t, "/comments", errorDto.Errors[1].Field)
+	assert.Equal(t, api.RULE_MAX_LENGTH, errorDto.Errors[1].Rule)
+	assert.EqualValues(t, api.MAX_COMMENT_LENGTH, errorDto.Errors[1].Params["maxLength"])
+}
+
+func TestValidationErrorsPointAtNestedFields(t *testing.T) {
+	entry := mustCreateRandomEntry(t)
+	start := entry.TriedToSleepAt
+	hypnogram := api.UploadHypnogramDto{Segments: []api.SleepStageSegmentDto{
+		{Stage: api.LightSleepStage, StartAt: start, EndAt: start.Add(time.Hour)},
+		{Stage: "nap", StartAt: start.Add(time.Hour), EndAt: start.Add(2 * time.Hour)},
+	}}
+
+	resp := mustPut(t, fmt.Sprintf("/sleep_diary/entries/%d/hypnogram", entry.Id), hypnogram)
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+	errorDto := mustDecode[api.ErrorDto](resp.Body)
+
+	assert.Len(t, errorDto.Errors, 1)
+	assert.Equal(t, "/segments/1/stage", errorDto.Errors[0].Field)
+	assert.Equal(t, api.RULE_ENUM, errorDto.Errors[0].Rule)
+}
+
+func TestInvalidJsonTypePointsAtField(t *testing.T) {
+	body := []byte(`{"account_uuid": "` + uuid.NewString() + `", "sleep_quality": "good"}`)
+
+	resp := mustSend(t, http.MethodPost, "/sleep_diary/entries", "application/json", body)
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+	errorDto := mustDecode[api.ErrorDto](resp.Body)
+
+	assert.Equal(t, "invalid JSON format", errorDto.Message)
+	assert.Len(t, errorDto.Errors, 1)
+	assert.Equal(t, "/sleep_quality", errorDto.Errors[0].Field)
+	assert.Equal(t, api.RULE_TYPE, errorDto.Errors[0].Rule)
+}
+
+func TestUnknownFieldsIgnoredByDefault(t *testing.T) {
+	body := newCreateBodyWithUnknownField(t)
+
+	resp := mustSend(t, http.MethodPost, "/sleep_diary/entries", "application/json", body)
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusCreated, resp)
+}
+
+func TestUnknownFieldsRejectedInStrictMode(t *testing.T) {
+	body := newCreateBodyWithUnknownField(t)
+
+	resp := mustSendStrict(t, "/sleep_diary/entries", body)
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+	errorDto := mustDecode[api.ErrorDto](resp.Body)
+
+	assert.Len(t, errorDto.Errors, 1)
+	assert.Equal(t, "/sleep_quaility", errorDto.Errors[0].Field)
+	assert.Equal(t, api.RULE_UNKNOWN_FIELD, errorDto.Errors[0].Rule)
+}
+
+func TestNestedUnknownFieldPointsAtPath(t *testing.T) {
+	body := []byte(`{
+		"account_uuid": "` + uuid.NewString() + `",
+		"changes": [
+			{"uuid": "` + uuid.NewString() + `", "entry": {"sleep_quality": 3}},
+			{"uuid": "` + uuid.NewString() + `", "entry": {"sleep_quaility": 3}}
+		]
+	}`)
+
+	resp := mustSendStrict(t, "/sleep_diary/sync", body)
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+	errorDto := mustDecode[api.ErrorDto](resp.Body)
+
+	assert.Len(t, errorDto.Errors, 1)
+	assert.Equal(t, "/changes/1/entry/sleep_quaility", errorDto.Errors[0].Field)
+	assert.Equal(t, api.RULE_UNKNOWN_FIELD, errorDto.Errors[0].Rule)
+}
+
+func mustSendStrict(t *testing.T, path string, body []byte) *http.Response {
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "handling=strict")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	return resp
+}
+
+func newCreateBodyWithUnknownField(t *testing.T) []byte {
+	createDto := api.CreateSleepDiaryEntryDto{
+		AccountUuid:            uuid.NewString(),
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(mustMashal(createDto), &fields); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	fields["sleep_quaility"] = 3
+	return mustMashal(fields)
+}
@@ -0,0 +1,245 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+const testWebhookSecret = "test-webhook-secret"
+
+type receivedWebhook struct {
+	Event     string
+	Signature error
+	Change    api.EntryChangeDto
+}
+
+func TestWebhookDeliversSignedEvents(t *testing.T) {
+	received := make(chan receivedWebhook, 10)
+	receiver := newWebhookReceiver(t, received, nil)
+	accountUuid := uuid.NewString()
+	mustCreateWebhook(t, receiver.URL, accountUuid, api.CHANGE_CREATED, api.CHANGE_DELETED)
+
+	entry := mustCreateRandomEntryOfAccount(t, accountUuid)
+	created := mustReceiveWebhook(t, received)
+	assert.Equal(t, string(api.CHANGE_CREATED), created.Event)
+	assert.NoError(t, created.Signature)
+	assert.Equal(t, entry.Id, created.Change.EntryId)
+	if assert.NotNil(t, created.Change.Entry) {
+		assert.Equal(t, accountUuid, created.Change.Entry.AccountUuid)
+	}
+
+	// Updates are not subscribed to.
+	resp := mustPut(t, fmt.Sprintf("/sleep_diary/entries/%d", entry.Id), api.UpdateSleepDiaryEntryDto{
+		SleepDiaryEntryDataDto: newRandomEntryData(),
+	})
+	resp.Body.Close()
+	resp = mustDelete(t, fmt.Sprintf("/sleep_diary/entries/%d", entry.Id))
+	resp.Body.Close()
+
+	deleted := mustReceiveWebhook(t, received)
+	assert.Equal(t, string(api.CHANGE_DELETED), deleted.Event)
+	assert.Equal(t, entry.Id, deleted.Change.EntryId)
+	assert.Nil(t, deleted.Change.Entry)
+}
+
+func TestWebhookIgnoresOtherAccounts(t *testing.T) {
+	received := make(chan receivedWebhook, 10)
+	receiver := newWebhookReceiver(t, received, nil)
+	accountUuid := uuid.NewString()
+	mustCreateWebhook(t, receiver.URL, accountUuid, api.CHANGE_CREATED)
+
+	mustCreateRandomEntry(t)
+	entry := mustCreateRandomEntryOfAccount(t, accountUuid)
+
+	assert.Equal(t, entry.Id, mustReceiveWebhook(t, received).Change.EntryId)
+}
+
+func TestWebhookRetriesFailedDelivery(t *testing.T) {
+	received := make(chan receivedWebhook, 10)
+	var calls atomic.Int32
+	receiver := newWebhookReceiver(t, received, func() int {
+		if calls.Add(1) < TEST_WEBHOOK_MAX_ATTEMPTS {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	accountUuid := uuid.NewString()
+	hook := mustCreateWebhook(t, receiver.URL, accountUuid, api.CHANGE_CREATED)
+
+	entry := mustCreateRandomEntryOfAccount(t, accountUuid)
+
+	assert.Equal(t, entry.Id, mustReceiveWebhook(t, received).Change.EntryId)
+	delivery := mustAwaitDeliveryStatus(t, hook.Id, api.DeliveredWebhookDeliveryStatus)
+	assert.Equal(t, TEST_WEBHOOK_MAX_ATTEMPTS, delivery.Attempts)
+	assert.Equal(t, http.StatusOK, *delivery.LastStatusCode)
+	assert.NotNil(t, delivery.DeliveredAt)
+}
+
+func TestWebhookDeadLettersAndRedelivers(t *testing.T) {
+	received := make(chan receivedWebhook, 10)
+	var failing atomic.Bool
+	failing.Store(true)
+	receiver := newWebhookReceiver(t, received, func() int {
+		if failing.Load() {
+			return http.StatusInternalServerError
+		}
+		return http.StatusNoContent
+	})
+	accountUuid := uuid.NewString()
+	hook := mustCreateWebhook(t, receiver.URL, accountUuid, api.CHANGE_CREATED)
+
+	mustCreateRandomEntryOfAccount(t, accountUuid)
+
+	dead := mustAwaitDeliveryStatus(t, hook.Id, api.DeadWebhookDeliveryStatus)
+	assert.Equal(t, TEST_WEBHOOK_MAX_ATTEMPTS, dead.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, *dead.LastStatusCode)
+	assert.NotNil(t, dead.LastError)
+
+	failing.Store(false)
+	resp := mustPost(t, fmt.Sprintf("/webhooks/deliveries/%d/redeliver", dead.Id), nil)
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusOK, resp)
+
+	delivered := mustAwaitDeliveryStatus(t, hook.Id, api.DeliveredWebhookDeliveryStatus)
+	assert.Equal(t, dead.Id, delivered.Id)
+}
+
+func TestRedeliverPendingDeliveryConflict(t *testing.T) {
+	received := make(chan receivedWebhook, 10)
+	receiver := newWebhookReceiver(t, received, nil)
+	accountUuid := uuid.NewString()
+	hook := mustCreateWebhook(t, receiver.URL, accountUuid, api.CHANGE_CREATED)
+	mustCreateRandomEntryOfAccount(t, accountUuid)
+	delivery := mustAwaitDeliveryStatus(t, hook.Id, api.DeliveredWebhookDeliveryStatus)
+
+	resp := mustPost(t, fmt.Sprintf("/webhooks/deliveries/%d/redeliver", delivery.Id), nil)
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusConflict, resp)
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	resp := mustPost(t, "/webhooks", api.CreateWebhookDto{
+		Url:        "ftp://example.com",
+		Secret:     "short",
+		EventTypes: []api.ChangeType{"archived"},
+	})
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+
+	dto := mustDecode[api.ErrorDto](resp.Body)
+	fields := []string{}
+	for _, fieldErr := range dto.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	assert.ElementsMatch(t, []string{"/url", "/secret", "/event_types/0"}, fields)
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	hook := mustCreateWebhook(t, "http://localhost/hook", uuid.NewString(), api.CHANGE_CREATED)
+
+	resp := mustDelete(t, fmt.Sprintf("/webhooks/%d", hook.Id))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusNoContent, resp)
+
+	getResp := mustGet(t, fmt.Sprintf("/webhooks/%d/deliveries", hook.Id))
+	defer getResp.Body.Close()
+	assertHttpStatusCode(t, http.StatusNotFound, getResp)
+}
+
+func TestGetWebhookDeliveriesInvalidStatus(t *testing.T) {
+	hook := mustCreateWebhook(t, "http://localhost/hook", uuid.NewString(), api.CHANGE_CREATED)
+
+	resp := mustGet(t, fmt.Sprintf("/webhooks/%d/deliveries?status=lost", hook.Id))
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusBadRequest, resp)
+}
+
+// newWebhookReceiver starts a receiver verifying signatures and passing
+// received payloads to the channel; status (if not nil) decides the response
+// status of every call.
+func newWebhookReceiver(t *testing.T, received chan<- receivedWebhook, status func() int) *httptest.Server {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if status != nil {
+			if code := status(); code >= 300 {
+				w.WriteHeader(code)
+				return
+			}
+		}
+
+		hook := receivedWebhook{
+			Event: r.Header.Get(webhook.EVENT_HEADER),
+			Signature: webhook.Verify(
+				testWebhookSecret,
+				r.Header.Get(webhook.SIGNATURE_HEADER),
+				r.Header.Get(webhook.TIMESTAMP_HEADER),
+				body,
+				time.Minute),
+		}
+		json.Unmarshal(body, &hook.Change)
+		received <- hook
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func mustCreateWebhook(t *testing.T, url string, accountUuid string, eventTypes ...api.ChangeType) api.WebhookDto {
+	resp := mustPost(t, "/webhooks", api.CreateWebhookDto{
+		Url:          url,
+		Secret:       testWebhookSecret,
+		EventTypes:   eventTypes,
+		AccountUuids: []string{accountUuid},
+	})
+	defer resp.Body.Close()
+	assertHttpStatusCode(t, http.StatusCreated, resp)
+	hook := mustDecode[api.WebhookDto](resp.Body)
+	t.Cleanup(func() {
+		mustDelete(t, fmt.Sprintf("/webhooks/%d", hook.Id)).Body.Close()
+	})
+	return hook
+}
+
+func mustReceiveWebhook(t *testing.T, received <-chan receivedWebhook) receivedWebhook {
+	select {
+	case hook := <-received:
+		return hook
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No webhook received")
+		return receivedWebhook{}
+	}
+}
+
+// mustAwaitDeliveryStatus polls the delivery log of a webhook until its
+// latest delivery reaches the status.
+func mustAwaitDeliveryStatus(t *testing.T, webhookId int64, status api.WebhookDeliveryStatus) api.WebhookDeliveryDto {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		resp := mustGet(t, fmt.Sprintf("/webhooks/%d/deliveries?status=%s", webhookId, status))
+		assertHttpStatusCode(t, http.StatusOK, resp)
+		deliveries := mustDecode[[]api.WebhookDeliveryDto](resp.Body)
+		resp.Body.Close()
+		if len(deliveries) > 0 {
+			return deliveries[0]
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("No %s delivery of webhook %d", status, webhookId)
+			return api.WebhookDeliveryDto{}
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
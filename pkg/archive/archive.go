@@ -0,0 +1,218 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// WriteAccountArchive renders an account export as a zip archive holding the
+// whole export as account.json and a CSV file for each of its lists, with
+// columns named after the JSON fields. Times are RFC 3339; missing values are
+// empty.
+func WriteAccountArchive(w io.Writer, export api.AccountExportDto) error {
+	zw := zip.NewWriter(w)
+
+	file, err := zw.Create("account.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name    string
+		header  []string
+		records [][]string
+	}{
+		{"entries.csv", append([]string{"id", "uuid", "account_uuid", "version"}, entryDataHeader...), entryRecords(export.Entries)},
+		{"sleep_stages.csv", []string{"entry_id", "stage", "start_at", "end_at", "source_device"}, sleepStageRecords(export.SleepStages)},
+		{"drafts.csv", append([]string{"id", "account_uuid", "source"}, entryDataHeader...), draftRecords(export.Drafts)},
+		{"changes.csv", []string{"id", "type", "entry_id", "entry_uuid", "account_uuid", "version", "changed_at"}, changeRecords(export.Changes)},
+		{"grants.csv", grantHeader, grantRecords(export.Grants)},
+		{"received_grants.csv", grantHeader, grantRecords(export.ReceivedGrants)},
+		{"api_keys.csv", []string{"id", "name", "prefix", "scopes", "account_uuid", "expires_at", "created_at", "rotated_at", "revoked_at"}, apiKeyRecords(export.ApiKeys)},
+	}
+	for _, f := range files {
+		file, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(file)
+		cw.Write(f.header)
+		cw.WriteAll(f.records)
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// WriteResearchArchive renders a research dataset as a zip archive holding
+// the whole dataset as dataset.json and its entries as entries.csv, in the
+// format of WriteAccountArchive.
+func WriteResearchArchive(w io.Writer, dataset api.ResearchDatasetDto) error {
+	zw := zip.NewWriter(w)
+
+	file, err := zw.Create("dataset.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(dataset); err != nil {
+		return err
+	}
+
+	file, err = zw.Create("entries.csv")
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(file)
+	cw.Write(append([]string{"participant_id"}, entryDataHeader...))
+	for _, entry := range dataset.Entries {
+		cw.Write(append([]string{entry.ParticipantId}, entryDataRecord(entry.SleepDiaryEntryDataDto)...))
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+var entryDataHeader = []string{
+	"timezone", "in_bed_at", "tried_to_sleep_at", "sleep_delay_in_min", "awakenings_count",
+	"awakenings_total_duration_in_min", "final_wake_up_at", "out_of_bed_at", "sleep_quality", "comments",
+}
+
+var grantHeader = []string{"id", "account_uuid", "grantee", "access", "valid_from", "valid_to", "created_at", "revoked_at"}
+
+func entryRecords(entries []api.SleepDiaryEntryDto) [][]string {
+	records := make([][]string, len(entries))
+	for i, entry := range entries {
+		records[i] = append(
+			[]string{formatInt(entry.Id), entry.Uuid, entry.AccountUuid, formatInt(entry.Version)},
+			entryDataRecord(entry.SleepDiaryEntryDataDto)...)
+	}
+	return records
+}
+
+func draftRecords(drafts []api.SleepDiaryDraftDto) [][]string {
+	records := make([][]string, len(drafts))
+	for i, draft := range drafts {
+		records[i] = append(
+			[]string{formatInt(draft.Id), draft.AccountUuid, string(draft.Source)},
+			entryDataRecord(draft.SleepDiaryEntryDataDto)...)
+	}
+	return records
+}
+
+func entryDataRecord(data api.SleepDiaryEntryDataDto) []string {
+	return []string{
+		formatOptional(data.Timezone, identity),
+		formatOptional(data.InBedAt, formatTime),
+		formatTime(data.TriedToSleepAt),
+		formatOptional(data.SleepDelayInMin, strconv.Itoa),
+		formatOptional(data.AwakeningsCount, strconv.Itoa),
+		formatOptional(data.AwakeningsTotalDurationInMin, strconv.Itoa),
+		formatTime(data.FinalWakeUpAt),
+		formatOptional(data.OutOfBedAt, formatTime),
+		strconv.Itoa(int(data.SleepQuality)),
+		formatOptional(data.Comments, identity),
+	}
+}
+
+func sleepStageRecords(stages []api.EntrySleepStageDto) [][]string {
+	records := make([][]string, len(stages))
+	for i, stage := range stages {
+		records[i] = []string{
+			formatInt(stage.EntryId),
+			string(stage.Stage),
+			formatTime(stage.StartAt),
+			formatTime(stage.EndAt),
+			formatOptional(stage.SourceDevice, identity),
+		}
+	}
+	return records
+}
+
+func changeRecords(changes []api.EntryChangeDto) [][]string {
+	records := make([][]string, len(changes))
+	for i, change := range changes {
+		records[i] = []string{
+			formatInt(change.Id),
+			string(change.Type),
+			formatInt(change.EntryId),
+			change.EntryUuid,
+			change.AccountUuid,
+			formatInt(change.Version),
+			formatTime(change.ChangedAt),
+		}
+	}
+	return records
+}
+
+func grantRecords(grants []api.GrantDto) [][]string {
+	records := make([][]string, len(grants))
+	for i, grant := range grants {
+		records[i] = []string{
+			formatInt(grant.Id),
+			grant.AccountUuid,
+			grant.Grantee,
+			string(grant.Access),
+			formatTime(grant.ValidFrom),
+			formatTime(grant.ValidTo),
+			formatTime(grant.CreatedAt),
+			formatOptional(grant.RevokedAt, formatTime),
+		}
+	}
+	return records
+}
+
+func apiKeyRecords(keys []api.ApiKeyDto) [][]string {
+	records := make([][]string, len(keys))
+	for i, key := range keys {
+		records[i] = []string{
+			formatInt(key.Id),
+			key.Name,
+			key.Prefix,
+			strings.Join(key.Scopes, " "),
+			key.AccountUuid,
+			formatOptional(key.ExpiresAt, formatTime),
+			formatTime(key.CreatedAt),
+			formatOptional(key.RotatedAt, formatTime),
+			formatOptional(key.RevokedAt, formatTime),
+		}
+	}
+	return records
+}
+
+func formatInt(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+func identity(s string) string {
+	return s
+}
+
+func formatOptional[T any](value *T, format func(T) string) string {
+	if value == nil {
+		return ""
+	}
+	return format(*value)
+}
@@ -0,0 +1,55 @@
+// Package auditlog chains events of the audit log, recorded without hashes in
+// the transactions of audited operations, into the hash chains of their
+// tenants. The chainer may run in several processes at once; they take turns.
+package auditlog
+
+import (
+	"context"
+	"time"
+
+	"github.com/mabzd/snorlax/internal/service"
+)
+
+const DEFAULT_POLL_INTERVAL = time.Second
+
+// Number of events chained at once.
+const BATCH_SIZE = 500
+
+type Chainer struct {
+	service      *service.SleepDiaryService
+	pollInterval time.Duration
+}
+
+type Option func(*Chainer)
+
+func WithPollInterval(pollInterval time.Duration) Option {
+	return func(c *Chainer) {
+		c.pollInterval = pollInterval
+	}
+}
+
+func NewChainer(service *service.SleepDiaryService, options ...Option) *Chainer {
+	c := &Chainer{
+		service:      service,
+		pollInterval: DEFAULT_POLL_INTERVAL,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Run chains recorded events until ctx is cancelled.
+func (c *Chainer) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		count, serviceErr := c.service.ChainAuditEvents(BATCH_SIZE)
+		if serviceErr == nil && count == BATCH_SIZE {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.pollInterval):
+		}
+	}
+}
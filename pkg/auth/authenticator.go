@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/config"
+)
+
+// API_KEY_HEADER carries API keys of server-to-server integrations.
+const API_KEY_HEADER = "X-Api-Key"
+
+// HeaderFunc returns the value of a request header, or of gRPC metadata of
+// the same name.
+type HeaderFunc func(name string) string
+
+// ApiKeyFunc returns the principal of an API key.
+type ApiKeyFunc func(key string) (api.Principal, api.Error)
+
+// Error is a failed authentication. Challenge, if set, is the
+// WWW-Authenticate value of HTTP responses.
+type Error struct {
+	Code      api.ErrorCode
+	Message   string
+	Challenge string
+	Err       error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Authenticator tells the principal of a request, whatever its transport:
+// verified from a bearer token when a JWKS is configured, or taken from
+// headers of a trusted gateway, or read from an API key when API keys are
+// enabled.
+type Authenticator struct {
+	verifier      *Verifier
+	accountHeader string
+	scopesHeader  string
+	tenantHeader  string
+	apiKeys       ApiKeyFunc
+}
+
+// NewAuthenticatorFromConfig returns the authenticator configured in cfg, or
+// nil when none is configured and callers are trusted. apiKeys is used only
+// when API keys are enabled.
+func NewAuthenticatorFromConfig(cfg config.Config, apiKeys ApiKeyFunc) (*Authenticator, error) {
+	verifier, err := NewVerifierFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	a := &Authenticator{verifier: verifier}
+	if verifier == nil && cfg.AccountHeader != "" {
+		a.accountHeader = cfg.AccountHeader
+		a.scopesHeader = cfg.ScopesHeader
+		a.tenantHeader = cfg.TenantHeader
+	}
+	if cfg.ApiKeys {
+		a.apiKeys = apiKeys
+	}
+	if a.verifier == nil && a.accountHeader == "" && a.apiKeys == nil {
+		return nil, nil
+	}
+	return a, nil
+}
+
+// Authenticate returns the principal of a request. An API key must have
+// given scope or the admin scope; requests without a key fall back to a
+// bearer token or gateway headers.
+func (a *Authenticator) Authenticate(ctx context.Context, header HeaderFunc, scope string) (api.Principal, *Error) {
+	if a.apiKeys != nil {
+		if key := header(API_KEY_HEADER); key != "" {
+			return a.authenticateApiKey(key, scope)
+		}
+	}
+	switch {
+	case a.verifier != nil:
+		return a.authenticateBearer(ctx, header("Authorization"))
+	case a.accountHeader != "":
+		return a.authenticateGateway(header)
+	default:
+		return api.Principal{}, &Error{Code: api.ERR_UNAUTHORIZED, Message: API_KEY_HEADER + " header required"}
+	}
+}
+
+func (a *Authenticator) authenticateApiKey(key string, scope string) (api.Principal, *Error) {
+	principal, serviceErr := a.apiKeys(key)
+	if serviceErr != nil {
+		dto := serviceErr.ToErrorDto()
+		return api.Principal{}, &Error{Code: dto.Code, Message: dto.Message}
+	}
+	if !principal.HasScope(scope) && !principal.HasScope(api.SCOPE_ADMIN) {
+		return api.Principal{}, &Error{Code: api.ERR_FORBIDDEN, Message: "API key lacks scope " + scope}
+	}
+	return principal, nil
+}
+
+func (a *Authenticator) authenticateBearer(ctx context.Context, authorization string) (api.Principal, *Error) {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		return api.Principal{}, &Error{Code: api.ERR_UNAUTHORIZED, Message: "bearer token required", Challenge: "Bearer"}
+	}
+	principal, err := a.verifier.Verify(ctx, token)
+	if err != nil {
+		return api.Principal{}, &Error{Code: api.ERR_UNAUTHORIZED, Message: "invalid bearer token", Challenge: `Bearer error="invalid_token"`, Err: err}
+	}
+	return principal, nil
+}
+
+// authenticateGateway requires the account header set by a gateway that
+// authenticated the caller; the gateway must replace these headers when
+// clients send them.
+func (a *Authenticator) authenticateGateway(header HeaderFunc) (api.Principal, *Error) {
+	accountUuid := header(a.accountHeader)
+	if accountUuid == "" {
+		return api.Principal{}, &Error{Code: api.ERR_UNAUTHORIZED, Message: a.accountHeader + " header required"}
+	}
+	principal := api.Principal{Subject: accountUuid, AccountUuid: accountUuid}
+	if a.scopesHeader != "" {
+		principal.Scopes = strings.Fields(header(a.scopesHeader))
+	}
+	if a.tenantHeader != "" {
+		principal.TenantId = header(a.tenantHeader)
+	}
+	return principal, nil
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+type principalKey struct{}
+
+type traceIdKey struct{}
+
+// NewContext returns a context carrying the authenticated principal.
+func NewContext(ctx context.Context, principal api.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal of an authenticated request.
+func FromContext(ctx context.Context) (api.Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(api.Principal)
+	return principal, ok
+}
+
+// WithTraceId returns a context carrying the trace ID of a request.
+func WithTraceId(ctx context.Context, traceId string) context.Context {
+	return context.WithValue(ctx, traceIdKey{}, traceId)
+}
+
+// Caller returns the principal of an authenticated request, or
+// api.SystemPrincipal when the server runs without authentication, along
+// with the trace ID of the request.
+func Caller(ctx context.Context) api.Principal {
+	principal, ok := FromContext(ctx)
+	if !ok {
+		principal = api.SystemPrincipal
+	}
+	principal.TraceId, _ = ctx.Value(traceIdKey{}).(string)
+	return principal
+}
@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DEFAULT_CACHE_TTL = 5 * time.Minute
+const DEFAULT_FETCH_TIMEOUT = 10 * time.Second
+
+// Minimum delay between fetches, so that tokens with forged key IDs cannot
+// make every request fetch the key set.
+const DEFAULT_MIN_REFRESH_INTERVAL = 30 * time.Second
+
+// Minimum size of accepted RSA keys.
+const minRsaKeyBits = 2048
+
+// Maximum size of a fetched key set.
+const maxKeySetSize = 1 << 20
+
+// KeySet is a JSON Web Key Set read from a URL or a file. Keys are cached for
+// a TTL and fetched again earlier when a token is signed with an unknown key,
+// so that keys rotated by the issuer are picked up without a restart. When a
+// fetch fails, previously fetched keys remain in use.
+//
+// One fetch runs at a time, outside of any lock. Requests with a known key
+// are served from the cache meanwhile, even when it expired; only requests
+// with an unknown key wait for the fetch.
+type KeySet struct {
+	fetch              func(ctx context.Context) ([]byte, error)
+	httpClient         *http.Client
+	ttl                time.Duration
+	minRefreshInterval time.Duration
+
+	// Fetched keys, replaced as a whole.
+	keys atomic.Pointer[cachedKeys]
+
+	mu          sync.Mutex
+	attemptedAt time.Time
+	// Closed when the running fetch completes; nil when none runs.
+	refreshing chan struct{}
+}
+
+type cachedKeys struct {
+	keys      map[string]jwk
+	fetchedAt time.Time
+}
+
+type KeySetOption func(*KeySet)
+
+// WithCaching sets how long fetched keys are used and how often the set may
+// be fetched at most.
+func WithCaching(ttl time.Duration, minRefreshInterval time.Duration) KeySetOption {
+	return func(s *KeySet) {
+		s.ttl = ttl
+		s.minRefreshInterval = minRefreshInterval
+	}
+}
+
+// WithHttpClient sets the HTTP client fetching a key set from a URL.
+func WithHttpClient(httpClient *http.Client) KeySetOption {
+	return func(s *KeySet) {
+		s.httpClient = httpClient
+	}
+}
+
+// NewUrlKeySet creates a key set fetched from url, usually the jwks_uri of
+// the token issuer.
+func NewUrlKeySet(url string, options ...KeySetOption) *KeySet {
+	s := newKeySet(options)
+	s.fetch = func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s responded with %d", url, resp.StatusCode)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, maxKeySetSize))
+	}
+	return s
+}
+
+// NewFileKeySet creates a key set read from a local file. The file is read
+// again after the TTL, so keys can be rotated by replacing it.
+func NewFileKeySet(path string, options ...KeySetOption) *KeySet {
+	s := newKeySet(options)
+	s.fetch = func(_ context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}
+	return s
+}
+
+func newKeySet(options []KeySetOption) *KeySet {
+	s := &KeySet{
+		httpClient:         &http.Client{Timeout: DEFAULT_FETCH_TIMEOUT},
+		ttl:                DEFAULT_CACHE_TTL,
+		minRefreshInterval: DEFAULT_MIN_REFRESH_INTERVAL,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// jwk is a parsed public key of the set.
+type jwk struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// key returns the key with given ID, fetching the set when the cached one
+// expired or does not contain the key.
+func (s *KeySet) key(ctx context.Context, kid string) (jwk, error) {
+	cached := s.keys.Load()
+	if cached != nil {
+		if key, ok := cached.keys[kid]; ok {
+			if time.Since(cached.fetchedAt) > s.ttl {
+				s.startRefresh()
+			}
+			return key, nil
+		}
+	}
+
+	// Unknown keys wait for a fetch, unless the set was fetched too recently.
+	if done := s.startRefresh(); done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return jwk{}, ctx.Err()
+		}
+		cached = s.keys.Load()
+	}
+
+	if cached == nil {
+		return jwk{}, fmt.Errorf("key set unavailable")
+	}
+	key, ok := cached.keys[kid]
+	if !ok {
+		return jwk{}, fmt.Errorf("unknown key '%s'", kid)
+	}
+	return key, nil
+}
+
+// startRefresh starts fetching the set in the background unless a fetch runs
+// already or the last one was attempted too recently. It returns a channel
+// closed when the running fetch completes, or nil when none runs.
+func (s *KeySet) startRefresh() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refreshing == nil && time.Since(s.attemptedAt) > s.minRefreshInterval {
+		s.attemptedAt = time.Now()
+		s.refreshing = make(chan struct{})
+		go s.refresh(s.attemptedAt, s.refreshing)
+	}
+	return s.refreshing
+}
+
+// refresh fetches the set and closes done. The fetch is not bound to the
+// request that started it, which other requests may be waiting along with;
+// it is bounded by the timeout of the HTTP client instead.
+func (s *KeySet) refresh(attemptedAt time.Time, done chan struct{}) {
+	defer func() {
+		s.mu.Lock()
+		s.refreshing = nil
+		s.mu.Unlock()
+		close(done)
+	}()
+
+	data, err := s.fetch(context.Background())
+	if err == nil {
+		var keys map[string]jwk
+		keys, err = parseKeySet(data)
+		if err == nil {
+			s.keys.Store(&cachedKeys{keys: keys, fetchedAt: attemptedAt})
+			return
+		}
+	}
+	cachedCount := 0
+	if cached := s.keys.Load(); cached != nil {
+		cachedCount = len(cached.keys)
+	}
+	log.Printf("Fetching JWKS failed, keeping %d cached keys: %v\n", cachedCount, err)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseKeySet returns the signing keys of a set by key ID. Keys of other
+// types or uses are skipped, so the set may hold keys not used for tokens;
+// invalid keys are skipped too, so that they do not disable the valid ones.
+func parseKeySet(data []byte) (map[string]jwk, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid key set: %w", err)
+	}
+
+	keys := map[string]jwk{}
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		var parsed jwk
+		var err error
+		switch key.Kty {
+		case "RSA":
+			parsed, err = parseRsaKey(key)
+		case "EC":
+			parsed, err = parseEcKey(key)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("Skipping JWKS key '%s': %v\n", key.Kid, err)
+			continue
+		}
+		keys[key.Kid] = parsed
+	}
+	return keys, nil
+}
+
+func parseRsaKey(key jsonWebKey) (jwk, error) {
+	if key.Alg != "" && key.Alg != ALG_RS256 {
+		return jwk{}, fmt.Errorf("unsupported algorithm '%s'", key.Alg)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return jwk{}, fmt.Errorf("invalid modulus")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return jwk{}, fmt.Errorf("invalid exponent")
+	}
+	publicKey := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}
+	if publicKey.N.BitLen() < minRsaKeyBits {
+		return jwk{}, fmt.Errorf("key shorter than %d bits", minRsaKeyBits)
+	}
+	return jwk{alg: ALG_RS256, key: publicKey}, nil
+}
+
+func parseEcKey(key jsonWebKey) (jwk, error) {
+	if key.Alg != "" && key.Alg != ALG_ES256 {
+		return jwk{}, fmt.Errorf("unsupported algorithm '%s'", key.Alg)
+	}
+	if key.Crv != "P-256" {
+		return jwk{}, fmt.Errorf("unsupported curve '%s'", key.Crv)
+	}
+	x, errX := base64.RawURLEncoding.DecodeString(key.X)
+	y, errY := base64.RawURLEncoding.DecodeString(key.Y)
+	if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+		return jwk{}, fmt.Errorf("invalid coordinates")
+	}
+	// Parsing the uncompressed point checks that it lies on the curve.
+	point := append(append([]byte{4}, x...), y...)
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return jwk{}, fmt.Errorf("invalid point: %w", err)
+	}
+	publicKey := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+	return jwk{alg: ALG_ES256, key: publicKey}, nil
+}
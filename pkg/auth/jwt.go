@@ -0,0 +1,254 @@
+// Package auth verifies JWT bearer tokens signed with keys of a JSON Web Key
+// Set. Only asymmetric algorithms are accepted (RS256 and ES256), so the API
+// never holds a secret able to issue tokens.
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/config"
+)
+
+const ALG_RS256 = "RS256"
+const ALG_ES256 = "ES256"
+
+// Clock skew tolerated when checking exp and nbf.
+const DEFAULT_LEEWAY = time.Minute
+
+type Verifier struct {
+	keys         *KeySet
+	issuer       string
+	audience     string
+	leeway       time.Duration
+	accountClaim string
+	tenantClaim  string
+}
+
+type VerifierOption func(*Verifier)
+
+func WithLeeway(leeway time.Duration) VerifierOption {
+	return func(v *Verifier) {
+		v.leeway = leeway
+	}
+}
+
+// WithAccountClaim sets the claim holding the account UUID of the caller,
+// "sub" by default.
+func WithAccountClaim(claim string) VerifierOption {
+	return func(v *Verifier) {
+		v.accountClaim = claim
+	}
+}
+
+// WithTenantClaim sets the claim holding the tenant of the caller,
+// "tenant_id" by default.
+func WithTenantClaim(claim string) VerifierOption {
+	return func(v *Verifier) {
+		v.tenantClaim = claim
+	}
+}
+
+// NewVerifier creates a verifier of tokens signed with keys of given set.
+// Tokens must be issued by issuer for audience; empty values are not checked.
+func NewVerifier(keys *KeySet, issuer string, audience string, options ...VerifierOption) *Verifier {
+	v := &Verifier{
+		keys:         keys,
+		issuer:       issuer,
+		audience:     audience,
+		leeway:       DEFAULT_LEEWAY,
+		accountClaim: "sub",
+		tenantClaim:  "tenant_id",
+	}
+	for _, option := range options {
+		option(v)
+	}
+	return v
+}
+
+// NewVerifierFromConfig returns the verifier configured with JWKS_URL or
+// JWKS_FILE, or nil when neither is set and authentication is disabled.
+func NewVerifierFromConfig(cfg config.Config) (*Verifier, error) {
+	var keys *KeySet
+	switch {
+	case cfg.JwksUrl != "" && cfg.JwksFile != "":
+		return nil, fmt.Errorf("only one of JWKS_URL and JWKS_FILE may be set")
+	case cfg.JwksUrl != "":
+		keys = NewUrlKeySet(cfg.JwksUrl)
+	case cfg.JwksFile != "":
+		keys = NewFileKeySet(cfg.JwksFile)
+	default:
+		return nil, nil
+	}
+	return NewVerifier(
+		keys,
+		cfg.JwtIssuer,
+		cfg.JwtAudience,
+		WithAccountClaim(cfg.JwtAccountClaim),
+		WithTenantClaim(cfg.JwtTenantClaim)), nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+	Scope     string   `json:"scope"`
+	Scp       scopes   `json:"scp"`
+}
+
+// audience is the aud claim, either a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	return unmarshalStringOrArray(data, (*[]string)(a))
+}
+
+// scopes is the scp claim used by some issuers instead of scope, either a
+// space-separated string or an array of strings.
+type scopes []string
+
+func (s *scopes) UnmarshalJSON(data []byte) error {
+	if err := unmarshalStringOrArray(data, (*[]string)(s)); err != nil {
+		return err
+	}
+	if len(*s) == 1 {
+		*s = strings.Fields((*s)[0])
+	}
+	return nil
+}
+
+func unmarshalStringOrArray(data []byte, target *[]string) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*target = []string{single}
+		return nil
+	}
+	return json.Unmarshal(data, target)
+}
+
+// Verify checks the signature and claims of a compact JWT and returns the
+// principal it was issued to.
+func (v *Verifier) Verify(ctx context.Context, token string) (api.Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return api.Principal{}, errors.New("malformed token")
+	}
+
+	var head header
+	if err := decodeSegment(parts[0], &head); err != nil {
+		return api.Principal{}, fmt.Errorf("invalid header: %w", err)
+	}
+	if head.Alg != ALG_RS256 && head.Alg != ALG_ES256 {
+		return api.Principal{}, fmt.Errorf("unsupported algorithm '%s'", head.Alg)
+	}
+	key, err := v.keys.key(ctx, head.Kid)
+	if err != nil {
+		return api.Principal{}, err
+	}
+	// The algorithm of the key decides, so a token cannot pick one its key
+	// was not meant for.
+	if key.alg != head.Alg {
+		return api.Principal{}, fmt.Errorf("algorithm '%s' does not match key '%s'", head.Alg, head.Kid)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return api.Principal{}, errors.New("invalid signature encoding")
+	}
+	if !verifySignature(key, parts[0]+"."+parts[1], signature) {
+		return api.Principal{}, errors.New("invalid signature")
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return api.Principal{}, fmt.Errorf("invalid claims: %w", err)
+	}
+	if err := v.checkClaims(c); err != nil {
+		return api.Principal{}, err
+	}
+
+	principal := api.Principal{Subject: c.Subject, AccountUuid: c.Subject, Scopes: strings.Fields(c.Scope)}
+	if len(principal.Scopes) == 0 {
+		principal.Scopes = c.Scp
+	}
+
+	var all map[string]any
+	if err := decodeSegment(parts[1], &all); err != nil {
+		return api.Principal{}, fmt.Errorf("invalid claims: %w", err)
+	}
+	if v.accountClaim != "sub" {
+		// Tokens without the claim act as no account, accessing only what
+		// their scopes allow.
+		principal.AccountUuid, _ = all[v.accountClaim].(string)
+	}
+	// Tokens without the claim belong to the default tenant.
+	principal.TenantId, _ = all[v.tenantClaim].(string)
+	return principal, nil
+}
+
+func (v *Verifier) checkClaims(c claims) error {
+	now := time.Now()
+	if c.ExpiresAt == nil {
+		return errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(int64(*c.ExpiresAt), 0).Add(v.leeway)) {
+		return errors.New("token expired")
+	}
+	if c.NotBefore != nil && now.Before(time.Unix(int64(*c.NotBefore), 0).Add(-v.leeway)) {
+		return errors.New("token not valid yet")
+	}
+	if v.issuer != "" && c.Issuer != v.issuer {
+		return fmt.Errorf("unexpected issuer '%s'", c.Issuer)
+	}
+	if v.audience != "" && !slices.Contains(c.Audience, v.audience) {
+		return fmt.Errorf("token not issued for audience '%s'", v.audience)
+	}
+	if c.Subject == "" {
+		return errors.New("token has no subject")
+	}
+	return nil
+}
+
+func verifySignature(key jwk, signingInput string, signature []byte) bool {
+	digest := sha256.Sum256([]byte(signingInput))
+	switch publicKey := key.key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS signatures are r and s concatenated, not ASN.1.
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(publicKey, digest[:], r, s)
+	default:
+		return false
+	}
+}
+
+func decodeSegment(segment string, target any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
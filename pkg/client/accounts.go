@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+type ReportFormat string
+
+const (
+	HtmlReportFormat ReportFormat = "html"
+	PdfReportFormat  ReportFormat = "pdf"
+)
+
+// GetSleepCalendar returns entries of an account as an iCalendar document.
+func (c *Client) GetSleepCalendar(ctx context.Context, accountUuid string, fromDate *time.Time, toDate *time.Time) ([]byte, error) {
+	query := url.Values{}
+	setTime(query, "from_date", fromDate)
+	setTime(query, "to_date", toDate)
+	return c.doBytes(ctx, fmt.Sprintf("/sleep_diary/accounts/%s/calendar.ics", url.PathEscape(accountUuid)), query)
+}
+
+// GetSleepReport returns the two-week report of an account starting at
+// fromDate (two weeks ago when nil).
+func (c *Client) GetSleepReport(ctx context.Context, accountUuid string, format ReportFormat, fromDate *time.Time) ([]byte, error) {
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", string(format))
+	}
+	setTime(query, "from_date", fromDate)
+	return c.doBytes(ctx, fmt.Sprintf("/sleep_diary/accounts/%s/report", url.PathEscape(accountUuid)), query)
+}
+
+// ExportAccount returns the zip archive of everything stored about an account.
+func (c *Client) ExportAccount(ctx context.Context, accountUuid string) ([]byte, error) {
+	return c.doBytes(ctx, fmt.Sprintf("/sleep_diary/accounts/%s/export", url.PathEscape(accountUuid)), nil)
+}
+
+// DeleteAccount erases all data of an account in given mode (delete when
+// empty) and returns the receipt of the deletion.
+func (c *Client) DeleteAccount(ctx context.Context, accountUuid string, mode api.AccountDeletionMode) (api.AccountDeletionReceiptDto, error) {
+	query := url.Values{}
+	if mode != "" {
+		query.Set("mode", string(mode))
+	}
+	return doJson[api.AccountDeletionReceiptDto](c, ctx, http.MethodDelete, fmt.Sprintf("/sleep_diary/accounts/%s", url.PathEscape(accountUuid)), query, nil)
+}
+
+// VerifyDeletionReceipt fails with ERR_INVALID unless the receipt was signed
+// by the server.
+func (c *Client) VerifyDeletionReceipt(ctx context.Context, receipt api.AccountDeletionReceiptDto) error {
+	_, err := doJson[struct{}](c, ctx, http.MethodPost, "/sleep_diary/deletion_receipts/verify", nil, receipt)
+	return err
+}
+
+// GetOpenApiSpec returns the OpenAPI document of the API.
+func (c *Client) GetOpenApiSpec(ctx context.Context) (json.RawMessage, error) {
+	return c.doBytes(ctx, "/openapi.json", nil)
+}
@@ -0,0 +1,29 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// CreateApiKey creates an API key; the key is set only in the returned DTO.
+func (c *Client) CreateApiKey(ctx context.Context, dto api.CreateApiKeyDto) (api.ApiKeyDto, error) {
+	return doJson[api.ApiKeyDto](c, ctx, http.MethodPost, "/api_keys", nil, dto)
+}
+
+func (c *Client) GetApiKeys(ctx context.Context) ([]api.ApiKeyDto, error) {
+	return doJson[[]api.ApiKeyDto](c, ctx, http.MethodGet, "/api_keys", nil, nil)
+}
+
+// RotateApiKey replaces the key of an API key; the new key is set only in the
+// returned DTO.
+func (c *Client) RotateApiKey(ctx context.Context, id int64) (api.ApiKeyDto, error) {
+	return doJson[api.ApiKeyDto](c, ctx, http.MethodPost, fmt.Sprintf("/api_keys/%d/rotate", id), nil, nil)
+}
+
+func (c *Client) RevokeApiKey(ctx context.Context, id int64) error {
+	_, err := doJson[struct{}](c, ctx, http.MethodDelete, fmt.Sprintf("/api_keys/%d", id), nil, nil)
+	return err
+}
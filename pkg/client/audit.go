@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// GetAuditLog returns a page of audit events matching the filter, the latest
+// first. Requires the admin scope.
+func (c *Client) GetAuditLog(ctx context.Context, filter api.AuditLogFilterDto) (api.PageDto[api.AuditEventDto], error) {
+	query := url.Values{}
+	setString(query, "subject", filter.Subject)
+	setString(query, "account_uuid", filter.AccountUuid)
+	if filter.EntryId != nil {
+		query.Set("entry_id", strconv.FormatInt(*filter.EntryId, 10))
+	}
+	if filter.Action != nil {
+		query.Set("action", string(*filter.Action))
+	}
+	setString(query, "trace_id", filter.TraceId)
+	setTime(query, "from_date", filter.FromDate)
+	setTime(query, "to_date", filter.ToDate)
+	if filter.PageSize != 0 {
+		query.Set("page_size", strconv.FormatInt(filter.PageSize, 10))
+	}
+	if filter.PageNumber != 0 {
+		query.Set("page_number", strconv.FormatInt(filter.PageNumber, 10))
+	}
+	return doJson[api.PageDto[api.AuditEventDto]](c, ctx, http.MethodGet, "/audit_log", query, nil)
+}
+
+// VerifyAuditLog checks the hash chain of the audit log. Requires the admin
+// scope.
+func (c *Client) VerifyAuditLog(ctx context.Context) (api.AuditLogVerificationDto, error) {
+	return doJson[api.AuditLogVerificationDto](c, ctx, http.MethodGet, "/audit_log/verify", nil, nil)
+}
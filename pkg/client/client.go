@@ -0,0 +1,301 @@
+// Package client is a Go client of the Snorlax sleep diary API. Methods take
+// and return the api DTOs; failed requests return *Error carrying the
+// api.ErrorDto sent by the server.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+)
+
+const TRACE_HEADER = "X-Trace-Id"
+
+const DEFAULT_MAX_RETRIES = 3
+const DEFAULT_RETRY_BACKOFF = 200 * time.Millisecond
+const MAX_RETRY_BACKOFF = 5 * time.Second
+
+type Client struct {
+	baseUrl      string
+	httpClient   *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+	token        string
+	apiKey       string
+}
+
+type Option func(*Client)
+
+// WithHttpClient sets the HTTP client used to send requests (for timeouts,
+// transports etc.). http.DefaultClient is used otherwise.
+func WithHttpClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times a request is retried after a 5xx response,
+// a network error or a 429 response, and the delay before the first retry.
+// The delay doubles with every retry, and is at least the Retry-After of 429
+// responses. Zero maxRetries disables retries.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryBackoff = backoff
+	}
+}
+
+// WithBearerToken sets the token sent in the Authorization header of every
+// request, required when the server has authentication enabled.
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithApiKey sets the API key sent in the X-Api-Key header of every request.
+func WithApiKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// New creates a client of the API hosted at baseUrl (e.g.
+// "http://localhost:8080").
+func New(baseUrl string, options ...Option) *Client {
+	c := &Client{
+		baseUrl:      strings.TrimSuffix(baseUrl, "/"),
+		httpClient:   http.DefaultClient,
+		maxRetries:   DEFAULT_MAX_RETRIES,
+		retryBackoff: DEFAULT_RETRY_BACKOFF,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+type traceIdKey struct{}
+
+// WithTraceId returns a context whose requests are sent with given trace ID.
+// Requests made with a context without trace ID get a new one each.
+func WithTraceId(ctx context.Context, traceId string) context.Context {
+	return context.WithValue(ctx, traceIdKey{}, traceId)
+}
+
+// TraceIdFromContext returns the trace ID set with WithTraceId.
+func TraceIdFromContext(ctx context.Context) (string, bool) {
+	traceId, ok := ctx.Value(traceIdKey{}).(string)
+	return traceId, ok && traceId != ""
+}
+
+// Error is returned when the API responds with an error status.
+type Error struct {
+	StatusCode int
+	TraceId    string
+	api.ErrorDto
+}
+
+func (e *Error) Error() string {
+	message := fmt.Sprintf("%s: %s (HTTP %d, trace %s)", e.Code, e.Message, e.StatusCode, e.TraceId)
+	if len(e.Details) > 0 {
+		message += ": " + strings.Join(e.Details, ", ")
+	}
+	return message
+}
+
+// ErrorCode returns the API error code of err, or empty string if err is not
+// an API error.
+func ErrorCode(err error) api.ErrorCode {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	contentType string
+	body        []byte
+	// Streamed body; such requests are never retried.
+	bodyReader io.Reader
+}
+
+// do sends the request and returns the successful response; the caller
+// closes its body. Idempotent requests are retried on 5xx responses and
+// network errors, POST requests are not since they might have been applied.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	traceId, ok := TraceIdFromContext(ctx)
+	if !ok {
+		traceId = uuid.NewString()
+	}
+
+	target := c.baseUrl + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	retries := c.maxRetries
+	if req.method == http.MethodPost || req.bodyReader != nil {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		body := req.bodyReader
+		if body == nil && req.body != nil {
+			body = bytes.NewReader(req.body)
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set(TRACE_HEADER, traceId)
+		if c.token != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		if c.apiKey != "" {
+			httpReq.Header.Set("X-Api-Key", c.apiKey)
+		}
+		if req.contentType != "" {
+			httpReq.Header.Set("Content-Type", req.contentType)
+		}
+
+		resp, err := c.httpClient.Do(httpReq)
+		delay := c.backoff(attempt)
+		retry := attempt < retries && ctx.Err() == nil &&
+			(err != nil || resp.StatusCode >= http.StatusInternalServerError)
+		// Rate limited requests were not applied, so POST requests are
+		// retried too, unless the server asks to wait too long.
+		if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+			retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"))
+			retry = attempt < c.maxRetries && req.bodyReader == nil && ctx.Err() == nil &&
+				ok && retryAfter <= MAX_RETRY_BACKOFF
+			delay = max(delay, retryAfter)
+		}
+		if !retry {
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode >= http.StatusBadRequest {
+				defer resp.Body.Close()
+				return nil, decodeError(resp, traceId)
+			}
+			return resp, nil
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns the delay before retry of given attempt: exponential with
+// up to 50% of random jitter, capped at MAX_RETRY_BACKOFF.
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := min(c.retryBackoff<<attempt, MAX_RETRY_BACKOFF)
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// parseRetryAfter parses the Retry-After header given in seconds or as a
+// date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func decodeError(resp *http.Response, traceId string) error {
+	apiErr := &Error{StatusCode: resp.StatusCode, TraceId: traceId}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || json.Unmarshal(body, &apiErr.ErrorDto) != nil || apiErr.Code == "" {
+		apiErr.ErrorDto = api.NewError(strings.TrimSpace(fmt.Sprintf("%s %s", resp.Status, body)), api.ERR_UNKNOWN)
+	}
+	return apiErr
+}
+
+// doJson sends the request with payload encoded as JSON (if not nil) and
+// decodes the JSON response into result (if not nil).
+func doJson[T any](c *Client, ctx context.Context, method string, path string, query url.Values, payload any) (T, error) {
+	var result T
+	req := request{method: method, path: path, query: query}
+	if payload != nil {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return result, err
+		}
+		req.body = body
+		req.contentType = "application/json"
+	}
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return result, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, fmt.Errorf("decoding response of %s %s failed: %w", method, path, err)
+	}
+	return result, nil
+}
+
+func (c *Client) doBytes(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func setTime(query url.Values, name string, value *time.Time) {
+	if value != nil {
+		query.Set(name, value.Format(time.RFC3339))
+	}
+}
+
+func setString(query url.Values, name string, value string) {
+	if value != "" {
+		query.Set(name, value)
+	}
+}
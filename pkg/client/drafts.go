@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// Import uploads a wearable export file; timezone may be empty for UTC. The
+// file is streamed, so the request is not retried.
+func (c *Client) Import(ctx context.Context, source api.ImportSource, accountUuid string, timezone string, file io.Reader) (api.ImportResultDto, error) {
+	query := url.Values{"account_uuid": {accountUuid}}
+	if timezone != "" {
+		query.Set("timezone", timezone)
+	}
+
+	resp, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        fmt.Sprintf("/sleep_diary/imports/%s", url.PathEscape(string(source))),
+		query:       query,
+		contentType: "application/octet-stream",
+		bodyReader:  file,
+	})
+	if err != nil {
+		return api.ImportResultDto{}, err
+	}
+	defer resp.Body.Close()
+
+	var result api.ImportResultDto
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, fmt.Errorf("decoding import result failed: %w", err)
+	}
+	return result, nil
+}
+
+func (c *Client) GetDrafts(ctx context.Context, accountUuid string) ([]api.SleepDiaryDraftDto, error) {
+	query := url.Values{"account_uuid": {accountUuid}}
+	return doJson[[]api.SleepDiaryDraftDto](c, ctx, http.MethodGet, "/sleep_diary/drafts", query, nil)
+}
+
+func (c *Client) ConfirmDraft(ctx context.Context, id int64, dto api.ConfirmSleepDiaryDraftDto) (api.SleepDiaryEntryDto, error) {
+	return doJson[api.SleepDiaryEntryDto](c, ctx, http.MethodPost, fmt.Sprintf("/sleep_diary/drafts/%d/confirm", id), nil, dto)
+}
+
+func (c *Client) DeleteDraft(ctx context.Context, id int64) error {
+	_, err := doJson[struct{}](c, ctx, http.MethodDelete, fmt.Sprintf("/sleep_diary/drafts/%d", id), nil, nil)
+	return err
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+func (c *Client) GetEntryById(ctx context.Context, id int64) (api.SleepDiaryEntryDto, error) {
+	return doJson[api.SleepDiaryEntryDto](c, ctx, http.MethodGet, fmt.Sprintf("/sleep_diary/entries/%d", id), nil, nil)
+}
+
+func (c *Client) GetEntryByUuid(ctx context.Context, uuid string) (api.SleepDiaryEntryDto, error) {
+	return doJson[api.SleepDiaryEntryDto](c, ctx, http.MethodGet, "/sleep_diary/entries/"+url.PathEscape(uuid), nil, nil)
+}
+
+// GetEntriesByFilter returns a single page of entries. Zero page size and
+// page number are left for the server to default.
+func (c *Client) GetEntriesByFilter(ctx context.Context, filter api.SleepDiaryFilterDto) (api.PageDto[api.SleepDiaryEntryDto], error) {
+	query := url.Values{}
+	for _, accountUuid := range filter.AccountUuid {
+		query.Add("account_uuid", accountUuid)
+	}
+	setTime(query, "from_date", filter.FromDate)
+	setTime(query, "to_date", filter.ToDate)
+	if filter.PageSize != 0 {
+		query.Set("page_size", strconv.FormatInt(filter.PageSize, 10))
+	}
+	if filter.PageNumber != 0 {
+		query.Set("page_number", strconv.FormatInt(filter.PageNumber, 10))
+	}
+	return doJson[api.PageDto[api.SleepDiaryEntryDto]](c, ctx, http.MethodGet, "/sleep_diary/entries", query, nil)
+}
+
+// AllEntriesByFilter iterates over entries of all pages matching the filter,
+// starting at filter.PageNumber. Pages are fetched lazily; iteration stops
+// after the first error, which is yielded with a zero entry.
+func (c *Client) AllEntriesByFilter(ctx context.Context, filter api.SleepDiaryFilterDto) iter.Seq2[api.SleepDiaryEntryDto, error] {
+	return func(yield func(api.SleepDiaryEntryDto, error) bool) {
+		if filter.PageNumber == 0 {
+			filter.PageNumber = 1
+		}
+		for {
+			page, err := c.GetEntriesByFilter(ctx, filter)
+			if err != nil {
+				yield(api.SleepDiaryEntryDto{}, err)
+				return
+			}
+			for _, entry := range page.Items {
+				if !yield(entry, nil) {
+					return
+				}
+			}
+			if len(page.Items) == 0 || page.PageNumber*page.PageSize >= page.TotalCount {
+				return
+			}
+			filter.PageNumber = page.PageNumber + 1
+		}
+	}
+}
+
+func (c *Client) CreateEntry(ctx context.Context, dto api.CreateSleepDiaryEntryDto) (api.SleepDiaryEntryDto, error) {
+	return doJson[api.SleepDiaryEntryDto](c, ctx, http.MethodPost, "/sleep_diary/entries", nil, dto)
+}
+
+func (c *Client) UpdateEntry(ctx context.Context, id int64, dto api.UpdateSleepDiaryEntryDto) (api.SleepDiaryEntryDto, error) {
+	return doJson[api.SleepDiaryEntryDto](c, ctx, http.MethodPut, fmt.Sprintf("/sleep_diary/entries/%d", id), nil, dto)
+}
+
+func (c *Client) UpdateEntryByUuid(ctx context.Context, uuid string, dto api.UpdateSleepDiaryEntryDto) (api.SleepDiaryEntryDto, error) {
+	return doJson[api.SleepDiaryEntryDto](c, ctx, http.MethodPut, "/sleep_diary/entries/"+url.PathEscape(uuid), nil, dto)
+}
+
+func (c *Client) DeleteEntry(ctx context.Context, id int64) error {
+	_, err := doJson[struct{}](c, ctx, http.MethodDelete, fmt.Sprintf("/sleep_diary/entries/%d", id), nil, nil)
+	return err
+}
+
+func (c *Client) GetHypnogram(ctx context.Context, entryId int64) (api.HypnogramDto, error) {
+	return doJson[api.HypnogramDto](c, ctx, http.MethodGet, fmt.Sprintf("/sleep_diary/entries/%d/hypnogram", entryId), nil, nil)
+}
+
+func (c *Client) UploadHypnogram(ctx context.Context, entryId int64, dto api.UploadHypnogramDto) (api.HypnogramDto, error) {
+	return doJson[api.HypnogramDto](c, ctx, http.MethodPut, fmt.Sprintf("/sleep_diary/entries/%d/hypnogram", entryId), nil, dto)
+}
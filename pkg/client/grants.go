@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+func (c *Client) CreateGrant(ctx context.Context, dto api.CreateGrantDto) (api.GrantDto, error) {
+	return doJson[api.GrantDto](c, ctx, http.MethodPost, "/sleep_diary/grants", nil, dto)
+}
+
+// GetGrants returns all grants given by an account.
+func (c *Client) GetGrants(ctx context.Context, accountUuid string) ([]api.GrantDto, error) {
+	query := url.Values{}
+	query.Set("account_uuid", accountUuid)
+	return doJson[[]api.GrantDto](c, ctx, http.MethodGet, "/sleep_diary/grants", query, nil)
+}
+
+// GetReceivedGrants returns grants in force given to the caller.
+func (c *Client) GetReceivedGrants(ctx context.Context) ([]api.GrantDto, error) {
+	return doJson[[]api.GrantDto](c, ctx, http.MethodGet, "/sleep_diary/grants/received", nil, nil)
+}
+
+func (c *Client) RevokeGrant(ctx context.Context, id int64) error {
+	_, err := doJson[struct{}](c, ctx, http.MethodDelete, fmt.Sprintf("/sleep_diary/grants/%d", id), nil, nil)
+	return err
+}
@@ -0,0 +1,22 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// ExportResearchDataset returns the zip archive of a de-identified dataset
+// selected by the filter. Requires the admin scope.
+func (c *Client) ExportResearchDataset(ctx context.Context, filter api.ResearchExportFilterDto) ([]byte, error) {
+	query := url.Values{}
+	query.Set("study_id", filter.StudyId)
+	for _, accountUuid := range filter.AccountUuid {
+		query.Add("account_uuid", accountUuid)
+	}
+	setTime(query, "from_date", filter.FromDate)
+	setTime(query, "to_date", filter.ToDate)
+	setString(query, "comments_mode", string(filter.Comments))
+	return c.doBytes(ctx, "/research_export", query)
+}
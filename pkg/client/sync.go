@@ -0,0 +1,23 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// GetSyncChanges returns entries of an account changed since the sync token;
+// an empty token returns all entries.
+func (c *Client) GetSyncChanges(ctx context.Context, accountUuid string, since string) (api.SyncDto, error) {
+	query := url.Values{"account_uuid": {accountUuid}}
+	if since != "" {
+		query.Set("since", since)
+	}
+	return doJson[api.SyncDto](c, ctx, http.MethodGet, "/sleep_diary/sync", query, nil)
+}
+
+func (c *Client) PushSyncChanges(ctx context.Context, dto api.SyncPushDto) (api.SyncPushResultDto, error) {
+	return doJson[api.SyncPushResultDto](c, ctx, http.MethodPost, "/sleep_diary/sync", nil, dto)
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+func (c *Client) CreateWebhook(ctx context.Context, dto api.CreateWebhookDto) (api.WebhookDto, error) {
+	return doJson[api.WebhookDto](c, ctx, http.MethodPost, "/webhooks", nil, dto)
+}
+
+func (c *Client) GetWebhooks(ctx context.Context) ([]api.WebhookDto, error) {
+	return doJson[[]api.WebhookDto](c, ctx, http.MethodGet, "/webhooks", nil, nil)
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id int64) error {
+	_, err := doJson[struct{}](c, ctx, http.MethodDelete, fmt.Sprintf("/webhooks/%d", id), nil, nil)
+	return err
+}
+
+// GetWebhookDeliveries returns the most recent deliveries of a webhook; an
+// empty status returns deliveries in any status.
+func (c *Client) GetWebhookDeliveries(ctx context.Context, webhookId int64, status api.WebhookDeliveryStatus) ([]api.WebhookDeliveryDto, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", string(status))
+	}
+	return doJson[[]api.WebhookDeliveryDto](c, ctx, http.MethodGet, fmt.Sprintf("/webhooks/%d/deliveries", webhookId), query, nil)
+}
+
+func (c *Client) RedeliverWebhookDelivery(ctx context.Context, id int64) (api.WebhookDeliveryDto, error) {
+	return doJson[api.WebhookDeliveryDto](c, ctx, http.MethodPost, fmt.Sprintf("/webhooks/deliveries/%d/redeliver", id), nil, nil)
+}
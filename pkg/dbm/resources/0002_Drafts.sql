@@ -0,0 +1,19 @@
+CREATE TABLE sleep_diary_drafts (
+    id BIGSERIAL PRIMARY KEY,
+    account_uuid UUID NOT NULL,
+    source TEXT NOT NULL,
+    timezone TEXT NOT NULL,
+    in_bed_at TIMESTAMPTZ NULL,
+    tried_to_sleep_at TIMESTAMPTZ NOT NULL,
+    sleep_delay_in_min INTEGER NULL,
+    awakenings_count INTEGER NULL,
+    awakenings_total_duration_in_min INTEGER NULL,
+    final_wake_up_at TIMESTAMPTZ NOT NULL,
+    out_of_bed_at TIMESTAMPTZ NULL,
+    sleep_quality INTEGER NULL,
+    comments VARCHAR(2048) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_sleep_diary_drafts_account_uuid
+ON sleep_diary_drafts (account_uuid);
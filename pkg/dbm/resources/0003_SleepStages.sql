@@ -0,0 +1,11 @@
+CREATE TABLE sleep_stage_segments (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES sleep_diary_entries (id) ON DELETE CASCADE,
+    stage TEXT NOT NULL,
+    start_at TIMESTAMPTZ NOT NULL,
+    end_at TIMESTAMPTZ NOT NULL,
+    source_device TEXT NULL
+);
+
+CREATE INDEX idx_sleep_stage_segments_entry_id
+ON sleep_stage_segments (entry_id, start_at);
@@ -0,0 +1,11 @@
+CREATE TABLE sleep_diary_entry_changes (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL,
+    account_uuid UUID NOT NULL,
+    change_type TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_sleep_diary_entry_changes_account_uuid
+ON sleep_diary_entry_changes (account_uuid, id);
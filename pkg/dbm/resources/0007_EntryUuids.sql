@@ -0,0 +1,13 @@
+ALTER TABLE sleep_diary_entries
+ADD COLUMN uuid UUID NOT NULL DEFAULT gen_random_uuid();
+
+CREATE UNIQUE INDEX idx_sleep_diary_entries_uuid
+ON sleep_diary_entries (uuid);
+
+ALTER TABLE sleep_diary_entry_changes
+ADD COLUMN entry_uuid UUID NULL;
+
+UPDATE sleep_diary_entry_changes c
+SET entry_uuid = e.uuid
+FROM sleep_diary_entries e
+WHERE e.id = c.entry_id;
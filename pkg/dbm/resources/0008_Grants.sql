@@ -0,0 +1,16 @@
+CREATE TABLE account_grants (
+    id BIGSERIAL PRIMARY KEY,
+    account_uuid UUID NOT NULL,
+    grantee TEXT NOT NULL,
+    access TEXT NOT NULL,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_to TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX idx_account_grants_grantee
+ON account_grants (grantee, account_uuid) WHERE revoked_at IS NULL;
+
+CREATE INDEX idx_account_grants_account_uuid
+ON account_grants (account_uuid, id);
@@ -0,0 +1,79 @@
+-- Tenant transactions run as this role, so that row-level security applies
+-- to them even when the service connects as the table owner or a superuser.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'snorlax_tenant') THEN
+        CREATE ROLE snorlax_tenant NOLOGIN;
+    END IF;
+END
+$$;
+
+GRANT snorlax_tenant TO CURRENT_USER;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO snorlax_tenant;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO snorlax_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO snorlax_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO snorlax_tenant;
+
+-- Existing rows belong to the default tenant; new rows to the tenant of the
+-- transaction, failing when none is set.
+ALTER TABLE sleep_diary_entries
+ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE sleep_diary_entries
+ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('snorlax.tenant_id', true), '');
+
+ALTER TABLE sleep_diary_entry_changes
+ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE sleep_diary_entry_changes
+ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('snorlax.tenant_id', true), '');
+
+ALTER TABLE sleep_diary_drafts
+ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE sleep_diary_drafts
+ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('snorlax.tenant_id', true), '');
+
+ALTER TABLE account_grants
+ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE account_grants
+ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('snorlax.tenant_id', true), '');
+
+CREATE INDEX idx_sleep_diary_entries_tenant_id_account_uuid
+ON sleep_diary_entries (tenant_id, account_uuid);
+
+-- Rows are visible only to transactions of their tenant; without a tenant
+-- set no rows are visible at all.
+ALTER TABLE sleep_diary_entries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE sleep_diary_entries FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON sleep_diary_entries
+USING (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''))
+WITH CHECK (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''));
+
+ALTER TABLE sleep_diary_entry_changes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE sleep_diary_entry_changes FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON sleep_diary_entry_changes
+USING (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''))
+WITH CHECK (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''));
+
+ALTER TABLE sleep_diary_drafts ENABLE ROW LEVEL SECURITY;
+ALTER TABLE sleep_diary_drafts FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON sleep_diary_drafts
+USING (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''))
+WITH CHECK (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''));
+
+ALTER TABLE account_grants ENABLE ROW LEVEL SECURITY;
+ALTER TABLE account_grants FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON account_grants
+USING (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''))
+WITH CHECK (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''));
+
+-- API keys are looked up before the tenant is known, so they are filtered by
+-- the service instead.
+ALTER TABLE api_keys
+ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
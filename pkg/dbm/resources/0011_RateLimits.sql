@@ -0,0 +1,10 @@
+-- Token buckets of the shared rate limit store; rows of buckets idle long
+-- enough to be full again are deleted.
+CREATE TABLE rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_rate_limit_buckets_updated_at
+ON rate_limit_buckets (updated_at);
@@ -0,0 +1,37 @@
+-- Data keys of tenants encrypting comments, each wrapped (encrypted) by the
+-- master key with given fingerprint. A tenant has at most one current key;
+-- retired keys are kept until nothing is encrypted with them.
+CREATE TABLE data_keys (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id TEXT NOT NULL DEFAULT NULLIF(current_setting('snorlax.tenant_id', true), ''),
+    master_key_id TEXT NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    retired_at TIMESTAMPTZ NULL
+);
+
+CREATE UNIQUE INDEX idx_data_keys_tenant_id_current
+ON data_keys (tenant_id)
+WHERE retired_at IS NULL;
+
+ALTER TABLE data_keys ENABLE ROW LEVEL SECURITY;
+ALTER TABLE data_keys FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON data_keys
+USING (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''))
+WITH CHECK (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''));
+
+-- Comments encrypted with a data key hold base64 of the nonce followed by
+-- the ciphertext, which is longer than the comment itself; comments without
+-- a key are plain text written before encryption was enabled.
+ALTER TABLE sleep_diary_entries
+ALTER COLUMN comments TYPE TEXT;
+
+ALTER TABLE sleep_diary_entries
+ADD COLUMN comments_key_id BIGINT NULL REFERENCES data_keys(id);
+
+ALTER TABLE sleep_diary_drafts
+ALTER COLUMN comments TYPE TEXT;
+
+ALTER TABLE sleep_diary_drafts
+ADD COLUMN comments_key_id BIGINT NULL REFERENCES data_keys(id);
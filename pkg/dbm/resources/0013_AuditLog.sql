@@ -0,0 +1,45 @@
+-- Audit log of access to data of accounts. Events of a tenant form a hash
+-- chain: hash covers the content of the event along with prev_hash, the hash
+-- of the previous event of the tenant, which is NULL for the first one.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id TEXT NOT NULL DEFAULT NULLIF(current_setting('snorlax.tenant_id', true), ''),
+    action TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    account_uuids UUID[] NOT NULL,
+    entry_ids BIGINT[] NOT NULL,
+    trace_id TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    prev_hash BYTEA NULL,
+    hash BYTEA NOT NULL
+);
+
+CREATE INDEX idx_audit_log_tenant_id_created_at
+ON audit_log (tenant_id, created_at);
+
+CREATE INDEX idx_audit_log_account_uuids
+ON audit_log USING GIN (account_uuids);
+
+CREATE INDEX idx_audit_log_entry_ids
+ON audit_log USING GIN (entry_ids);
+
+ALTER TABLE audit_log ENABLE ROW LEVEL SECURITY;
+ALTER TABLE audit_log FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON audit_log
+USING (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''))
+WITH CHECK (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''));
+
+-- The log is append-only: tenant transactions may not change events, and
+-- changes made otherwise, even by the table owner, are rejected as well.
+REVOKE UPDATE, DELETE, TRUNCATE ON audit_log FROM snorlax_tenant;
+
+CREATE FUNCTION reject_audit_log_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_log_change();
@@ -0,0 +1,43 @@
+-- Webhooks and their deliveries belong to a tenant like the changes they
+-- carry. Existing webhooks belong to the default tenant; existing deliveries
+-- to the tenant of their change, read with row-level security of changes
+-- lifted for the owner while this migration runs.
+ALTER TABLE webhooks
+ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE webhooks
+ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('snorlax.tenant_id', true), '');
+
+ALTER TABLE webhook_deliveries
+ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE sleep_diary_entry_changes NO FORCE ROW LEVEL SECURITY;
+
+UPDATE webhook_deliveries d
+SET tenant_id = c.tenant_id
+FROM sleep_diary_entry_changes c
+WHERE c.id = d.change_id;
+
+ALTER TABLE sleep_diary_entry_changes FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE webhook_deliveries
+ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('snorlax.tenant_id', true), '');
+
+CREATE INDEX idx_webhooks_tenant_id
+ON webhooks (tenant_id);
+
+-- Tenant transactions see only webhooks and deliveries of their tenant, so
+-- changes are delivered only to webhooks of their tenant. Security is not
+-- forced: the webhook worker claims deliveries of all tenants as the table
+-- owner.
+ALTER TABLE webhooks ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON webhooks
+USING (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''))
+WITH CHECK (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''));
+
+ALTER TABLE webhook_deliveries ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON webhook_deliveries
+USING (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''))
+WITH CHECK (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''));
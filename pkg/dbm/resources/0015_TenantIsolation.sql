@@ -0,0 +1,53 @@
+-- Sleep stages, outbox events and API keys belong to a tenant as well.
+-- Existing sleep stages belong to the tenant of their entry and existing
+-- outbox events to the tenant of their change, read with row-level security
+-- lifted for the owner while this migration runs.
+ALTER TABLE sleep_stage_segments
+ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE outbox
+ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE sleep_diary_entries NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE sleep_diary_entry_changes NO FORCE ROW LEVEL SECURITY;
+
+UPDATE sleep_stage_segments s
+SET tenant_id = e.tenant_id
+FROM sleep_diary_entries e
+WHERE e.id = s.entry_id;
+
+UPDATE outbox o
+SET tenant_id = c.tenant_id
+FROM sleep_diary_entry_changes c
+WHERE c.entry_id = o.aggregate_id;
+
+ALTER TABLE sleep_diary_entries FORCE ROW LEVEL SECURITY;
+ALTER TABLE sleep_diary_entry_changes FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE sleep_stage_segments
+ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('snorlax.tenant_id', true), '');
+
+ALTER TABLE outbox
+ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('snorlax.tenant_id', true), '');
+
+ALTER TABLE sleep_stage_segments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE sleep_stage_segments FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON sleep_stage_segments
+USING (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''))
+WITH CHECK (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''));
+
+-- Security of these tables is not forced: the outbox relay claims events of
+-- all tenants and API keys are looked up before the tenant is known, both as
+-- the table owner. Tenant transactions see rows of their tenant only.
+ALTER TABLE outbox ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON outbox
+USING (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''))
+WITH CHECK (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''));
+
+ALTER TABLE api_keys ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON api_keys
+USING (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''))
+WITH CHECK (tenant_id = NULLIF(current_setting('snorlax.tenant_id', true), ''));
@@ -0,0 +1,51 @@
+-- Events are chained by a background chainer rather than by the transaction
+-- recording them, so that audited operations of a tenant do not wait for one
+-- another. Events are inserted with NULL hashes and chain_seq; the chainer
+-- sets them once, in the order it chains events, which is the order of the
+-- chain. Existing events were chained in the order of their IDs.
+--
+-- Security is no longer forced: the chainer processes events of all tenants
+-- as the table owner. Tenant transactions see events of their tenant only.
+ALTER TABLE audit_log NO FORCE ROW LEVEL SECURITY;
+
+DROP TRIGGER audit_log_append_only ON audit_log;
+
+ALTER TABLE audit_log
+ADD COLUMN chain_seq BIGINT NULL;
+
+UPDATE audit_log a
+SET chain_seq = s.seq
+FROM (SELECT id, row_number() OVER (PARTITION BY tenant_id ORDER BY id) AS seq FROM audit_log) s
+WHERE s.id = a.id;
+
+ALTER TABLE audit_log
+ALTER COLUMN hash DROP NOT NULL;
+
+CREATE UNIQUE INDEX idx_audit_log_tenant_id_chain_seq
+ON audit_log (tenant_id, chain_seq);
+
+CREATE INDEX idx_audit_log_unchained
+ON audit_log (id) WHERE chain_seq IS NULL;
+
+-- Events may still not be deleted, and updated only to be chained once.
+CREATE TRIGGER audit_log_append_only
+BEFORE DELETE OR TRUNCATE ON audit_log
+FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_log_change();
+
+CREATE FUNCTION reject_audit_log_update() RETURNS trigger AS $$
+BEGIN
+    IF OLD.chain_seq IS NULL
+        AND NEW.chain_seq IS NOT NULL
+        AND (NEW.id, NEW.tenant_id, NEW.action, NEW.subject, NEW.account_uuids, NEW.entry_ids, NEW.trace_id, NEW.created_at)
+            IS NOT DISTINCT FROM
+            (OLD.id, OLD.tenant_id, OLD.action, OLD.subject, OLD.account_uuids, OLD.entry_ids, OLD.trace_id, OLD.created_at)
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_log is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_chain_only
+BEFORE UPDATE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION reject_audit_log_update();
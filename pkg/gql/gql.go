@@ -0,0 +1,65 @@
+// Package gql serves a GraphQL view of the sleep diary for dashboards that
+// need entries, accounts and derived metrics in a single request. Queries
+// are resolved through SleepDiaryService and bounded by depth and complexity
+// limits checked before execution.
+package gql
+
+import (
+	"context"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+)
+
+type Executor struct {
+	schema  graphql.Schema
+	service *service.SleepDiaryService
+}
+
+// NewExecutor builds the schema; it fails only if the schema definition is
+// inconsistent.
+func NewExecutor(service *service.SleepDiaryService) (*Executor, error) {
+	schema, err := newSchema()
+	if err != nil {
+		return nil, err
+	}
+	return &Executor{schema: schema, service: service}, nil
+}
+
+// Execute runs a query. Every request gets its own loader so batching and
+// caching never span requests.
+func (e *Executor) Execute(ctx context.Context, request api.GraphQLRequestDto) *graphql.Result {
+	if err := checkLimits(e.schema, request.Query, request.OperationName); err != nil {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(graphql.NewLocatedError(err, nil))}}
+	}
+
+	ctx = context.WithValue(ctx, loaderKey{}, newEntriesLoader(e.service, auth.Caller(ctx)))
+	return graphql.Do(graphql.Params{
+		Schema:         e.schema,
+		RequestString:  request.Query,
+		OperationName:  request.OperationName,
+		VariableValues: request.Variables,
+		Context:        ctx,
+	})
+}
+
+// resolverError exposes the api error code (and field errors of validation
+// failures) in the GraphQL error extensions.
+type resolverError struct {
+	api.ErrorDto
+}
+
+func (e resolverError) Extensions() map[string]any {
+	extensions := map[string]any{"code": e.Code}
+	if len(e.Errors) > 0 {
+		extensions["errors"] = e.Errors
+	}
+	return extensions
+}
+
+func toResolverError(err api.Error) error {
+	return resolverError{err.ToErrorDto()}
+}
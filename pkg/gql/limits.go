@@ -0,0 +1,135 @@
+package gql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/mabzd/snorlax/api"
+)
+
+// Maximum nesting of fields in a query.
+const MAX_QUERY_DEPTH = 8
+
+// Maximum complexity of a query. Every field costs 1 and the cost of fields
+// selected below a list is multiplied by LIST_COMPLEXITY_FACTOR, the assumed
+// list length.
+const MAX_QUERY_COMPLEXITY = 10000
+const LIST_COMPLEXITY_FACTOR = 10
+
+// checkLimits rejects queries that are too deep or too complex before they
+// are executed. Syntax errors and unknown operations are left for the
+// executor to report.
+func checkLimits(schema graphql.Schema, query string, operationName string) error {
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(query)})})
+	if err != nil {
+		return nil
+	}
+
+	var operation *ast.OperationDefinition
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		case *ast.FragmentDefinition:
+			fragments[definition.Name.Value] = definition
+		}
+	}
+	if operation == nil || operation.Operation != ast.OperationTypeQuery {
+		return nil
+	}
+
+	measure := limitsMeasure{schema: schema, fragments: fragments, visiting: map[string]bool{}}
+	depth, complexity := measure.selectionSet(schema.QueryType(), operation.SelectionSet)
+	if depth > MAX_QUERY_DEPTH {
+		return limitsError(fmt.Sprintf("query depth %d exceeds maximum of %d", depth, MAX_QUERY_DEPTH))
+	}
+	if complexity > MAX_QUERY_COMPLEXITY {
+		return limitsError(fmt.Sprintf("query complexity %d exceeds maximum of %d", complexity, MAX_QUERY_COMPLEXITY))
+	}
+	return nil
+}
+
+func limitsError(message string) error {
+	return resolverError{api.NewError(message, api.ERR_INVALID)}
+}
+
+type limitsMeasure struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	// Fragments on the current path; a fragment spreading itself is invalid
+	// and is reported by the executor, here it only must not loop forever.
+	visiting map[string]bool
+}
+
+// selectionSet returns the depth and complexity of a selection set on
+// parentType. Fragments add their fields at the level they are spread.
+func (m *limitsMeasure) selectionSet(parentType graphql.Type, selectionSet *ast.SelectionSet) (int, int) {
+	if selectionSet == nil {
+		return 0, 0
+	}
+
+	depth, complexity := 0, 0
+	add := func(d int, c int) {
+		depth = max(depth, d)
+		complexity += c
+	}
+	for _, selection := range selectionSet.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			add(m.field(parentType, selection))
+		case *ast.InlineFragment:
+			fragmentType := parentType
+			if selection.TypeCondition != nil {
+				fragmentType = m.schema.Type(selection.TypeCondition.Name.Value)
+			}
+			add(m.selectionSet(fragmentType, selection.SelectionSet))
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := m.fragments[name]
+			if !ok || m.visiting[name] {
+				continue
+			}
+			m.visiting[name] = true
+			add(m.selectionSet(m.schema.Type(fragment.TypeCondition.Name.Value), fragment.SelectionSet))
+			delete(m.visiting, name)
+		}
+	}
+	return depth, complexity
+}
+
+func (m *limitsMeasure) field(parentType graphql.Type, field *ast.Field) (int, int) {
+	if strings.HasPrefix(field.Name.Value, "__") {
+		return 0, 0
+	}
+
+	var fieldType graphql.Type
+	if object, ok := parentType.(*graphql.Object); ok {
+		if definition, ok := object.Fields()[field.Name.Value]; ok {
+			fieldType = definition.Type
+		}
+	}
+
+	factor := 1
+	for {
+		switch t := fieldType.(type) {
+		case *graphql.NonNull:
+			fieldType = t.OfType
+			continue
+		case *graphql.List:
+			factor *= LIST_COMPLEXITY_FACTOR
+			fieldType = t.OfType
+			continue
+		}
+		break
+	}
+
+	depth, complexity := m.selectionSet(fieldType, field.SelectionSet)
+	return depth + 1, 1 + factor*complexity
+}
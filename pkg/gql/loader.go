@@ -0,0 +1,106 @@
+package gql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+)
+
+type loaderKey struct{}
+
+type dateRange struct {
+	from string
+	to   string
+}
+
+func newDateRange(from *time.Time, to *time.Time) dateRange {
+	format := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return dateRange{from: format(from), to: format(to)}
+}
+
+// entriesLoader batches reads of entries of several accounts. Resolvers
+// queue an account and return a thunk; since the executor resolves thunks
+// breadth first, all accounts of one level of a query are queued before the
+// first thunk runs and are read with a single service call per date range.
+type entriesLoader struct {
+	service *service.SleepDiaryService
+	caller  api.Principal
+	mu      sync.Mutex
+	queued  map[dateRange]map[string]bool
+	loaded  map[dateRange]map[string][]api.SleepDiaryEntryDto
+	failed  map[dateRange]error
+}
+
+func newEntriesLoader(service *service.SleepDiaryService, caller api.Principal) *entriesLoader {
+	return &entriesLoader{
+		service: service,
+		caller:  caller,
+		queued:  map[dateRange]map[string]bool{},
+		loaded:  map[dateRange]map[string][]api.SleepDiaryEntryDto{},
+		failed:  map[dateRange]error{},
+	}
+}
+
+func loaderFrom(ctx context.Context) *entriesLoader {
+	return ctx.Value(loaderKey{}).(*entriesLoader)
+}
+
+// load queues entries of an account in the date range [from, to) and returns
+// a thunk yielding them.
+func (l *entriesLoader) load(accountUuid string, from *time.Time, to *time.Time) func() ([]api.SleepDiaryEntryDto, error) {
+	key := newDateRange(from, to)
+
+	l.mu.Lock()
+	if _, ok := l.loaded[key][accountUuid]; !ok {
+		if l.queued[key] == nil {
+			l.queued[key] = map[string]bool{}
+		}
+		l.queued[key][accountUuid] = true
+	}
+	l.mu.Unlock()
+
+	return func() ([]api.SleepDiaryEntryDto, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if len(l.queued[key]) > 0 {
+			l.flush(key, from, to)
+		}
+		if err := l.failed[key]; err != nil {
+			return nil, err
+		}
+		return l.loaded[key][accountUuid], nil
+	}
+}
+
+func (l *entriesLoader) flush(key dateRange, from *time.Time, to *time.Time) {
+	accountUuids := make([]string, 0, len(l.queued[key]))
+	for accountUuid := range l.queued[key] {
+		accountUuids = append(accountUuids, accountUuid)
+	}
+	delete(l.queued, key)
+
+	entries, serviceErr := l.service.GetAllEntriesByAccounts(l.caller, accountUuids, from, to)
+	if serviceErr != nil {
+		l.failed[key] = toResolverError(serviceErr)
+		return
+	}
+
+	if l.loaded[key] == nil {
+		l.loaded[key] = map[string][]api.SleepDiaryEntryDto{}
+	}
+	for _, accountUuid := range accountUuids {
+		l.loaded[key][accountUuid] = []api.SleepDiaryEntryDto{}
+	}
+	for _, entry := range entries {
+		l.loaded[key][entry.AccountUuid] = append(l.loaded[key][entry.AccountUuid], entry)
+	}
+}
@@ -0,0 +1,292 @@
+package gql
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/report"
+)
+
+// account is the source value of the Account type; its entries and summary
+// are resolved lazily through the entries loader.
+type account struct {
+	uuid string
+}
+
+func newSchema() (graphql.Schema, error) {
+	metricsType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "NightMetrics",
+		Description: "Sleep measures derived from an entry.",
+		Fields: graphql.Fields{
+			"totalSleepTimeInMin": nightField(graphql.Int, func(n report.Night) any { return n.TotalSleepTimeInMin }),
+			"timeInBedInMin":      nightField(graphql.Int, func(n report.Night) any { return n.TimeInBedInMin }),
+			"sleepEfficiency": nightField(graphql.Float, func(n report.Night) any {
+				return n.SleepEfficiency
+			}),
+		},
+	})
+
+	entryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Entry",
+		Fields: graphql.Fields{
+			"id":                           entryField(graphql.NewNonNull(graphql.ID), func(e api.SleepDiaryEntryDto) any { return strconv.FormatInt(e.Id, 10) }),
+			"uuid":                         entryField(graphql.NewNonNull(graphql.ID), func(e api.SleepDiaryEntryDto) any { return e.Uuid }),
+			"accountUuid":                  entryField(graphql.NewNonNull(graphql.ID), func(e api.SleepDiaryEntryDto) any { return e.AccountUuid }),
+			"version":                      entryField(graphql.NewNonNull(graphql.Int), func(e api.SleepDiaryEntryDto) any { return e.Version }),
+			"timezone":                     entryField(graphql.String, func(e api.SleepDiaryEntryDto) any { return deref(e.Timezone) }),
+			"inBedAt":                      entryField(graphql.DateTime, func(e api.SleepDiaryEntryDto) any { return deref(e.InBedAt) }),
+			"triedToSleepAt":               entryField(graphql.NewNonNull(graphql.DateTime), func(e api.SleepDiaryEntryDto) any { return e.TriedToSleepAt }),
+			"sleepDelayInMin":              entryField(graphql.Int, func(e api.SleepDiaryEntryDto) any { return deref(e.SleepDelayInMin) }),
+			"awakeningsCount":              entryField(graphql.Int, func(e api.SleepDiaryEntryDto) any { return deref(e.AwakeningsCount) }),
+			"awakeningsTotalDurationInMin": entryField(graphql.Int, func(e api.SleepDiaryEntryDto) any { return deref(e.AwakeningsTotalDurationInMin) }),
+			"finalWakeUpAt":                entryField(graphql.NewNonNull(graphql.DateTime), func(e api.SleepDiaryEntryDto) any { return e.FinalWakeUpAt }),
+			"outOfBedAt":                   entryField(graphql.DateTime, func(e api.SleepDiaryEntryDto) any { return deref(e.OutOfBedAt) }),
+			"sleepQuality":                 entryField(graphql.NewNonNull(graphql.Int), func(e api.SleepDiaryEntryDto) any { return int(e.SleepQuality) }),
+			"comments":                     entryField(graphql.String, func(e api.SleepDiaryEntryDto) any { return deref(e.Comments) }),
+			"metrics": entryField(graphql.NewNonNull(metricsType), func(e api.SleepDiaryEntryDto) any {
+				return report.NewNight(e)
+			}),
+		},
+	})
+
+	pageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "EntryPage",
+		Fields: graphql.Fields{
+			"totalCount": pageField(graphql.NewNonNull(graphql.Int), func(p api.PageDto[api.SleepDiaryEntryDto]) any { return p.TotalCount }),
+			"pageSize":   pageField(graphql.NewNonNull(graphql.Int), func(p api.PageDto[api.SleepDiaryEntryDto]) any { return p.PageSize }),
+			"pageNumber": pageField(graphql.NewNonNull(graphql.Int), func(p api.PageDto[api.SleepDiaryEntryDto]) any { return p.PageNumber }),
+			"items": pageField(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(entryType))), func(p api.PageDto[api.SleepDiaryEntryDto]) any {
+				return p.Items
+			}),
+		},
+	})
+
+	summaryType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "SleepSummary",
+		Description: "Averages of sleep measures over recorded nights.",
+		Fields: graphql.Fields{
+			"recordedNights":              summaryField(graphql.Int, func(s report.Summary) any { return s.RecordedNights }),
+			"totalNights":                 summaryField(graphql.Int, func(s report.Summary) any { return s.TotalNights }),
+			"avgTotalSleepTimeInMin":      summaryField(graphql.Float, func(s report.Summary) any { return s.AvgTotalSleepTimeInMin }),
+			"avgSleepOnsetLatencyInMin":   summaryField(graphql.Float, func(s report.Summary) any { return s.AvgSleepOnsetLatencyInMin }),
+			"avgWakeAfterSleepOnsetInMin": summaryField(graphql.Float, func(s report.Summary) any { return s.AvgWakeAfterSleepOnsetInMin }),
+			"avgSleepEfficiency":          summaryField(graphql.Float, func(s report.Summary) any { return s.AvgSleepEfficiency }),
+			"avgSleepQuality":             summaryField(graphql.Float, func(s report.Summary) any { return s.AvgSleepQuality }),
+		},
+	})
+
+	dateRangeArgs := graphql.FieldConfigArgument{
+		"fromDate": &graphql.ArgumentConfig{Type: graphql.DateTime},
+		"toDate":   &graphql.ArgumentConfig{Type: graphql.DateTime},
+	}
+
+	accountType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Account",
+		Fields: graphql.Fields{
+			"uuid": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(account).uuid, nil
+				},
+			},
+			"entries": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(entryType))),
+				Description: "Entries in the date range [fromDate, toDate).",
+				Args:        dateRangeArgs,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					from, to := dateRangeArg(p.Args)
+					load := loaderFrom(p.Context).load(p.Source.(account).uuid, from, to)
+					return func() (any, error) {
+						return load()
+					}, nil
+				},
+			},
+			"summary": &graphql.Field{
+				Type:        graphql.NewNonNull(summaryType),
+				Description: "Summary of entries in the date range [fromDate, toDate). Total nights count days of the range when both dates are given.",
+				Args:        dateRangeArgs,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					from, to := dateRangeArg(p.Args)
+					load := loaderFrom(p.Context).load(p.Source.(account).uuid, from, to)
+					return func() (any, error) {
+						entries, err := load()
+						if err != nil {
+							return nil, err
+						}
+						return summarize(entries, from, to), nil
+					}, nil
+				},
+			},
+		},
+	})
+
+	filterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "EntryFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"accountUuid": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.ID)))},
+			"fromDate":    &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+			"toDate":      &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+			"pageSize":    &graphql.InputObjectFieldConfig{Type: graphql.Int, DefaultValue: int(api.DEFAULT_PAGE_SIZE)},
+			"pageNumber":  &graphql.InputObjectFieldConfig{Type: graphql.Int, DefaultValue: 1},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"entry": &graphql.Field{
+				Type: entryType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id, serviceErr := serviceFrom(p).ResolveEntryId(callerFrom(p), p.Args["id"].(string))
+					if serviceErr != nil {
+						return nil, toResolverError(serviceErr)
+					}
+					entry, serviceErr := serviceFrom(p).GetEntryById(callerFrom(p), id)
+					if serviceErr != nil {
+						return nil, toResolverError(serviceErr)
+					}
+					return entry, nil
+				},
+			},
+			"entries": &graphql.Field{
+				Type: graphql.NewNonNull(pageType),
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: graphql.NewNonNull(filterType)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					page, serviceErr := serviceFrom(p).GetEntriesByFilter(callerFrom(p), filterArg(p.Args["filter"].(map[string]any)))
+					if serviceErr != nil {
+						return nil, toResolverError(serviceErr)
+					}
+					return page, nil
+				},
+			},
+			"account": &graphql.Field{
+				Type: graphql.NewNonNull(accountType),
+				Args: graphql.FieldConfigArgument{
+					"uuid": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					uuid := p.Args["uuid"].(string)
+					if err := api.ValidateAccountUuid(uuid); err != nil {
+						return nil, toResolverError(api.NewValidationError("invalid account", []error{err}))
+					}
+					return account{uuid: uuid}, nil
+				},
+			},
+			"accounts": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(accountType))),
+				Description: "Accounts queried together; their entries are read in a single batch.",
+				Args: graphql.FieldConfigArgument{
+					"uuids": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.ID)))},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					accounts := []account{}
+					for _, value := range p.Args["uuids"].([]any) {
+						uuid := value.(string)
+						if err := api.ValidateAccountUuid(uuid); err != nil {
+							return nil, toResolverError(api.NewValidationError("invalid account", []error{err}))
+						}
+						accounts = append(accounts, account{uuid: uuid})
+					}
+					return accounts, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+func serviceFrom(p graphql.ResolveParams) *service.SleepDiaryService {
+	return loaderFrom(p.Context).service
+}
+
+func callerFrom(p graphql.ResolveParams) api.Principal {
+	return loaderFrom(p.Context).caller
+}
+
+func entryField(fieldType graphql.Output, value func(api.SleepDiaryEntryDto) any) *graphql.Field {
+	return sourceField(fieldType, value)
+}
+
+func nightField(fieldType graphql.Output, value func(report.Night) any) *graphql.Field {
+	return sourceField(fieldType, value)
+}
+
+func pageField(fieldType graphql.Output, value func(api.PageDto[api.SleepDiaryEntryDto]) any) *graphql.Field {
+	return sourceField(fieldType, value)
+}
+
+func summaryField(fieldType graphql.Output, value func(report.Summary) any) *graphql.Field {
+	return sourceField(fieldType, value)
+}
+
+func sourceField[T any](fieldType graphql.Output, value func(T) any) *graphql.Field {
+	return &graphql.Field{
+		Type: fieldType,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			return value(p.Source.(T)), nil
+		},
+	}
+}
+
+// deref turns nil pointers into untyped nil so optional fields resolve to
+// null.
+func deref[T any](value *T) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func dateRangeArg(args map[string]any) (*time.Time, *time.Time) {
+	return timeArg(args["fromDate"]), timeArg(args["toDate"])
+}
+
+func timeArg(value any) *time.Time {
+	if t, ok := value.(time.Time); ok {
+		return &t
+	}
+	return nil
+}
+
+// intArg reads an optional Int argument; explicit null reads as zero and is
+// rejected by filter validation.
+func intArg(value any) int64 {
+	if i, ok := value.(int); ok {
+		return int64(i)
+	}
+	return 0
+}
+
+func filterArg(args map[string]any) api.SleepDiaryFilterDto {
+	filter := api.SleepDiaryFilterDto{
+		FromDate:   timeArg(args["fromDate"]),
+		ToDate:     timeArg(args["toDate"]),
+		PageSize:   intArg(args["pageSize"]),
+		PageNumber: intArg(args["pageNumber"]),
+	}
+	for _, value := range args["accountUuid"].([]any) {
+		filter.AccountUuid = append(filter.AccountUuid, value.(string))
+	}
+	return filter
+}
+
+func summarize(entries []api.SleepDiaryEntryDto, from *time.Time, to *time.Time) report.Summary {
+	nights := make([]report.Night, 0, len(entries))
+	for _, entry := range entries {
+		nights = append(nights, report.NewNight(entry))
+	}
+	totalNights := len(nights)
+	if from != nil && to != nil {
+		totalNights = int(to.Sub(*from).Hours() / 24)
+	}
+	return report.Summarize(nights, totalNights)
+}
@@ -0,0 +1,238 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+const localLayout = "20060102T150405"
+const utcLayout = "20060102T150405Z"
+const maxLineLength = 75
+
+// WriteSleepCalendar renders entries as an iCalendar (RFC 5545) document, one
+// VEVENT per entry spanning from tried_to_sleep_at to final_wake_up_at in the
+// entry's timezone. Every timezone used gets a VTIMEZONE block describing its
+// transitions over the period covered by the entries.
+func WriteSleepCalendar(w io.Writer, name string, entries []api.SleepDiaryEntryDto) error {
+	bw := bufio.NewWriter(w)
+	cw := &contentWriter{w: bw}
+
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:-//Snorlax//Sleep Diary//EN")
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	cw.line("X-WR-CALNAME:" + escapeText(name))
+
+	locations, err := collectLocations(entries)
+	if err != nil {
+		return err
+	}
+	from, to := coveredPeriod(entries)
+	for _, name := range sortedKeys(locations) {
+		writeTimezone(cw, locations[name], from, to)
+	}
+
+	stamp := time.Now().UTC().Format(utcLayout)
+	for _, entry := range entries {
+		loc := locationOf(entry, locations)
+		cw.line("BEGIN:VEVENT")
+		cw.line(fmt.Sprintf("UID:sleep-diary-entry-%d@snorlax", entry.Id))
+		cw.line("DTSTAMP:" + stamp)
+		cw.line(formatDateTime("DTSTART", entry.TriedToSleepAt, loc))
+		cw.line(formatDateTime("DTEND", entry.FinalWakeUpAt, loc))
+		cw.line("SUMMARY:" + escapeText(fmt.Sprintf("Sleep (%s)", entry.SleepQuality.String())))
+		cw.line("DESCRIPTION:" + escapeText(describe(entry)))
+		cw.line("TRANSP:TRANSPARENT")
+		cw.line("END:VEVENT")
+	}
+
+	cw.line("END:VCALENDAR")
+	if cw.err != nil {
+		return cw.err
+	}
+	return bw.Flush()
+}
+
+func collectLocations(entries []api.SleepDiaryEntryDto) (map[string]*time.Location, error) {
+	locations := map[string]*time.Location{}
+	for _, entry := range entries {
+		name := "UTC"
+		if entry.Timezone != nil {
+			name = *entry.Timezone
+		}
+		if _, ok := locations[name]; ok {
+			continue
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, err
+		}
+		locations[name] = loc
+	}
+	return locations, nil
+}
+
+func locationOf(entry api.SleepDiaryEntryDto, locations map[string]*time.Location) *time.Location {
+	if entry.Timezone == nil {
+		return time.UTC
+	}
+	return locations[*entry.Timezone]
+}
+
+func coveredPeriod(entries []api.SleepDiaryEntryDto) (time.Time, time.Time) {
+	var from, to time.Time
+	for _, entry := range entries {
+		if from.IsZero() || entry.TriedToSleepAt.Before(from) {
+			from = entry.TriedToSleepAt
+		}
+		if entry.FinalWakeUpAt.After(to) {
+			to = entry.FinalWakeUpAt
+		}
+	}
+	return from, to
+}
+
+// writeTimezone writes VTIMEZONE with the observance in effect at from and
+// every transition until to. UTC needs no definition.
+func writeTimezone(cw *contentWriter, loc *time.Location, from time.Time, to time.Time) {
+	if loc == time.UTC || loc.String() == "UTC" {
+		return
+	}
+
+	cw.line("BEGIN:VTIMEZONE")
+	cw.line("TZID:" + loc.String())
+
+	t := from.In(loc)
+	start, end := t.ZoneBounds()
+	_, prevOffset := t.Zone()
+	if !start.IsZero() {
+		_, prevOffset = start.Add(-time.Second).In(loc).Zone()
+	} else {
+		start = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	writeObservance(cw, start.In(loc), prevOffset)
+
+	for !end.IsZero() && !end.After(to) {
+		_, prevOffset = end.Add(-time.Second).In(loc).Zone()
+		t = end.In(loc)
+		writeObservance(cw, t, prevOffset)
+		_, end = t.ZoneBounds()
+	}
+
+	cw.line("END:VTIMEZONE")
+}
+
+func writeObservance(cw *contentWriter, t time.Time, offsetFrom int) {
+	kind := "STANDARD"
+	if t.IsDST() {
+		kind = "DAYLIGHT"
+	}
+	name, offsetTo := t.Zone()
+
+	cw.line("BEGIN:" + kind)
+	cw.line("DTSTART:" + t.In(time.FixedZone("", offsetFrom)).Format(localLayout))
+	cw.line("TZOFFSETFROM:" + formatOffset(offsetFrom))
+	cw.line("TZOFFSETTO:" + formatOffset(offsetTo))
+	cw.line("TZNAME:" + escapeText(name))
+	cw.line("END:" + kind)
+}
+
+func formatDateTime(property string, t time.Time, loc *time.Location) string {
+	if loc == time.UTC || loc.String() == "UTC" {
+		return property + ":" + t.UTC().Format(utcLayout)
+	}
+	return fmt.Sprintf("%s;TZID=%s:%s", property, loc.String(), t.In(loc).Format(localLayout))
+}
+
+func formatOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset%3600/60)
+}
+
+func describe(entry api.SleepDiaryEntryDto) string {
+	lines := []string{fmt.Sprintf("Sleep quality: %s (%d/5)", entry.SleepQuality.String(), entry.SleepQuality)}
+	if entry.InBedAt != nil {
+		lines = append(lines, "In bed at: "+entry.InBedAt.Format("15:04"))
+	}
+	if entry.SleepDelayInMin != nil {
+		lines = append(lines, fmt.Sprintf("Fell asleep after: %d min", *entry.SleepDelayInMin))
+	}
+	if entry.AwakeningsCount != nil {
+		lines = append(lines, fmt.Sprintf("Awakenings: %d", *entry.AwakeningsCount))
+	}
+	if entry.AwakeningsTotalDurationInMin != nil {
+		lines = append(lines, fmt.Sprintf("Awake during the night: %d min", *entry.AwakeningsTotalDurationInMin))
+	}
+	if entry.OutOfBedAt != nil {
+		lines = append(lines, "Out of bed at: "+entry.OutOfBedAt.Format("15:04"))
+	}
+	if entry.Comments != nil {
+		lines = append(lines, *entry.Comments)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func escapeText(s string) string {
+	replacer := strings.NewReplacer(
+		"\\", "\\\\",
+		";", "\\;",
+		",", "\\,",
+		"\r\n", "\\n",
+		"\n", "\\n",
+	)
+	return replacer.Replace(s)
+}
+
+// contentWriter writes CRLF terminated content lines folded at 75 octets
+// without splitting UTF-8 sequences.
+type contentWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (cw *contentWriter) line(s string) {
+	if cw.err != nil {
+		return
+	}
+	limit := maxLineLength
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(s[cut]) {
+			cut--
+		}
+		cw.write(s[:cut] + "\r\n ")
+		s = s[cut:]
+		limit = maxLineLength - 1
+	}
+	cw.write(s + "\r\n")
+}
+
+func (cw *contentWriter) write(s string) {
+	if cw.err == nil {
+		_, cw.err = cw.w.WriteString(s)
+	}
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func sortedKeys(m map[string]*time.Location) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
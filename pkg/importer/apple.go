@@ -0,0 +1,291 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+const appleHealthSleepType = "HKCategoryTypeIdentifierSleepAnalysis"
+const appleHealthDateLayout = "2006-01-02 15:04:05 -0700"
+
+// Samples closer to each other than this are considered to belong to the same
+// night.
+const maxEpisodeGap = 2 * time.Hour
+
+type sampleKind int
+
+const (
+	inBedSample sampleKind = iota
+	asleepSample
+	awakeSample
+)
+
+type sample struct {
+	kind   sampleKind
+	staged bool
+	start  time.Time
+	end    time.Time
+}
+
+// ParseAppleHealthExport reads sleep analysis records from an Apple Health
+// export (either export.xml or the whole export.zip) and collapses them into
+// nightly entries. HealthKit does not know the self-rated sleep quality, so it
+// is left unknown (zero) and the entries are meant to be imported as drafts.
+// export.xml extracted from the archive may not exceed maxExtractedSize bytes.
+func ParseAppleHealthExport(r io.Reader, accountUuid string, tz *time.Location, maxExtractedSize int64) ([]api.CreateSleepDiaryEntryDto, error) {
+	xmlReader, err := openAppleHealthExport(r, maxExtractedSize)
+	if err != nil {
+		return nil, err
+	}
+	defer xmlReader.Close()
+
+	samples, err := readAppleHealthSleepSamples(xmlReader)
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := []api.CreateSleepDiaryEntryDto{}
+	for _, episode := range groupIntoEpisodes(samples) {
+		data, ok := toEntryData(episode, tz)
+		if !ok {
+			continue
+		}
+		dtos = append(dtos, api.CreateSleepDiaryEntryDto{
+			AccountUuid:            accountUuid,
+			SleepDiaryEntryDataDto: data,
+		})
+	}
+
+	return dtos, nil
+}
+
+// openAppleHealthExport returns a reader of export.xml, removing the spooled
+// archive it is extracted from when closed.
+func openAppleHealthExport(r io.Reader, maxExtractedSize int64) (io.ReadCloser, error) {
+	archive, reader, err := openArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	if archive == nil {
+		return io.NopCloser(reader), nil
+	}
+	for _, file := range archive.File {
+		if path.Base(file.Name) == "export.xml" {
+			xmlReader, err := archive.open(file, maxExtractedSize)
+			if err != nil {
+				archive.Close()
+				return nil, err
+			}
+			return &extractedFile{ReadCloser: xmlReader, archive: archive}, nil
+		}
+	}
+	archive.Close()
+	return nil, fmt.Errorf("export.xml not found in archive")
+}
+
+// extractedFile is a file of an archive closing the archive along with it.
+type extractedFile struct {
+	io.ReadCloser
+	archive *archive
+}
+
+func (f *extractedFile) Close() error {
+	f.ReadCloser.Close()
+	return f.archive.Close()
+}
+
+func readAppleHealthSleepSamples(r io.Reader) ([]sample, error) {
+	decoder := xml.NewDecoder(r)
+	samples := []sample{}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid export XML: %w", err)
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok || element.Name.Local != "Record" {
+			continue
+		}
+
+		attrs := make(map[string]string, len(element.Attr))
+		for _, attr := range element.Attr {
+			attrs[attr.Name.Local] = attr.Value
+		}
+		if attrs["type"] != appleHealthSleepType {
+			continue
+		}
+
+		s, ok := toSample(attrs["value"])
+		if !ok {
+			continue
+		}
+		if s.start, err = time.Parse(appleHealthDateLayout, attrs["startDate"]); err != nil {
+			return nil, fmt.Errorf("invalid startDate '%s': %w", attrs["startDate"], err)
+		}
+		if s.end, err = time.Parse(appleHealthDateLayout, attrs["endDate"]); err != nil {
+			return nil, fmt.Errorf("invalid endDate '%s': %w", attrs["endDate"], err)
+		}
+		if !s.end.After(s.start) {
+			continue
+		}
+		samples = append(samples, s)
+	}
+
+	return samples, nil
+}
+
+func toSample(value string) (sample, bool) {
+	switch value {
+	case "HKCategoryValueSleepAnalysisInBed":
+		return sample{kind: inBedSample}, true
+	case "HKCategoryValueSleepAnalysisAsleep", "HKCategoryValueSleepAnalysisAsleepUnspecified":
+		return sample{kind: asleepSample}, true
+	case "HKCategoryValueSleepAnalysisAsleepCore", "HKCategoryValueSleepAnalysisAsleepDeep", "HKCategoryValueSleepAnalysisAsleepREM":
+		return sample{kind: asleepSample, staged: true}, true
+	case "HKCategoryValueSleepAnalysisAwake":
+		return sample{kind: awakeSample, staged: true}, true
+	default:
+		return sample{}, false
+	}
+}
+
+func groupIntoEpisodes(samples []sample) [][]sample {
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].start.Before(samples[j].start)
+	})
+
+	episodes := [][]sample{}
+	var current []sample
+	var currentEnd time.Time
+
+	for _, s := range samples {
+		if current != nil && s.start.Sub(currentEnd) > maxEpisodeGap {
+			episodes = append(episodes, current)
+			current = nil
+		}
+		current = append(current, s)
+		if s.end.After(currentEnd) || len(current) == 1 {
+			currentEnd = s.end
+		}
+	}
+	if current != nil {
+		episodes = append(episodes, current)
+	}
+
+	return episodes
+}
+
+func toEntryData(episode []sample, tz *time.Location) (api.SleepDiaryEntryDataDto, bool) {
+	var onset, finalWakeUp, inBedStart, inBedEnd time.Time
+	staged := false
+	awake := []sample{}
+
+	for _, s := range episode {
+		staged = staged || s.staged
+		switch s.kind {
+		case asleepSample:
+			if onset.IsZero() || s.start.Before(onset) {
+				onset = s.start
+			}
+			if s.end.After(finalWakeUp) {
+				finalWakeUp = s.end
+			}
+		case inBedSample:
+			if inBedStart.IsZero() || s.start.Before(inBedStart) {
+				inBedStart = s.start
+			}
+			if s.end.After(inBedEnd) {
+				inBedEnd = s.end
+			}
+		case awakeSample:
+			awake = append(awake, s)
+		}
+	}
+
+	if onset.IsZero() {
+		return api.SleepDiaryEntryDataDto{}, false
+	}
+
+	timezone := tz.String()
+	data := api.SleepDiaryEntryDataDto{
+		Timezone:       &timezone,
+		TriedToSleepAt: onset.In(tz),
+		FinalWakeUpAt:  finalWakeUp.In(tz),
+	}
+
+	if !inBedStart.IsZero() && !inBedStart.After(onset) {
+		inBedAt := inBedStart.In(tz)
+		data.InBedAt = &inBedAt
+		data.TriedToSleepAt = inBedAt
+		data.SleepDelayInMin = toMinutes(onset.Sub(inBedStart))
+	}
+	if !inBedEnd.IsZero() && !inBedEnd.Before(finalWakeUp) {
+		outOfBedAt := inBedEnd.In(tz)
+		data.OutOfBedAt = &outOfBedAt
+	}
+	if staged {
+		count, duration := mergeAwakenings(awake, onset, finalWakeUp)
+		data.AwakeningsCount = &count
+		data.AwakeningsTotalDurationInMin = toMinutes(duration)
+	}
+
+	return data, true
+}
+
+// mergeAwakenings clips awake samples to the sleep period and merges the
+// overlapping ones, returning the number of awakenings and their total time.
+func mergeAwakenings(awake []sample, from, to time.Time) (int, time.Duration) {
+	count := 0
+	total := time.Duration(0)
+	var end time.Time
+
+	for _, s := range awake {
+		start := maxTime(s.start, from)
+		stop := minTime(s.end, to)
+		if !stop.After(start) {
+			continue
+		}
+		if count > 0 && !start.After(end) {
+			if stop.After(end) {
+				total += stop.Sub(end)
+				end = stop
+			}
+			continue
+		}
+		count++
+		total += stop.Sub(start)
+		end = stop
+	}
+
+	return count, total
+}
+
+func toMinutes(d time.Duration) *int {
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	return &minutes
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
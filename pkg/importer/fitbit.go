@@ -0,0 +1,170 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+const fitbitTimeLayout = "2006-01-02T15:04:05.000"
+
+type fitbitSleepLog struct {
+	LogId               int64        `json:"logId"`
+	StartTime           string       `json:"startTime"`
+	EndTime             string       `json:"endTime"`
+	MinutesToFallAsleep *int         `json:"minutesToFallAsleep"`
+	MinutesAwake        *int         `json:"minutesAwake"`
+	MinutesAfterWakeup  *int         `json:"minutesAfterWakeup"`
+	AwakeningsCount     *int         `json:"awakeningsCount"`
+	Type                string       `json:"type"`
+	Levels              *fitbitLevel `json:"levels"`
+}
+
+type fitbitLevel struct {
+	Data []fitbitLevelData `json:"data"`
+}
+
+type fitbitLevelData struct {
+	DateTime string `json:"dateTime"`
+	Level    string `json:"level"`
+	Seconds  int    `json:"seconds"`
+}
+
+// ParseFitbitExport reads Fitbit sleep logs from a single sleep-YYYY-MM-DD.json
+// file or from a Google Takeout zip archive containing such files. Fitbit logs
+// carry local times without offset, so they are interpreted in given timezone.
+// Sleep quality is left unknown (zero). Files extracted from the archive may
+// not exceed maxExtractedSize bytes each.
+func ParseFitbitExport(r io.Reader, accountUuid string, tz *time.Location, maxExtractedSize int64) ([]api.CreateSleepDiaryEntryDto, error) {
+	archive, reader, err := openArchive(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []fitbitSleepLog
+	if archive == nil {
+		if logs, err = readFitbitSleepLogs(reader); err != nil {
+			return nil, err
+		}
+	} else {
+		defer archive.Close()
+		for _, file := range archive.File {
+			name := path.Base(file.Name)
+			if !strings.HasPrefix(name, "sleep-") || path.Ext(name) != ".json" {
+				continue
+			}
+			f, err := archive.open(file, maxExtractedSize)
+			if err != nil {
+				return nil, err
+			}
+			fileLogs, err := readFitbitSleepLogs(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file.Name, err)
+			}
+			logs = append(logs, fileLogs...)
+		}
+	}
+
+	dtos := make([]api.CreateSleepDiaryEntryDto, 0, len(logs))
+	for _, sleepLog := range logs {
+		data, err := fitbitLogToEntryData(sleepLog, tz)
+		if err != nil {
+			return nil, fmt.Errorf("sleep log %d: %w", sleepLog.LogId, err)
+		}
+		dtos = append(dtos, api.CreateSleepDiaryEntryDto{
+			AccountUuid:            accountUuid,
+			SleepDiaryEntryDataDto: data,
+		})
+	}
+
+	sort.Slice(dtos, func(i, j int) bool {
+		return dtos[i].TriedToSleepAt.Before(dtos[j].TriedToSleepAt)
+	})
+
+	return dtos, nil
+}
+
+func readFitbitSleepLogs(r io.Reader) ([]fitbitSleepLog, error) {
+	var logs []fitbitSleepLog
+	if err := json.NewDecoder(r).Decode(&logs); err != nil {
+		return nil, fmt.Errorf("invalid Fitbit sleep JSON: %w", err)
+	}
+	return logs, nil
+}
+
+func fitbitLogToEntryData(sleepLog fitbitSleepLog, tz *time.Location) (api.SleepDiaryEntryDataDto, error) {
+	start, err := time.ParseInLocation(fitbitTimeLayout, sleepLog.StartTime, tz)
+	if err != nil {
+		return api.SleepDiaryEntryDataDto{}, fmt.Errorf("invalid startTime '%s': %w", sleepLog.StartTime, err)
+	}
+	end, err := time.ParseInLocation(fitbitTimeLayout, sleepLog.EndTime, tz)
+	if err != nil {
+		return api.SleepDiaryEntryDataDto{}, fmt.Errorf("invalid endTime '%s': %w", sleepLog.EndTime, err)
+	}
+
+	finalWakeUp := end
+	if sleepLog.MinutesAfterWakeup != nil {
+		finalWakeUp = end.Add(-time.Duration(*sleepLog.MinutesAfterWakeup) * time.Minute)
+	}
+
+	timezone := tz.String()
+	data := api.SleepDiaryEntryDataDto{
+		Timezone:                     &timezone,
+		InBedAt:                      &start,
+		TriedToSleepAt:               start,
+		SleepDelayInMin:              sleepLog.MinutesToFallAsleep,
+		AwakeningsCount:              sleepLog.AwakeningsCount,
+		AwakeningsTotalDurationInMin: sleepLog.MinutesAwake,
+		FinalWakeUpAt:                finalWakeUp,
+		OutOfBedAt:                   &end,
+	}
+
+	if data.AwakeningsCount == nil && sleepLog.Levels != nil {
+		count, err := countFitbitAwakenings(sleepLog.Levels.Data, tz)
+		if err != nil {
+			return api.SleepDiaryEntryDataDto{}, err
+		}
+		data.AwakeningsCount = &count
+	}
+
+	return data, nil
+}
+
+// countFitbitAwakenings counts wake segments ("wake" in stages logs, "awake"
+// in classic logs) that lie between the first and the last sleep segment.
+func countFitbitAwakenings(levels []fitbitLevelData, tz *time.Location) (int, error) {
+	var firstSleep, lastSleep time.Time
+	wakeStarts := []time.Time{}
+
+	for _, level := range levels {
+		start, err := time.ParseInLocation(fitbitTimeLayout, level.DateTime, tz)
+		if err != nil {
+			return 0, fmt.Errorf("invalid level dateTime '%s': %w", level.DateTime, err)
+		}
+		if level.Level == "wake" || level.Level == "awake" {
+			wakeStarts = append(wakeStarts, start)
+			continue
+		}
+		if firstSleep.IsZero() || start.Before(firstSleep) {
+			firstSleep = start
+		}
+		if start.After(lastSleep) {
+			lastSleep = start
+		}
+	}
+
+	count := 0
+	for _, start := range wakeStarts {
+		if start.After(firstSleep) && start.Before(lastSleep) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,105 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// Parse converts an export file of given source into entries of an account.
+// Timestamps are interpreted in given timezone. Files extracted from a zip
+// archive may not exceed maxExtractedSize bytes.
+func Parse(source api.ImportSource, r io.Reader, accountUuid string, tz *time.Location, maxExtractedSize int64) ([]api.CreateSleepDiaryEntryDto, error) {
+	switch source {
+	case api.AppleHealthImportSource:
+		return ParseAppleHealthExport(r, accountUuid, tz, maxExtractedSize)
+	case api.FitbitImportSource:
+		return ParseFitbitExport(r, accountUuid, tz, maxExtractedSize)
+	default:
+		return nil, fmt.Errorf("unsupported import source '%s'", source)
+	}
+}
+
+// ErrTooLarge is returned when a file extracted from an archive exceeds the
+// size allowed.
+var ErrTooLarge = errors.New("file too large")
+
+// archive is a zip archive spooled to a temporary file, removed on Close.
+type archive struct {
+	*zip.Reader
+	file *os.File
+}
+
+func (a *archive) Close() error {
+	a.file.Close()
+	return os.Remove(a.file.Name())
+}
+
+// open opens a file of the archive, failing with ErrTooLarge once more than
+// maxSize bytes are extracted from it.
+func (a *archive) open(file *zip.File, maxSize int64) (io.ReadCloser, error) {
+	if file.UncompressedSize64 > uint64(maxSize) {
+		return nil, fmt.Errorf("%s: %w", file.Name, ErrTooLarge)
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &limitedReadCloser{ReadCloser: rc, left: maxSize}, nil
+}
+
+// limitedReadCloser fails with ErrTooLarge once more than left bytes are
+// read, unlike io.LimitedReader, which ends silently.
+type limitedReadCloser struct {
+	io.ReadCloser
+	left int64
+}
+
+func (r *limitedReadCloser) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.left+1 {
+		p = p[:r.left+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.left -= int64(n)
+	if r.left < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+// openArchive detects whether r holds a zip archive. If it does, the archive
+// is streamed to a temporary file and returned, to be closed by the caller;
+// otherwise the returned reader yields the unconsumed data.
+func openArchive(r io.Reader) (*archive, io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	if !bytes.Equal(magic, []byte("PK\x03\x04")) {
+		return nil, br, nil
+	}
+
+	file, err := os.CreateTemp("", "snorlax-import-*.zip")
+	if err != nil {
+		return nil, nil, err
+	}
+	a := &archive{file: file}
+	size, err := io.Copy(file, br)
+	if err != nil {
+		a.Close()
+		return nil, nil, err
+	}
+	if a.Reader, err = zip.NewReader(file, size); err != nil {
+		a.Close()
+		return nil, nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+	return a, nil, nil
+}
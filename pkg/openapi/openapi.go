@@ -0,0 +1,229 @@
+// Package openapi builds an OpenAPI 3.1 document from route descriptions and
+// the api DTO types. Schemas are derived by reflection from the json tags of
+// the DTOs; validation constraints are taken from the api package constants.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const OPENAPI_VERSION = "3.1.0"
+
+type Document struct {
+	OpenApi    string                               `json:"openapi"`
+	Info       Info                                 `json:"info"`
+	Paths      map[string]map[string]*JsonOperation `json:"paths"`
+	Components Components                           `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type JsonOperation struct {
+	OperationId string                   `json:"operationId"`
+	Summary     string                   `json:"summary"`
+	Description string                   `json:"description,omitempty"`
+	Parameters  []JsonParameter          `json:"parameters,omitempty"`
+	RequestBody *JsonRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*JsonResponse `json:"responses"`
+}
+
+type JsonParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type JsonRequestBody struct {
+	Required bool                     `json:"required"`
+	Content  map[string]JsonMediaType `json:"content"`
+}
+
+type JsonResponse struct {
+	Description string                   `json:"description"`
+	Content     map[string]JsonMediaType `json:"content,omitempty"`
+}
+
+type JsonMediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Operation describes a single route. Pattern uses the http.ServeMux syntax
+// ("METHOD /path/{param}").
+type Operation struct {
+	Pattern     string
+	Id          string
+	Summary     string
+	Description string
+	Parameters  []Parameter
+	Request     *Body
+	Responses   []Response
+}
+
+type Parameter struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Type        reflect.Type
+}
+
+// Body is a request or response payload. Payloads without Type (files,
+// documents) are described as binary strings.
+type Body struct {
+	ContentType string
+	Type        reflect.Type
+}
+
+type Response struct {
+	Status      int
+	Description string
+	Bodies      []Body
+}
+
+// TypeOf returns the reflect.Type of T; shorthand for describing bodies and
+// parameters.
+func TypeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// JsonBody describes an application/json payload of type T.
+func JsonBody[T any]() Body {
+	return Body{ContentType: "application/json", Type: TypeOf[T]()}
+}
+
+// PathParam describes a required path parameter of type T.
+func PathParam[T any](name string, description string) Parameter {
+	return Parameter{Name: name, In: "path", Description: description, Required: true, Type: TypeOf[T]()}
+}
+
+// QueryParam describes a query parameter of type T. Slice types describe
+// parameters that may be repeated.
+func QueryParam[T any](name string, description string, required bool) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Required: required, Type: TypeOf[T]()}
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// PathParams returns names of the parameters of a route pattern path.
+func PathParams(path string) []string {
+	names := []string{}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+type builder struct {
+	schemas map[string]*Schema
+}
+
+// Build generates the document describing given operations.
+func Build(info Info, operations []Operation) (*Document, error) {
+	b := &builder{schemas: map[string]*Schema{}}
+	doc := &Document{
+		OpenApi:    OPENAPI_VERSION,
+		Info:       info,
+		Paths:      map[string]map[string]*JsonOperation{},
+		Components: Components{Schemas: b.schemas},
+	}
+
+	for _, op := range operations {
+		method, path, ok := strings.Cut(op.Pattern, " ")
+		if !ok {
+			return nil, fmt.Errorf("operation %s: pattern '%s' has no method", op.Id, op.Pattern)
+		}
+		path = pathParamPattern.ReplaceAllString(path, "{$1}")
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*JsonOperation{}
+		}
+		key := strings.ToLower(method)
+		if _, exists := doc.Paths[path][key]; exists {
+			return nil, fmt.Errorf("operation %s: duplicate route '%s'", op.Id, op.Pattern)
+		}
+		doc.Paths[path][key] = b.operation(op)
+	}
+
+	return doc, nil
+}
+
+func (b *builder) operation(op Operation) *JsonOperation {
+	result := &JsonOperation{
+		OperationId: op.Id,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Responses:   map[string]*JsonResponse{},
+	}
+
+	for _, param := range op.Parameters {
+		schema := b.schemaOf(param.Type)
+		if constraint, ok := constraints[param.Name]; ok {
+			target := schema
+			if schema.Type == "array" {
+				target = schema.Items
+			}
+			applyConstraint(target, constraint)
+		}
+		result.Parameters = append(result.Parameters, JsonParameter{
+			Name:        param.Name,
+			In:          param.In,
+			Description: param.Description,
+			Required:    param.Required,
+			Schema:      schema,
+		})
+	}
+
+	if op.Request != nil {
+		result.RequestBody = &JsonRequestBody{
+			Required: true,
+			Content:  b.content([]Body{*op.Request}),
+		}
+	}
+
+	for _, response := range op.Responses {
+		status := strconv.Itoa(response.Status)
+		existing, ok := result.Responses[status]
+		if !ok {
+			description := response.Description
+			if description == "" {
+				description = http.StatusText(response.Status)
+			}
+			result.Responses[status] = &JsonResponse{Description: description, Content: b.content(response.Bodies)}
+			continue
+		}
+		// Several error codes may share a status.
+		existing.Description += ", " + response.Description
+	}
+
+	return result
+}
+
+func (b *builder) content(bodies []Body) map[string]JsonMediaType {
+	if len(bodies) == 0 {
+		return nil
+	}
+	content := map[string]JsonMediaType{}
+	for _, body := range bodies {
+		schema := &Schema{Type: "string", Format: "binary"}
+		if body.Type != nil {
+			schema = b.schemaOf(body.Type)
+		}
+		content[body.ContentType] = JsonMediaType{Schema: schema}
+	}
+	return content
+}
@@ -0,0 +1,267 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Minimum              *int64             `json:"minimum,omitempty"`
+	Maximum              *int64             `json:"maximum,omitempty"`
+	MinLength            *int64             `json:"minLength,omitempty"`
+	MaxLength            *int64             `json:"maxLength,omitempty"`
+	MaxItems             *int64             `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Enumerations of named api types.
+var enums = map[reflect.Type][]any{
+	reflect.TypeOf(api.ErrorCode("")): {
+		api.ERR_UNKNOWN, api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_CONFLICT, api.ERR_UNAUTHORIZED, api.ERR_FORBIDDEN,
+		api.ERR_RATE_LIMITED,
+	},
+	reflect.TypeOf(api.ValidationRule("")): {
+		api.RULE_REQUIRED, api.RULE_TYPE, api.RULE_FORMAT, api.RULE_ENUM, api.RULE_MINIMUM, api.RULE_MAXIMUM,
+		api.RULE_MIN_LENGTH, api.RULE_MAX_LENGTH, api.RULE_MAX_ITEMS, api.RULE_UNKNOWN_FIELD, api.RULE_SYNTAX, api.RULE_ORDER,
+		api.RULE_OVERLAP, api.RULE_MATCH,
+	},
+	reflect.TypeOf(api.ImportSource("")): {
+		api.AppleHealthImportSource, api.FitbitImportSource,
+	},
+	reflect.TypeOf(api.ImportRecordStatus("")): {
+		api.CreatedImportRecordStatus, api.MergedImportRecordStatus, api.SkippedImportRecordStatus,
+	},
+	reflect.TypeOf(api.SleepStage("")): {
+		api.WakeSleepStage, api.LightSleepStage, api.DeepSleepStage, api.RemSleepStage,
+	},
+	reflect.TypeOf(api.ChangeType("")): {
+		api.CHANGE_CREATED, api.CHANGE_UPDATED, api.CHANGE_DELETED,
+	},
+	reflect.TypeOf(api.WebhookDeliveryStatus("")): {
+		api.PendingWebhookDeliveryStatus, api.DeliveredWebhookDeliveryStatus, api.DeadWebhookDeliveryStatus,
+	},
+	reflect.TypeOf(api.SyncStatus("")): {
+		api.AppliedSyncStatus, api.ConflictSyncStatus,
+	},
+	reflect.TypeOf(api.GrantAccess("")): {
+		api.ReadGrantAccess, api.ReadWriteGrantAccess,
+	},
+	reflect.TypeOf(api.AuditAction("")): {
+		api.ReadEntryAuditAction, api.ListEntriesAuditAction, api.CreateEntryAuditAction, api.UpdateEntryAuditAction,
+		api.DeleteEntryAuditAction, api.ReadHypnogramAuditAction, api.ReplaceHypnogramAuditAction, api.ImportDraftsAuditAction,
+		api.ListDraftsAuditAction, api.ConfirmDraftAuditAction, api.DeleteDraftAuditAction, api.ListChangesAuditAction,
+		api.PullSyncAuditAction, api.CreateGrantAuditAction, api.ListGrantsAuditAction, api.ListReceivedGrantsAuditAction,
+		api.RevokeGrantAuditAction, api.ExportAccountAuditAction, api.DeleteAccountAuditAction, api.ReadAuditLogAuditAction,
+		api.ExportResearchAuditAction,
+	},
+	reflect.TypeOf(api.AccountDeletionMode("")): {
+		api.DeleteAccountDeletionMode, api.PseudonymizeAccountDeletionMode,
+	},
+	reflect.TypeOf(api.ResearchCommentsMode("")): {
+		api.StripResearchCommentsMode, api.RedactResearchCommentsMode,
+	},
+}
+
+// Constraints of properties enforced by the api validation, by property name.
+// For array properties the constraint applies to the items.
+var constraints = map[string]Schema{
+	"account_uuid":                     {Format: "uuid"},
+	"account_uuids":                    {Format: "uuid"},
+	"uuid":                             {Format: "uuid"},
+	"entry_uuid":                       {Format: "uuid"},
+	"timezone":                         {Description: "IANA timezone name", Default: "UTC"},
+	"sleep_quality":                    {Minimum: ptr(int64(api.VeryPoorSleepQuality)), Maximum: ptr(int64(api.ExcellentSleepQuality))},
+	"sleep_delay_in_min":               {Minimum: ptr(int64(0))},
+	"awakenings_count":                 {Minimum: ptr(int64(0))},
+	"awakenings_total_duration_in_min": {Minimum: ptr(int64(0))},
+	"comments":                         {MaxLength: ptr(int64(api.MAX_COMMENT_LENGTH))},
+	"source_device":                    {MaxLength: ptr(int64(api.MAX_SOURCE_DEVICE_LENGTH))},
+	"study_id":                         {MaxLength: ptr(int64(api.MAX_STUDY_ID_LENGTH))},
+	"segments":                         {MaxItems: ptr(int64(api.MAX_HYPNOGRAM_SEGMENTS))},
+	"page_size":                        {Minimum: ptr(int64(1)), Maximum: ptr(api.MAX_PAGE_SIZE), Default: api.DEFAULT_PAGE_SIZE},
+	"page_number":                      {Minimum: ptr(int64(1)), Default: 1},
+}
+
+// Constraints overriding the ones above for a property of a given schema.
+var schemaConstraints = map[string]map[string]Schema{
+	"SleepDiaryDraftDto": {
+		"sleep_quality": {Minimum: ptr(int64(0)), Description: "0 when unknown"},
+	},
+	"CreateWebhookDto": {
+		"url":    {Format: "uri", MaxLength: ptr(int64(api.MAX_WEBHOOK_URL_LENGTH))},
+		"secret": {MinLength: ptr(int64(api.MIN_WEBHOOK_SECRET_LENGTH)), MaxLength: ptr(int64(api.MAX_WEBHOOK_SECRET_LENGTH))},
+	},
+	"SyncPushDto": {
+		"changes": {MaxItems: ptr(int64(api.MAX_SYNC_PUSH_CHANGES))},
+	},
+	"CreateApiKeyDto": {
+		"name":   {MaxLength: ptr(int64(api.MAX_API_KEY_NAME_LENGTH))},
+		"scopes": {Description: "Any of " + strings.Join(api.ApiKeyScopes, ", ")},
+	},
+	"CreateGrantDto": {
+		"grantee": {MaxLength: ptr(int64(api.MAX_GRANTEE_LENGTH))},
+	},
+	"GraphQLRequestDto": {
+		"query": {MaxLength: ptr(int64(api.MAX_GRAPHQL_QUERY_LENGTH))},
+	},
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// schemaOf returns schema of t. Named structs are registered in components
+// and referenced.
+func (b *builder) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if values, ok := enums[t]; ok {
+		schema := primitiveSchema(t)
+		schema.Enum = values
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		return b.refOf(t)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	default:
+		return primitiveSchema(t)
+	}
+}
+
+func (b *builder) refOf(t reflect.Type) *Schema {
+	name := schemaName(t)
+	if _, ok := b.schemas[name]; !ok {
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		b.schemas[name] = schema
+		b.addProperties(schema, t)
+		for property, constraint := range schemaConstraints[name] {
+			applyConstraint(schema.Properties[property], constraint)
+		}
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// addProperties adds exported fields of t to schema; embedded structs are
+// flattened the same way encoding/json does it.
+func (b *builder) addProperties(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			b.addProperties(schema, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := b.schemaOf(field.Type)
+		if constraint, ok := constraints[name]; ok {
+			target := property
+			if property.Type == "array" && property.Items.Ref == "" {
+				target = property.Items
+			}
+			applyConstraint(target, constraint)
+		}
+		schema.Properties[name] = property
+
+		if field.Type.Kind() != reflect.Pointer && !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+func applyConstraint(schema *Schema, constraint Schema) {
+	if constraint.Format != "" {
+		schema.Format = constraint.Format
+	}
+	if constraint.Description != "" {
+		schema.Description = constraint.Description
+	}
+	if constraint.Default != nil {
+		schema.Default = constraint.Default
+	}
+	if constraint.Minimum != nil {
+		schema.Minimum = constraint.Minimum
+	}
+	if constraint.Maximum != nil {
+		schema.Maximum = constraint.Maximum
+	}
+	if constraint.MinLength != nil {
+		schema.MinLength = constraint.MinLength
+	}
+	if constraint.MaxLength != nil {
+		schema.MaxLength = constraint.MaxLength
+	}
+	if constraint.MaxItems != nil {
+		schema.MaxItems = constraint.MaxItems
+	}
+}
+
+func primitiveSchema(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	default:
+		return &Schema{Type: "string"}
+	}
+}
+
+// schemaName turns type names (including instantiated generics such as
+// PageDto[github.com/mabzd/snorlax/api.SleepDiaryEntryDto]) into component
+// names (PageDtoSleepDiaryEntryDto).
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if open := strings.Index(name, "["); open >= 0 {
+		args := strings.Split(strings.TrimSuffix(name[open+1:], "]"), ",")
+		name = name[:open]
+		for _, arg := range args {
+			name += arg[strings.LastIndex(arg, ".")+1:]
+		}
+	}
+	return invalidNameChars.ReplaceAllString(name, "")
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
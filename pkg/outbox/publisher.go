@@ -0,0 +1,135 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/config"
+)
+
+const EVENT_ID_HEADER = "X-Event-Id"
+const EVENT_TYPE_HEADER = "X-Event-Type"
+
+// Event is an entry change published from the outbox. The same event may be
+// published more than once; consumers deduplicate by Id.
+type Event struct {
+	Id          int64           `json:"id"`
+	Type        api.ChangeType  `json:"type"`
+	AggregateId int64           `json:"aggregate_id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+// Publisher publishes events to a sink. An event counts as published once
+// Publish returns without error; it is retried otherwise.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// NewPublisher creates the publisher of sink configured with OUTBOX_SINK.
+func NewPublisher(cfg config.Config) (Publisher, error) {
+	switch cfg.OutboxSink {
+	case "log":
+		return LogPublisher{}, nil
+	case "http":
+		if cfg.OutboxHttpUrl == "" {
+			return nil, fmt.Errorf("OUTBOX_HTTP_URL is required by http sink")
+		}
+		return NewHttpPublisher(cfg.OutboxHttpUrl, &http.Client{Timeout: DEFAULT_TIMEOUT}), nil
+	case "file":
+		if cfg.OutboxFilePath == "" {
+			return nil, fmt.Errorf("OUTBOX_FILE_PATH is required by file sink")
+		}
+		return NewFilePublisher(cfg.OutboxFilePath), nil
+	default:
+		return nil, fmt.Errorf("unknown outbox sink '%s'", cfg.OutboxSink)
+	}
+}
+
+// LogPublisher writes events to the standard logger. Payloads hold data of
+// accounts and are left out of logs.
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(ctx context.Context, event Event) error {
+	log.Printf("Event %d (%s) of entry %d\n", event.Id, event.Type, event.AggregateId)
+	return nil
+}
+
+// HttpPublisher POSTs events as JSON to a URL; any 2xx response acknowledges
+// the event.
+type HttpPublisher struct {
+	url        string
+	httpClient *http.Client
+}
+
+func NewHttpPublisher(url string, httpClient *http.Client) *HttpPublisher {
+	return &HttpPublisher{url: url, httpClient: httpClient}
+}
+
+func (p *HttpPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EVENT_ID_HEADER, strconv.FormatInt(event.Id, 10))
+	req.Header.Set(EVENT_TYPE_HEADER, string(event.Type))
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sink responded with %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// FilePublisher appends events to a file as JSON lines. Every event is synced
+// to disk before it is acknowledged.
+type FilePublisher struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFilePublisher(path string) *FilePublisher {
+	return &FilePublisher{path: path}
+}
+
+func (p *FilePublisher) Publish(ctx context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := os.OpenFile(p.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return file.Sync()
+}
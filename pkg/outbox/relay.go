@@ -0,0 +1,131 @@
+// Package outbox publishes entry changes recorded in the outbox table in the
+// transaction of the change. The relay may run in several processes at once;
+// events are claimed with a lease and published at least once.
+package outbox
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/mabzd/snorlax/internal/service"
+)
+
+const DEFAULT_BACKOFF = 5 * time.Second
+const DEFAULT_MAX_BACKOFF = 10 * time.Minute
+const DEFAULT_POLL_INTERVAL = time.Second
+const DEFAULT_TIMEOUT = 10 * time.Second
+
+// Number of events claimed at once.
+const BATCH_SIZE = 50
+
+type Relay struct {
+	service      *service.SleepDiaryService
+	publisher    Publisher
+	timeout      time.Duration
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+}
+
+type Option func(*Relay)
+
+// WithBackoff sets the delay before the first retry of an event, doubled
+// with every further retry up to maxBackoff. Events are retried until
+// published.
+func WithBackoff(backoff time.Duration, maxBackoff time.Duration) Option {
+	return func(r *Relay) {
+		r.backoff = backoff
+		r.maxBackoff = maxBackoff
+	}
+}
+
+func WithPollInterval(pollInterval time.Duration) Option {
+	return func(r *Relay) {
+		r.pollInterval = pollInterval
+	}
+}
+
+// WithTimeout limits the time of a single Publish call.
+func WithTimeout(timeout time.Duration) Option {
+	return func(r *Relay) {
+		r.timeout = timeout
+	}
+}
+
+func NewRelay(service *service.SleepDiaryService, publisher Publisher, options ...Option) *Relay {
+	r := &Relay{
+		service:      service,
+		publisher:    publisher,
+		timeout:      DEFAULT_TIMEOUT,
+		backoff:      DEFAULT_BACKOFF,
+		maxBackoff:   DEFAULT_MAX_BACKOFF,
+		pollInterval: DEFAULT_POLL_INTERVAL,
+	}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// Run publishes due events until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		count := r.PublishDue(ctx)
+		if count == BATCH_SIZE {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+// PublishDue makes a single attempt to publish up to BATCH_SIZE due events
+// in order of creation and returns their number.
+func (r *Relay) PublishDue(ctx context.Context) int {
+	// The lease outlasts the attempts of the whole batch.
+	lease := 2 * BATCH_SIZE * r.timeout
+	events, serviceErr := r.service.ClaimOutboxEvents(BATCH_SIZE, lease)
+	if serviceErr != nil {
+		return 0
+	}
+
+	for _, event := range events {
+		err := r.publish(ctx, event)
+		if ctx.Err() != nil {
+			// Interrupted attempts are not counted; the event is picked up
+			// again once its lease passes.
+			break
+		}
+		var nextAttemptAt time.Time
+		if err != nil {
+			log.Printf("Publishing outbox event %d failed (attempt %d): %v\n", event.Id, event.Attempts+1, err)
+			nextAttemptAt = time.Now().UTC().Add(r.retryDelay(event.Attempts))
+		}
+		r.service.RecordOutboxEventAttempt(event.Id, err, nextAttemptAt)
+	}
+	return len(events)
+}
+
+func (r *Relay) publish(ctx context.Context, event service.OutboxEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.publisher.Publish(ctx, Event{
+		Id:          event.Id,
+		Type:        event.EventType,
+		AggregateId: event.AggregateId,
+		CreatedAt:   event.CreatedAt,
+		Payload:     event.Payload,
+	})
+}
+
+// retryDelay doubles the backoff with every attempt made so far, up to
+// maxBackoff, and adds up to 10% of jitter.
+func (r *Relay) retryDelay(attempts int) time.Duration {
+	delay := min(r.backoff<<min(attempts, 20), r.maxBackoff)
+	return delay + time.Duration(rand.Int64N(int64(delay)/10+1))
+}
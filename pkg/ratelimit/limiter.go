@@ -0,0 +1,191 @@
+// Package ratelimit limits callers of the REST and gRPC APIs with token
+// buckets kept per caller and class of calls.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+)
+
+// PERIOD is the period configured limits are counted in.
+const PERIOD = time.Minute
+
+// Class of calls limited separately.
+type Class string
+
+const (
+	ReadsClass   Class = "reads"
+	WritesClass  Class = "writes"
+	ExportsClass Class = "exports"
+	// Failed authentications of an IP address, counted before the caller
+	// is known.
+	AuthFailuresClass Class = "auth_failures"
+)
+
+// Store keeps token buckets of callers; implemented by the service storing
+// them in the database and by MemoryStore.
+type Store interface {
+	TakeRateLimitToken(key string, limit service.RateLimit) (service.RateLimitResult, api.Error)
+	PeekRateLimitToken(key string, limit service.RateLimit) (service.RateLimitResult, api.Error)
+	DeleteIdleRateLimitBuckets(idleSince time.Time) api.Error
+}
+
+type Limiter struct {
+	store  Store
+	limits map[Class]service.RateLimit
+	// Unix time in nanoseconds of the last removal of idle buckets.
+	lastSweep atomic.Int64
+}
+
+// Decision is the state of the bucket of a caller after a call, allowed or
+// not.
+type Decision struct {
+	Class  Class
+	Limit  service.RateLimit
+	Result service.RateLimitResult
+}
+
+// NewLimiterFromConfig returns the limiter configured with RATE_LIMIT_*
+// variables, or nil when no limit is set.
+func NewLimiterFromConfig(cfg config.Config, svc *service.SleepDiaryService) (*Limiter, error) {
+	limits := map[Class]service.RateLimit{}
+	for class, burst := range map[Class]int{
+		ReadsClass:        cfg.RateLimitReads,
+		WritesClass:       cfg.RateLimitWrites,
+		ExportsClass:      cfg.RateLimitExports,
+		AuthFailuresClass: cfg.RateLimitAuthFailures,
+	} {
+		if burst > 0 {
+			limits[class] = service.RateLimit{Burst: burst, Period: PERIOD}
+		}
+	}
+	if len(limits) == 0 {
+		return nil, nil
+	}
+
+	var store Store
+	switch cfg.RateLimitStore {
+	case "memory":
+		store = NewMemoryStore()
+	case "postgres":
+		store = svc
+	default:
+		return nil, fmt.Errorf("invalid rate limit store '%s'", cfg.RateLimitStore)
+	}
+	return &Limiter{store: store, limits: limits}, nil
+}
+
+// Limits tells whether calls of a class are limited.
+func (l *Limiter) Limits(class Class) bool {
+	_, ok := l.limits[class]
+	return ok
+}
+
+// Take takes a token from the bucket of a caller, identified by key, for a
+// call of a class. It returns false when calls of the class are not limited
+// or the store fails; calls are let through then, so that rate limiting
+// never takes the API down.
+func (l *Limiter) Take(class Class, key string) (Decision, bool) {
+	return l.update(class, key, l.store.TakeRateLimitToken)
+}
+
+// Peek tells whether the bucket of a caller has a token left for a call of
+// a class, without taking it.
+func (l *Limiter) Peek(class Class, key string) (Decision, bool) {
+	return l.update(class, key, l.store.PeekRateLimitToken)
+}
+
+func (l *Limiter) update(
+	class Class,
+	key string,
+	take func(string, service.RateLimit) (service.RateLimitResult, api.Error),
+) (Decision, bool) {
+	limit, ok := l.limits[class]
+	if !ok {
+		return Decision{}, false
+	}
+	l.sweepIdleBuckets()
+	key = string(class) + ":" + key
+	result, serviceErr := take(key, limit)
+	if serviceErr != nil {
+		log.Printf("Rate limiting %s failed: %v\n", key, serviceErr)
+		return Decision{}, false
+	}
+	return Decision{Class: class, Limit: limit, Result: result}, true
+}
+
+// sweepIdleBuckets removes buckets idle for a whole period in the
+// background, at most once a period.
+func (l *Limiter) sweepIdleBuckets() {
+	now := time.Now()
+	last := l.lastSweep.Load()
+	if now.UnixNano()-last < int64(PERIOD) || !l.lastSweep.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	go func() {
+		if serviceErr := l.store.DeleteIdleRateLimitBuckets(now.Add(-PERIOD).UTC()); serviceErr != nil {
+			log.Printf("Removing idle rate limit buckets failed: %v\n", serviceErr)
+		}
+	}()
+}
+
+// Message is the message of the error a denied call fails with.
+func (d Decision) Message() string {
+	return "rate limit of " + string(d.Class) + " exceeded"
+}
+
+// Headers returns RateLimit-* headers describing the bucket, and
+// Retry-After when the call is denied; gRPC sends them as metadata.
+func (d Decision) Headers() map[string]string {
+	headers := map[string]string{
+		"RateLimit-Limit":     strconv.Itoa(d.Limit.Burst),
+		"RateLimit-Remaining": strconv.Itoa(d.Result.Remaining),
+		"RateLimit-Reset":     strconv.Itoa(ceilSeconds(d.Result.ResetAfter)),
+		"RateLimit-Policy":    strconv.Itoa(d.Limit.Burst) + ";w=" + strconv.Itoa(ceilSeconds(d.Limit.Period)),
+	}
+	if !d.Result.Allowed {
+		headers["Retry-After"] = strconv.Itoa(ceilSeconds(d.Result.RetryAfter))
+	}
+	return headers
+}
+
+// CallerKey identifies the caller of a request: the account it acts as, the
+// client it authenticated as (such as an API key) or, for anonymous
+// requests, the IP address of remoteAddr.
+func CallerKey(ctx context.Context, remoteAddr string) string {
+	if principal, ok := auth.FromContext(ctx); ok {
+		tenant := principal.Tenant()
+		switch {
+		case principal.AccountUuid != "" && !strings.HasPrefix(principal.Subject, service.API_KEY_SUBJECT_PREFIX):
+			return "account:" + tenant + "/" + strings.ToLower(principal.AccountUuid)
+		case principal.Subject != "":
+			return "client:" + tenant + "/" + principal.Subject
+		}
+	}
+	return IpKey(remoteAddr)
+}
+
+// IpKey identifies the IP address of remoteAddr, with or without a port.
+func IpKey(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+)
+
+// MemoryStore keeps buckets in memory, limiting callers per server replica.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*service.TokenBucket
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*service.TokenBucket{}}
+}
+
+func (s *MemoryStore) TakeRateLimitToken(key string, limit service.RateLimit) (service.RateLimitResult, api.Error) {
+	return s.updateBucket(key, limit, (*service.TokenBucket).Take), nil
+}
+
+func (s *MemoryStore) PeekRateLimitToken(key string, limit service.RateLimit) (service.RateLimitResult, api.Error) {
+	return s.updateBucket(key, limit, (*service.TokenBucket).Peek), nil
+}
+
+func (s *MemoryStore) updateBucket(
+	key string,
+	limit service.RateLimit,
+	take func(*service.TokenBucket, service.RateLimit, time.Time) service.RateLimitResult,
+) service.RateLimitResult {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		newBucket := service.NewTokenBucket(limit, now)
+		bucket = &newBucket
+		s.buckets[key] = bucket
+	}
+	return take(bucket, limit, now)
+}
+
+func (s *MemoryStore) DeleteIdleRateLimitBuckets(idleSince time.Time) api.Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, bucket := range s.buckets {
+		if bucket.UpdatedAt.Before(idleSince) {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
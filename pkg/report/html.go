@@ -0,0 +1,169 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+const chartLabelWidth = 90
+const chartHourWidth = 28
+const chartRowHeight = 22
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"x":          func(minutes int) int { return chartLabelWidth + minutes*chartHourWidth/60 },
+	"width":      func(from, to int) int { return max((to-from)*chartHourWidth/60, 1) },
+	"y":          func(row int) int { return 20 + row*chartRowHeight },
+	"hours":      func() []int { return hourTicks() },
+	"hourLabel":  hourLabel,
+	"chartWidth": func() int { return chartLabelWidth + 24*chartHourWidth },
+	"chartHeight": func(rows int) int {
+		return 20 + rows*chartRowHeight + 4
+	},
+	"date":     formatDate,
+	"clock":    formatClock,
+	"clockPtr": formatClockPtr,
+	"duration": formatDuration,
+	"minutes":  formatMinutesPtr,
+	"count":    formatCountPtr,
+	"percent":  func(v float64) string { return fmt.Sprintf("%.0f%%", v) },
+	"avg":      func(v float64) string { return fmt.Sprintf("%.1f", v) },
+	"avgDur":   func(v float64) string { return formatDuration(int(v + 0.5)) },
+	"quality":  func(q api.SleepQuality) string { return fmt.Sprintf("%d - %s", q, q.String()) },
+	"comments": func(c *string) string {
+		if c == nil {
+			return ""
+		}
+		return *c
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Sleep diary report {{date .From}} - {{date .To}}</title>
+<style>
+  body { font-family: Helvetica, Arial, sans-serif; font-size: 12px; color: #222; margin: 24px; }
+  h1 { font-size: 20px; margin: 0 0 4px 0; }
+  h2 { font-size: 15px; margin: 24px 0 8px 0; }
+  .meta { color: #666; }
+  table { border-collapse: collapse; width: 100%; }
+  th, td { border: 1px solid #bbb; padding: 3px 5px; text-align: left; vertical-align: top; }
+  th { background: #eee; }
+  td.num { text-align: right; }
+  .summary td { border: none; padding: 2px 16px 2px 0; }
+  .legend span { display: inline-block; width: 12px; height: 10px; margin: 0 4px 0 12px; vertical-align: middle; }
+  svg text { font-size: 10px; fill: #444; }
+  @media print { body { margin: 0; } h2 { page-break-after: avoid; } }
+</style>
+</head>
+<body>
+<h1>Sleep diary report</h1>
+<div class="meta">Account {{.AccountUuid}} &middot; {{date .From}} - {{date .To}} &middot; generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}</div>
+
+<h2>Summary</h2>
+<table class="summary">
+  <tr><td>Nights recorded</td><td>{{.Summary.RecordedNights}} of {{.Summary.TotalNights}}</td></tr>
+  <tr><td>Average total sleep time</td><td>{{avgDur .Summary.AvgTotalSleepTimeInMin}}</td></tr>
+  <tr><td>Average time to fall asleep</td><td>{{avgDur .Summary.AvgSleepOnsetLatencyInMin}}</td></tr>
+  <tr><td>Average time awake after sleep onset</td><td>{{avgDur .Summary.AvgWakeAfterSleepOnsetInMin}}</td></tr>
+  <tr><td>Average sleep efficiency</td><td>{{percent .Summary.AvgSleepEfficiency}}</td></tr>
+  <tr><td>Average sleep quality (1-5)</td><td>{{avg .Summary.AvgSleepQuality}}</td></tr>
+</table>
+
+<h2>Sleep log</h2>
+<div class="legend"><span style="background:#c6d8ef"></span>In bed<span style="background:#2f5d95"></span>Asleep</div>
+<svg xmlns="http://www.w3.org/2000/svg" width="{{chartWidth}}" height="{{chartHeight (len .Days)}}">
+  {{range hours}}
+  <line x1="{{x .}}" y1="14" x2="{{x .}}" y2="{{y (len $.Days)}}" stroke="#ddd"/>
+  <text x="{{x .}}" y="10" text-anchor="middle">{{hourLabel .}}</text>
+  {{end}}
+  {{range $i, $day := .Days}}
+  <text x="0" y="{{y $i}}" dy="15">{{date $day.Date}}</text>
+  <line x1="{{x 0}}" y1="{{y $i}}" x2="{{x 1440}}" y2="{{y $i}}" stroke="#eee"/>
+  {{range $day.Entries}}
+  <rect x="{{x .InBedFrom}}" y="{{y $i}}" width="{{width .InBedFrom .InBedTo}}" height="{{$.RowHeight}}" fill="#c6d8ef" transform="translate(0,3)"/>
+  <rect x="{{x .AsleepFrom}}" y="{{y $i}}" width="{{width .AsleepFrom .AsleepTo}}" height="{{$.RowHeight}}" fill="#2f5d95" transform="translate(0,3)"/>
+  {{end}}
+  {{end}}
+</svg>
+
+<h2>Daily entries</h2>
+<table>
+  <tr>
+    <th>Night of</th><th>In bed</th><th>Tried to sleep</th><th>Fell asleep after</th><th>Awakenings</th><th>Awake</th>
+    <th>Final wake up</th><th>Out of bed</th><th>Total sleep</th><th>Efficiency</th><th>Quality</th><th>Comments</th>
+  </tr>
+  {{range .Days}}{{$day := .}}
+  {{range .Entries}}
+  <tr>
+    <td>{{date $day.Date}}</td><td>{{clockPtr .InBedAt}}</td><td>{{clock .TriedToSleepAt}}</td>
+    <td class="num">{{minutes .SleepDelayInMin}}</td><td class="num">{{count .AwakeningsCount}}</td><td class="num">{{minutes .AwakeningsTotalDurationInMin}}</td>
+    <td>{{clock .FinalWakeUpAt}}</td><td>{{clockPtr .OutOfBedAt}}</td><td class="num">{{duration .TotalSleepTimeInMin}}</td>
+    <td class="num">{{percent .SleepEfficiency}}</td><td>{{quality .SleepQuality}}</td><td>{{comments .Comments}}</td>
+  </tr>
+  {{else}}
+  <tr><td>{{date $day.Date}}</td><td colspan="11" class="meta">no entry</td></tr>
+  {{end}}
+  {{end}}
+</table>
+</body>
+</html>
+`))
+
+type htmlReport struct {
+	Report
+	RowHeight int
+}
+
+// WriteHTML renders the report as a self-contained printable HTML page.
+func WriteHTML(w io.Writer, report Report) error {
+	return htmlTemplate.Execute(w, htmlReport{Report: report, RowHeight: chartRowHeight - 6})
+}
+
+func hourTicks() []int {
+	ticks := make([]int, 0, 13)
+	for m := 0; m <= minutesPerDay; m += 120 {
+		ticks = append(ticks, m)
+	}
+	return ticks
+}
+
+func hourLabel(minutes int) string {
+	return fmt.Sprintf("%02d", (dayStartHour+minutes/60)%24)
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("Mon 02 Jan")
+}
+
+func formatClock(t time.Time) string {
+	return t.Format("15:04")
+}
+
+func formatClockPtr(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return formatClock(*t)
+}
+
+func formatDuration(minutes int) string {
+	return fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
+
+func formatMinutesPtr(minutes *int) string {
+	if minutes == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d min", *minutes)
+}
+
+func formatCountPtr(count *int) string {
+	if count == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d", *count)
+}
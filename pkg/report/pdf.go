@@ -0,0 +1,179 @@
+package report
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Page layout in millimeters (A4 landscape).
+const pdfMargin = 12.0
+const pdfLabelWidth = 24.0
+const pdfHourWidth = 10.0
+const pdfRowHeight = 6.0
+
+// WritePDF renders the report as an A4 landscape PDF document.
+func WritePDF(w io.Writer, report Report) error {
+	pdf := fpdf.New("L", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.Cell(0, 8, "Sleep diary report")
+	pdf.Ln(8)
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.SetTextColor(100, 100, 100)
+	pdf.Cell(0, 5, fmt.Sprintf(
+		"Account %s  |  %s - %s  |  generated %s",
+		report.AccountUuid,
+		formatDate(report.From),
+		formatDate(report.To),
+		report.GeneratedAt.Format("2006-01-02 15:04 MST")))
+	pdf.Ln(8)
+	pdf.SetTextColor(0, 0, 0)
+
+	writePdfSummary(pdf, report.Summary)
+	writePdfChart(pdf, report)
+	pdf.AddPage()
+	writePdfTable(pdf, report, tr)
+
+	return pdf.Output(w)
+}
+
+func writePdfSummary(pdf *fpdf.Fpdf, summary Summary) {
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.Cell(0, 7, "Summary")
+	pdf.Ln(7)
+
+	rows := [][2]string{
+		{"Nights recorded", fmt.Sprintf("%d of %d", summary.RecordedNights, summary.TotalNights)},
+		{"Average total sleep time", formatDuration(int(summary.AvgTotalSleepTimeInMin + 0.5))},
+		{"Average time to fall asleep", formatDuration(int(summary.AvgSleepOnsetLatencyInMin + 0.5))},
+		{"Average time awake after sleep onset", formatDuration(int(summary.AvgWakeAfterSleepOnsetInMin + 0.5))},
+		{"Average sleep efficiency", fmt.Sprintf("%.0f%%", summary.AvgSleepEfficiency)},
+		{"Average sleep quality (1-5)", fmt.Sprintf("%.1f", summary.AvgSleepQuality)},
+	}
+	pdf.SetFont("Helvetica", "", 9)
+	for _, row := range rows {
+		pdf.Cell(65, 5, row[0])
+		pdf.Cell(0, 5, row[1])
+		pdf.Ln(5)
+	}
+	pdf.Ln(4)
+}
+
+func writePdfChart(pdf *fpdf.Fpdf, report Report) {
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.Cell(0, 7, "Sleep log")
+	pdf.Ln(9)
+
+	left := pdfMargin + pdfLabelWidth
+	top := pdf.GetY() + 4
+	bottom := top + float64(len(report.Days))*pdfRowHeight
+	x := func(minutes int) float64 {
+		return left + float64(minutes)*pdfHourWidth/60
+	}
+
+	pdf.SetFont("Helvetica", "", 7)
+	pdf.SetDrawColor(210, 210, 210)
+	for _, tick := range hourTicks() {
+		pdf.Line(x(tick), top, x(tick), bottom)
+		pdf.Text(x(tick)-1.5, top-1.5, hourLabel(tick))
+	}
+
+	for i, day := range report.Days {
+		y := top + float64(i)*pdfRowHeight
+		pdf.SetDrawColor(235, 235, 235)
+		pdf.Line(left, y, x(minutesPerDay), y)
+		pdf.Text(pdfMargin, y+4, formatDate(day.Date))
+		for _, night := range day.Entries {
+			pdf.SetFillColor(198, 216, 239)
+			pdf.Rect(x(night.InBedFrom), y+1, x(night.InBedTo)-x(night.InBedFrom), pdfRowHeight-2, "F")
+			pdf.SetFillColor(47, 93, 149)
+			pdf.Rect(x(night.AsleepFrom), y+1, x(night.AsleepTo)-x(night.AsleepFrom), pdfRowHeight-2, "F")
+		}
+	}
+	pdf.SetY(bottom + 4)
+
+	pdf.SetFillColor(198, 216, 239)
+	pdf.Rect(left, pdf.GetY()+1, 4, 3, "F")
+	pdf.Text(left+5, pdf.GetY()+3.5, "In bed")
+	pdf.SetFillColor(47, 93, 149)
+	pdf.Rect(left+20, pdf.GetY()+1, 4, 3, "F")
+	pdf.Text(left+25, pdf.GetY()+3.5, "Asleep")
+}
+
+func writePdfTable(pdf *fpdf.Fpdf, report Report, tr func(string) string) {
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.Cell(0, 7, "Daily entries")
+	pdf.Ln(8)
+
+	headers := []string{"Night of", "In bed", "Tried to sleep", "Fell asleep after", "Awakenings", "Awake", "Final wake up", "Out of bed", "Total sleep", "Efficiency", "Quality", "Comments"}
+	widths := []float64{20, 14, 20, 22, 18, 15, 20, 17, 18, 16, 24, 69}
+
+	pdf.SetFont("Helvetica", "B", 8)
+	pdf.SetFillColor(238, 238, 238)
+	pdf.SetDrawColor(187, 187, 187)
+	for i, header := range headers {
+		pdf.CellFormat(widths[i], 6, header, "1", 0, "L", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 8)
+	for _, day := range report.Days {
+		if len(day.Entries) == 0 {
+			pdf.CellFormat(widths[0], 6, formatDate(day.Date), "1", 0, "L", false, 0, "")
+			pdf.SetTextColor(120, 120, 120)
+			pdf.CellFormat(sum(widths[1:]), 6, "no entry", "1", 0, "L", false, 0, "")
+			pdf.SetTextColor(0, 0, 0)
+			pdf.Ln(-1)
+			continue
+		}
+		for _, night := range day.Entries {
+			comments := ""
+			if night.Comments != nil {
+				comments = tr(*night.Comments)
+			}
+			cells := []string{
+				formatDate(day.Date),
+				formatClockPtr(night.InBedAt),
+				formatClock(night.TriedToSleepAt),
+				formatMinutesPtr(night.SleepDelayInMin),
+				formatCountPtr(night.AwakeningsCount),
+				formatMinutesPtr(night.AwakeningsTotalDurationInMin),
+				formatClock(night.FinalWakeUpAt),
+				formatClockPtr(night.OutOfBedAt),
+				formatDuration(night.TotalSleepTimeInMin),
+				fmt.Sprintf("%.0f%%", night.SleepEfficiency),
+				fmt.Sprintf("%d - %s", night.SleepQuality, night.SleepQuality.String()),
+				truncate(pdf, comments, widths[len(widths)-1]-2),
+			}
+			for i, cell := range cells {
+				pdf.CellFormat(widths[i], 6, cell, "1", 0, "L", false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+	}
+}
+
+// truncate shortens text so it fits into a table cell of given width.
+func truncate(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	for len(text) > 0 && pdf.GetStringWidth(text+"...") > width {
+		text = text[:len(text)-1]
+	}
+	return text + "..."
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
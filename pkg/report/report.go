@@ -0,0 +1,159 @@
+package report
+
+import (
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+// Number of nights covered by a report.
+const REPORT_NIGHTS = 14
+
+// Sleep log chart rows span 24 hours starting at noon, so that a night is
+// never split between two rows.
+const dayStartHour = 12
+const minutesPerDay = 24 * 60
+
+type Report struct {
+	AccountUuid string
+	From        time.Time
+	To          time.Time
+	GeneratedAt time.Time
+	Days        []Day
+	Summary     Summary
+}
+
+// Day groups entries whose sleep attempt started between noon of Date and
+// noon of the following day (in the entry's timezone).
+type Day struct {
+	Date    time.Time
+	Entries []Night
+}
+
+type Night struct {
+	api.SleepDiaryEntryDto
+	// Minutes since noon of the day; bars of the sleep log chart.
+	InBedFrom  int
+	AsleepFrom int
+	AsleepTo   int
+	InBedTo    int
+
+	TotalSleepTimeInMin int
+	TimeInBedInMin      int
+	// Percentage of time in bed spent asleep.
+	SleepEfficiency float64
+}
+
+type Summary struct {
+	RecordedNights              int
+	TotalNights                 int
+	AvgTotalSleepTimeInMin      float64
+	AvgSleepOnsetLatencyInMin   float64
+	AvgWakeAfterSleepOnsetInMin float64
+	AvgSleepEfficiency          float64
+	AvgSleepQuality             float64
+}
+
+// Build lays out entries into REPORT_NIGHTS days starting at from (date only).
+// Entries outside of the period are ignored.
+func Build(accountUuid string, from time.Time, entries []api.SleepDiaryEntryDto) Report {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	report := Report{
+		AccountUuid: accountUuid,
+		From:        start,
+		To:          start.AddDate(0, 0, REPORT_NIGHTS-1),
+		GeneratedAt: time.Now().UTC(),
+		Days:        make([]Day, REPORT_NIGHTS),
+	}
+	for i := range report.Days {
+		report.Days[i].Date = start.AddDate(0, 0, i)
+	}
+
+	for _, entry := range entries {
+		local := entry.TriedToSleepAt.Add(-dayStartHour * time.Hour)
+		date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+		index := int(date.Sub(start).Hours() / 24)
+		if index < 0 || index >= REPORT_NIGHTS {
+			continue
+		}
+		report.Days[index].Entries = append(report.Days[index].Entries, NewNight(entry))
+	}
+
+	nights := []Night{}
+	for _, day := range report.Days {
+		nights = append(nights, day.Entries...)
+	}
+	report.Summary = Summarize(nights, len(report.Days))
+	return report
+}
+
+// NewNight derives sleep measures of an entry and lays it out on the chart
+// row of its day.
+func NewNight(entry api.SleepDiaryEntryDto) Night {
+	local := entry.TriedToSleepAt.Add(-dayStartHour * time.Hour)
+	noon := time.Date(local.Year(), local.Month(), local.Day(), dayStartHour, 0, 0, 0, entry.TriedToSleepAt.Location())
+	minutesSinceNoon := func(t time.Time) int {
+		return clamp(int(t.Sub(noon)/time.Minute), 0, minutesPerDay)
+	}
+
+	sol := valueOrZero(entry.SleepDelayInMin)
+	waso := valueOrZero(entry.AwakeningsTotalDurationInMin)
+	inBedAt := entry.TriedToSleepAt
+	if entry.InBedAt != nil {
+		inBedAt = *entry.InBedAt
+	}
+	outOfBedAt := entry.FinalWakeUpAt
+	if entry.OutOfBedAt != nil {
+		outOfBedAt = *entry.OutOfBedAt
+	}
+	asleepAt := entry.TriedToSleepAt.Add(time.Duration(sol) * time.Minute)
+
+	night := Night{
+		SleepDiaryEntryDto:  entry,
+		InBedFrom:           minutesSinceNoon(inBedAt),
+		AsleepFrom:          minutesSinceNoon(asleepAt),
+		AsleepTo:            minutesSinceNoon(entry.FinalWakeUpAt),
+		InBedTo:             minutesSinceNoon(outOfBedAt),
+		TotalSleepTimeInMin: max(int(entry.FinalWakeUpAt.Sub(entry.TriedToSleepAt)/time.Minute)-sol-waso, 0),
+		TimeInBedInMin:      int(outOfBedAt.Sub(inBedAt) / time.Minute),
+	}
+	if night.TimeInBedInMin > 0 {
+		night.SleepEfficiency = 100 * float64(night.TotalSleepTimeInMin) / float64(night.TimeInBedInMin)
+	}
+	return night
+}
+
+// Summarize averages sleep measures of recorded nights out of totalNights.
+func Summarize(nights []Night, totalNights int) Summary {
+	summary := Summary{TotalNights: totalNights}
+	var tst, sol, waso, se, quality float64
+
+	for _, night := range nights {
+		summary.RecordedNights++
+		tst += float64(night.TotalSleepTimeInMin)
+		sol += float64(valueOrZero(night.SleepDelayInMin))
+		waso += float64(valueOrZero(night.AwakeningsTotalDurationInMin))
+		se += night.SleepEfficiency
+		quality += float64(night.SleepQuality)
+	}
+
+	if n := float64(summary.RecordedNights); n > 0 {
+		summary.AvgTotalSleepTimeInMin = tst / n
+		summary.AvgSleepOnsetLatencyInMin = sol / n
+		summary.AvgWakeAfterSleepOnsetInMin = waso / n
+		summary.AvgSleepEfficiency = se / n
+		summary.AvgSleepQuality = quality / n
+	}
+	return summary
+}
+
+func valueOrZero(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+func clamp(value, low, high int) int {
+	return min(max(value, low), high)
+}
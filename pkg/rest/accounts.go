@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/archive"
+	"github.com/mabzd/snorlax/pkg/auth"
+)
+
+func deleteAccount(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mode := api.AccountDeletionMode(r.URL.Query().Get("mode"))
+		if mode == "" {
+			mode = api.DeleteAccountDeletionMode
+		}
+
+		receipt, serviceErr := service.DeleteAccount(auth.Caller(r.Context()), r.PathValue("account_uuid"), mode)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, receipt)
+	}
+}
+
+func verifyDeletionReceipt(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid request body", err)
+			return
+		}
+		defer r.Body.Close()
+
+		var receipt api.AccountDeletionReceiptDto
+		if jsonErr := unmarshalJson(r, body, &receipt); jsonErr != nil {
+			respondWithApiError(w, jsonErr)
+			return
+		}
+
+		if serviceErr := service.VerifyDeletionReceipt(receipt); serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func exportAccount(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountUuid := r.PathValue("account_uuid")
+		export, serviceErr := service.ExportAccount(auth.Caller(r.Context()), accountUuid)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		var content bytes.Buffer
+		if err := archive.WriteAccountArchive(&content, export); err != nil {
+			respondWithError(w, api.ERR_UNKNOWN, "archive rendering failed", err)
+			return
+		}
+
+		fileName := fmt.Sprintf("account-%s-%s.zip", accountUuid, export.ExportedAt.Format("2006-01-02"))
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+		w.WriteHeader(http.StatusOK)
+		w.Write(content.Bytes())
+	}
+}
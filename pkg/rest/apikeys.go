@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+)
+
+func createApiKey(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid request body", err)
+			return
+		}
+		defer r.Body.Close()
+
+		var dto api.CreateApiKeyDto
+		if jsonErr := unmarshalJson(r, body, &dto); jsonErr != nil {
+			respondWithApiError(w, jsonErr)
+			return
+		}
+
+		result, serviceErr := service.CreateApiKey(auth.Caller(r.Context()), dto)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusCreated, result)
+	}
+}
+
+func getApiKeys(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, serviceErr := service.GetApiKeys(auth.Caller(r.Context()))
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, keys)
+	}
+}
+
+func rotateApiKey(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid ID format", err)
+			return
+		}
+
+		result, serviceErr := service.RotateApiKey(auth.Caller(r.Context()), id)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, result)
+	}
+}
+
+func revokeApiKey(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid ID format", err)
+			return
+		}
+
+		if serviceErr := service.RevokeApiKey(auth.Caller(r.Context()), id); serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
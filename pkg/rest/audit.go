@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+)
+
+func getAuditLog(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		entryId, err := parseInt64QueryParam(query.Get("entry_id"))
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid entry_id format", err)
+			return
+		}
+
+		fromDate, err := parseTimeQueryParam(query.Get("from_date"))
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid from_date format", err)
+			return
+		}
+
+		toDate, err := parseTimeQueryParam(query.Get("to_date"))
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid to_date format", err)
+			return
+		}
+
+		pageSize, err := parseInt64QueryParam(query.Get("page_size"))
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid page_size format", err)
+			return
+		}
+
+		pageNumber, err := parseInt64QueryParam(query.Get("page_number"))
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid page_number format", err)
+			return
+		}
+
+		filter := api.AuditLogFilterDto{
+			Subject:     query.Get("subject"),
+			AccountUuid: query.Get("account_uuid"),
+			EntryId:     entryId,
+			TraceId:     query.Get("trace_id"),
+			FromDate:    fromDate,
+			ToDate:      toDate,
+			PageSize:    withDefault(pageSize, api.DEFAULT_PAGE_SIZE),
+			PageNumber:  withDefault(pageNumber, 1),
+		}
+		if action := query.Get("action"); action != "" {
+			filter.Action = toPtr(api.AuditAction(action))
+		}
+
+		events, serviceErr := service.GetAuditLog(auth.Caller(r.Context()), filter)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, events)
+	}
+}
+
+func verifyAuditLog(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, serviceErr := service.VerifyAuditLog(auth.Caller(r.Context()))
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"cmp"
+	"log"
+	"net/http"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+)
+
+// Routes served without authentication.
+var publicRoutes = map[string]bool{
+	"GET /openapi.json": true,
+}
+
+// API_KEY_HEADER carries API keys of server-to-server integrations.
+const API_KEY_HEADER = auth.API_KEY_HEADER
+
+// Scopes an API key needs for each route; routes not listed require the
+// admin scope, which allows all routes.
+var apiKeyScopes = map[string]string{
+	"GET /sleep_diary/entries/{id}":                         api.SCOPE_READ_ENTRIES,
+	"GET /sleep_diary/entries":                              api.SCOPE_READ_ENTRIES,
+	"POST /sleep_diary/entries":                             api.SCOPE_WRITE_ENTRIES,
+	"PUT /sleep_diary/entries/{id}":                         api.SCOPE_WRITE_ENTRIES,
+	"DELETE /sleep_diary/entries/{id}":                      api.SCOPE_WRITE_ENTRIES,
+	"GET /sleep_diary/entries/{id}/hypnogram":               api.SCOPE_READ_ENTRIES,
+	"PUT /sleep_diary/entries/{id}/hypnogram":               api.SCOPE_WRITE_ENTRIES,
+	"GET /sleep_diary/accounts/{account_uuid}/calendar.ics": api.SCOPE_EXPORT,
+	"GET /sleep_diary/accounts/{account_uuid}/report":       api.SCOPE_EXPORT,
+	"GET /sleep_diary/accounts/{account_uuid}/export":       api.SCOPE_EXPORT,
+	"POST /sleep_diary/imports/{source}":                    api.SCOPE_WRITE_ENTRIES,
+	"GET /sleep_diary/drafts":                               api.SCOPE_READ_ENTRIES,
+	"POST /sleep_diary/drafts/{id}/confirm":                 api.SCOPE_WRITE_ENTRIES,
+	"DELETE /sleep_diary/drafts/{id}":                       api.SCOPE_WRITE_ENTRIES,
+	"GET /sleep_diary/changes/stream":                       api.SCOPE_READ_ENTRIES,
+	"GET /sleep_diary/sync":                                 api.SCOPE_READ_ENTRIES,
+	"POST /sleep_diary/sync":                                api.SCOPE_WRITE_ENTRIES,
+	"GET /sleep_diary/grants/received":                      api.SCOPE_READ_ENTRIES,
+	"POST /graphql":                                         api.SCOPE_READ_ENTRIES,
+}
+
+// newAuthMiddleware returns the middleware putting the principal of a
+// request to a route on its context, as told by the authenticator of cfg. It
+// returns nil when none is configured and callers are trusted.
+func newAuthMiddleware(cfg config.Config, svc *service.SleepDiaryService) func(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	authenticator, err := auth.NewAuthenticatorFromConfig(cfg, svc.AuthenticateApiKey)
+	if err != nil {
+		log.Fatalf("Invalid authentication config: %v", err)
+	}
+	if authenticator == nil {
+		return nil
+	}
+	return func(pattern string, next http.HandlerFunc) http.HandlerFunc {
+		return withAuth(authenticator, cmp.Or(apiKeyScopes[pattern], api.SCOPE_ADMIN), next)
+	}
+}
+
+// withAuth requires valid credentials and puts their principal on the
+// request context. API keys must have given scope.
+func withAuth(authenticator *auth.Authenticator, scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, authErr := authenticator.Authenticate(r.Context(), r.Header.Get, scope)
+		if authErr != nil {
+			if authErr.Challenge != "" {
+				w.Header().Set("WWW-Authenticate", authErr.Challenge)
+			}
+			respondWithError(w, authErr.Code, authErr.Message, authErr.Err)
+			return
+		}
+		next(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+	}
+}
@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+	"github.com/mabzd/snorlax/pkg/ical"
+)
+
+func getSleepCalendar(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller := auth.Caller(r.Context())
+		accountUuid := r.PathValue("account_uuid")
+		query := r.URL.Query()
+
+		fromDate, err := parseTimeQueryParam(query.Get("from_date"))
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid from_date format", err)
+			return
+		}
+
+		toDate, err := parseTimeQueryParam(query.Get("to_date"))
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid to_date format", err)
+			return
+		}
+
+		entries, serviceErr := service.GetAllEntriesByAccount(caller, accountUuid, fromDate, toDate)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		var calendar bytes.Buffer
+		if err := ical.WriteSleepCalendar(&calendar, fmt.Sprintf("Sleep diary %s", accountUuid), entries); err != nil {
+			respondWithError(w, api.ERR_UNKNOWN, "calendar rendering failed", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="calendar.ics"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write(calendar.Bytes())
+	}
+}
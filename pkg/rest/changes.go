@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+)
+
+// Interval of comments sent to keep idle streams open through proxies.
+const SSE_KEEPALIVE_INTERVAL = 15 * time.Second
+
+// streamEntryChanges pushes changes of an account as Server-Sent Events. A
+// client resuming with Last-Event-ID first receives changes it missed from
+// the change log; a new client receives changes made after it connected.
+func streamEntryChanges(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller := auth.Caller(r.Context())
+		accountUuid := r.URL.Query().Get("account_uuid")
+
+		lastEventId := r.Header.Get("Last-Event-ID")
+		if lastEventId == "" {
+			lastEventId = r.URL.Query().Get("last_event_id")
+		}
+		lastId, err := parseInt64QueryParam(lastEventId)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid Last-Event-ID", err)
+			return
+		}
+
+		// Subscribe before reading the change log, so that no change
+		// committed in between is missed.
+		signal, cancel, serviceErr := service.SubscribeEntryChanges(caller, accountUuid)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+		defer cancel()
+
+		if lastId == nil {
+			latestId, serviceErr := service.GetLatestEntryChangeId(caller, accountUuid)
+			if serviceErr != nil {
+				respondWithApiError(w, serviceErr)
+				return
+			}
+			lastId = &latestId
+		}
+
+		// The stream outlives the server write timeout.
+		controller := http.NewResponseController(w)
+		controller.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		controller.Flush()
+
+		keepalive := time.NewTicker(SSE_KEEPALIVE_INTERVAL)
+		defer keepalive.Stop()
+
+		for {
+			changes, serviceErr := service.GetEntryChanges(caller, accountUuid, *lastId)
+			if serviceErr != nil {
+				writeSseEvent(w, 0, "error", serviceErr.ToErrorDto())
+				controller.Flush()
+				return
+			}
+			for _, change := range changes {
+				writeSseEvent(w, change.Id, string(change.Type), change)
+				*lastId = change.Id
+			}
+			if err := controller.Flush(); err != nil {
+				return
+			}
+			if len(changes) > 0 {
+				// Read on until the change log is drained.
+				continue
+			}
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-signal:
+			case <-keepalive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+			}
+		}
+	}
+}
+
+func writeSseEvent(w http.ResponseWriter, id int64, event string, payload any) {
+	data, _ := json.Marshal(payload)
+	if id > 0 {
+		fmt.Fprintf(w, "id: %s\n", strconv.FormatInt(id, 10))
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
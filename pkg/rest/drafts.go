@@ -0,0 +1,121 @@
+package rest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+	"github.com/mabzd/snorlax/pkg/importer"
+)
+
+func importSleepDiaryDrafts(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		source := api.ImportSource(r.PathValue("source"))
+		query := r.URL.Query()
+		accountUuid := query.Get("account_uuid")
+
+		timezone := query.Get("timezone")
+		if timezone == "" {
+			timezone = "UTC"
+		}
+
+		tz, err := time.LoadLocation(timezone)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid timezone", err)
+			return
+		}
+		defer r.Body.Close()
+
+		maxSize, maxExtractedSize := service.ImportMaxSizes()
+		body := http.MaxBytesReader(w, r.Body, maxSize)
+		entries, err := importer.Parse(source, body, accountUuid, tz, maxExtractedSize)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) || errors.Is(err, importer.ErrTooLarge) {
+			respondWithError(w, api.ERR_INVALID, "import file too large", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid import file", err)
+			return
+		}
+
+		result, serviceErr := service.ImportDrafts(auth.Caller(r.Context()), api.ImportDto{
+			AccountUuid: accountUuid,
+			Source:      source,
+			Entries:     entries,
+		})
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusCreated, result)
+	}
+}
+
+func getSleepDiaryDrafts(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		drafts, serviceErr := service.GetDraftsByAccount(auth.Caller(r.Context()), r.URL.Query().Get("account_uuid"))
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, drafts)
+	}
+}
+
+func confirmSleepDiaryDraft(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid ID format", err)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid request body", err)
+			return
+		}
+		defer r.Body.Close()
+
+		var dto api.ConfirmSleepDiaryDraftDto
+		if jsonErr := unmarshalJson(r, body, &dto); jsonErr != nil {
+			respondWithApiError(w, jsonErr)
+			return
+		}
+
+		result, serviceErr := service.ConfirmDraft(auth.Caller(r.Context()), id, dto)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusCreated, result)
+	}
+}
+
+func deleteSleepDiaryDraft(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid ID format", err)
+			return
+		}
+
+		if serviceErr := service.DeleteDraft(auth.Caller(r.Context()), id); serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+)
+
+func createGrant(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid request body", err)
+			return
+		}
+		defer r.Body.Close()
+
+		var dto api.CreateGrantDto
+		if jsonErr := unmarshalJson(r, body, &dto); jsonErr != nil {
+			respondWithApiError(w, jsonErr)
+			return
+		}
+
+		result, serviceErr := service.CreateGrant(auth.Caller(r.Context()), dto)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusCreated, result)
+	}
+}
+
+func getGrants(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountUuid := r.URL.Query().Get("account_uuid")
+		grants, serviceErr := service.GetGrantsByAccount(auth.Caller(r.Context()), accountUuid)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, grants)
+	}
+}
+
+func getReceivedGrants(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		grants, serviceErr := service.GetReceivedGrants(auth.Caller(r.Context()))
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, grants)
+	}
+}
+
+func revokeGrant(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid ID format", err)
+			return
+		}
+
+		if serviceErr := service.RevokeGrant(auth.Caller(r.Context()), id); serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
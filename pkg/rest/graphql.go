@@ -0,0 +1,42 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/gql"
+)
+
+func executeGraphQL(service *service.SleepDiaryService) http.HandlerFunc {
+	executor, schemaErr := gql.NewExecutor(service)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if schemaErr != nil {
+			respondWithError(w, api.ERR_UNKNOWN, "GraphQL schema is invalid", schemaErr)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid request body", err)
+			return
+		}
+		defer r.Body.Close()
+
+		var dto api.GraphQLRequestDto
+		if jsonErr := unmarshalJson(r, body, &dto); jsonErr != nil {
+			respondWithApiError(w, jsonErr)
+			return
+		}
+		if errors := dto.Validate(); len(errors) > 0 {
+			respondWithApiError(w, api.NewValidationError("invalid GraphQL request", errors))
+			return
+		}
+
+		// Query errors are part of the GraphQL result, so the status is 200
+		// whenever the query was executed or rejected by its limits.
+		respondWithJSON(w, http.StatusOK, executor.Execute(r.Context(), dto))
+	}
+}
@@ -12,18 +12,19 @@ import (
 
 	"github.com/mabzd/snorlax/api"
 	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
 )
 
 func getSleepDiaryEntry(service *service.SleepDiaryService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		idStr := r.PathValue("id")
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			respondWithError(w, api.ERR_INVALID, "invalid ID format", err)
+		caller := auth.Caller(r.Context())
+		id, serviceErr := service.ResolveEntryId(caller, r.PathValue("id"))
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
 			return
 		}
 
-		dto, serviceErr := service.GetEntryById(id)
+		dto, serviceErr := service.GetEntryById(caller, id)
 		if serviceErr != nil {
 			respondWithApiError(w, serviceErr)
 			return
@@ -35,6 +36,7 @@ func getSleepDiaryEntry(service *service.SleepDiaryService) http.HandlerFunc {
 
 func getSleepDiaryEntries(service *service.SleepDiaryService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		caller := auth.Caller(r.Context())
 		query := r.URL.Query()
 		accountUuids := query["account_uuid"]
 
@@ -70,7 +72,7 @@ func getSleepDiaryEntries(service *service.SleepDiaryService) http.HandlerFunc {
 			PageNumber:  withDefault(pageNumber, 1),
 		}
 
-		entries, serviceErr := service.GetEntriesByFilter(filter)
+		entries, serviceErr := service.GetEntriesByFilter(caller, filter)
 		if serviceErr != nil {
 			respondWithApiError(w, serviceErr)
 			return
@@ -82,6 +84,7 @@ func getSleepDiaryEntries(service *service.SleepDiaryService) http.HandlerFunc {
 
 func createSleepDiaryEntry(service *service.SleepDiaryService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		caller := auth.Caller(r.Context())
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
 			respondWithError(w, api.ERR_INVALID, "invalid request body", err)
@@ -90,12 +93,12 @@ func createSleepDiaryEntry(service *service.SleepDiaryService) http.HandlerFunc
 		defer r.Body.Close()
 
 		var dto api.CreateSleepDiaryEntryDto
-		if err := json.Unmarshal(body, &dto); err != nil {
-			respondWithError(w, api.ERR_INVALID, "invalid JSON format", err)
+		if jsonErr := unmarshalJson(r, body, &dto); jsonErr != nil {
+			respondWithApiError(w, jsonErr)
 			return
 		}
 
-		result, serviceErr := service.CreateEntry(dto)
+		result, serviceErr := service.CreateEntry(caller, dto)
 		if serviceErr != nil {
 			respondWithApiError(w, serviceErr)
 			return
@@ -107,10 +110,10 @@ func createSleepDiaryEntry(service *service.SleepDiaryService) http.HandlerFunc
 
 func updateSleepDiaryEntry(service *service.SleepDiaryService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		idStr := r.PathValue("id")
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			respondWithError(w, api.ERR_INVALID, "invalid ID format", err)
+		caller := auth.Caller(r.Context())
+		id, serviceErr := service.ResolveEntryId(caller, r.PathValue("id"))
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
 			return
 		}
 
@@ -122,12 +125,12 @@ func updateSleepDiaryEntry(service *service.SleepDiaryService) http.HandlerFunc
 		defer r.Body.Close()
 
 		var dto api.UpdateSleepDiaryEntryDto
-		if err := json.Unmarshal(body, &dto); err != nil {
-			respondWithError(w, api.ERR_INVALID, "invalid JSON format", err)
+		if jsonErr := unmarshalJson(r, body, &dto); jsonErr != nil {
+			respondWithApiError(w, jsonErr)
 			return
 		}
 
-		result, serviceErr := service.UpdateEntry(id, dto)
+		result, serviceErr := service.UpdateEntry(caller, id, dto)
 		if serviceErr != nil {
 			respondWithApiError(w, serviceErr)
 			return
@@ -137,6 +140,24 @@ func updateSleepDiaryEntry(service *service.SleepDiaryService) http.HandlerFunc
 	}
 }
 
+func deleteSleepDiaryEntry(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller := auth.Caller(r.Context())
+		id, serviceErr := service.ResolveEntryId(caller, r.PathValue("id"))
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		if serviceErr := service.DeleteEntry(caller, id); serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
 func parseTimeQueryParam(param string) (*time.Time, error) {
 	if param == "" {
 		return nil, nil
@@ -186,6 +207,12 @@ func toHttpError(code api.ErrorCode) int {
 		return http.StatusConflict
 	case api.ERR_INVALID:
 		return http.StatusBadRequest
+	case api.ERR_UNAUTHORIZED:
+		return http.StatusUnauthorized
+	case api.ERR_FORBIDDEN:
+		return http.StatusForbidden
+	case api.ERR_RATE_LIMITED:
+		return http.StatusTooManyRequests
 	default:
 		return http.StatusInternalServerError
 	}
@@ -197,3 +224,7 @@ func withDefault[T any](value *T, defaultValue T) T {
 	}
 	return *value
 }
+
+func toPtr[T any](value T) *T {
+	return &value
+}
@@ -0,0 +1,61 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+)
+
+func getHypnogram(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller := auth.Caller(r.Context())
+		id, serviceErr := service.ResolveEntryId(caller, r.PathValue("id"))
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		dto, serviceErr := service.GetHypnogram(caller, id)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, dto)
+	}
+}
+
+func uploadHypnogram(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller := auth.Caller(r.Context())
+		id, serviceErr := service.ResolveEntryId(caller, r.PathValue("id"))
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid request body", err)
+			return
+		}
+		defer r.Body.Close()
+
+		var dto api.UploadHypnogramDto
+		if jsonErr := unmarshalJson(r, body, &dto); jsonErr != nil {
+			respondWithApiError(w, jsonErr)
+			return
+		}
+
+		result, serviceErr := service.ReplaceHypnogram(caller, id, dto)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, result)
+	}
+}
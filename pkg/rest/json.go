@@ -0,0 +1,215 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+)
+
+type strictJsonKey struct{}
+
+// withStrictJson sets the default JSON decoding mode of requests. A request
+// can override it with "Prefer: handling=strict" or "Prefer: handling=lenient"
+// (RFC 7240).
+func withStrictJson(strict bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		strict := strict
+		for _, value := range r.Header.Values("Prefer") {
+			for _, preference := range strings.Split(value, ",") {
+				switch strings.TrimSpace(preference) {
+				case "handling=strict":
+					strict = true
+				case "handling=lenient":
+					strict = false
+				}
+			}
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), strictJsonKey{}, strict)))
+	}
+}
+
+// unmarshalJson decodes a JSON request body into dto. In strict mode fields
+// that dto does not define are rejected instead of being ignored. Decoding
+// failures are reported as validation errors pointing at the offending field.
+func unmarshalJson(r *http.Request, body []byte, dto any) api.Error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if strict, _ := r.Context().Value(strictJsonKey{}).(bool); strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	err := decoder.Decode(dto)
+	if err == nil && decoder.More() {
+		err = &json.SyntaxError{Offset: decoder.InputOffset()}
+	}
+	if err == nil {
+		return nil
+	}
+	return api.NewValidationError("invalid JSON format", []error{toFieldError(err, body, reflect.TypeOf(dto))})
+}
+
+// toFieldError converts a decoding error of body into type t into a field
+// error.
+func toFieldError(err error, body []byte, t reflect.Type) api.FieldErrorDto {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var timeErr *time.ParseError
+
+	switch {
+	case errors.As(err, &typeErr):
+		field := "/" + strings.ReplaceAll(typeErr.Field, ".", "/")
+		expected := jsonTypeName(typeErr.Type)
+		return api.NewFieldError(field, api.RULE_TYPE, map[string]any{"type": expected}, "%s should be %s", typeErr.Field, expected)
+	case errors.As(err, &syntaxErr):
+		return api.NewFieldError("", api.RULE_SYNTAX, map[string]any{"offset": syntaxErr.Offset}, "malformed JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &timeErr):
+		return api.NewFieldError("", api.RULE_FORMAT, map[string]any{"format": "date-time", "value": timeErr.Value}, "invalid time '%s', expected RFC 3339 format", timeErr.Value)
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return api.NewFieldError("", api.RULE_SYNTAX, nil, "unexpected end of JSON input")
+	}
+
+	// encoding/json reports unknown fields only with a plain error naming
+	// the field, so the path to it is found in body again.
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		name = strings.Trim(name, `"`)
+		pointer, found := findUnknownField(json.NewDecoder(bytes.NewReader(body)), t, "")
+		if !found {
+			pointer = "/" + escapeJsonPointer(name)
+		}
+		return api.NewFieldError(pointer, api.RULE_UNKNOWN_FIELD, nil, "%s is not a recognized field", name)
+	}
+	return api.NewFieldError("", api.RULE_TYPE, nil, "%s", err.Error())
+}
+
+var jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// findUnknownField returns the JSON pointer of the first field of the next
+// value of decoder, in document order, that type t does not define, as
+// encoding/json finds it with DisallowUnknownFields. Values decoded by
+// their own UnmarshalJSON, into maps or into interfaces are not checked.
+func findUnknownField(decoder *json.Decoder, t reflect.Type, pointer string) (string, bool) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t != nil && reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		t = nil
+	}
+
+	token, err := decoder.Token()
+	if err != nil {
+		return "", false
+	}
+	switch token {
+	case json.Delim('{'):
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return "", false
+			}
+			key, _ := token.(string)
+			fieldPointer := pointer + "/" + escapeJsonPointer(key)
+			var fieldType reflect.Type
+			if t != nil && t.Kind() == reflect.Struct {
+				var ok bool
+				if fieldType, ok = jsonFieldType(t, key); !ok {
+					return fieldPointer, true
+				}
+			}
+			if found, ok := findUnknownField(decoder, fieldType, fieldPointer); ok {
+				return found, true
+			}
+		}
+		decoder.Token()
+	case json.Delim('['):
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+		for i := 0; decoder.More(); i++ {
+			if found, ok := findUnknownField(decoder, elemType, fmt.Sprintf("%s/%d", pointer, i)); ok {
+				return found, true
+			}
+		}
+		decoder.Token()
+	}
+	return "", false
+}
+
+// jsonFieldType returns the type of the field of struct type t that
+// encoding/json decodes key into: the one named so exactly, or else
+// regardless of case, looking into embedded structs after direct fields.
+func jsonFieldType(t reflect.Type, key string) (reflect.Type, bool) {
+	var folded reflect.Type
+	var embedded []reflect.Type
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embeddedType := field.Type
+			if embeddedType.Kind() == reflect.Pointer {
+				embeddedType = embeddedType.Elem()
+			}
+			if embeddedType.Kind() == reflect.Struct {
+				embedded = append(embedded, embeddedType)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if name == key {
+			return field.Type, true
+		}
+		if folded == nil && strings.EqualFold(name, key) {
+			folded = field.Type
+		}
+	}
+	if folded != nil {
+		return folded, true
+	}
+	for _, embeddedType := range embedded {
+		if fieldType, ok := jsonFieldType(embeddedType, key); ok {
+			return fieldType, true
+		}
+	}
+	return nil, false
+}
+
+func escapeJsonPointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
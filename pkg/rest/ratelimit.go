@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/pkg/ratelimit"
+)
+
+// rateLimitClassOf returns the class of a route: the one of the API key
+// scope it requires, or reads for other GET routes and writes for the rest.
+// Research exports require the admin scope but count as exports.
+func rateLimitClassOf(pattern string) ratelimit.Class {
+	if pattern == "GET /research_export" {
+		return ratelimit.ExportsClass
+	}
+	switch apiKeyScopes[pattern] {
+	case api.SCOPE_READ_ENTRIES:
+		return ratelimit.ReadsClass
+	case api.SCOPE_WRITE_ENTRIES:
+		return ratelimit.WritesClass
+	case api.SCOPE_EXPORT:
+		return ratelimit.ExportsClass
+	}
+	if strings.HasPrefix(pattern, http.MethodGet+" ") {
+		return ratelimit.ReadsClass
+	}
+	return ratelimit.WritesClass
+}
+
+// limitRoute rejects requests to a route with ERR_RATE_LIMITED once the
+// caller has used up the limit of the route class.
+func limitRoute(limiter *ratelimit.Limiter, pattern string, next http.HandlerFunc) http.HandlerFunc {
+	class := rateLimitClassOf(pattern)
+	if !limiter.Limits(class) {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		decision, ok := limiter.Take(class, ratelimit.CallerKey(r.Context(), r.RemoteAddr))
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		setRateLimitHeaders(w, decision)
+		if !decision.Result.Allowed {
+			respondWithError(w, api.ERR_RATE_LIMITED, decision.Message(), nil)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// limitAuthFailures wraps authentication of a route, rejecting requests
+// from an IP address with ERR_RATE_LIMITED once it has used up the limit of
+// failed authentications. Only requests failing authentication take a
+// token, so that guessing credentials is limited without limiting callers
+// that authenticate.
+func limitAuthFailures(limiter *ratelimit.Limiter, next http.HandlerFunc) http.HandlerFunc {
+	if !limiter.Limits(ratelimit.AuthFailuresClass) {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := ratelimit.IpKey(r.RemoteAddr)
+		decision, ok := limiter.Peek(ratelimit.AuthFailuresClass, key)
+		if !ok {
+			next(w, r)
+			return
+		}
+		if !decision.Result.Allowed {
+			setRateLimitHeaders(w, decision)
+			respondWithError(w, api.ERR_RATE_LIMITED, decision.Message(), nil)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next(recorder, r)
+		// Only authentication responds with ERR_UNAUTHORIZED.
+		if recorder.status == http.StatusUnauthorized {
+			limiter.Take(ratelimit.AuthFailuresClass, key)
+		}
+	}
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, decision ratelimit.Decision) {
+	for name, value := range decision.Headers() {
+		w.Header().Set(name, value)
+	}
+}
+
+// statusRecorder remembers the status code written to the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController flush streamed responses.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+	"github.com/mabzd/snorlax/pkg/report"
+)
+
+func getSleepReport(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller := auth.Caller(r.Context())
+		accountUuid := r.PathValue("account_uuid")
+		query := r.URL.Query()
+
+		format := query.Get("format")
+		if format == "" {
+			format = "html"
+		}
+		if format != "html" && format != "pdf" {
+			respondWithError(w, api.ERR_INVALID, "format should be html or pdf", nil)
+			return
+		}
+
+		fromDate, err := parseTimeQueryParam(query.Get("from_date"))
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid from_date format", err)
+			return
+		}
+		if fromDate == nil {
+			fromDate = toPtr(time.Now().UTC().AddDate(0, 0, -report.REPORT_NIGHTS))
+		}
+
+		// Entries are fetched with a day of margin on both sides since nights
+		// are assigned to days in the entry's own timezone.
+		from := fromDate.Truncate(24*time.Hour).AddDate(0, 0, -1)
+		to := from.AddDate(0, 0, report.REPORT_NIGHTS+2)
+		entries, serviceErr := service.GetAllEntriesByAccount(caller, accountUuid, &from, &to)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		sleepReport := report.Build(accountUuid, *fromDate, entries)
+		fileName := fmt.Sprintf("sleep-report-%s.%s", sleepReport.From.Format("2006-01-02"), format)
+
+		var content bytes.Buffer
+		contentType := "text/html; charset=utf-8"
+		if format == "pdf" {
+			contentType = "application/pdf"
+			err = report.WritePDF(&content, sleepReport)
+		} else {
+			err = report.WriteHTML(&content, sleepReport)
+		}
+		if err != nil {
+			respondWithError(w, api.ERR_UNKNOWN, "report rendering failed", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, fileName))
+		w.WriteHeader(http.StatusOK)
+		w.Write(content.Bytes())
+	}
+}
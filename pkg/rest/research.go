@@ -0,0 +1,61 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/archive"
+	"github.com/mabzd/snorlax/pkg/auth"
+)
+
+func exportResearchDataset(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		fromDate, err := parseTimeQueryParam(query.Get("from_date"))
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid from_date format", err)
+			return
+		}
+
+		toDate, err := parseTimeQueryParam(query.Get("to_date"))
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid to_date format", err)
+			return
+		}
+
+		comments := api.ResearchCommentsMode(query.Get("comments_mode"))
+		if comments == "" {
+			comments = api.StripResearchCommentsMode
+		}
+
+		filter := api.ResearchExportFilterDto{
+			StudyId:     query.Get("study_id"),
+			AccountUuid: query["account_uuid"],
+			FromDate:    fromDate,
+			ToDate:      toDate,
+			Comments:    comments,
+		}
+
+		dataset, serviceErr := service.ExportResearchDataset(auth.Caller(r.Context()), filter)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		var content bytes.Buffer
+		if err := archive.WriteResearchArchive(&content, dataset); err != nil {
+			respondWithError(w, api.ERR_UNKNOWN, "archive rendering failed", err)
+			return
+		}
+
+		fileName := fmt.Sprintf("research-%s.zip", dataset.ExportedAt.Format("2006-01-02"))
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+		w.WriteHeader(http.StatusOK)
+		w.Write(content.Bytes())
+	}
+}
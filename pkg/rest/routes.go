@@ -0,0 +1,474 @@
+package rest
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/openapi"
+)
+
+const API_VERSION = "1.0.0"
+
+type route struct {
+	handler func(service *service.SleepDiaryService) http.HandlerFunc
+	openapi.Operation
+}
+
+var idParam = openapi.PathParam[int64]("id", "")
+var entryIdParam = openapi.PathParam[string]("id", "Numeric ID or UUID of the entry")
+var accountUuidPathParam = openapi.PathParam[string]("account_uuid", "")
+
+// routes returns all API routes along with their OpenAPI description; both
+// the server mux and the spec served at /openapi.json are built from it.
+func routes() []route {
+	return []route{
+		{getSleepDiaryEntry, openapi.Operation{
+			Pattern:    "GET /sleep_diary/entries/{id}",
+			Id:         "getSleepDiaryEntry",
+			Summary:    "Read entry by ID",
+			Parameters: []openapi.Parameter{entryIdParam},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[api.SleepDiaryEntryDto]()}}},
+				api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{getSleepDiaryEntries, openapi.Operation{
+			Pattern: "GET /sleep_diary/entries",
+			Id:      "getSleepDiaryEntries",
+			Summary: "Read entries by filter",
+			Parameters: []openapi.Parameter{
+				openapi.QueryParam[[]string]("account_uuid", "May be repeated to read entries of several accounts", true),
+				openapi.QueryParam[time.Time]("from_date", "Entries with tried_to_sleep_at at or after this time", false),
+				openapi.QueryParam[time.Time]("to_date", "Entries with tried_to_sleep_at before this time", false),
+				openapi.QueryParam[int64]("page_size", "", false),
+				openapi.QueryParam[int64]("page_number", "", false),
+			},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[api.PageDto[api.SleepDiaryEntryDto]]()}}},
+				api.ERR_INVALID, api.ERR_UNKNOWN),
+		}},
+		{createSleepDiaryEntry, openapi.Operation{
+			Pattern:     "POST /sleep_diary/entries",
+			Id:          "createSleepDiaryEntry",
+			Summary:     "Create entry",
+			Description: "The entry UUID may be generated by the client; creating an entry with a UUID already taken fails with ERR_CONFLICT.",
+			Request:     toPtr(openapi.JsonBody[api.CreateSleepDiaryEntryDto]()),
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusCreated, Bodies: []openapi.Body{openapi.JsonBody[api.SleepDiaryEntryDto]()}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_CONFLICT, api.ERR_UNKNOWN),
+		}},
+		{updateSleepDiaryEntry, openapi.Operation{
+			Pattern:     "PUT /sleep_diary/entries/{id}",
+			Id:          "updateSleepDiaryEntry",
+			Summary:     "Update entry",
+			Description: "When version is given, the update fails with ERR_CONFLICT if the entry was modified in the meantime.",
+			Parameters:  []openapi.Parameter{entryIdParam},
+			Request:     toPtr(openapi.JsonBody[api.UpdateSleepDiaryEntryDto]()),
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[api.SleepDiaryEntryDto]()}}},
+				api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_FORBIDDEN, api.ERR_CONFLICT, api.ERR_UNKNOWN),
+		}},
+		{deleteSleepDiaryEntry, openapi.Operation{
+			Pattern:    "DELETE /sleep_diary/entries/{id}",
+			Id:         "deleteSleepDiaryEntry",
+			Summary:    "Delete entry along with its sleep stages",
+			Parameters: []openapi.Parameter{entryIdParam},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusNoContent}},
+				api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_FORBIDDEN, api.ERR_UNKNOWN),
+		}},
+		{getHypnogram, openapi.Operation{
+			Pattern:    "GET /sleep_diary/entries/{id}/hypnogram",
+			Id:         "getHypnogram",
+			Summary:    "Read sleep stages of an entry with subjective and objective sleep measures",
+			Parameters: []openapi.Parameter{entryIdParam},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[api.HypnogramDto]()}}},
+				api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{uploadHypnogram, openapi.Operation{
+			Pattern:    "PUT /sleep_diary/entries/{id}/hypnogram",
+			Id:         "uploadHypnogram",
+			Summary:    "Replace sleep stages of an entry",
+			Parameters: []openapi.Parameter{entryIdParam},
+			Request:    toPtr(openapi.JsonBody[api.UploadHypnogramDto]()),
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[api.HypnogramDto]()}}},
+				api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_FORBIDDEN, api.ERR_UNKNOWN),
+		}},
+		{getSleepCalendar, openapi.Operation{
+			Pattern: "GET /sleep_diary/accounts/{account_uuid}/calendar.ics",
+			Id:      "getSleepCalendar",
+			Summary: "Read entries of an account as an iCalendar feed",
+			Parameters: []openapi.Parameter{
+				accountUuidPathParam,
+				openapi.QueryParam[time.Time]("from_date", "", false),
+				openapi.QueryParam[time.Time]("to_date", "", false),
+			},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{{ContentType: "text/calendar"}}}},
+				api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{getSleepReport, openapi.Operation{
+			Pattern: "GET /sleep_diary/accounts/{account_uuid}/report",
+			Id:      "getSleepReport",
+			Summary: "Render a printable two-week report of an account",
+			Parameters: []openapi.Parameter{
+				accountUuidPathParam,
+				openapi.QueryParam[string]("format", "html (default) or pdf", false),
+				openapi.QueryParam[time.Time]("from_date", "First night of the report; defaults to two weeks ago", false),
+			},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{{ContentType: "text/html"}, {ContentType: "application/pdf"}}}},
+				api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{exportAccount, openapi.Operation{
+			Pattern:     "GET /sleep_diary/accounts/{account_uuid}/export",
+			Id:          "exportAccount",
+			Summary:     "Export everything stored about an account",
+			Description: "Returns a zip archive holding the export as account.json and a CSV file for each of its lists. Only the account owner or an admin may export it.",
+			Parameters:  []openapi.Parameter{accountUuidPathParam},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{{ContentType: "application/zip"}}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_UNKNOWN),
+		}},
+		{deleteAccount, openapi.Operation{
+			Pattern:     "DELETE /sleep_diary/accounts/{account_uuid}",
+			Id:          "deleteAccount",
+			Summary:     "Erase all data of an account",
+			Description: "Deletes entries, sleep stages, changes, drafts, grants, API keys and queued events of the account in one transaction, or with mode=pseudonymize keeps entries and their changes under a random account UUID. Returns a signed receipt of the deletion. Only the account owner or an admin may delete it.",
+			Parameters: []openapi.Parameter{
+				accountUuidPathParam,
+				openapi.QueryParam[api.AccountDeletionMode]("mode", "delete (default) or pseudonymize", false),
+			},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[api.AccountDeletionReceiptDto]()}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_UNKNOWN),
+		}},
+		{verifyDeletionReceipt, openapi.Operation{
+			Pattern:     "POST /sleep_diary/deletion_receipts/verify",
+			Id:          "verifyDeletionReceipt",
+			Summary:     "Check that a deletion receipt was signed by this server",
+			Description: "Fails with ERR_INVALID when the signature does not match the receipt.",
+			Request:     toPtr(openapi.JsonBody[api.AccountDeletionReceiptDto]()),
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusNoContent}},
+				api.ERR_INVALID, api.ERR_UNKNOWN),
+		}},
+		{importSleepDiaryDrafts, openapi.Operation{
+			Pattern:     "POST /sleep_diary/imports/{source}",
+			Id:          "importSleepDiaryDrafts",
+			Summary:     "Import a wearable export file as drafts",
+			Description: "The body is an Apple Health export (XML or zip) or a Fitbit export (JSON or Takeout zip).",
+			Parameters: []openapi.Parameter{
+				openapi.PathParam[api.ImportSource]("source", ""),
+				openapi.QueryParam[string]("account_uuid", "", true),
+				openapi.QueryParam[string]("timezone", "Timezone of the recorded times when the export has none", false),
+			},
+			Request: &openapi.Body{ContentType: "application/octet-stream"},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusCreated, Bodies: []openapi.Body{openapi.JsonBody[api.ImportResultDto]()}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{getSleepDiaryDrafts, openapi.Operation{
+			Pattern:    "GET /sleep_diary/drafts",
+			Id:         "getSleepDiaryDrafts",
+			Summary:    "Read drafts of an account",
+			Parameters: []openapi.Parameter{openapi.QueryParam[string]("account_uuid", "", true)},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[[]api.SleepDiaryDraftDto]()}}},
+				api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{confirmSleepDiaryDraft, openapi.Operation{
+			Pattern:    "POST /sleep_diary/drafts/{id}/confirm",
+			Id:         "confirmSleepDiaryDraft",
+			Summary:    "Turn a draft into an entry",
+			Parameters: []openapi.Parameter{idParam},
+			Request:    toPtr(openapi.JsonBody[api.ConfirmSleepDiaryDraftDto]()),
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusCreated, Bodies: []openapi.Body{openapi.JsonBody[api.SleepDiaryEntryDto]()}}},
+				api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{deleteSleepDiaryDraft, openapi.Operation{
+			Pattern:    "DELETE /sleep_diary/drafts/{id}",
+			Id:         "deleteSleepDiaryDraft",
+			Summary:    "Discard a draft",
+			Parameters: []openapi.Parameter{idParam},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusNoContent}},
+				api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{streamEntryChanges, openapi.Operation{
+			Pattern:     "GET /sleep_diary/changes/stream",
+			Id:          "streamEntryChanges",
+			Summary:     "Stream changes of an account's entries as Server-Sent Events",
+			Description: "Each event is named after the change type (created, updated or deleted), carries the change ID as its id and the change as JSON data. Without Last-Event-ID only changes made after connecting are sent.",
+			Parameters: []openapi.Parameter{
+				openapi.QueryParam[string]("account_uuid", "", true),
+				openapi.QueryParam[int64]("last_event_id", "Alternative to the Last-Event-ID header; changes after this one are sent first", false),
+			},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{{ContentType: "text/event-stream", Type: openapi.TypeOf[api.EntryChangeDto]()}}}},
+				api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{getSyncChanges, openapi.Operation{
+			Pattern:     "GET /sleep_diary/sync",
+			Id:          "getSyncChanges",
+			Summary:     "Read entries of an account changed since a sync token",
+			Description: "Returns the current state of entries created or updated since the token and tombstones of deleted ones, along with the token of the next sync. Without a token all entries are returned.",
+			Parameters: []openapi.Parameter{
+				openapi.QueryParam[string]("account_uuid", "", true),
+				openapi.QueryParam[string]("since", "Opaque next_token of the previous sync", false),
+			},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[api.SyncDto]()}}},
+				api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{pushSyncChanges, openapi.Operation{
+			Pattern:     "POST /sleep_diary/sync",
+			Id:          "pushSyncChanges",
+			Summary:     "Apply entry changes made by a client",
+			Description: "Changes are applied in order. Changes made to an outdated version of an entry are not applied and reported as conflicts along with the current entry.",
+			Request:     toPtr(openapi.JsonBody[api.SyncPushDto]()),
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[api.SyncPushResultDto]()}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{createGrant, openapi.Operation{
+			Pattern:     "POST /sleep_diary/grants",
+			Id:          "createGrant",
+			Summary:     "Share the diary of an account with another principal",
+			Description: "Gives the principal with subject grantee read or read-write access to entries of the account from valid_from (now by default) until valid_to. Only the account owner or an admin may give grants.",
+			Request:     toPtr(openapi.JsonBody[api.CreateGrantDto]()),
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusCreated, Bodies: []openapi.Body{openapi.JsonBody[api.GrantDto]()}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_UNKNOWN),
+		}},
+		{getGrants, openapi.Operation{
+			Pattern: "GET /sleep_diary/grants",
+			Id:      "getGrants",
+			Summary: "List grants given by an account, including expired and revoked ones",
+			Parameters: []openapi.Parameter{
+				openapi.QueryParam[string]("account_uuid", "", true),
+			},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[[]api.GrantDto]()}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_UNKNOWN),
+		}},
+		{getReceivedGrants, openapi.Operation{
+			Pattern: "GET /sleep_diary/grants/received",
+			Id:      "getReceivedGrants",
+			Summary: "List grants in force given to the caller",
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[[]api.GrantDto]()}}},
+				api.ERR_UNKNOWN),
+		}},
+		{revokeGrant, openapi.Operation{
+			Pattern:    "DELETE /sleep_diary/grants/{id}",
+			Id:         "revokeGrant",
+			Summary:    "Revoke a grant",
+			Parameters: []openapi.Parameter{idParam},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusNoContent}},
+				api.ERR_INVALID, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{createWebhook, openapi.Operation{
+			Pattern:     "POST /webhooks",
+			Id:          "createWebhook",
+			Summary:     "Register a webhook for entry changes",
+			Description: "Changes of given types (of given accounts, or of all accounts when none are given) are POSTed to the URL as EntryChangeDto, signed with HMAC-SHA256 of the secret in the X-Webhook-Signature header.",
+			Request:     toPtr(openapi.JsonBody[api.CreateWebhookDto]()),
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusCreated, Bodies: []openapi.Body{openapi.JsonBody[api.WebhookDto]()}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_UNKNOWN),
+		}},
+		{getWebhooks, openapi.Operation{
+			Pattern: "GET /webhooks",
+			Id:      "getWebhooks",
+			Summary: "List webhooks",
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[[]api.WebhookDto]()}}},
+				api.ERR_FORBIDDEN, api.ERR_UNKNOWN),
+		}},
+		{deleteWebhook, openapi.Operation{
+			Pattern:    "DELETE /webhooks/{id}",
+			Id:         "deleteWebhook",
+			Summary:    "Delete a webhook along with its deliveries",
+			Parameters: []openapi.Parameter{idParam},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusNoContent}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{getWebhookDeliveries, openapi.Operation{
+			Pattern: "GET /webhooks/{id}/deliveries",
+			Id:      "getWebhookDeliveries",
+			Summary: "Read the most recent deliveries of a webhook",
+			Parameters: []openapi.Parameter{
+				idParam,
+				openapi.QueryParam[api.WebhookDeliveryStatus]("status", "Only deliveries in this status, e.g. dead", false),
+			},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[[]api.WebhookDeliveryDto]()}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{redeliverWebhookDelivery, openapi.Operation{
+			Pattern:    "POST /webhooks/deliveries/{id}/redeliver",
+			Id:         "redeliverWebhookDelivery",
+			Summary:    "Queue a dead delivery again",
+			Parameters: []openapi.Parameter{idParam},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[api.WebhookDeliveryDto]()}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_NOT_FOUND, api.ERR_CONFLICT, api.ERR_UNKNOWN),
+		}},
+		{createApiKey, openapi.Operation{
+			Pattern:     "POST /api_keys",
+			Id:          "createApiKey",
+			Summary:     "Create an API key for a server-to-server integration",
+			Description: "The key is returned only in this response. Keys with account_uuid only access entries of that account; others access entries of all accounts within their scopes. Requires the admin scope.",
+			Request:     toPtr(openapi.JsonBody[api.CreateApiKeyDto]()),
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusCreated, Bodies: []openapi.Body{openapi.JsonBody[api.ApiKeyDto]()}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_UNKNOWN),
+		}},
+		{getApiKeys, openapi.Operation{
+			Pattern: "GET /api_keys",
+			Id:      "getApiKeys",
+			Summary: "List API keys, including expired and revoked ones",
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[[]api.ApiKeyDto]()}}},
+				api.ERR_FORBIDDEN, api.ERR_UNKNOWN),
+		}},
+		{rotateApiKey, openapi.Operation{
+			Pattern:     "POST /api_keys/{id}/rotate",
+			Id:          "rotateApiKey",
+			Summary:     "Replace the key of an API key",
+			Description: "The new key is returned only in this response; the previous one stops working immediately.",
+			Parameters:  []openapi.Parameter{idParam},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[api.ApiKeyDto]()}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_NOT_FOUND, api.ERR_CONFLICT, api.ERR_UNKNOWN),
+		}},
+		{revokeApiKey, openapi.Operation{
+			Pattern:    "DELETE /api_keys/{id}",
+			Id:         "revokeApiKey",
+			Summary:    "Revoke an API key",
+			Parameters: []openapi.Parameter{idParam},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusNoContent}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_NOT_FOUND, api.ERR_UNKNOWN),
+		}},
+		{getAuditLog, openapi.Operation{
+			Pattern:     "GET /audit_log",
+			Id:          "getAuditLog",
+			Summary:     "Read the audit log of access to account data",
+			Description: "Returns events of reads and writes of account data matching all filters given, the latest first. Requires the admin scope; reading the log is recorded in it as well.",
+			Parameters: []openapi.Parameter{
+				openapi.QueryParam[string]("subject", "Events of operations performed by this principal", false),
+				openapi.QueryParam[string]("account_uuid", "Events of operations on data of this account", false),
+				openapi.QueryParam[int64]("entry_id", "Events of operations on this entry", false),
+				openapi.QueryParam[api.AuditAction]("action", "", false),
+				openapi.QueryParam[string]("trace_id", "Events of the request with this X-Trace-Id", false),
+				openapi.QueryParam[time.Time]("from_date", "Events recorded at or after this time", false),
+				openapi.QueryParam[time.Time]("to_date", "Events recorded before this time", false),
+				openapi.QueryParam[int64]("page_size", "", false),
+				openapi.QueryParam[int64]("page_number", "", false),
+			},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[api.PageDto[api.AuditEventDto]]()}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_UNKNOWN),
+		}},
+		{verifyAuditLog, openapi.Operation{
+			Pattern:     "GET /audit_log/verify",
+			Id:          "verifyAuditLog",
+			Summary:     "Check the hash chain of the audit log",
+			Description: "Computes the hash of every event of the tenant again and reports the first event that was altered or does not follow the previous one. Requires the admin scope.",
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[api.AuditLogVerificationDto]()}}},
+				api.ERR_FORBIDDEN, api.ERR_UNKNOWN),
+		}},
+		{exportResearchDataset, openapi.Operation{
+			Pattern:     "GET /research_export",
+			Id:          "exportResearchDataset",
+			Summary:     "Export a de-identified dataset for a study",
+			Description: "Returns a zip archive holding the dataset as dataset.json and its entries as entries.csv. Accounts are replaced by participant IDs keyed per study and dates of each participant are shifted by whole weeks, keeping weekdays and local times of day. Comments are stripped or redacted. Fails when the dataset has fewer participants than the minimum cohort size. Requires the admin scope.",
+			Parameters: []openapi.Parameter{
+				openapi.QueryParam[string]("study_id", "Study the dataset is exported for; participant IDs and date shifts are stable within a study", true),
+				openapi.QueryParam[[]string]("account_uuid", "Entries of these accounts; all accounts of the tenant when not given", false),
+				openapi.QueryParam[time.Time]("from_date", "Entries with tried_to_sleep_at at or after this time, before shifting", false),
+				openapi.QueryParam[time.Time]("to_date", "Entries with tried_to_sleep_at before this time, before shifting", false),
+				openapi.QueryParam[api.ResearchCommentsMode]("comments_mode", "How comments are left out; defaults to strip", false),
+			},
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{{ContentType: "application/zip"}}}},
+				api.ERR_INVALID, api.ERR_FORBIDDEN, api.ERR_UNKNOWN),
+		}},
+		{executeGraphQL, openapi.Operation{
+			Pattern:     "POST /graphql",
+			Id:          "executeGraphQL",
+			Summary:     "Execute a GraphQL query",
+			Description: "Queries entries, accounts and derived metrics. Errors of executed queries, including queries exceeding depth or complexity limits, are returned in the errors of a 200 response.",
+			Request:     toPtr(openapi.JsonBody[api.GraphQLRequestDto]()),
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[map[string]any]()}}},
+				api.ERR_INVALID, api.ERR_UNKNOWN),
+		}},
+		{getOpenApiSpec, openapi.Operation{
+			Pattern: "GET /openapi.json",
+			Id:      "getOpenApiSpec",
+			Summary: "Read this OpenAPI document",
+			Responses: withErrors(
+				[]openapi.Response{{Status: http.StatusOK, Bodies: []openapi.Body{openapi.JsonBody[map[string]any]()}}},
+				api.ERR_UNKNOWN),
+		}},
+	}
+}
+
+func withErrors(responses []openapi.Response, codes ...api.ErrorCode) []openapi.Response {
+	for _, code := range codes {
+		responses = append(responses, openapi.Response{
+			Status:      toHttpError(code),
+			Description: string(code),
+			Bodies:      []openapi.Body{openapi.JsonBody[api.ErrorDto]()},
+		})
+	}
+	return responses
+}
+
+// withMiddlewareErrors adds to the responses of an operation the errors of
+// middleware wrapping its handler: authentication errors of routes that
+// are not public and ERR_RATE_LIMITED of all routes. Errors the handler
+// responds with already are not repeated.
+func withMiddlewareErrors(operation openapi.Operation) openapi.Operation {
+	codes := []api.ErrorCode{}
+	if !publicRoutes[operation.Pattern] {
+		codes = append(codes, api.ERR_UNAUTHORIZED, api.ERR_FORBIDDEN)
+	}
+	codes = append(codes, api.ERR_RATE_LIMITED)
+	for _, code := range codes {
+		documented := slices.ContainsFunc(operation.Responses, func(response openapi.Response) bool {
+			return response.Description == string(code)
+		})
+		if !documented {
+			operation.Responses = withErrors(operation.Responses, code)
+		}
+	}
+	return operation
+}
+
+func getOpenApiSpec(_ *service.SleepDiaryService) http.HandlerFunc {
+	operations := []openapi.Operation{}
+	for _, route := range routes() {
+		operations = append(operations, withMiddlewareErrors(route.Operation))
+	}
+	spec, err := openapi.Build(openapi.Info{Title: "Snorlax Sleep Diary API", Version: API_VERSION}, operations)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			respondWithError(w, api.ERR_UNKNOWN, "OpenAPI document generation failed", err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, spec)
+	}
+}
@@ -10,15 +10,36 @@ import (
 	"github.com/mabzd/snorlax/api"
 	"github.com/mabzd/snorlax/internal/config"
 	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+	"github.com/mabzd/snorlax/pkg/ratelimit"
 )
 
 func NewServerHandler(cfg config.Config) http.Handler {
 	svc := service.NewSleepDiaryService(cfg)
+	authenticate := newAuthMiddleware(cfg, svc)
+	limiter, err := ratelimit.NewLimiterFromConfig(cfg, svc)
+	if err != nil {
+		log.Fatalf("Invalid rate limit config: %v", err)
+	}
 	mux := http.NewServeMux()
-	add(mux, "GET /sleep_diary/entries/{id}", getSleepDiaryEntry(svc))
-	add(mux, "GET /sleep_diary/entries", getSleepDiaryEntries(svc))
-	add(mux, "POST /sleep_diary/entries", createSleepDiaryEntry(svc))
-	add(mux, "PUT /sleep_diary/entries/{id}", updateSleepDiaryEntry(svc))
+	for _, route := range routes() {
+		handler := withStrictJson(cfg.StrictJson, route.handler(svc))
+		// Limited after authentication, so that callers are told apart by
+		// their principal.
+		if limiter != nil {
+			handler = limitRoute(limiter, route.Pattern, handler)
+		}
+		if authenticate != nil && !publicRoutes[route.Pattern] {
+			handler = authenticate(route.Pattern, handler)
+			// Failed authentications are limited per IP address before
+			// the caller is known, so that credentials cannot be guessed
+			// at will.
+			if limiter != nil {
+				handler = limitAuthFailures(limiter, handler)
+			}
+		}
+		add(mux, route.Pattern, handler)
+	}
 	add(mux, "/", notFound())
 	return mux
 }
@@ -50,8 +71,9 @@ func withTrace(next http.HandlerFunc) http.HandlerFunc {
 			traceId = uuid.NewString()
 			r.Header.Set(TRACE_HEADER, traceId)
 		}
+		w.Header().Set(TRACE_HEADER, traceId)
 		log.SetPrefix(fmt.Sprintf("[%s] ", traceId))
-		next(w, r)
+		next(w, r.WithContext(auth.WithTraceId(r.Context(), traceId)))
 	}
 }
 
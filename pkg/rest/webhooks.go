@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+)
+
+func createWebhook(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid request body", err)
+			return
+		}
+		defer r.Body.Close()
+
+		var dto api.CreateWebhookDto
+		if jsonErr := unmarshalJson(r, body, &dto); jsonErr != nil {
+			respondWithApiError(w, jsonErr)
+			return
+		}
+
+		result, serviceErr := service.CreateWebhook(auth.Caller(r.Context()), dto)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusCreated, result)
+	}
+}
+
+func getWebhooks(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		webhooks, serviceErr := service.GetWebhooks(auth.Caller(r.Context()))
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, webhooks)
+	}
+}
+
+func deleteWebhook(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid ID format", err)
+			return
+		}
+
+		if serviceErr := service.DeleteWebhook(auth.Caller(r.Context()), id); serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func getWebhookDeliveries(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid ID format", err)
+			return
+		}
+
+		status := api.WebhookDeliveryStatus(r.URL.Query().Get("status"))
+		deliveries, serviceErr := service.GetWebhookDeliveries(auth.Caller(r.Context()), id, status)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, deliveries)
+	}
+}
+
+func redeliverWebhookDelivery(service *service.SleepDiaryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			respondWithError(w, api.ERR_INVALID, "invalid ID format", err)
+			return
+		}
+
+		delivery, serviceErr := service.RedeliverWebhookDelivery(auth.Caller(r.Context()), id)
+		if serviceErr != nil {
+			respondWithApiError(w, serviceErr)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, delivery)
+	}
+}
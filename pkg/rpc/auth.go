@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"cmp"
+	"context"
+	"log"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+	"github.com/mabzd/snorlax/pkg/rpc/sleepdiarypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Scopes an API key needs for each method; methods not listed require the
+// admin scope, as routes of the REST API do.
+var apiKeyScopes = map[string]string{
+	sleepdiarypb.SleepDiaryService_GetEntry_FullMethodName:    api.SCOPE_READ_ENTRIES,
+	sleepdiarypb.SleepDiaryService_ListEntries_FullMethodName: api.SCOPE_READ_ENTRIES,
+	sleepdiarypb.SleepDiaryService_CreateEntry_FullMethodName: api.SCOPE_WRITE_ENTRIES,
+	sleepdiarypb.SleepDiaryService_UpdateEntry_FullMethodName: api.SCOPE_WRITE_ENTRIES,
+}
+
+// newAuthInterceptors returns interceptors putting the principal of a call
+// on its context, with credentials read from metadata named as the headers
+// of the REST API. It returns nil interceptors when no authentication is
+// configured and callers are trusted.
+func newAuthInterceptors(cfg config.Config, svc *service.SleepDiaryService) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	authenticator, err := auth.NewAuthenticatorFromConfig(cfg, svc.AuthenticateApiKey)
+	if err != nil {
+		log.Fatalf("Invalid authentication config: %v", err)
+	}
+	if authenticator == nil {
+		return nil, nil
+	}
+
+	unary := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, authenticator, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), authenticator, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &tracedStream{ServerStream: stream, ctx: ctx})
+	}
+	return unary, stream
+}
+
+// authenticate returns ctx carrying the principal of a call of a method.
+func authenticate(ctx context.Context, authenticator *auth.Authenticator, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := func(name string) string {
+		if values := md.Get(name); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	principal, authErr := authenticator.Authenticate(ctx, header, cmp.Or(apiKeyScopes[method], api.SCOPE_ADMIN))
+	if authErr != nil {
+		log.Printf("Authenticating call of %s failed: %s %v\n", method, authErr.Message, authErr.Err)
+		return nil, status.Error(toStatusCode(authErr.Code), authErr.Message)
+	}
+	return auth.NewContext(ctx, principal), nil
+}
@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"log"
+
+	"github.com/mabzd/snorlax/api"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatusError converts a service error into a gRPC status. Validation
+// errors carry a BadRequest detail with a violation per failed rule; the
+// field is a JSON pointer and the reason is the rule code, as in ErrorDto.
+func toStatusError(err api.Error) error {
+	dto := err.ToErrorDto()
+	log.Printf("service error [%s]: %v\n", dto.Code, dto.Message)
+
+	st := status.New(toStatusCode(dto.Code), dto.Message)
+	if len(dto.Errors) == 0 {
+		return st.Err()
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	for _, fieldErr := range dto.Errors {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       fieldErr.Field,
+			Description: fieldErr.Message,
+			Reason:      string(fieldErr.Rule),
+		})
+	}
+	withDetails, detailsErr := st.WithDetails(badRequest)
+	if detailsErr != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+func toStatusCode(code api.ErrorCode) codes.Code {
+	switch code {
+	case api.ERR_INVALID:
+		return codes.InvalidArgument
+	case api.ERR_NOT_FOUND:
+		return codes.NotFound
+	case api.ERR_CONFLICT:
+		return codes.Aborted
+	case api.ERR_UNAUTHORIZED:
+		return codes.Unauthenticated
+	case api.ERR_FORBIDDEN:
+		return codes.PermissionDenied
+	case api.ERR_RATE_LIMITED:
+		return codes.ResourceExhausted
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,121 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+	"github.com/mabzd/snorlax/pkg/rpc/sleepdiarypb"
+	"google.golang.org/grpc"
+)
+
+type sleepDiaryServer struct {
+	sleepdiarypb.UnimplementedSleepDiaryServiceServer
+	service *service.SleepDiaryService
+}
+
+func (s *sleepDiaryServer) GetEntry(ctx context.Context, req *sleepdiarypb.GetEntryRequest) (*sleepdiarypb.SleepDiaryEntry, error) {
+	id, serviceErr := s.resolveEntryId(auth.Caller(ctx), req)
+	if serviceErr != nil {
+		return nil, toStatusError(serviceErr)
+	}
+	dto, serviceErr := s.service.GetEntryById(auth.Caller(ctx), id)
+	if serviceErr != nil {
+		return nil, toStatusError(serviceErr)
+	}
+	return toSleepDiaryEntry(dto), nil
+}
+
+// ListEntries streams entries of all pages matching the filter; pages are
+// read from the service one at a time.
+func (s *sleepDiaryServer) ListEntries(req *sleepdiarypb.ListEntriesRequest, stream grpc.ServerStreamingServer[sleepdiarypb.SleepDiaryEntry]) error {
+	filter := api.SleepDiaryFilterDto{
+		AccountUuid: req.GetAccountUuid(),
+		FromDate:    fromTimestamp(req.GetFromDate()),
+		ToDate:      fromTimestamp(req.GetToDate()),
+		PageSize:    api.MAX_PAGE_SIZE,
+		PageNumber:  1,
+	}
+
+	for {
+		if err := stream.Context().Err(); err != nil {
+			return err
+		}
+
+		page, serviceErr := s.service.GetEntriesByFilter(auth.Caller(stream.Context()), filter)
+		if serviceErr != nil {
+			return toStatusError(serviceErr)
+		}
+		for _, dto := range page.Items {
+			if err := stream.Send(toSleepDiaryEntry(dto)); err != nil {
+				return err
+			}
+		}
+		if len(page.Items) == 0 || filter.PageNumber*filter.PageSize >= page.TotalCount {
+			return nil
+		}
+		filter.PageNumber++
+	}
+}
+
+func (s *sleepDiaryServer) CreateEntry(ctx context.Context, req *sleepdiarypb.CreateEntryRequest) (*sleepdiarypb.SleepDiaryEntry, error) {
+	if req.GetData() == nil {
+		return nil, toStatusError(missingDataError())
+	}
+	dto, serviceErr := s.service.CreateEntry(auth.Caller(ctx), api.CreateSleepDiaryEntryDto{
+		Uuid:                   req.GetUuid(),
+		AccountUuid:            req.GetAccountUuid(),
+		SleepDiaryEntryDataDto: fromSleepDiaryEntryData(req.GetData()),
+	})
+	if serviceErr != nil {
+		return nil, toStatusError(serviceErr)
+	}
+	return toSleepDiaryEntry(dto), nil
+}
+
+func (s *sleepDiaryServer) UpdateEntry(ctx context.Context, req *sleepdiarypb.UpdateEntryRequest) (*sleepdiarypb.SleepDiaryEntry, error) {
+	if req.GetData() == nil {
+		return nil, toStatusError(missingDataError())
+	}
+	id, serviceErr := s.resolveEntryId(auth.Caller(ctx), req)
+	if serviceErr != nil {
+		return nil, toStatusError(serviceErr)
+	}
+	dto, serviceErr := s.service.UpdateEntry(auth.Caller(ctx), id, api.UpdateSleepDiaryEntryDto{
+		Version:                req.Version,
+		SleepDiaryEntryDataDto: fromSleepDiaryEntryData(req.GetData()),
+	})
+	if serviceErr != nil {
+		return nil, toStatusError(serviceErr)
+	}
+	return toSleepDiaryEntry(dto), nil
+}
+
+// entryRef is a request addressing an entry by its numeric ID or by its
+// UUID.
+type entryRef interface {
+	GetId() int64
+	GetUuid() string
+}
+
+// resolveEntryId returns the ID of the entry a request addresses, as
+// ResolveEntryId does for the path of the REST API.
+func (s *sleepDiaryServer) resolveEntryId(caller api.Principal, req entryRef) (int64, api.Error) {
+	if req.GetUuid() == "" {
+		return req.GetId(), nil
+	}
+	if _, err := uuid.Parse(req.GetUuid()); err != nil {
+		return 0, api.NewError("invalid UUID format", api.ERR_INVALID)
+	}
+	return s.service.ResolveEntryId(caller, req.GetUuid())
+}
+
+// missingDataError is returned for requests without entry data, which the
+// REST API cannot receive since data is the request body there.
+func missingDataError() api.Error {
+	return api.NewValidationError("invalid entry data", []error{
+		api.NewFieldError("/data", api.RULE_REQUIRED, nil, "data is required"),
+	})
+}
@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/pkg/rpc/sleepdiarypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toSleepDiaryEntry(dto api.SleepDiaryEntryDto) *sleepdiarypb.SleepDiaryEntry {
+	return &sleepdiarypb.SleepDiaryEntry{
+		Id:          dto.Id,
+		Uuid:        dto.Uuid,
+		AccountUuid: dto.AccountUuid,
+		Version:     dto.Version,
+		Data: &sleepdiarypb.SleepDiaryEntryData{
+			Timezone:                     dto.Timezone,
+			InBedAt:                      toTimestamp(dto.InBedAt),
+			TriedToSleepAt:               timestamppb.New(dto.TriedToSleepAt),
+			SleepDelayInMin:              toInt32(dto.SleepDelayInMin),
+			AwakeningsCount:              toInt32(dto.AwakeningsCount),
+			AwakeningsTotalDurationInMin: toInt32(dto.AwakeningsTotalDurationInMin),
+			FinalWakeUpAt:                timestamppb.New(dto.FinalWakeUpAt),
+			OutOfBedAt:                   toTimestamp(dto.OutOfBedAt),
+			SleepQuality:                 sleepdiarypb.SleepQuality(dto.SleepQuality),
+			Comments:                     dto.Comments,
+		},
+	}
+}
+
+// fromSleepDiaryEntryData converts entry data; missing timestamps become
+// zero times so that they fail validation the same way as in the REST API.
+// Missing data converts to empty data, though handlers reject it earlier.
+func fromSleepDiaryEntryData(data *sleepdiarypb.SleepDiaryEntryData) api.SleepDiaryEntryDataDto {
+	if data == nil {
+		return api.SleepDiaryEntryDataDto{}
+	}
+	// Optional fields are read directly, since getters do not tell unset
+	// fields from empty ones.
+	dto := api.SleepDiaryEntryDataDto{
+		Timezone:                     data.Timezone,
+		InBedAt:                      fromTimestamp(data.GetInBedAt()),
+		SleepDelayInMin:              fromInt32(data.SleepDelayInMin),
+		AwakeningsCount:              fromInt32(data.AwakeningsCount),
+		AwakeningsTotalDurationInMin: fromInt32(data.AwakeningsTotalDurationInMin),
+		OutOfBedAt:                   fromTimestamp(data.GetOutOfBedAt()),
+		SleepQuality:                 api.SleepQuality(data.GetSleepQuality()),
+		Comments:                     data.Comments,
+	}
+	if data.GetTriedToSleepAt() != nil {
+		dto.TriedToSleepAt = data.GetTriedToSleepAt().AsTime()
+	}
+	if data.GetFinalWakeUpAt() != nil {
+		dto.FinalWakeUpAt = data.GetFinalWakeUpAt().AsTime()
+	}
+	return dto
+}
+
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func toInt32(value *int) *int32 {
+	if value == nil {
+		return nil
+	}
+	v := int32(*value)
+	return &v
+}
+
+func fromInt32(value *int32) *int {
+	if value == nil {
+		return nil
+	}
+	v := int(*value)
+	return &v
+}
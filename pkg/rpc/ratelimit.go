@@ -0,0 +1,119 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/mabzd/snorlax/api"
+	"github.com/mabzd/snorlax/pkg/ratelimit"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// rateLimitClassOf returns the class of a method: the one of the API key
+// scope it requires, as for routes of the REST API.
+func rateLimitClassOf(method string) ratelimit.Class {
+	if apiKeyScopes[method] == api.SCOPE_READ_ENTRIES {
+		return ratelimit.ReadsClass
+	}
+	return ratelimit.WritesClass
+}
+
+// newRateLimitInterceptors returns interceptors rejecting calls with
+// RESOURCE_EXHAUSTED once the caller has used up the limit of the method
+// class. Callers are told apart as in the REST API, so they run after
+// authentication.
+func newRateLimitInterceptors(limiter *ratelimit.Limiter) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	unary := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := limitCall(ctx, limiter, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := limitCall(stream.Context(), limiter, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+	return unary, stream
+}
+
+// newAuthFailureLimitInterceptors returns interceptors wrapping
+// authentication, rejecting calls from an IP address with
+// RESOURCE_EXHAUSTED once it has used up the limit of failed
+// authentications. Only calls failing authentication take a token.
+func newAuthFailureLimitInterceptors(limiter *ratelimit.Limiter) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	unary := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		key := ratelimit.IpKey(peerAddr(ctx))
+		if err := checkAuthFailures(ctx, limiter, key); err != nil {
+			return nil, err
+		}
+		resp, err := handler(ctx, req)
+		countAuthFailure(limiter, key, err)
+		return resp, err
+	}
+	stream := func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		key := ratelimit.IpKey(peerAddr(stream.Context()))
+		if err := checkAuthFailures(stream.Context(), limiter, key); err != nil {
+			return err
+		}
+		err := handler(srv, stream)
+		countAuthFailure(limiter, key, err)
+		return err
+	}
+	return unary, stream
+}
+
+func limitCall(ctx context.Context, limiter *ratelimit.Limiter, method string) error {
+	decision, ok := limiter.Take(rateLimitClassOf(method), ratelimit.CallerKey(ctx, peerAddr(ctx)))
+	if !ok {
+		return nil
+	}
+	return applyRateLimitDecision(ctx, decision)
+}
+
+func checkAuthFailures(ctx context.Context, limiter *ratelimit.Limiter, key string) error {
+	decision, ok := limiter.Peek(ratelimit.AuthFailuresClass, key)
+	if !ok || decision.Result.Allowed {
+		return nil
+	}
+	return applyRateLimitDecision(ctx, decision)
+}
+
+// countAuthFailure takes a token of failed authentications when a call
+// failed authentication, the only step failing with UNAUTHENTICATED.
+func countAuthFailure(limiter *ratelimit.Limiter, key string, err error) {
+	if status.Code(err) == codes.Unauthenticated {
+		limiter.Take(ratelimit.AuthFailuresClass, key)
+	}
+}
+
+// applyRateLimitDecision sends the ratelimit-* metadata of a decision, as
+// the headers of the REST API, and returns RESOURCE_EXHAUSTED with
+// retry-after metadata and a RetryInfo detail when the call is denied.
+func applyRateLimitDecision(ctx context.Context, decision ratelimit.Decision) error {
+	grpc.SetHeader(ctx, metadata.New(decision.Headers()))
+	if decision.Result.Allowed {
+		return nil
+	}
+
+	st := status.New(toStatusCode(api.ERR_RATE_LIMITED), decision.Message())
+	withDetails, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(decision.Result.RetryAfter)})
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// peerAddr returns the address of the client of a call.
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
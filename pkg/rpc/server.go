@@ -0,0 +1,141 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"runtime/debug"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mabzd/snorlax/internal/config"
+	"github.com/mabzd/snorlax/internal/service"
+	"github.com/mabzd/snorlax/pkg/auth"
+	"github.com/mabzd/snorlax/pkg/ratelimit"
+	"github.com/mabzd/snorlax/pkg/rpc/sleepdiarypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata key of the trace ID, the gRPC counterpart of the X-Trace-Id header.
+const TRACE_METADATA_KEY = "x-trace-id"
+
+// NewServer creates a gRPC server exposing SleepDiaryService. Calls are
+// authenticated and rate limited as requests of the REST API are.
+func NewServer(cfg config.Config) *grpc.Server {
+	svc := service.NewSleepDiaryService(cfg)
+	limiter, err := ratelimit.NewLimiterFromConfig(cfg, svc)
+	if err != nil {
+		log.Fatalf("Invalid rate limit config: %v", err)
+	}
+
+	unaryInterceptors := []grpc.UnaryServerInterceptor{unaryTraceInterceptor, unaryRecoveryInterceptor}
+	streamInterceptors := []grpc.StreamServerInterceptor{streamTraceInterceptor, streamRecoveryInterceptor}
+	if unaryAuth, streamAuth := newAuthInterceptors(cfg, svc); unaryAuth != nil {
+		// Failed authentications are limited per IP address before the
+		// caller is known.
+		if limiter != nil {
+			unaryLimit, streamLimit := newAuthFailureLimitInterceptors(limiter)
+			unaryInterceptors = append(unaryInterceptors, unaryLimit)
+			streamInterceptors = append(streamInterceptors, streamLimit)
+		}
+		unaryInterceptors = append(unaryInterceptors, unaryAuth)
+		streamInterceptors = append(streamInterceptors, streamAuth)
+	}
+	if limiter != nil {
+		unaryLimit, streamLimit := newRateLimitInterceptors(limiter)
+		unaryInterceptors = append(unaryInterceptors, unaryLimit)
+		streamInterceptors = append(streamInterceptors, streamLimit)
+	}
+
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+		grpc.ConnectionTimeout(time.Duration(cfg.ServerTimeoutInSec)*time.Second),
+	)
+	sleepdiarypb.RegisterSleepDiaryServiceServer(server, &sleepDiaryServer{
+		service: svc,
+	})
+	return server
+}
+
+// ListenAndServe serves the gRPC server on the configured port.
+func ListenAndServe(cfg config.Config, server *grpc.Server) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", cfg.GrpcPort))
+	if err != nil {
+		return err
+	}
+	return server.Serve(listener)
+}
+
+// traceId returns the trace ID sent by the client or a new one, and sends it
+// back in the response header.
+func traceId(ctx context.Context) string {
+	traceId := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(TRACE_METADATA_KEY); len(values) > 0 {
+			traceId = values[0]
+		}
+	}
+	if traceId == "" {
+		traceId = uuid.NewString()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(TRACE_METADATA_KEY, traceId))
+	return traceId
+}
+
+func unaryTraceInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	id := traceId(ctx)
+	log.SetPrefix(fmt.Sprintf("[%s] ", id))
+	start := time.Now()
+	resp, err := handler(auth.WithTraceId(ctx, id), req)
+	log.Printf("Completed '%s' in %v", info.FullMethod, time.Since(start))
+	return resp, err
+}
+
+func streamTraceInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	id := traceId(stream.Context())
+	log.SetPrefix(fmt.Sprintf("[%s] ", id))
+	start := time.Now()
+	err := handler(srv, &tracedStream{ServerStream: stream, ctx: auth.WithTraceId(stream.Context(), id)})
+	log.Printf("Completed '%s' in %v", info.FullMethod, time.Since(start))
+	return err
+}
+
+// unaryRecoveryInterceptor turns a panic of a call into an INTERNAL status,
+// so that one bad request does not bring the server down.
+func unaryRecoveryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			resp, err = nil, recovered(info.FullMethod, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func streamRecoveryInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(info.FullMethod, r)
+		}
+	}()
+	return handler(srv, stream)
+}
+
+func recovered(method string, r any) error {
+	log.Printf("Call of '%s' panicked: %v\n%s", method, r, debug.Stack())
+	return status.Error(codes.Internal, "internal error")
+}
+
+// tracedStream is a server stream whose context carries the trace ID.
+type tracedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,728 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: snorlax/sleepdiary/v1/sleep_diary.proto
+
+package sleepdiarypb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SleepQuality int32
+
+const (
+	SleepQuality_SLEEP_QUALITY_UNSPECIFIED SleepQuality = 0
+	SleepQuality_SLEEP_QUALITY_VERY_POOR   SleepQuality = 1
+	SleepQuality_SLEEP_QUALITY_POOR        SleepQuality = 2
+	SleepQuality_SLEEP_QUALITY_AVERAGE     SleepQuality = 3
+	SleepQuality_SLEEP_QUALITY_GOOD        SleepQuality = 4
+	SleepQuality_SLEEP_QUALITY_EXCELLENT   SleepQuality = 5
+)
+
+// Enum value maps for SleepQuality.
+var (
+	SleepQuality_name = map[int32]string{
+		0: "SLEEP_QUALITY_UNSPECIFIED",
+		1: "SLEEP_QUALITY_VERY_POOR",
+		2: "SLEEP_QUALITY_POOR",
+		3: "SLEEP_QUALITY_AVERAGE",
+		4: "SLEEP_QUALITY_GOOD",
+		5: "SLEEP_QUALITY_EXCELLENT",
+	}
+	SleepQuality_value = map[string]int32{
+		"SLEEP_QUALITY_UNSPECIFIED": 0,
+		"SLEEP_QUALITY_VERY_POOR":   1,
+		"SLEEP_QUALITY_POOR":        2,
+		"SLEEP_QUALITY_AVERAGE":     3,
+		"SLEEP_QUALITY_GOOD":        4,
+		"SLEEP_QUALITY_EXCELLENT":   5,
+	}
+)
+
+func (x SleepQuality) Enum() *SleepQuality {
+	p := new(SleepQuality)
+	*p = x
+	return p
+}
+
+func (x SleepQuality) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SleepQuality) Descriptor() protoreflect.EnumDescriptor {
+	return file_snorlax_sleepdiary_v1_sleep_diary_proto_enumTypes[0].Descriptor()
+}
+
+func (SleepQuality) Type() protoreflect.EnumType {
+	return &file_snorlax_sleepdiary_v1_sleep_diary_proto_enumTypes[0]
+}
+
+func (x SleepQuality) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SleepQuality.Descriptor instead.
+func (SleepQuality) EnumDescriptor() ([]byte, []int) {
+	return file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDescGZIP(), []int{0}
+}
+
+type SleepDiaryEntryData struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// IANA timezone name; UTC when not set.
+	Timezone                     *string                `protobuf:"bytes,1,opt,name=timezone,proto3,oneof" json:"timezone,omitempty"`
+	InBedAt                      *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=in_bed_at,json=inBedAt,proto3" json:"in_bed_at,omitempty"`
+	TriedToSleepAt               *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=tried_to_sleep_at,json=triedToSleepAt,proto3" json:"tried_to_sleep_at,omitempty"`
+	SleepDelayInMin              *int32                 `protobuf:"varint,4,opt,name=sleep_delay_in_min,json=sleepDelayInMin,proto3,oneof" json:"sleep_delay_in_min,omitempty"`
+	AwakeningsCount              *int32                 `protobuf:"varint,5,opt,name=awakenings_count,json=awakeningsCount,proto3,oneof" json:"awakenings_count,omitempty"`
+	AwakeningsTotalDurationInMin *int32                 `protobuf:"varint,6,opt,name=awakenings_total_duration_in_min,json=awakeningsTotalDurationInMin,proto3,oneof" json:"awakenings_total_duration_in_min,omitempty"`
+	FinalWakeUpAt                *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=final_wake_up_at,json=finalWakeUpAt,proto3" json:"final_wake_up_at,omitempty"`
+	OutOfBedAt                   *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=out_of_bed_at,json=outOfBedAt,proto3" json:"out_of_bed_at,omitempty"`
+	SleepQuality                 SleepQuality           `protobuf:"varint,9,opt,name=sleep_quality,json=sleepQuality,proto3,enum=snorlax.sleepdiary.v1.SleepQuality" json:"sleep_quality,omitempty"`
+	Comments                     *string                `protobuf:"bytes,10,opt,name=comments,proto3,oneof" json:"comments,omitempty"`
+	unknownFields                protoimpl.UnknownFields
+	sizeCache                    protoimpl.SizeCache
+}
+
+func (x *SleepDiaryEntryData) Reset() {
+	*x = SleepDiaryEntryData{}
+	mi := &file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SleepDiaryEntryData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SleepDiaryEntryData) ProtoMessage() {}
+
+func (x *SleepDiaryEntryData) ProtoReflect() protoreflect.Message {
+	mi := &file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SleepDiaryEntryData.ProtoReflect.Descriptor instead.
+func (*SleepDiaryEntryData) Descriptor() ([]byte, []int) {
+	return file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SleepDiaryEntryData) GetTimezone() string {
+	if x != nil && x.Timezone != nil {
+		return *x.Timezone
+	}
+	return ""
+}
+
+func (x *SleepDiaryEntryData) GetInBedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.InBedAt
+	}
+	return nil
+}
+
+func (x *SleepDiaryEntryData) GetTriedToSleepAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.TriedToSleepAt
+	}
+	return nil
+}
+
+func (x *SleepDiaryEntryData) GetSleepDelayInMin() int32 {
+	if x != nil && x.SleepDelayInMin != nil {
+		return *x.SleepDelayInMin
+	}
+	return 0
+}
+
+func (x *SleepDiaryEntryData) GetAwakeningsCount() int32 {
+	if x != nil && x.AwakeningsCount != nil {
+		return *x.AwakeningsCount
+	}
+	return 0
+}
+
+func (x *SleepDiaryEntryData) GetAwakeningsTotalDurationInMin() int32 {
+	if x != nil && x.AwakeningsTotalDurationInMin != nil {
+		return *x.AwakeningsTotalDurationInMin
+	}
+	return 0
+}
+
+func (x *SleepDiaryEntryData) GetFinalWakeUpAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FinalWakeUpAt
+	}
+	return nil
+}
+
+func (x *SleepDiaryEntryData) GetOutOfBedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OutOfBedAt
+	}
+	return nil
+}
+
+func (x *SleepDiaryEntryData) GetSleepQuality() SleepQuality {
+	if x != nil {
+		return x.SleepQuality
+	}
+	return SleepQuality_SLEEP_QUALITY_UNSPECIFIED
+}
+
+func (x *SleepDiaryEntryData) GetComments() string {
+	if x != nil && x.Comments != nil {
+		return *x.Comments
+	}
+	return ""
+}
+
+type SleepDiaryEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	AccountUuid   string                 `protobuf:"bytes,2,opt,name=account_uuid,json=accountUuid,proto3" json:"account_uuid,omitempty"`
+	Version       int64                  `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	Data          *SleepDiaryEntryData   `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	Uuid          string                 `protobuf:"bytes,5,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SleepDiaryEntry) Reset() {
+	*x = SleepDiaryEntry{}
+	mi := &file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SleepDiaryEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SleepDiaryEntry) ProtoMessage() {}
+
+func (x *SleepDiaryEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SleepDiaryEntry.ProtoReflect.Descriptor instead.
+func (*SleepDiaryEntry) Descriptor() ([]byte, []int) {
+	return file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SleepDiaryEntry) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SleepDiaryEntry) GetAccountUuid() string {
+	if x != nil {
+		return x.AccountUuid
+	}
+	return ""
+}
+
+func (x *SleepDiaryEntry) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *SleepDiaryEntry) GetData() *SleepDiaryEntryData {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *SleepDiaryEntry) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+type GetEntryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The entry is addressed by its numeric ID or by its UUID.
+	//
+	// Types that are valid to be assigned to Entry:
+	//
+	//	*GetEntryRequest_Id
+	//	*GetEntryRequest_Uuid
+	Entry         isGetEntryRequest_Entry `protobuf_oneof:"entry"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetEntryRequest) Reset() {
+	*x = GetEntryRequest{}
+	mi := &file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetEntryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetEntryRequest) ProtoMessage() {}
+
+func (x *GetEntryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetEntryRequest.ProtoReflect.Descriptor instead.
+func (*GetEntryRequest) Descriptor() ([]byte, []int) {
+	return file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetEntryRequest) GetEntry() isGetEntryRequest_Entry {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+func (x *GetEntryRequest) GetId() int64 {
+	if x != nil {
+		if x, ok := x.Entry.(*GetEntryRequest_Id); ok {
+			return x.Id
+		}
+	}
+	return 0
+}
+
+func (x *GetEntryRequest) GetUuid() string {
+	if x != nil {
+		if x, ok := x.Entry.(*GetEntryRequest_Uuid); ok {
+			return x.Uuid
+		}
+	}
+	return ""
+}
+
+type isGetEntryRequest_Entry interface {
+	isGetEntryRequest_Entry()
+}
+
+type GetEntryRequest_Id struct {
+	Id int64 `protobuf:"varint,1,opt,name=id,proto3,oneof"`
+}
+
+type GetEntryRequest_Uuid struct {
+	Uuid string `protobuf:"bytes,2,opt,name=uuid,proto3,oneof"`
+}
+
+func (*GetEntryRequest_Id) isGetEntryRequest_Entry() {}
+
+func (*GetEntryRequest_Uuid) isGetEntryRequest_Entry() {}
+
+type ListEntriesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AccountUuid   []string               `protobuf:"bytes,1,rep,name=account_uuid,json=accountUuid,proto3" json:"account_uuid,omitempty"`
+	FromDate      *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=from_date,json=fromDate,proto3" json:"from_date,omitempty"`
+	ToDate        *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=to_date,json=toDate,proto3" json:"to_date,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListEntriesRequest) Reset() {
+	*x = ListEntriesRequest{}
+	mi := &file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListEntriesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListEntriesRequest) ProtoMessage() {}
+
+func (x *ListEntriesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListEntriesRequest.ProtoReflect.Descriptor instead.
+func (*ListEntriesRequest) Descriptor() ([]byte, []int) {
+	return file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListEntriesRequest) GetAccountUuid() []string {
+	if x != nil {
+		return x.AccountUuid
+	}
+	return nil
+}
+
+func (x *ListEntriesRequest) GetFromDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FromDate
+	}
+	return nil
+}
+
+func (x *ListEntriesRequest) GetToDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ToDate
+	}
+	return nil
+}
+
+type CreateEntryRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	AccountUuid string                 `protobuf:"bytes,1,opt,name=account_uuid,json=accountUuid,proto3" json:"account_uuid,omitempty"`
+	Data        *SleepDiaryEntryData   `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	// Client-generated UUID of the entry; generated by the server when not
+	// set. Creating an entry with a UUID already taken fails with ABORTED.
+	Uuid          string `protobuf:"bytes,3,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateEntryRequest) Reset() {
+	*x = CreateEntryRequest{}
+	mi := &file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateEntryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateEntryRequest) ProtoMessage() {}
+
+func (x *CreateEntryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateEntryRequest.ProtoReflect.Descriptor instead.
+func (*CreateEntryRequest) Descriptor() ([]byte, []int) {
+	return file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CreateEntryRequest) GetAccountUuid() string {
+	if x != nil {
+		return x.AccountUuid
+	}
+	return ""
+}
+
+func (x *CreateEntryRequest) GetData() *SleepDiaryEntryData {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *CreateEntryRequest) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+type UpdateEntryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The entry is addressed by its numeric ID or by its UUID.
+	//
+	// Types that are valid to be assigned to Entry:
+	//
+	//	*UpdateEntryRequest_Id
+	//	*UpdateEntryRequest_Uuid
+	Entry isUpdateEntryRequest_Entry `protobuf_oneof:"entry"`
+	// When set, the update fails with ABORTED if the entry was modified in the
+	// meantime.
+	Version       *int64               `protobuf:"varint,2,opt,name=version,proto3,oneof" json:"version,omitempty"`
+	Data          *SleepDiaryEntryData `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateEntryRequest) Reset() {
+	*x = UpdateEntryRequest{}
+	mi := &file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateEntryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateEntryRequest) ProtoMessage() {}
+
+func (x *UpdateEntryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateEntryRequest.ProtoReflect.Descriptor instead.
+func (*UpdateEntryRequest) Descriptor() ([]byte, []int) {
+	return file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UpdateEntryRequest) GetEntry() isUpdateEntryRequest_Entry {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+func (x *UpdateEntryRequest) GetId() int64 {
+	if x != nil {
+		if x, ok := x.Entry.(*UpdateEntryRequest_Id); ok {
+			return x.Id
+		}
+	}
+	return 0
+}
+
+func (x *UpdateEntryRequest) GetUuid() string {
+	if x != nil {
+		if x, ok := x.Entry.(*UpdateEntryRequest_Uuid); ok {
+			return x.Uuid
+		}
+	}
+	return ""
+}
+
+func (x *UpdateEntryRequest) GetVersion() int64 {
+	if x != nil && x.Version != nil {
+		return *x.Version
+	}
+	return 0
+}
+
+func (x *UpdateEntryRequest) GetData() *SleepDiaryEntryData {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type isUpdateEntryRequest_Entry interface {
+	isUpdateEntryRequest_Entry()
+}
+
+type UpdateEntryRequest_Id struct {
+	Id int64 `protobuf:"varint,1,opt,name=id,proto3,oneof"`
+}
+
+type UpdateEntryRequest_Uuid struct {
+	Uuid string `protobuf:"bytes,4,opt,name=uuid,proto3,oneof"`
+}
+
+func (*UpdateEntryRequest_Id) isUpdateEntryRequest_Entry() {}
+
+func (*UpdateEntryRequest_Uuid) isUpdateEntryRequest_Entry() {}
+
+var File_snorlax_sleepdiary_v1_sleep_diary_proto protoreflect.FileDescriptor
+
+const file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDesc = "" +
+	"\n" +
+	"'snorlax/sleepdiary/v1/sleep_diary.proto\x12\x15snorlax.sleepdiary.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xbe\x05\n" +
+	"\x13SleepDiaryEntryData\x12\x1f\n" +
+	"\btimezone\x18\x01 \x01(\tH\x00R\btimezone\x88\x01\x01\x126\n" +
+	"\tin_bed_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\ainBedAt\x12E\n" +
+	"\x11tried_to_sleep_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x0etriedToSleepAt\x120\n" +
+	"\x12sleep_delay_in_min\x18\x04 \x01(\x05H\x01R\x0fsleepDelayInMin\x88\x01\x01\x12.\n" +
+	"\x10awakenings_count\x18\x05 \x01(\x05H\x02R\x0fawakeningsCount\x88\x01\x01\x12K\n" +
+	" awakenings_total_duration_in_min\x18\x06 \x01(\x05H\x03R\x1cawakeningsTotalDurationInMin\x88\x01\x01\x12C\n" +
+	"\x10final_wake_up_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\rfinalWakeUpAt\x12=\n" +
+	"\rout_of_bed_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"outOfBedAt\x12H\n" +
+	"\rsleep_quality\x18\t \x01(\x0e2#.snorlax.sleepdiary.v1.SleepQualityR\fsleepQuality\x12\x1f\n" +
+	"\bcomments\x18\n" +
+	" \x01(\tH\x04R\bcomments\x88\x01\x01B\v\n" +
+	"\t_timezoneB\x15\n" +
+	"\x13_sleep_delay_in_minB\x13\n" +
+	"\x11_awakenings_countB#\n" +
+	"!_awakenings_total_duration_in_minB\v\n" +
+	"\t_comments\"\xb2\x01\n" +
+	"\x0fSleepDiaryEntry\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12!\n" +
+	"\faccount_uuid\x18\x02 \x01(\tR\vaccountUuid\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\x03R\aversion\x12>\n" +
+	"\x04data\x18\x04 \x01(\v2*.snorlax.sleepdiary.v1.SleepDiaryEntryDataR\x04data\x12\x12\n" +
+	"\x04uuid\x18\x05 \x01(\tR\x04uuid\"B\n" +
+	"\x0fGetEntryRequest\x12\x10\n" +
+	"\x02id\x18\x01 \x01(\x03H\x00R\x02id\x12\x14\n" +
+	"\x04uuid\x18\x02 \x01(\tH\x00R\x04uuidB\a\n" +
+	"\x05entry\"\xa5\x01\n" +
+	"\x12ListEntriesRequest\x12!\n" +
+	"\faccount_uuid\x18\x01 \x03(\tR\vaccountUuid\x127\n" +
+	"\tfrom_date\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\bfromDate\x123\n" +
+	"\ato_date\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x06toDate\"\x8b\x01\n" +
+	"\x12CreateEntryRequest\x12!\n" +
+	"\faccount_uuid\x18\x01 \x01(\tR\vaccountUuid\x12>\n" +
+	"\x04data\x18\x02 \x01(\v2*.snorlax.sleepdiary.v1.SleepDiaryEntryDataR\x04data\x12\x12\n" +
+	"\x04uuid\x18\x03 \x01(\tR\x04uuid\"\xb0\x01\n" +
+	"\x12UpdateEntryRequest\x12\x10\n" +
+	"\x02id\x18\x01 \x01(\x03H\x00R\x02id\x12\x14\n" +
+	"\x04uuid\x18\x04 \x01(\tH\x00R\x04uuid\x12\x1d\n" +
+	"\aversion\x18\x02 \x01(\x03H\x01R\aversion\x88\x01\x01\x12>\n" +
+	"\x04data\x18\x03 \x01(\v2*.snorlax.sleepdiary.v1.SleepDiaryEntryDataR\x04dataB\a\n" +
+	"\x05entryB\n" +
+	"\n" +
+	"\b_version*\xb2\x01\n" +
+	"\fSleepQuality\x12\x1d\n" +
+	"\x19SLEEP_QUALITY_UNSPECIFIED\x10\x00\x12\x1b\n" +
+	"\x17SLEEP_QUALITY_VERY_POOR\x10\x01\x12\x16\n" +
+	"\x12SLEEP_QUALITY_POOR\x10\x02\x12\x19\n" +
+	"\x15SLEEP_QUALITY_AVERAGE\x10\x03\x12\x16\n" +
+	"\x12SLEEP_QUALITY_GOOD\x10\x04\x12\x1b\n" +
+	"\x17SLEEP_QUALITY_EXCELLENT\x10\x052\x97\x03\n" +
+	"\x11SleepDiaryService\x12Z\n" +
+	"\bGetEntry\x12&.snorlax.sleepdiary.v1.GetEntryRequest\x1a&.snorlax.sleepdiary.v1.SleepDiaryEntry\x12b\n" +
+	"\vListEntries\x12).snorlax.sleepdiary.v1.ListEntriesRequest\x1a&.snorlax.sleepdiary.v1.SleepDiaryEntry0\x01\x12`\n" +
+	"\vCreateEntry\x12).snorlax.sleepdiary.v1.CreateEntryRequest\x1a&.snorlax.sleepdiary.v1.SleepDiaryEntry\x12`\n" +
+	"\vUpdateEntry\x12).snorlax.sleepdiary.v1.UpdateEntryRequest\x1a&.snorlax.sleepdiary.v1.SleepDiaryEntryB/Z-github.com/mabzd/snorlax/pkg/rpc/sleepdiarypbb\x06proto3"
+
+var (
+	file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDescOnce sync.Once
+	file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDescData []byte
+)
+
+func file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDescGZIP() []byte {
+	file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDescOnce.Do(func() {
+		file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDesc), len(file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDesc)))
+	})
+	return file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDescData
+}
+
+var file_snorlax_sleepdiary_v1_sleep_diary_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_snorlax_sleepdiary_v1_sleep_diary_proto_goTypes = []any{
+	(SleepQuality)(0),             // 0: snorlax.sleepdiary.v1.SleepQuality
+	(*SleepDiaryEntryData)(nil),   // 1: snorlax.sleepdiary.v1.SleepDiaryEntryData
+	(*SleepDiaryEntry)(nil),       // 2: snorlax.sleepdiary.v1.SleepDiaryEntry
+	(*GetEntryRequest)(nil),       // 3: snorlax.sleepdiary.v1.GetEntryRequest
+	(*ListEntriesRequest)(nil),    // 4: snorlax.sleepdiary.v1.ListEntriesRequest
+	(*CreateEntryRequest)(nil),    // 5: snorlax.sleepdiary.v1.CreateEntryRequest
+	(*UpdateEntryRequest)(nil),    // 6: snorlax.sleepdiary.v1.UpdateEntryRequest
+	(*timestamppb.Timestamp)(nil), // 7: google.protobuf.Timestamp
+}
+var file_snorlax_sleepdiary_v1_sleep_diary_proto_depIdxs = []int32{
+	7,  // 0: snorlax.sleepdiary.v1.SleepDiaryEntryData.in_bed_at:type_name -> google.protobuf.Timestamp
+	7,  // 1: snorlax.sleepdiary.v1.SleepDiaryEntryData.tried_to_sleep_at:type_name -> google.protobuf.Timestamp
+	7,  // 2: snorlax.sleepdiary.v1.SleepDiaryEntryData.final_wake_up_at:type_name -> google.protobuf.Timestamp
+	7,  // 3: snorlax.sleepdiary.v1.SleepDiaryEntryData.out_of_bed_at:type_name -> google.protobuf.Timestamp
+	0,  // 4: snorlax.sleepdiary.v1.SleepDiaryEntryData.sleep_quality:type_name -> snorlax.sleepdiary.v1.SleepQuality
+	1,  // 5: snorlax.sleepdiary.v1.SleepDiaryEntry.data:type_name -> snorlax.sleepdiary.v1.SleepDiaryEntryData
+	7,  // 6: snorlax.sleepdiary.v1.ListEntriesRequest.from_date:type_name -> google.protobuf.Timestamp
+	7,  // 7: snorlax.sleepdiary.v1.ListEntriesRequest.to_date:type_name -> google.protobuf.Timestamp
+	1,  // 8: snorlax.sleepdiary.v1.CreateEntryRequest.data:type_name -> snorlax.sleepdiary.v1.SleepDiaryEntryData
+	1,  // 9: snorlax.sleepdiary.v1.UpdateEntryRequest.data:type_name -> snorlax.sleepdiary.v1.SleepDiaryEntryData
+	3,  // 10: snorlax.sleepdiary.v1.SleepDiaryService.GetEntry:input_type -> snorlax.sleepdiary.v1.GetEntryRequest
+	4,  // 11: snorlax.sleepdiary.v1.SleepDiaryService.ListEntries:input_type -> snorlax.sleepdiary.v1.ListEntriesRequest
+	5,  // 12: snorlax.sleepdiary.v1.SleepDiaryService.CreateEntry:input_type -> snorlax.sleepdiary.v1.CreateEntryRequest
+	6,  // 13: snorlax.sleepdiary.v1.SleepDiaryService.UpdateEntry:input_type -> snorlax.sleepdiary.v1.UpdateEntryRequest
+	2,  // 14: snorlax.sleepdiary.v1.SleepDiaryService.GetEntry:output_type -> snorlax.sleepdiary.v1.SleepDiaryEntry
+	2,  // 15: snorlax.sleepdiary.v1.SleepDiaryService.ListEntries:output_type -> snorlax.sleepdiary.v1.SleepDiaryEntry
+	2,  // 16: snorlax.sleepdiary.v1.SleepDiaryService.CreateEntry:output_type -> snorlax.sleepdiary.v1.SleepDiaryEntry
+	2,  // 17: snorlax.sleepdiary.v1.SleepDiaryService.UpdateEntry:output_type -> snorlax.sleepdiary.v1.SleepDiaryEntry
+	14, // [14:18] is the sub-list for method output_type
+	10, // [10:14] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_snorlax_sleepdiary_v1_sleep_diary_proto_init() }
+func file_snorlax_sleepdiary_v1_sleep_diary_proto_init() {
+	if File_snorlax_sleepdiary_v1_sleep_diary_proto != nil {
+		return
+	}
+	file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[0].OneofWrappers = []any{}
+	file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[2].OneofWrappers = []any{
+		(*GetEntryRequest_Id)(nil),
+		(*GetEntryRequest_Uuid)(nil),
+	}
+	file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes[5].OneofWrappers = []any{
+		(*UpdateEntryRequest_Id)(nil),
+		(*UpdateEntryRequest_Uuid)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDesc), len(file_snorlax_sleepdiary_v1_sleep_diary_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_snorlax_sleepdiary_v1_sleep_diary_proto_goTypes,
+		DependencyIndexes: file_snorlax_sleepdiary_v1_sleep_diary_proto_depIdxs,
+		EnumInfos:         file_snorlax_sleepdiary_v1_sleep_diary_proto_enumTypes,
+		MessageInfos:      file_snorlax_sleepdiary_v1_sleep_diary_proto_msgTypes,
+	}.Build()
+	File_snorlax_sleepdiary_v1_sleep_diary_proto = out.File
+	file_snorlax_sleepdiary_v1_sleep_diary_proto_goTypes = nil
+	file_snorlax_sleepdiary_v1_sleep_diary_proto_depIdxs = nil
+}
@@ -0,0 +1,249 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: snorlax/sleepdiary/v1/sleep_diary.proto
+
+package sleepdiarypb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SleepDiaryService_GetEntry_FullMethodName    = "/snorlax.sleepdiary.v1.SleepDiaryService/GetEntry"
+	SleepDiaryService_ListEntries_FullMethodName = "/snorlax.sleepdiary.v1.SleepDiaryService/ListEntries"
+	SleepDiaryService_CreateEntry_FullMethodName = "/snorlax.sleepdiary.v1.SleepDiaryService/CreateEntry"
+	SleepDiaryService_UpdateEntry_FullMethodName = "/snorlax.sleepdiary.v1.SleepDiaryService/UpdateEntry"
+)
+
+// SleepDiaryServiceClient is the client API for SleepDiaryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SleepDiaryService exposes sleep diary entries to internal backend services.
+// It is served by the same service layer as the REST API and follows the same
+// validation rules.
+type SleepDiaryServiceClient interface {
+	GetEntry(ctx context.Context, in *GetEntryRequest, opts ...grpc.CallOption) (*SleepDiaryEntry, error)
+	// Streams all entries matching the filter ordered by tried_to_sleep_at.
+	ListEntries(ctx context.Context, in *ListEntriesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SleepDiaryEntry], error)
+	CreateEntry(ctx context.Context, in *CreateEntryRequest, opts ...grpc.CallOption) (*SleepDiaryEntry, error)
+	UpdateEntry(ctx context.Context, in *UpdateEntryRequest, opts ...grpc.CallOption) (*SleepDiaryEntry, error)
+}
+
+type sleepDiaryServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSleepDiaryServiceClient(cc grpc.ClientConnInterface) SleepDiaryServiceClient {
+	return &sleepDiaryServiceClient{cc}
+}
+
+func (c *sleepDiaryServiceClient) GetEntry(ctx context.Context, in *GetEntryRequest, opts ...grpc.CallOption) (*SleepDiaryEntry, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SleepDiaryEntry)
+	err := c.cc.Invoke(ctx, SleepDiaryService_GetEntry_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sleepDiaryServiceClient) ListEntries(ctx context.Context, in *ListEntriesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SleepDiaryEntry], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SleepDiaryService_ServiceDesc.Streams[0], SleepDiaryService_ListEntries_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListEntriesRequest, SleepDiaryEntry]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SleepDiaryService_ListEntriesClient = grpc.ServerStreamingClient[SleepDiaryEntry]
+
+func (c *sleepDiaryServiceClient) CreateEntry(ctx context.Context, in *CreateEntryRequest, opts ...grpc.CallOption) (*SleepDiaryEntry, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SleepDiaryEntry)
+	err := c.cc.Invoke(ctx, SleepDiaryService_CreateEntry_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sleepDiaryServiceClient) UpdateEntry(ctx context.Context, in *UpdateEntryRequest, opts ...grpc.CallOption) (*SleepDiaryEntry, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SleepDiaryEntry)
+	err := c.cc.Invoke(ctx, SleepDiaryService_UpdateEntry_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SleepDiaryServiceServer is the server API for SleepDiaryService service.
+// All implementations must embed UnimplementedSleepDiaryServiceServer
+// for forward compatibility.
+//
+// SleepDiaryService exposes sleep diary entries to internal backend services.
+// It is served by the same service layer as the REST API and follows the same
+// validation rules.
+type SleepDiaryServiceServer interface {
+	GetEntry(context.Context, *GetEntryRequest) (*SleepDiaryEntry, error)
+	// Streams all entries matching the filter ordered by tried_to_sleep_at.
+	ListEntries(*ListEntriesRequest, grpc.ServerStreamingServer[SleepDiaryEntry]) error
+	CreateEntry(context.Context, *CreateEntryRequest) (*SleepDiaryEntry, error)
+	UpdateEntry(context.Context, *UpdateEntryRequest) (*SleepDiaryEntry, error)
+	mustEmbedUnimplementedSleepDiaryServiceServer()
+}
+
+// UnimplementedSleepDiaryServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSleepDiaryServiceServer struct{}
+
+func (UnimplementedSleepDiaryServiceServer) GetEntry(context.Context, *GetEntryRequest) (*SleepDiaryEntry, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetEntry not implemented")
+}
+func (UnimplementedSleepDiaryServiceServer) ListEntries(*ListEntriesRequest, grpc.ServerStreamingServer[SleepDiaryEntry]) error {
+	return status.Error(codes.Unimplemented, "method ListEntries not implemented")
+}
+func (UnimplementedSleepDiaryServiceServer) CreateEntry(context.Context, *CreateEntryRequest) (*SleepDiaryEntry, error) {
+	return nil, status.Error(codes.Unimplemented, "method CreateEntry not implemented")
+}
+func (UnimplementedSleepDiaryServiceServer) UpdateEntry(context.Context, *UpdateEntryRequest) (*SleepDiaryEntry, error) {
+	return nil, status.Error(codes.Unimplemented, "method UpdateEntry not implemented")
+}
+func (UnimplementedSleepDiaryServiceServer) mustEmbedUnimplementedSleepDiaryServiceServer() {}
+func (UnimplementedSleepDiaryServiceServer) testEmbeddedByValue()                           {}
+
+// UnsafeSleepDiaryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SleepDiaryServiceServer will
+// result in compilation errors.
+type UnsafeSleepDiaryServiceServer interface {
+	mustEmbedUnimplementedSleepDiaryServiceServer()
+}
+
+func RegisterSleepDiaryServiceServer(s grpc.ServiceRegistrar, srv SleepDiaryServiceServer) {
+	// If the following call panics, it indicates UnimplementedSleepDiaryServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SleepDiaryService_ServiceDesc, srv)
+}
+
+func _SleepDiaryService_GetEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEntryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SleepDiaryServiceServer).GetEntry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SleepDiaryService_GetEntry_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SleepDiaryServiceServer).GetEntry(ctx, req.(*GetEntryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SleepDiaryService_ListEntries_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListEntriesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SleepDiaryServiceServer).ListEntries(m, &grpc.GenericServerStream[ListEntriesRequest, SleepDiaryEntry]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SleepDiaryService_ListEntriesServer = grpc.ServerStreamingServer[SleepDiaryEntry]
+
+func _SleepDiaryService_CreateEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateEntryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SleepDiaryServiceServer).CreateEntry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SleepDiaryService_CreateEntry_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SleepDiaryServiceServer).CreateEntry(ctx, req.(*CreateEntryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SleepDiaryService_UpdateEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateEntryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SleepDiaryServiceServer).UpdateEntry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SleepDiaryService_UpdateEntry_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SleepDiaryServiceServer).UpdateEntry(ctx, req.(*UpdateEntryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SleepDiaryService_ServiceDesc is the grpc.ServiceDesc for SleepDiaryService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SleepDiaryService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "snorlax.sleepdiary.v1.SleepDiaryService",
+	HandlerType: (*SleepDiaryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetEntry",
+			Handler:    _SleepDiaryService_GetEntry_Handler,
+		},
+		{
+			MethodName: "CreateEntry",
+			Handler:    _SleepDiaryService_CreateEntry_Handler,
+		},
+		{
+			MethodName: "UpdateEntry",
+			Handler:    _SleepDiaryService_UpdateEntry_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListEntries",
+			Handler:       _SleepDiaryService_ListEntries_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "snorlax/sleepdiary/v1/sleep_diary.proto",
+}
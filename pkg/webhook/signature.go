@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SIGNATURE_HEADER = "X-Webhook-Signature"
+const TIMESTAMP_HEADER = "X-Webhook-Timestamp"
+const EVENT_HEADER = "X-Webhook-Event"
+const DELIVERY_HEADER = "X-Webhook-Delivery"
+
+// Sign returns the signature of a payload sent at timestamp (Unix seconds):
+// "sha256=" followed by hex HMAC-SHA256 of "<timestamp>.<payload>" keyed by
+// the webhook secret. Signing the timestamp lets receivers reject replays.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a received payload;
+// payloads signed more than tolerance ago are rejected.
+func Verify(secret string, signature string, timestamp string, payload []byte, tolerance time.Duration) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp '%s'", timestamp)
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("timestamp outside of tolerance")
+	}
+	expected := Sign(secret, seconds, payload)
+	if !strings.HasPrefix(signature, "sha256=") || !hmac.Equal([]byte(signature), []byte(expected)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Package webhook delivers entry changes queued for registered webhooks. The
+// worker may run in several processes at once; deliveries are claimed with a
+// lease and delivered at least once.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mabzd/snorlax/internal/service"
+)
+
+const DEFAULT_MAX_ATTEMPTS = 8
+const DEFAULT_BACKOFF = 30 * time.Second
+const DEFAULT_POLL_INTERVAL = 5 * time.Second
+const DEFAULT_TIMEOUT = 10 * time.Second
+
+// Number of deliveries claimed at once.
+const BATCH_SIZE = 20
+
+// Maximum size of a receiver's response body kept as error message.
+const maxErrorBodySize = 256
+
+type Worker struct {
+	service      *service.SleepDiaryService
+	httpClient   *http.Client
+	maxAttempts  int
+	backoff      time.Duration
+	pollInterval time.Duration
+}
+
+type Option func(*Worker)
+
+func WithHttpClient(httpClient *http.Client) Option {
+	return func(w *Worker) {
+		w.httpClient = httpClient
+	}
+}
+
+// WithRetries sets the number of attempts after which a delivery is
+// dead-lettered and the delay before the first retry, doubled with every
+// further retry.
+func WithRetries(maxAttempts int, backoff time.Duration) Option {
+	return func(w *Worker) {
+		w.maxAttempts = maxAttempts
+		w.backoff = backoff
+	}
+}
+
+func WithPollInterval(pollInterval time.Duration) Option {
+	return func(w *Worker) {
+		w.pollInterval = pollInterval
+	}
+}
+
+func NewWorker(service *service.SleepDiaryService, options ...Option) *Worker {
+	w := &Worker{
+		service:      service,
+		httpClient:   &http.Client{Timeout: DEFAULT_TIMEOUT},
+		maxAttempts:  DEFAULT_MAX_ATTEMPTS,
+		backoff:      DEFAULT_BACKOFF,
+		pollInterval: DEFAULT_POLL_INTERVAL,
+	}
+	for _, option := range options {
+		option(w)
+	}
+	return w
+}
+
+// Run delivers due deliveries until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		count := w.DeliverDue(ctx)
+		if count == BATCH_SIZE {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.pollInterval):
+		}
+	}
+}
+
+// DeliverDue makes a single attempt of up to BATCH_SIZE due deliveries and
+// returns their number.
+func (w *Worker) DeliverDue(ctx context.Context) int {
+	// The lease outlasts the attempts of the whole batch.
+	lease := 2 * BATCH_SIZE * w.httpClient.Timeout
+	if lease == 0 {
+		lease = 2 * BATCH_SIZE * DEFAULT_TIMEOUT
+	}
+	deliveries, serviceErr := w.service.ClaimWebhookDeliveries(BATCH_SIZE, lease)
+	if serviceErr != nil {
+		return 0
+	}
+
+	for _, delivery := range deliveries {
+		statusCode, err := w.deliver(ctx, delivery)
+		if ctx.Err() != nil {
+			// Interrupted attempts are not counted; the delivery is picked up
+			// again once its lease passes.
+			break
+		}
+		var nextAttemptAt *time.Time
+		if err != nil {
+			log.Printf("Delivery %d to %s failed (attempt %d): %v\n", delivery.Id, delivery.Url, delivery.Attempts+1, err)
+			if delivery.Attempts+1 < w.maxAttempts {
+				next := time.Now().UTC().Add(w.retryDelay(delivery.Attempts))
+				nextAttemptAt = &next
+			}
+		}
+		w.service.RecordWebhookDeliveryAttempt(delivery.Id, statusCode, err, nextAttemptAt)
+	}
+	return len(deliveries)
+}
+
+func (w *Worker) deliver(ctx context.Context, delivery service.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "snorlax-webhook")
+	req.Header.Set(EVENT_HEADER, string(delivery.EventType))
+	req.Header.Set(DELIVERY_HEADER, strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set(TIMESTAMP_HEADER, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SIGNATURE_HEADER, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return resp.StatusCode, fmt.Errorf("receiver responded with %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return resp.StatusCode, nil
+}
+
+// retryDelay doubles the backoff with every attempt made so far and adds up
+// to 10% of jitter, so that retries of a failing receiver spread out.
+func (w *Worker) retryDelay(attempts int) time.Duration {
+	delay := w.backoff << min(attempts, 20)
+	return delay + time.Duration(rand.Int64N(int64(delay)/10+1))
+}